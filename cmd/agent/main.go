@@ -28,7 +28,9 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	cfg.BuildVersion = BuildVersion
 	clientApp := app.NewApp(cfg)
+	http.Handle("/metrics", clientApp.Telemetry())
 
 	clientApp.Run(ctx)
 	err = http.ListenAndServe(":8081", nil)
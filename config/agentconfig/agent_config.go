@@ -3,21 +3,46 @@ package agentconfig
 import (
 	"encoding/json"
 	"flag"
+	"fmt"
 	"github.com/MxTrap/metrics/config"
 	"github.com/caarlos0/env/v11"
+	"maps"
 	"os"
 	"reflect"
+	"slices"
+	"strings"
 	"time"
 )
 
 type AgentConfig struct {
-	HTTPServerAddr config.AddrConfig `env:"ADDRESS"`
-	GRPCServerAddr config.AddrConfig `env:"GRPC_ADDRESS"`
-	ReportInterval int               `env:"REPORT_INTERVAL"`
-	PollInterval   int               `env:"POLL_INTERVAL"`
-	Key            string            `env:"KEY"`
-	RateLimit      int               `env:"RATE_LIMIT"`
-	CryptoKey      string            `env:"CRYPTO_KEY"`
+	HTTPServerAddr       config.AddrConfig `env:"ADDRESS"`
+	GRPCServerAddr       config.AddrConfig `env:"GRPC_ADDRESS"`
+	ReportInterval       int               `env:"REPORT_INTERVAL"`
+	PollInterval         int               `env:"POLL_INTERVAL"`
+	Key                  string            `env:"KEY"`
+	RateLimit            int               `env:"RATE_LIMIT"`
+	CryptoKey            string            `env:"CRYPTO_KEY"`
+	Token                string            `env:"TOKEN"`
+	Tenant               string            `env:"TENANT"`
+	Collectors           string            `env:"COLLECTORS"`
+	CollectorIntervals   string            `env:"COLLECTOR_INTERVALS"`
+	CollectorTimeouts    string            `env:"COLLECTOR_TIMEOUTS"`
+	Processes            string            `env:"PROCESSES"`
+	CGroupPath           string            `env:"CGROUP_PATH"`
+	StatsDAddress        string            `env:"STATSD_ADDRESS"`
+	PushAddress          string            `env:"PUSH_ADDRESS"`
+	SpoolDir             string            `env:"SPOOL_DIR"`
+	SpoolMaxBatches      int               `env:"SPOOL_MAX_BATCHES"`
+	GaugeAggregation     bool              `env:"GAUGE_AGGREGATION"`
+	Transport            string            `env:"TRANSPORT"`
+	Endpoints            string            `env:"ENDPOINTS"`
+	EndpointMode         string            `env:"ENDPOINT_MODE"`
+	GRPCStreaming        bool              `env:"GRPC_STREAM"`
+	AgentID              string            `env:"AGENT_ID"`
+	AgentGroup           string            `env:"AGENT_GROUP"`
+	RemoteConfigInterval int               `env:"REMOTE_CONFIG_INTERVAL"`
+	// BuildVersion — версия сборки агента из ldflags, не задаётся конфигурацией.
+	BuildVersion string
 }
 
 func NewAgentConfig() (*AgentConfig, error) {
@@ -42,6 +67,25 @@ func (cfg *AgentConfig) parseFromFlags() {
 	key := flag.String("k", "", "secret key")
 	rateLimit := flag.Int("l", 1, "rate limit")
 	cryptoKey := flag.String("crypto-key", "", "crypto key")
+	token := flag.String("token", cfg.Token, "api token")
+	tenant := flag.String("tenant", cfg.Tenant, "tenant of reported metrics")
+	collectors := flag.String("collectors", cfg.Collectors, "enabled collectors, comma-separated; on linux system is an alias of host")
+	collectorIntervals := flag.String("collector-intervals", cfg.CollectorIntervals, "per-collector poll intervals, e.g. runtime=2s,system=10s")
+	collectorTimeouts := flag.String("collector-timeouts", cfg.CollectorTimeouts, "per-collector timeouts, e.g. system=1s")
+	cgroupPath := flag.String("cgroup-path", cfg.CGroupPath, "cgroup v2 directory of the container, default /sys/fs/cgroup when the agent runs in a non-root cgroup")
+	statsdAddress := flag.String("statsd-address", cfg.StatsDAddress, "local UDP address for StatsD metrics, e.g. 127.0.0.1:8125")
+	pushAddress := flag.String("push-address", cfg.PushAddress, "local HTTP address for POST /push, e.g. 127.0.0.1:8126")
+	spoolDir := flag.String("spool-dir", cfg.SpoolDir, "directory for batches not yet delivered to the server, empty disables spooling")
+	spoolMaxBatches := flag.Int("spool-max-batches", cfg.SpoolMaxBatches, "max spooled batches per transport, oldest are dropped first, 0 is unlimited")
+	transport := flag.String("transport", cfg.Transport, "transport of reported metrics: http, grpc or grpc-http (grpc with http fallback), default http")
+	endpoints := flag.String("endpoints", cfg.Endpoints, "servers to report to instead of -a/-g, comma-separated, e.g. http://primary:8080,grpc://secondary:3200")
+	endpointMode := flag.String("endpoint-mode", cfg.EndpointMode, "how metrics are spread over endpoints: replicate (default) or shard")
+	grpcStreaming := flag.Bool("grpc-stream", cfg.GRPCStreaming, "send grpc reports over a long-lived StreamMetrics stream instead of unary calls")
+	agentID := flag.String("agent-id", cfg.AgentID, "agent id reported to the server, default hostname")
+	agentGroup := flag.String("agent-group", cfg.AgentGroup, "agent group used to select remote config")
+	remoteConfig := flag.Int("remote-config-interval", cfg.RemoteConfigInterval, "interval of fetching remote config from the server in seconds, 0 disables remote config")
+	gaugeAggregation := flag.Bool("gauge-aggregation", cfg.GaugeAggregation, "report min/max/avg of polled gauges between reports")
+	processes := flag.String("processes", cfg.Processes, "watched processes, e.g. nginx=pidfile:/run/nginx.pid;api=pattern:^api;db=cgroup:/system.slice/db.service")
 
 	httpAddr := config.NewDefaultHTTPAddr()
 	flag.Var(&httpAddr, "a", "server host:port")
@@ -58,7 +102,25 @@ func (cfg *AgentConfig) parseFromFlags() {
 	cfg.Key = *key
 	cfg.RateLimit = *rateLimit
 	cfg.CryptoKey = *cryptoKey
-
+	cfg.Token = *token
+	cfg.Tenant = *tenant
+	cfg.Collectors = *collectors
+	cfg.CollectorIntervals = *collectorIntervals
+	cfg.CollectorTimeouts = *collectorTimeouts
+	cfg.Processes = *processes
+	cfg.CGroupPath = *cgroupPath
+	cfg.StatsDAddress = *statsdAddress
+	cfg.PushAddress = *pushAddress
+	cfg.SpoolDir = *spoolDir
+	cfg.SpoolMaxBatches = *spoolMaxBatches
+	cfg.GaugeAggregation = *gaugeAggregation
+	cfg.Transport = *transport
+	cfg.Endpoints = *endpoints
+	cfg.EndpointMode = *endpointMode
+	cfg.GRPCStreaming = *grpcStreaming
+	cfg.AgentID = *agentID
+	cfg.AgentGroup = *agentGroup
+	cfg.RemoteConfigInterval = *remoteConfig
 }
 
 func (cfg *AgentConfig) parseFromEnv() error {
@@ -104,6 +166,34 @@ func (cfg *AgentConfig) parseFromFile() error {
 		ReportInterval string `json:"report_interval"`
 		PollInterval   string `json:"poll_interval"`
 		CryptoKey      string `json:"crypto_key"`
+		Token          string `json:"token"`
+		Tenant         string `json:"tenant"`
+		Collectors     struct {
+			Enabled   []string          `json:"enabled"`
+			Intervals map[string]string `json:"intervals"`
+			Timeouts  map[string]string `json:"timeouts"`
+		} `json:"collectors"`
+		Processes []struct {
+			Name    string `json:"name"`
+			PIDFile string `json:"pid_file"`
+			Pattern string `json:"pattern"`
+			CGroup  string `json:"cgroup"`
+		} `json:"processes"`
+		CGroupPath    string `json:"cgroup_path"`
+		StatsDAddress string `json:"statsd_address"`
+		PushAddress   string `json:"push_address"`
+		Spool         struct {
+			Dir        string `json:"dir"`
+			MaxBatches int    `json:"max_batches"`
+		} `json:"spool"`
+		GaugeAggregation     bool     `json:"gauge_aggregation"`
+		Transport            string   `json:"transport"`
+		Endpoints            []string `json:"endpoints"`
+		EndpointMode         string   `json:"endpoint_mode"`
+		GRPCStreaming        bool     `json:"grpc_stream"`
+		AgentID              string   `json:"agent_id"`
+		AgentGroup           string   `json:"agent_group"`
+		RemoteConfigInterval string   `json:"remote_config_interval"`
 	}
 	tmp := &tmpConfig{}
 
@@ -148,5 +238,53 @@ func (cfg *AgentConfig) parseFromFile() error {
 	}
 
 	cfg.CryptoKey = tmp.CryptoKey
+	cfg.Token = tmp.Token
+	cfg.Tenant = tmp.Tenant
+	cfg.Collectors = strings.Join(tmp.Collectors.Enabled, ",")
+	cfg.CollectorIntervals = joinPairs(tmp.Collectors.Intervals)
+	cfg.CollectorTimeouts = joinPairs(tmp.Collectors.Timeouts)
+
+	processes := make([]string, 0, len(tmp.Processes))
+	for _, p := range tmp.Processes {
+		switch {
+		case p.PIDFile != "":
+			processes = append(processes, p.Name+"=pidfile:"+p.PIDFile)
+		case p.Pattern != "":
+			processes = append(processes, p.Name+"=pattern:"+p.Pattern)
+		case p.CGroup != "":
+			processes = append(processes, p.Name+"=cgroup:"+p.CGroup)
+		default:
+			return fmt.Errorf("process %q has no pid_file, pattern or cgroup", p.Name)
+		}
+	}
+	cfg.Processes = strings.Join(processes, ";")
+	cfg.CGroupPath = tmp.CGroupPath
+	cfg.StatsDAddress = tmp.StatsDAddress
+	cfg.PushAddress = tmp.PushAddress
+	cfg.SpoolDir = tmp.Spool.Dir
+	cfg.SpoolMaxBatches = tmp.Spool.MaxBatches
+	cfg.GaugeAggregation = tmp.GaugeAggregation
+	cfg.Transport = tmp.Transport
+	cfg.Endpoints = strings.Join(tmp.Endpoints, ",")
+	cfg.EndpointMode = tmp.EndpointMode
+	cfg.GRPCStreaming = tmp.GRPCStreaming
+	cfg.AgentID = tmp.AgentID
+	cfg.AgentGroup = tmp.AgentGroup
+	if tmp.RemoteConfigInterval != "" {
+		dRemoteConfig, err := time.ParseDuration(tmp.RemoteConfigInterval)
+		if err != nil {
+			return err
+		}
+		cfg.RemoteConfigInterval = int(dRemoteConfig.Seconds())
+	}
 	return nil
 }
+
+// joinPairs сворачивает карту name → duration из файла в формат name=duration,... переменных окружения.
+func joinPairs(m map[string]string) string {
+	pairs := make([]string, 0, len(m))
+	for _, name := range slices.Sorted(maps.Keys(m)) {
+		pairs = append(pairs, name+"="+m[name])
+	}
+	return strings.Join(pairs, ",")
+}
@@ -81,7 +81,31 @@ func TestParseFromFile(t *testing.T) {
 		"address": "127.0.0.1:9090",
 		"report_interval": "15s",
 		"poll_interval": "3s",
-		"crypto_key": "/tmp/keys/private.pem"
+		"crypto_key": "/tmp/keys/private.pem",
+		"token": "file_token",
+		"tenant": "file_tenant",
+		"collectors": {
+			"enabled": ["runtime", "system"],
+			"intervals": {"system": "10s", "runtime": "2s"},
+			"timeouts": {"system": "1s"}
+		},
+		"processes": [
+			{"name": "nginx", "pid_file": "/run/nginx.pid"},
+			{"name": "api", "pattern": "^api-server"},
+			{"name": "db", "cgroup": "/system.slice/postgresql.service"}
+		],
+		"cgroup_path": "/host/sys/fs/cgroup",
+		"statsd_address": "127.0.0.1:8125",
+		"push_address": "127.0.0.1:8126",
+		"spool": {"dir": "/var/lib/agent/spool", "max_batches": 500},
+		"gauge_aggregation": true,
+		"transport": "grpc-http",
+		"endpoints": ["http://primary:8080", "grpc://secondary:3200"],
+		"endpoint_mode": "shard",
+		"grpc_stream": true,
+		"agent_id": "host-1",
+		"agent_group": "edge",
+		"remote_config_interval": "1m"
 	}`)
 	err = os.WriteFile(configFile, configContent, 0644)
 	require.NoError(t, err, "failed to write config file")
@@ -97,6 +121,25 @@ func TestParseFromFile(t *testing.T) {
 	assert.Equal(t, 15, cfg.ReportInterval, "ReportInterval should match file")
 	assert.Equal(t, 3, cfg.PollInterval, "PollInterval should match file")
 	assert.Equal(t, "/tmp/keys/private.pem", cfg.CryptoKey, "CryptoKey should match file")
+	assert.Equal(t, "file_token", cfg.Token, "Token should match file")
+	assert.Equal(t, "file_tenant", cfg.Tenant, "Tenant should match file")
+	assert.Equal(t, "runtime,system", cfg.Collectors, "Collectors should match file")
+	assert.Equal(t, "runtime=2s,system=10s", cfg.CollectorIntervals, "CollectorIntervals should match file")
+	assert.Equal(t, "system=1s", cfg.CollectorTimeouts, "CollectorTimeouts should match file")
+	assert.Equal(t, "nginx=pidfile:/run/nginx.pid;api=pattern:^api-server;db=cgroup:/system.slice/postgresql.service", cfg.Processes, "Processes should match file")
+	assert.Equal(t, "/host/sys/fs/cgroup", cfg.CGroupPath, "CGroupPath should match file")
+	assert.Equal(t, "127.0.0.1:8125", cfg.StatsDAddress, "StatsDAddress should match file")
+	assert.Equal(t, "127.0.0.1:8126", cfg.PushAddress, "PushAddress should match file")
+	assert.Equal(t, "/var/lib/agent/spool", cfg.SpoolDir, "SpoolDir should match file")
+	assert.Equal(t, 500, cfg.SpoolMaxBatches, "SpoolMaxBatches should match file")
+	assert.True(t, cfg.GaugeAggregation, "GaugeAggregation should match file")
+	assert.Equal(t, "grpc-http", cfg.Transport, "Transport should match file")
+	assert.Equal(t, "http://primary:8080,grpc://secondary:3200", cfg.Endpoints, "Endpoints should match file")
+	assert.Equal(t, "shard", cfg.EndpointMode, "EndpointMode should match file")
+	assert.True(t, cfg.GRPCStreaming, "GRPCStreaming should match file")
+	assert.Equal(t, "host-1", cfg.AgentID, "AgentID should match file")
+	assert.Equal(t, "edge", cfg.AgentGroup, "AgentGroup should match file")
+	assert.Equal(t, 60, cfg.RemoteConfigInterval, "RemoteConfigInterval should match file")
 	assert.Empty(t, cfg.Key, "Key should be empty")
 	assert.Equal(t, 0, cfg.RateLimit, "RateLimit should be zero")
 }
@@ -165,6 +208,24 @@ func TestParseFromFlags(t *testing.T) {
 		"-k", "flag_key",
 		"-l", "3",
 		"-crypto-key", "/flag/path/private.pem",
+		"-token", "flag_token",
+		"-tenant", "flag_tenant",
+		"-collectors", "runtime",
+		"-collector-intervals", "runtime=5s",
+		"-processes", "nginx=pattern:^nginx",
+		"-cgroup-path", "/sys/fs/cgroup/agent",
+		"-statsd-address", "127.0.0.1:9125",
+		"-push-address", "127.0.0.1:9126",
+		"-spool-dir", "/tmp/spool",
+		"-spool-max-batches", "100",
+		"-gauge-aggregation",
+		"-transport", "grpc",
+		"-endpoints", "http://primary:8080",
+		"-endpoint-mode", "replicate",
+		"-grpc-stream",
+		"-agent-id", "host-2",
+		"-agent-group", "core",
+		"-remote-config-interval", "30",
 	}
 
 	cfg := &AgentConfig{}
@@ -176,6 +237,35 @@ func TestParseFromFlags(t *testing.T) {
 	assert.Equal(t, "flag_key", cfg.Key, "Key should match flags")
 	assert.Equal(t, 3, cfg.RateLimit, "RateLimit should match flags")
 	assert.Equal(t, "/flag/path/private.pem", cfg.CryptoKey, "CryptoKey should match flags")
+	assert.Equal(t, "flag_token", cfg.Token, "Token should match flags")
+	assert.Equal(t, "flag_tenant", cfg.Tenant, "Tenant should match flags")
+	assert.Equal(t, "runtime", cfg.Collectors, "Collectors should match flags")
+	assert.Equal(t, "runtime=5s", cfg.CollectorIntervals, "CollectorIntervals should match flags")
+	assert.Equal(t, "nginx=pattern:^nginx", cfg.Processes, "Processes should match flags")
+	assert.Equal(t, "/sys/fs/cgroup/agent", cfg.CGroupPath, "CGroupPath should match flags")
+	assert.Equal(t, "127.0.0.1:9125", cfg.StatsDAddress, "StatsDAddress should match flags")
+	assert.Equal(t, "127.0.0.1:9126", cfg.PushAddress, "PushAddress should match flags")
+	assert.Equal(t, "/tmp/spool", cfg.SpoolDir, "SpoolDir should match flags")
+	assert.Equal(t, 100, cfg.SpoolMaxBatches, "SpoolMaxBatches should match flags")
+	assert.True(t, cfg.GaugeAggregation, "GaugeAggregation should match flags")
+	assert.Equal(t, "grpc", cfg.Transport, "Transport should match flags")
+	assert.Equal(t, "http://primary:8080", cfg.Endpoints, "Endpoints should match flags")
+	assert.Equal(t, "replicate", cfg.EndpointMode, "EndpointMode should match flags")
+	assert.True(t, cfg.GRPCStreaming, "GRPCStreaming should match flags")
+	assert.Equal(t, "host-2", cfg.AgentID, "AgentID should match flags")
+	assert.Equal(t, "core", cfg.AgentGroup, "AgentGroup should match flags")
+	assert.Equal(t, 30, cfg.RemoteConfigInterval, "RemoteConfigInterval should match flags")
+}
+
+func TestParseFromFlagsKeepsFileToken(t *testing.T) {
+	beforeEach()
+
+	os.Args = []string{"test"}
+
+	cfg := &AgentConfig{Token: "file_token"}
+	cfg.parseFromFlags()
+
+	assert.Equal(t, "file_token", cfg.Token, "Token from file should survive flag parsing")
 }
 
 func TestParseFromEnv(t *testing.T) {
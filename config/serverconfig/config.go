@@ -11,19 +11,40 @@ import (
 )
 
 type ServerConfig struct {
-	HTTPAddr        config.AddrConfig `env:"ADDRESS"`
-	GRPCAddr        config.AddrConfig `env:"GRPC_ADDRESS"`
-	StoreInterval   int               `env:"STORE_INTERVAL"`
-	FileStoragePath string            `env:"FILE_STORAGE_PATH"`
-	Restore         bool              `env:"RESTORE"`
-	DatabaseDSN     string            `env:"DATABASE_DSN"`
-	Key             string            `env:"KEY"`
-	CryptoKey       string            `env:"CRYPTO_KEY"`
-	TrustedSubnet   string            `env:"TRUSTED_SUBNET"`
+	HTTPAddr             config.AddrConfig `env:"ADDRESS"`
+	GRPCAddr             config.AddrConfig `env:"GRPC_ADDRESS"`
+	StoreInterval        int               `env:"STORE_INTERVAL"`
+	FileStoragePath      string            `env:"FILE_STORAGE_PATH"`
+	Restore              bool              `env:"RESTORE"`
+	DatabaseDSN          string            `env:"DATABASE_DSN"`
+	Key                  string            `env:"KEY"`
+	CryptoKey            string            `env:"CRYPTO_KEY"`
+	TrustedSubnet        string            `env:"TRUSTED_SUBNET"`
+	TrustedProxies       string            `env:"TRUSTED_PROXIES"`
+	AuthFile             string            `env:"AUTH_FILE"`
+	AuthFromDB           bool              `env:"AUTH_DB"`
+	RateLimitIP          float64           `env:"RATE_LIMIT_IP"`
+	RateBurstIP          int               `env:"RATE_BURST_IP"`
+	RateLimitHTTP        float64           `env:"RATE_LIMIT_HTTP"`
+	RateBurstHTTP        int               `env:"RATE_BURST_HTTP"`
+	RateLimitGRPC        float64           `env:"RATE_LIMIT_GRPC"`
+	RateBurstGRPC        int               `env:"RATE_BURST_GRPC"`
+	MaxBatchSize         int               `env:"MAX_BATCH_SIZE"`
+	MaxMetricsPerClient  int               `env:"MAX_METRICS_PER_CLIENT"`
+	ReplayWindow         int               `env:"REPLAY_WINDOW"`
+	ReplayCacheSize      int               `env:"REPLAY_CACHE_SIZE"`
+	IdempotencyWindow    int               `env:"IDEMPOTENCY_WINDOW"`
+	IdempotencyCacheSize int               `env:"IDEMPOTENCY_CACHE_SIZE"`
+	AgentConfigFile      string            `env:"AGENT_CONFIG_FILE"`
+	AgentSilentAfter     int               `env:"AGENT_SILENT_AFTER"`
 }
 
+// DefaultReplayWindow — допустимое расхождение часов подписанных запросов в секундах по умолчанию.
+// Защита от повтора включена, пока окно явно не обнулено.
+const DefaultReplayWindow = 300
+
 func NewServerConfig() (*ServerConfig, error) {
-	cfg := &ServerConfig{}
+	cfg := &ServerConfig{ReplayWindow: DefaultReplayWindow}
 
 	err := cfg.parseFromFile()
 	if err != nil {
@@ -46,7 +67,24 @@ func (cfg *ServerConfig) parseFromFlags() {
 	key := flag.String("k", "", "secret key")
 	cryptoKey := flag.String("crypto-key", "", "secret key")
 	databaseDSN := flag.String("d", "", "database DSN")
-	trustedSubnet := flag.String("t", "", "trusted subnet")
+	trustedSubnet := flag.String("t", "", "trusted subnets, comma-separated CIDRs")
+	trustedProxies := flag.String("trusted-proxies", cfg.TrustedProxies, "proxies allowed to set X-Real-IP/X-Forwarded-For, comma-separated CIDRs")
+	authFile := flag.String("auth-file", cfg.AuthFile, "path to api tokens file")
+	authFromDB := flag.Bool("auth-db", cfg.AuthFromDB, "load api tokens from database")
+	rateLimitIP := flag.Float64("rate-limit-ip", cfg.RateLimitIP, "http requests and grpc calls per second per client ip and route before authentication, 0 disables")
+	rateBurstIP := flag.Int("rate-burst-ip", cfg.RateBurstIP, "http requests and grpc calls burst per client ip and route before authentication")
+	rateLimitHTTP := flag.Float64("rate-limit-http", cfg.RateLimitHTTP, "http requests per second per client (token, or ip without auth) and route, 0 disables")
+	rateBurstHTTP := flag.Int("rate-burst-http", cfg.RateBurstHTTP, "http requests burst per client and route")
+	rateLimitGRPC := flag.Float64("rate-limit-grpc", cfg.RateLimitGRPC, "grpc calls per second per client (token, or ip without auth) and method, 0 disables")
+	rateBurstGRPC := flag.Int("rate-burst-grpc", cfg.RateBurstGRPC, "grpc calls burst per client and method")
+	maxBatchSize := flag.Int("max-batch-size", cfg.MaxBatchSize, "max metrics in one batch, 0 disables")
+	replayWindow := flag.Int("replay-window", cfg.ReplayWindow, "allowed clock skew of signed requests in seconds, 0 explicitly disables replay protection")
+	replayCacheSize := flag.Int("replay-cache-size", cfg.ReplayCacheSize, "max remembered nonces of signed requests")
+	idempotencyWindow := flag.Int("idempotency-window", cfg.IdempotencyWindow, "how long applied batch ids are remembered in seconds")
+	idempotencyCacheSize := flag.Int("idempotency-cache-size", cfg.IdempotencyCacheSize, "max remembered batch ids")
+	agentConfigFile := flag.String("agent-config-file", cfg.AgentConfigFile, "path to remote agent settings file, empty disables remote agent config")
+	agentSilentAfter := flag.Int("agent-silent-after", cfg.AgentSilentAfter, "seconds without requests after which an agent is reported silent, agents silent ten times longer are forgotten")
+	maxMetricsPerClient := flag.Int("max-metrics-per-client", cfg.MaxMetricsPerClient, "max distinct metric names per client, 0 disables")
 
 	httpAddr := config.NewDefaultHTTPAddr()
 	flag.Var(&httpAddr, "a", "server host:port")
@@ -64,6 +102,23 @@ func (cfg *ServerConfig) parseFromFlags() {
 	cfg.Key = *key
 	cfg.CryptoKey = *cryptoKey
 	cfg.TrustedSubnet = *trustedSubnet
+	cfg.TrustedProxies = *trustedProxies
+	cfg.AuthFile = *authFile
+	cfg.AuthFromDB = *authFromDB
+	cfg.RateLimitIP = *rateLimitIP
+	cfg.RateBurstIP = *rateBurstIP
+	cfg.RateLimitHTTP = *rateLimitHTTP
+	cfg.RateBurstHTTP = *rateBurstHTTP
+	cfg.RateLimitGRPC = *rateLimitGRPC
+	cfg.RateBurstGRPC = *rateBurstGRPC
+	cfg.MaxBatchSize = *maxBatchSize
+	cfg.MaxMetricsPerClient = *maxMetricsPerClient
+	cfg.ReplayWindow = *replayWindow
+	cfg.ReplayCacheSize = *replayCacheSize
+	cfg.IdempotencyWindow = *idempotencyWindow
+	cfg.IdempotencyCacheSize = *idempotencyCacheSize
+	cfg.AgentConfigFile = *agentConfigFile
+	cfg.AgentSilentAfter = *agentSilentAfter
 }
 
 func (cfg *ServerConfig) parseFromEnv() error {
@@ -103,14 +158,31 @@ func (cfg *ServerConfig) parseFromFile() error {
 	}
 
 	type tmpConfig struct {
-		HTTPAddress   string `json:"address"`
-		GRPCAddress   string `json:"grpc_address"`
-		Restore       bool   `json:"restore"`
-		StoreInterval string `json:"store_interval"`
-		StoreFile     string `json:"store_file"`
-		DatabaseDsn   string `json:"database_dsn"`
-		CryptoKey     string `json:"crypto_key"`
-		TrustedSubnet string `json:"trusted_subnet"`
+		HTTPAddress          string  `json:"address"`
+		GRPCAddress          string  `json:"grpc_address"`
+		Restore              bool    `json:"restore"`
+		StoreInterval        string  `json:"store_interval"`
+		StoreFile            string  `json:"store_file"`
+		DatabaseDsn          string  `json:"database_dsn"`
+		CryptoKey            string  `json:"crypto_key"`
+		TrustedSubnet        string  `json:"trusted_subnet"`
+		TrustedProxies       string  `json:"trusted_proxies"`
+		AuthFile             string  `json:"auth_file"`
+		AuthFromDB           bool    `json:"auth_db"`
+		RateLimitIP          float64 `json:"rate_limit_ip"`
+		RateBurstIP          int     `json:"rate_burst_ip"`
+		RateLimitHTTP        float64 `json:"rate_limit_http"`
+		RateBurstHTTP        int     `json:"rate_burst_http"`
+		RateLimitGRPC        float64 `json:"rate_limit_grpc"`
+		RateBurstGRPC        int     `json:"rate_burst_grpc"`
+		MaxBatchSize         int     `json:"max_batch_size"`
+		MaxMetricsPerClient  int     `json:"max_metrics_per_client"`
+		ReplayWindow         string  `json:"replay_window"`
+		ReplayCacheSize      int     `json:"replay_cache_size"`
+		IdempotencyWindow    string  `json:"idempotency_window"`
+		IdempotencyCacheSize int     `json:"idempotency_cache_size"`
+		AgentConfigFile      string  `json:"agent_config_file"`
+		AgentSilentAfter     string  `json:"agent_silent_after"`
 	}
 	tmp := tmpConfig{}
 	err = json.Unmarshal(fileBytes, &tmp)
@@ -149,6 +221,41 @@ func (cfg *ServerConfig) parseFromFile() error {
 	cfg.DatabaseDSN = tmp.DatabaseDsn
 	cfg.CryptoKey = tmp.CryptoKey
 	cfg.TrustedSubnet = tmp.TrustedSubnet
+	cfg.TrustedProxies = tmp.TrustedProxies
+	cfg.AuthFile = tmp.AuthFile
+	cfg.AuthFromDB = tmp.AuthFromDB
+	cfg.RateLimitIP = tmp.RateLimitIP
+	cfg.RateBurstIP = tmp.RateBurstIP
+	cfg.RateLimitHTTP = tmp.RateLimitHTTP
+	cfg.RateBurstHTTP = tmp.RateBurstHTTP
+	cfg.RateLimitGRPC = tmp.RateLimitGRPC
+	cfg.RateBurstGRPC = tmp.RateBurstGRPC
+	cfg.MaxBatchSize = tmp.MaxBatchSize
+	cfg.MaxMetricsPerClient = tmp.MaxMetricsPerClient
+	cfg.ReplayCacheSize = tmp.ReplayCacheSize
+	if tmp.ReplayWindow != "" {
+		dReplayWindow, err := time.ParseDuration(tmp.ReplayWindow)
+		if err != nil {
+			return err
+		}
+		cfg.ReplayWindow = int(dReplayWindow.Seconds())
+	}
+	cfg.IdempotencyCacheSize = tmp.IdempotencyCacheSize
+	if tmp.IdempotencyWindow != "" {
+		dIdempotencyWindow, err := time.ParseDuration(tmp.IdempotencyWindow)
+		if err != nil {
+			return err
+		}
+		cfg.IdempotencyWindow = int(dIdempotencyWindow.Seconds())
+	}
+	cfg.AgentConfigFile = tmp.AgentConfigFile
+	if tmp.AgentSilentAfter != "" {
+		dAgentSilentAfter, err := time.ParseDuration(tmp.AgentSilentAfter)
+		if err != nil {
+			return err
+		}
+		cfg.AgentSilentAfter = int(dAgentSilentAfter.Seconds())
+	}
 
 	return nil
 }
@@ -1,66 +1,396 @@
 package app
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
 	"github.com/MxTrap/metrics/config/agentconfig"
+	"github.com/MxTrap/metrics/internal/agent/collector"
 	"github.com/MxTrap/metrics/internal/agent/grpc"
 	"github.com/MxTrap/metrics/internal/agent/http"
+	"github.com/MxTrap/metrics/internal/agent/ingest"
+	"github.com/MxTrap/metrics/internal/agent/remoteconfig"
 	"github.com/MxTrap/metrics/internal/agent/repository"
+	"github.com/MxTrap/metrics/internal/agent/sender"
 	"github.com/MxTrap/metrics/internal/agent/service"
+	"github.com/MxTrap/metrics/internal/agent/spool"
+	"github.com/MxTrap/metrics/internal/agent/telemetry"
+	"github.com/MxTrap/metrics/internal/common/models"
+	"io"
 	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
 )
 
 type runner interface {
 	Run(ctx context.Context)
 }
 
+// reportIntervalUpdater — конвейер отправки, интервал которого меняется удалённой конфигурацией.
+type reportIntervalUpdater interface {
+	UpdateReportInterval(reportInterval int)
+}
+
+// Транспорты отправки метрик.
+const (
+	TransportHTTP         = "http"
+	TransportGRPC         = "grpc"
+	TransportGRPCWithHTTP = "grpc-http"
+)
+
+// Режимы распределения метрик между несколькими серверами.
+const (
+	EndpointModeReplicate = "replicate"
+	EndpointModeShard     = "shard"
+)
+
 type App struct {
-	service    runner
-	httpClient runner
-	grpcClient runner
+	service runner
+	senders []runner
+	ingest  []runner
+	watcher runner
+	// configCloser закрывает клиент удалённой конфигурации после остановки watcher, nil, если закрывать нечего
+	configCloser io.Closer
+	// telemetry — собственные метрики агента, отдаваемые локально на /metrics
+	telemetry *telemetry.Telemetry
 }
 
 func NewApp(cfg *agentconfig.AgentConfig) *App {
 	storage := repository.NewMetricsStorage()
+	if cfg.GaugeAggregation {
+		storage.EnableGaugeAggregation()
+	}
+	stats := telemetry.New(storage)
 	mService := service.NewMetricsObserverService(storage, cfg.PollInterval)
+	mService.RegisterStatsSink(stats)
 
-	httpClient := http.NewClient(
-		mService,
-		fmt.Sprintf("%s:%d", cfg.HTTPServerAddr.Host, cfg.HTTPServerAddr.Port),
-		cfg.ReportInterval,
-		cfg.Key,
-		cfg.RateLimit,
-	)
-	grpcClient, err := grpc.NewClient(
-		fmt.Sprintf("%s:%d", cfg.GRPCServerAddr.Host, cfg.GRPCServerAddr.Port),
-		mService,
-		cfg.ReportInterval,
-		cfg.Key,
-		cfg.RateLimit,
-	)
+	settings, err := collector.ParseSettings(cfg.Collectors, cfg.CollectorIntervals, cfg.CollectorTimeouts)
 	if err != nil {
 		log.Fatal(err)
 	}
+	registry := collector.Default()
+	if cfg.Processes != "" {
+		targets, err := collector.ParseProcessTargets(cfg.Processes)
+		if err != nil {
+			log.Fatal(err)
+		}
+		_ = registry.Register(collector.NewProcessCollector(collector.DefaultProcRoot, targets), collector.Options{})
+	}
+	// коллектор контейнера регистрируется, только если каталог cgroup задан явно или агент запущен
+	// в некорневой cgroup, и в этом каталоге доступна единая иерархия cgroup v2
+	if cfg.CGroupPath != "" || collector.InNestedCGroup(collector.DefaultProcRoot) {
+		if cgroupPath := cmp.Or(cfg.CGroupPath, collector.DefaultCGroupRoot); collector.CGroupV2Available(cgroupPath) {
+			_ = registry.Register(collector.NewCGroupCollector(cgroupPath), collector.Options{})
+		}
+	}
+	collectors, err := registry.Select(settings)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, e := range collectors {
+		mService.RegisterCollector(e.Collector, e.Options)
+	}
+
+	senders, err := newSenders(cfg, mService, stats)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var ingestRunners []runner
+	if cfg.StatsDAddress != "" {
+		ingestRunners = append(ingestRunners, ingest.NewStatsDServer(cfg.StatsDAddress, storage))
+	}
+	if cfg.PushAddress != "" {
+		ingestRunners = append(ingestRunners, ingest.NewPushServer(cfg.PushAddress, storage))
+	}
+
+	app := &App{
+		service:   mService,
+		senders:   senders,
+		ingest:    ingestRunners,
+		telemetry: stats,
+	}
+
+	if cfg.RemoteConfigInterval > 0 {
+		source, closer, err := newConfigSource(cfg)
+		if err != nil {
+			log.Fatal(err)
+		}
+		app.configCloser = closer
+		apply := func(rc models.AgentConfig) error {
+			remote := settings
+			if len(rc.Collectors) > 0 {
+				remote.Enabled = make(map[string]struct{}, len(rc.Collectors))
+				for _, name := range rc.Collectors {
+					remote.Enabled[name] = struct{}{}
+				}
+			}
+			entries, err := registry.Select(remote)
+			if err != nil {
+				return err
+			}
+			reportInterval := cmp.Or(rc.ReportInterval, cfg.ReportInterval)
+			mService.Reconfigure(cmp.Or(rc.PollInterval, cfg.PollInterval), entries)
+			for _, s := range senders {
+				if u, ok := s.(reportIntervalUpdater); ok {
+					u.UpdateReportInterval(reportInterval)
+				}
+			}
+			return nil
+		}
+		app.watcher = remoteconfig.NewWatcher(source, newAgentInfo(cfg).ID, cfg.AgentGroup, time.Duration(cfg.RemoteConfigInterval)*time.Second, apply)
+	}
+	return app
+}
+
+// newConfigSource создаёт клиент, через который агент получает удалённую конфигурацию.
+// Со списком серверов конфигурация запрашивается у первого из них, иначе — у сервера выбранного транспорта,
+// при транспорте grpc-http — по gRPC.
+// Клиент создаётся отдельно от клиентов отправки, поэтому его соединение gRPC закрывается возвращаемым io.Closer;
+// у клиента HTTP закрывать нечего, и io.Closer равен nil.
+func newConfigSource(cfg *agentconfig.AgentConfig) (remoteconfig.Source, io.Closer, error) {
+	transport := cmp.Or(cfg.Transport, TransportHTTP)
+	var addr string
+	switch {
+	case cfg.Endpoints != "":
+		endpoints, err := parseEndpoints(cfg.Endpoints)
+		if err != nil {
+			return nil, nil, err
+		}
+		transport, addr = endpoints[0].transport, endpoints[0].addr
+	case transport == TransportGRPC || transport == TransportGRPCWithHTTP:
+		transport, addr = TransportGRPC, fmt.Sprintf("%s:%d", cfg.GRPCServerAddr.Host, cfg.GRPCServerAddr.Port)
+	case transport == TransportHTTP:
+		addr = fmt.Sprintf("%s:%d", cfg.HTTPServerAddr.Host, cfg.HTTPServerAddr.Port)
+	default:
+		return nil, nil, fmt.Errorf("unknown transport %q", cfg.Transport)
+	}
+
+	if transport == TransportGRPC {
+		client, err := newGRPCClient(cfg, addr)
+		if err != nil {
+			return nil, nil, err
+		}
+		return client, client, nil
+	}
+	client, err := newHTTPClient(cfg, addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	return client, nil, nil
+}
+
+// newSenders создаёт конвейеры отправки метрик.
+// Без списка серверов метрики отправляются на адреса -a/-g выбранным транспортом.
+// Со списком у каждого сервера свой конвейер с собственными повторами и буфером,
+// а метрики копируются на все серверы или распределяются между ними по имени.
+// Собственные метрики доставки конвейеров сохраняются в stats и отправляются вместе с остальными.
+func newSenders(cfg *agentconfig.AgentConfig, source *service.MetricsObserverService, stats *telemetry.Telemetry) ([]runner, error) {
+	if cfg.Endpoints == "" {
+		transport, err := newTransport(cfg)
+		if err != nil {
+			return nil, err
+		}
+		s := sender.NewSender(source, transport, cfg.ReportInterval, cfg.RateLimit)
+		s.RegisterStatsSink(stats)
+		if cfg.SpoolDir != "" {
+			sp, err := spool.Open(cfg.SpoolDir, cfg.SpoolMaxBatches)
+			if err != nil {
+				return nil, err
+			}
+			s.RegisterSpool(sp)
+		}
+		return []runner{s}, nil
+	}
 
+	endpoints, err := parseEndpoints(cfg.Endpoints)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(endpoints))
+	for i, e := range endpoints {
+		names[i] = e.name()
+	}
+	var route sender.Router
+	switch cmp.Or(cfg.EndpointMode, EndpointModeReplicate) {
+	case EndpointModeReplicate:
+		route = sender.Replicate(len(endpoints))
+	case EndpointModeShard:
+		route = sender.Shard(names)
+	default:
+		return nil, fmt.Errorf("unknown endpoint mode %q", cfg.EndpointMode)
+	}
+
+	fanout := sender.NewFanout(source, len(endpoints), route)
+	senders := make([]runner, 0, len(endpoints))
+	for i, e := range endpoints {
+		var transport sender.Transport
+		if e.transport == TransportGRPC {
+			transport, err = newGRPCClient(cfg, e.addr)
+		} else {
+			transport, err = newHTTPClient(cfg, e.addr)
+		}
+		if err != nil {
+			return nil, err
+		}
+		s := sender.NewSender(fanout.Branch(i), transport, cfg.ReportInterval, cfg.RateLimit)
+		s.RegisterStatsSink(stats)
+		if cfg.SpoolDir != "" {
+			sp, err := spool.Open(filepath.Join(cfg.SpoolDir, names[i]), cfg.SpoolMaxBatches)
+			if err != nil {
+				return nil, err
+			}
+			s.RegisterSpool(sp)
+		}
+		senders = append(senders, s)
+	}
+	return senders, nil
+}
+
+// endpoint — сервер, на который отправляются метрики.
+type endpoint struct {
+	transport string
+	addr      string
+}
+
+// name возвращает имя сервера, пригодное для имени каталога.
+func (e endpoint) name() string {
+	return e.transport + "_" + strings.NewReplacer(":", "_", "/", "_", "[", "", "]", "").Replace(e.addr)
+}
+
+// parseEndpoints разбирает список серверов вида "http://primary:8080,grpc://secondary:3200".
+// Адрес без схемы считается HTTP.
+func parseEndpoints(list string) ([]endpoint, error) {
+	var endpoints []endpoint
+	seen := make(map[endpoint]struct{})
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		e := endpoint{transport: TransportHTTP, addr: item}
+		if scheme, addr, ok := strings.Cut(item, "://"); ok {
+			e = endpoint{transport: scheme, addr: addr}
+		}
+		if e.transport != TransportHTTP && e.transport != TransportGRPC {
+			return nil, fmt.Errorf("unknown endpoint transport %q in %s", e.transport, item)
+		}
+		if _, _, err := net.SplitHostPort(e.addr); err != nil {
+			return nil, fmt.Errorf("invalid endpoint %s: %w", item, err)
+		}
+		if _, ok := seen[e]; ok {
+			return nil, fmt.Errorf("duplicate endpoint %s", item)
+		}
+		seen[e] = struct{}{}
+		endpoints = append(endpoints, e)
+	}
+	if len(endpoints) == 0 {
+		return nil, errors.New("no endpoints configured")
+	}
+	return endpoints, nil
+}
+
+// newTransport создаёт транспорт, выбранный в конфигурации: http, grpc или grpc-http —
+// gRPC с переключением на HTTP, пока gRPC недоступен.
+func newTransport(cfg *agentconfig.AgentConfig) (sender.Transport, error) {
+	httpAddr := fmt.Sprintf("%s:%d", cfg.HTTPServerAddr.Host, cfg.HTTPServerAddr.Port)
+	grpcAddr := fmt.Sprintf("%s:%d", cfg.GRPCServerAddr.Host, cfg.GRPCServerAddr.Port)
+	switch cmp.Or(cfg.Transport, TransportHTTP) {
+	case TransportHTTP:
+		return newHTTPClient(cfg, httpAddr)
+	case TransportGRPC:
+		return newGRPCClient(cfg, grpcAddr)
+	case TransportGRPCWithHTTP:
+		grpcClient, err := newGRPCClient(cfg, grpcAddr)
+		if err != nil {
+			return nil, err
+		}
+		httpClient, err := newHTTPClient(cfg, httpAddr)
+		if err != nil {
+			return nil, errors.Join(err, grpcClient.Close())
+		}
+		return sender.NewFailover(sender.DefaultRetryAfter, grpcClient, httpClient), nil
+	}
+	return nil, fmt.Errorf("unknown transport %q", cfg.Transport)
+}
+
+// newAgentInfo возвращает сведения, которыми агент представляется серверу.
+// Без заданного идентификатора агент идентифицируется именем хоста.
+func newAgentInfo(cfg *agentconfig.AgentConfig) models.AgentInfo {
+	hostname, _ := os.Hostname()
+	return models.AgentInfo{
+		ID:       cmp.Or(cfg.AgentID, hostname),
+		Hostname: hostname,
+		Version:  cfg.BuildVersion,
+		OS:       runtime.GOOS + "/" + runtime.GOARCH,
+	}
+}
+
+func newHTTPClient(cfg *agentconfig.AgentConfig, addr string) (*http.HTTPClient, error) {
+	client := http.NewClient(addr, cfg.Key)
+	client.RegisterAgent(newAgentInfo(cfg))
+	if cfg.Token != "" {
+		client.RegisterToken(cfg.Token)
+	}
+	if cfg.Tenant != "" {
+		client.RegisterTenant(cfg.Tenant)
+	}
 	if cfg.CryptoKey != "" {
 		encrypter, err := service.NewEncrypterSvc(cfg.CryptoKey)
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
-		httpClient.RegisterEncrypter(encrypter)
+		client.RegisterEncrypter(encrypter)
 	}
+	return client, nil
+}
 
-	return &App{
-		service:    mService,
-		httpClient: httpClient,
-		grpcClient: grpcClient,
+func newGRPCClient(cfg *agentconfig.AgentConfig, addr string) (*grpc.Client, error) {
+	client, err := grpc.NewClient(addr, cfg.Key)
+	if err != nil {
+		return nil, err
+	}
+	client.RegisterAgent(newAgentInfo(cfg))
+	if cfg.Token != "" {
+		client.RegisterToken(cfg.Token)
+	}
+	if cfg.Tenant != "" {
+		client.RegisterTenant(cfg.Tenant)
 	}
+	if cfg.GRPCStreaming {
+		client.EnableStreaming()
+	}
+	return client, nil
+}
+
+// Telemetry возвращает собственные метрики агента, которые отдаются как HTTP-обработчик /metrics.
+func (a *App) Telemetry() *telemetry.Telemetry {
+	return a.telemetry
 }
 
 func (a *App) Run(ctx context.Context) {
 	fmt.Println("starting metrics observer")
 	go a.service.Run(ctx)
-	go a.httpClient.Run(ctx)
-	go a.grpcClient.Run(ctx)
+	for _, s := range a.senders {
+		go s.Run(ctx)
+	}
+	for _, r := range a.ingest {
+		go r.Run(ctx)
+	}
+	if a.watcher != nil {
+		go func() {
+			a.watcher.Run(ctx)
+			if a.configCloser == nil {
+				return
+			}
+			if err := a.configCloser.Close(); err != nil {
+				log.Printf("error closing remote config client: %v", err)
+			}
+		}()
+	}
 }
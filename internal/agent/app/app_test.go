@@ -3,15 +3,23 @@ package app
 import (
 	"context"
 	"github.com/MxTrap/metrics/config"
+	"os"
+	"path/filepath"
+	"runtime"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/MxTrap/metrics/config/agentconfig"
+	"github.com/MxTrap/metrics/internal/agent/grpc"
 	"github.com/MxTrap/metrics/internal/agent/http"
+	"github.com/MxTrap/metrics/internal/agent/ingest"
+	"github.com/MxTrap/metrics/internal/agent/remoteconfig"
+	"github.com/MxTrap/metrics/internal/agent/sender"
 	"github.com/MxTrap/metrics/internal/agent/service"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 type mockRunner struct {
@@ -37,13 +45,168 @@ func TestNewApp(t *testing.T) {
 	app := NewApp(cfg)
 	assert.NotNil(t, app, "app should not be nil")
 	assert.NotNil(t, app.service, "service should not be nil")
-	assert.NotNil(t, app.httpClient, "httpClient should not be nil")
+	assert.Len(t, app.senders, 1, "one sender should be created")
+	assert.NotNil(t, app.Telemetry(), "agent telemetry should be created")
 
 	// Проверяем типы
 	_, ok := app.service.(*service.MetricsObserverService)
 	assert.True(t, ok, "service should be MetricsObserverService")
-	_, ok = app.httpClient.(*http.HTTPClient)
-	assert.True(t, ok, "httpClient should be HTTPClient")
+	_, ok = app.senders[0].(*sender.Sender)
+	assert.True(t, ok, "sender should be Sender")
+}
+
+func TestNewAppWithEndpoints(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &agentconfig.AgentConfig{
+		ReportInterval: 10,
+		PollInterval:   2,
+		RateLimit:      1,
+		Endpoints:      "http://primary:8080, grpc://secondary:3200",
+		EndpointMode:   EndpointModeShard,
+		SpoolDir:       dir,
+	}
+
+	app := NewApp(cfg)
+	assert.Len(t, app.senders, 2, "each endpoint should get its own sender")
+	assert.DirExists(t, filepath.Join(dir, "http_primary_8080"), "each endpoint should get its own spool")
+	assert.DirExists(t, filepath.Join(dir, "grpc_secondary_3200"), "each endpoint should get its own spool")
+}
+
+func TestNewSendersInvalidMode(t *testing.T) {
+	_, err := newSenders(&agentconfig.AgentConfig{Endpoints: "primary:8080", EndpointMode: "random"}, nil, nil)
+	assert.Error(t, err)
+}
+
+func TestParseEndpoints(t *testing.T) {
+	endpoints, err := parseEndpoints("primary:8080,grpc://secondary:3200")
+	require.NoError(t, err)
+	assert.Equal(t, []endpoint{
+		{transport: TransportHTTP, addr: "primary:8080"},
+		{transport: TransportGRPC, addr: "secondary:3200"},
+	}, endpoints)
+
+	for _, list := range []string{"", "udp://primary:8080", "primary", "primary:8080,http://primary:8080"} {
+		_, err = parseEndpoints(list)
+		assert.Error(t, err, "%q should be rejected", list)
+	}
+}
+
+func TestNewTransport(t *testing.T) {
+	tests := []struct {
+		transport string
+		want      any
+	}{
+		{transport: "", want: &http.HTTPClient{}},
+		{transport: TransportHTTP, want: &http.HTTPClient{}},
+		{transport: TransportGRPC, want: &grpc.Client{}},
+		{transport: TransportGRPCWithHTTP, want: &sender.Failover{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.transport, func(t *testing.T) {
+			transport, err := newTransport(&agentconfig.AgentConfig{
+				HTTPServerAddr: config.AddrConfig{Host: "localhost", Port: 8080},
+				GRPCServerAddr: config.AddrConfig{Host: "localhost", Port: 9090},
+				Transport:      tt.transport,
+			})
+			require.NoError(t, err)
+			assert.IsType(t, tt.want, transport)
+		})
+	}
+
+	_, err := newTransport(&agentconfig.AgentConfig{Transport: "udp"})
+	assert.Error(t, err, "unknown transport should be rejected")
+}
+
+func TestNewConfigSource(t *testing.T) {
+	tests := []struct {
+		name      string
+		transport string
+		endpoints string
+		want      any
+	}{
+		{name: "http", transport: TransportHTTP, want: &http.HTTPClient{}},
+		{name: "grpc", transport: TransportGRPC, want: &grpc.Client{}},
+		{name: "grpc-http", transport: TransportGRPCWithHTTP, want: &grpc.Client{}},
+		{name: "first endpoint", transport: TransportHTTP, endpoints: "grpc://primary:3200,http://secondary:8080", want: &grpc.Client{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, closer, err := newConfigSource(&agentconfig.AgentConfig{
+				HTTPServerAddr: config.AddrConfig{Host: "localhost", Port: 8080},
+				GRPCServerAddr: config.AddrConfig{Host: "localhost", Port: 9090},
+				Transport:      tt.transport,
+				Endpoints:      tt.endpoints,
+			})
+			require.NoError(t, err)
+			assert.IsType(t, tt.want, source)
+			if _, ok := tt.want.(*grpc.Client); ok {
+				require.NotNil(t, closer, "gRPC connection should be closed on shutdown")
+				assert.NoError(t, closer.Close())
+			} else {
+				assert.Nil(t, closer)
+			}
+		})
+	}
+
+	_, _, err := newConfigSource(&agentconfig.AgentConfig{Transport: "udp"})
+	assert.Error(t, err, "unknown transport should be rejected")
+}
+
+func TestNewAppWithRemoteConfig(t *testing.T) {
+	cfg := &agentconfig.AgentConfig{
+		HTTPServerAddr:       config.AddrConfig{Host: "localhost", Port: 8080},
+		ReportInterval:       10,
+		PollInterval:         2,
+		RateLimit:            1,
+		AgentID:              "host-1",
+		RemoteConfigInterval: 30,
+	}
+	app := NewApp(cfg)
+	assert.IsType(t, &remoteconfig.Watcher{}, app.watcher, "watcher should be created when remote config is enabled")
+	assert.Nil(t, app.configCloser, "HTTP config client has nothing to close")
+
+	grpcCfg := *cfg
+	grpcCfg.Transport = TransportGRPC
+	grpcCfg.GRPCServerAddr = config.AddrConfig{Host: "localhost", Port: 9090}
+	grpcApp := NewApp(&grpcCfg)
+	require.NotNil(t, grpcApp.configCloser, "gRPC config client should be closed on shutdown")
+	assert.NoError(t, grpcApp.configCloser.Close())
+
+	cfg.RemoteConfigInterval = 0
+	assert.Nil(t, NewApp(cfg).watcher, "watcher should not be created by default")
+}
+
+func TestNewAppWithIngest(t *testing.T) {
+	cfg := &agentconfig.AgentConfig{
+		HTTPServerAddr: config.AddrConfig{Host: "localhost", Port: 8080},
+		ReportInterval: 10,
+		PollInterval:   2,
+		RateLimit:      1,
+		StatsDAddress:  "127.0.0.1:8125",
+		PushAddress:    "127.0.0.1:8126",
+	}
+
+	app := NewApp(cfg)
+	assert.Len(t, app.ingest, 2, "statsd and push servers should be created")
+	_, ok := app.ingest[0].(*ingest.StatsDServer)
+	assert.True(t, ok, "first ingest runner should be StatsDServer")
+	_, ok = app.ingest[1].(*ingest.PushServer)
+	assert.True(t, ok, "second ingest runner should be PushServer")
+}
+
+func TestNewAppWithSpool(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "spool")
+	cfg := &agentconfig.AgentConfig{
+		HTTPServerAddr:  config.AddrConfig{Host: "localhost", Port: 8080},
+		ReportInterval:  10,
+		PollInterval:    2,
+		RateLimit:       1,
+		SpoolDir:        dir,
+		SpoolMaxBatches: 10,
+	}
+
+	NewApp(cfg)
+	assert.DirExists(t, dir, "spool should be created")
 }
 
 func TestNewAppWithEncryption(t *testing.T) {
@@ -61,14 +224,13 @@ func TestNewAppWithEncryption(t *testing.T) {
 	app := NewApp(cfg)
 	assert.NotNil(t, app, "app should not be nil")
 	assert.NotNil(t, app.service, "service should not be nil")
-	assert.NotNil(t, app.httpClient, "httpClient should not be nil")
+	assert.NotEmpty(t, app.senders, "senders should not be empty")
 }
 
 func TestRun(t *testing.T) {
-	// Мокаем service и httpClient
+	// Мокаем service и sender
 	serviceRunner := &mockRunner{}
 	clientRunner := &mockRunner{}
-	grpcRunner := &mockRunner{}
 
 	// Создаём каналы для отслеживания вызовов
 	serviceStarted := make(chan struct{})
@@ -79,12 +241,10 @@ func TestRun(t *testing.T) {
 	clientRunner.On("Run", mock.Anything).Run(func(args mock.Arguments) {
 		close(clientStarted)
 	}).Return()
-	grpcRunner.On("Run", mock.Anything).Run(func(args mock.Arguments) {})
 
 	app := &App{
-		service:    serviceRunner,
-		httpClient: clientRunner,
-		grpcClient: grpcRunner,
+		service: serviceRunner,
+		senders: []runner{clientRunner},
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
@@ -105,7 +265,7 @@ func TestRun(t *testing.T) {
 	select {
 	case <-clientStarted:
 	case <-time.After(500 * time.Millisecond):
-		t.Fatal("httpClient did not start")
+		t.Fatal("sender did not start")
 	}
 
 	cancel()
@@ -114,3 +274,16 @@ func TestRun(t *testing.T) {
 	serviceRunner.AssertExpectations(t)
 	clientRunner.AssertExpectations(t)
 }
+
+func TestNewAgentInfo(t *testing.T) {
+	hostname, err := os.Hostname()
+	require.NoError(t, err)
+
+	info := newAgentInfo(&agentconfig.AgentConfig{BuildVersion: "v1.2.0"})
+	assert.Equal(t, hostname, info.ID, "agent id should default to hostname")
+	assert.Equal(t, hostname, info.Hostname)
+	assert.Equal(t, "v1.2.0", info.Version)
+	assert.Equal(t, runtime.GOOS+"/"+runtime.GOARCH, info.OS)
+
+	assert.Equal(t, "host-1", newAgentInfo(&agentconfig.AgentConfig{AgentID: "host-1"}).ID)
+}
@@ -0,0 +1,285 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	CGroupName        = "cgroup"
+	DefaultCGroupRoot = "/sys/fs/cgroup"
+
+	// cgroupUnlimited — значение лимита cgroup v2 без ограничения.
+	cgroupUnlimited = "max"
+)
+
+// CGroupV2Available сообщает, смонтирована ли в path единая иерархия cgroup v2.
+func CGroupV2Available(path string) bool {
+	_, err := os.Stat(filepath.Join(path, "cgroup.controllers"))
+	return err == nil
+}
+
+// InNestedCGroup сообщает, находится ли процесс в некорневой cgroup v2 по procRoot/self/cgroup.
+// В корневой cgroup файлы описывают весь хост, и коллектор контейнера лишь дублирует HostCollector.
+// В контейнере с собственным пространством имён cgroup процесс тоже видит себя в корне,
+// поэтому там каталог cgroup нужно задать явно.
+func InNestedCGroup(procRoot string) bool {
+	data, err := os.ReadFile(filepath.Join(procRoot, "self", "cgroup"))
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		// строка единой иерархии cgroup v2 имеет вид 0::<путь>
+		if path, ok := strings.CutPrefix(line, "0::"); ok {
+			return path != "" && path != "/"
+		}
+	}
+	return false
+}
+
+// CGroupCollector собирает потребление ресурсов контейнера из файлов cgroup v2,
+// в отличие от HostCollector учитывая лимиты контейнера, а не ресурсы хоста.
+//
+// Gauge-метрики (лимиты сообщаются, только если они заданы):
+//   - ContainerMemoryUsage, ContainerMemoryLimit — байты, ContainerMemoryUtilization — процент от лимита;
+//   - ContainerCPUUtilization — загрузка в процентах одного ядра с предыдущего опроса, ContainerCPULimit — доступные ядра;
+//   - ContainerPids, ContainerPidsLimit.
+//
+// Counter-метрики (приращения с предыдущего опроса):
+//   - ContainerCPUPeriods, ContainerCPUThrottledPeriods, ContainerCPUThrottledUsec;
+//   - ContainerOOMKills;
+//   - ContainerIOReadBytes.<dev>, ContainerIOWriteBytes.<dev>, ContainerIOReads.<dev>, ContainerIOWrites.<dev>,
+//     где <dev> — номер устройства вида 8_0.
+type CGroupCollector struct {
+	mx        sync.Mutex
+	path      string
+	now       func() time.Time
+	prevAt    time.Time
+	prevUsage uint64
+	prevRaw   map[string]uint64
+}
+
+// NewCGroupCollector создаёт коллектор для cgroup v2, смонтированной в path.
+func NewCGroupCollector(path string) *CGroupCollector {
+	return &CGroupCollector{
+		path:    path,
+		now:     time.Now,
+		prevRaw: make(map[string]uint64),
+	}
+}
+
+func (c *CGroupCollector) Name() string {
+	return CGroupName
+}
+
+// Collect читает файлы cgroup и возвращает выборку метрик контейнера.
+// Отсутствие файла отключённого контроллера возвращается ошибкой, не мешая сбору остальных.
+func (c *CGroupCollector) Collect(_ context.Context) (Sample, error) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	sample := Sample{
+		Gauges:   make(map[string]float64),
+		Counters: make(map[string]int64),
+	}
+	raw := make(map[string]uint64)
+	now := c.now()
+
+	errs := []error{
+		c.collectMemory(sample.Gauges),
+		c.collectCPU(sample.Gauges, raw, now),
+		c.collectPids(sample.Gauges),
+		c.collectIO(raw),
+		c.collectEvents(raw),
+	}
+
+	addDeltas(sample.Counters, c.prevRaw, raw)
+	c.prevRaw = raw
+
+	return sample, errors.Join(errs...)
+}
+
+// collectMemory читает memory.current и memory.max.
+func (c *CGroupCollector) collectMemory(gauges map[string]float64) error {
+	usage, err := c.readUint("memory.current")
+	if err != nil {
+		return err
+	}
+	gauges["ContainerMemoryUsage"] = float64(usage)
+
+	limit, limited, err := c.readLimit("memory.max")
+	if err != nil || !limited {
+		return err
+	}
+	gauges["ContainerMemoryLimit"] = float64(limit)
+	if limit > 0 {
+		gauges["ContainerMemoryUtilization"] = 100 * float64(usage) / float64(limit)
+	}
+	return nil
+}
+
+// collectCPU читает cpu.stat и cpu.max: загрузку, троттлинг и лимит в ядрах.
+func (c *CGroupCollector) collectCPU(gauges map[string]float64, raw map[string]uint64, now time.Time) error {
+	stat, err := c.readKeyValues("cpu.stat")
+	if err != nil {
+		return err
+	}
+	if usage, ok := stat["usage_usec"]; ok {
+		if elapsed := now.Sub(c.prevAt); !c.prevAt.IsZero() && elapsed > 0 && usage >= c.prevUsage {
+			gauges["ContainerCPUUtilization"] = 100 * float64(usage-c.prevUsage) / float64(elapsed.Microseconds())
+		}
+		c.prevAt, c.prevUsage = now, usage
+	}
+	for key, name := range map[string]string{
+		"nr_periods":     "ContainerCPUPeriods",
+		"nr_throttled":   "ContainerCPUThrottledPeriods",
+		"throttled_usec": "ContainerCPUThrottledUsec",
+	} {
+		if v, ok := stat[key]; ok {
+			raw[name] = v
+		}
+	}
+
+	// cpu.max: "$MAX $PERIOD", контроллер cpu может быть не делегирован в группу
+	data, err := os.ReadFile(filepath.Join(c.path, "cpu.max"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) != 2 {
+		return fmt.Errorf("cpu.max: invalid value %q", strings.TrimSpace(string(data)))
+	}
+	if fields[0] == cgroupUnlimited {
+		return nil
+	}
+	values, err := parseUints(fields[0], fields[1])
+	if err != nil {
+		return fmt.Errorf("cpu.max: %w", err)
+	}
+	if values[1] > 0 {
+		gauges["ContainerCPULimit"] = float64(values[0]) / float64(values[1])
+	}
+	return nil
+}
+
+// collectPids читает pids.current и pids.max.
+func (c *CGroupCollector) collectPids(gauges map[string]float64) error {
+	current, err := c.readUint("pids.current")
+	if err != nil {
+		return err
+	}
+	gauges["ContainerPids"] = float64(current)
+
+	limit, limited, err := c.readLimit("pids.max")
+	if err != nil || !limited {
+		return err
+	}
+	gauges["ContainerPidsLimit"] = float64(limit)
+	return nil
+}
+
+// collectIO читает io.stat: "8:0 rbytes=1 wbytes=2 rios=3 wios=4 dbytes=0 dios=0".
+func (c *CGroupCollector) collectIO(raw map[string]uint64) error {
+	data, err := os.ReadFile(filepath.Join(c.path, "io.stat"))
+	if err != nil {
+		return err
+	}
+	names := map[string]string{
+		"rbytes": "ContainerIOReadBytes.",
+		"wbytes": "ContainerIOWriteBytes.",
+		"rios":   "ContainerIOReads.",
+		"wios":   "ContainerIOWrites.",
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		device := strings.ReplaceAll(fields[0], ":", "_")
+		for _, field := range fields[1:] {
+			key, value, ok := strings.Cut(field, "=")
+			prefix, known := names[key]
+			if !ok || !known {
+				continue
+			}
+			v, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return fmt.Errorf("io.stat: invalid value %q", field)
+			}
+			raw[prefix+device] = v
+		}
+	}
+	return nil
+}
+
+// collectEvents читает число срабатываний OOM killer из memory.events.
+func (c *CGroupCollector) collectEvents(raw map[string]uint64) error {
+	events, err := c.readKeyValues("memory.events")
+	if err != nil {
+		return err
+	}
+	if v, ok := events["oom_kill"]; ok {
+		raw["ContainerOOMKills"] = v
+	}
+	return nil
+}
+
+func (c *CGroupCollector) readUint(name string) (uint64, error) {
+	data, err := os.ReadFile(filepath.Join(c.path, name))
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: invalid value %q", name, strings.TrimSpace(string(data)))
+	}
+	return v, nil
+}
+
+// readLimit читает лимит, возвращая false для значения "max".
+func (c *CGroupCollector) readLimit(name string) (uint64, bool, error) {
+	data, err := os.ReadFile(filepath.Join(c.path, name))
+	if err != nil {
+		return 0, false, err
+	}
+	value := strings.TrimSpace(string(data))
+	if value == cgroupUnlimited {
+		return 0, false, nil
+	}
+	v, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("%s: invalid value %q", name, value)
+	}
+	return v, true, nil
+}
+
+// readKeyValues читает плоский файл вида "key value" построчно.
+func (c *CGroupCollector) readKeyValues(name string) (map[string]uint64, error) {
+	data, err := os.ReadFile(filepath.Join(c.path, name))
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]uint64)
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		v, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid value %q", name, line)
+		}
+		values[fields[0]] = v
+	}
+	return values, nil
+}
@@ -0,0 +1,92 @@
+package collector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func cgroupFixture(name string) string {
+	return filepath.Join("testdata", "cgroup", name)
+}
+
+func TestCGroupCollector(t *testing.T) {
+	c := NewCGroupCollector(cgroupFixture("t0"))
+	now := time.Unix(1700000000, 0)
+	c.now = func() time.Time { return now }
+
+	sample, err := c.Collect(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{
+		"ContainerMemoryUsage":       104857600,
+		"ContainerMemoryLimit":       524288000,
+		"ContainerMemoryUtilization": 20,
+		"ContainerCPULimit":          2,
+		"ContainerPids":              12,
+		"ContainerPidsLimit":         1024,
+	}, sample.Gauges)
+	assert.Empty(t, sample.Counters, "first collection should only remember counter baselines")
+
+	c.path = cgroupFixture("t1")
+	now = now.Add(10 * time.Second)
+	sample, err = c.Collect(context.Background())
+	require.NoError(t, err)
+	assert.InDelta(t, 50, sample.Gauges["ContainerCPUUtilization"], 1e-9)
+	assert.InDelta(t, 30, sample.Gauges["ContainerMemoryUtilization"], 1e-9)
+	assert.Equal(t, map[string]int64{
+		"ContainerCPUPeriods":          100,
+		"ContainerCPUThrottledPeriods": 20,
+		"ContainerCPUThrottledUsec":    400000,
+		"ContainerOOMKills":            1,
+		"ContainerIOReadBytes.8_0":     2097152,
+		"ContainerIOWriteBytes.8_0":    0,
+		"ContainerIOReads.8_0":         200,
+		"ContainerIOWrites.8_0":        0,
+	}, sample.Counters)
+}
+
+func TestCGroupCollectorUnlimited(t *testing.T) {
+	sample, err := NewCGroupCollector(cgroupFixture("unlimited")).Collect(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{
+		"ContainerMemoryUsage": 209715200,
+		"ContainerPids":        3,
+	}, sample.Gauges)
+}
+
+func TestCGroupCollectorMissingController(t *testing.T) {
+	dir := t.TempDir()
+	require.False(t, CGroupV2Available(dir))
+
+	sample, err := NewCGroupCollector(dir).Collect(context.Background())
+	assert.Error(t, err)
+	assert.Empty(t, sample.Gauges)
+	assert.True(t, CGroupV2Available(cgroupFixture("t0")))
+}
+
+func TestInNestedCGroup(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    bool
+	}{
+		{name: "root cgroup", content: "0::/\n", want: false},
+		{name: "container cgroup", content: "0::/system.slice/docker-1a2b.scope\n", want: true},
+		{name: "hybrid hierarchy", content: "12:memory:/docker/1a2b\n0::/docker/1a2b\n", want: true},
+		{name: "cgroup v1 only", content: "12:memory:/docker/1a2b\n", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			require.NoError(t, os.Mkdir(filepath.Join(root, "self"), 0o755))
+			require.NoError(t, os.WriteFile(filepath.Join(root, "self", "cgroup"), []byte(tt.content), 0o644))
+			assert.Equal(t, tt.want, InNestedCGroup(root))
+		})
+	}
+	assert.False(t, InNestedCGroup(t.TempDir()), "missing file")
+}
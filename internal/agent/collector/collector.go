@@ -0,0 +1,236 @@
+// Package collector описывает подключаемые источники метрик агента.
+// Каждый Collector регистрируется в Registry и опрашивается сервисом наблюдения
+// со своим интервалом и таймаутом, которые можно переопределить из конфигурации агента.
+package collector
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+)
+
+var (
+	ErrDuplicateCollector = errors.New("collector already registered")
+	ErrUnknownCollector   = errors.New("unknown collector")
+)
+
+// Collector — источник метрик агента.
+// Name возвращает уникальное имя, под которым коллектор упоминается в конфигурации.
+// Collect должен учитывать отмену контекста: по истечении таймаута результат отбрасывается.
+// При частичном сбое Collect возвращает собранную часть выборки вместе с ошибкой.
+type Collector interface {
+	Name() string
+	Collect(ctx context.Context) (Sample, error)
+}
+
+// Sample — результат одного опроса коллектора.
+// Counters содержит приращения счётчиков с предыдущего опроса, а не накопленные значения.
+type Sample struct {
+	Gauges   map[string]float64
+	Counters map[string]int64
+}
+
+// Options задаёт расписание опроса коллектора.
+// Нулевой Interval означает интервал опроса агента по умолчанию, нулевой Timeout — равный интервалу.
+type Options struct {
+	Interval time.Duration
+	Timeout  time.Duration
+}
+
+// Entry связывает коллектор с его расписанием.
+type Entry struct {
+	Collector Collector
+	Options   Options
+}
+
+// Registry хранит зарегистрированные коллекторы в порядке регистрации.
+type Registry struct {
+	entries []Entry
+	names   map[string]struct{}
+	// aliases — прежние имена коллекторов, которые настройки могут использовать вместо текущих
+	aliases map[string]string
+}
+
+// NewRegistry создаёт пустой реестр коллекторов.
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]struct{}), aliases: make(map[string]string)}
+}
+
+// Register добавляет коллектор с расписанием по умолчанию.
+// Возвращает ErrDuplicateCollector, если коллектор с таким именем уже зарегистрирован.
+func (r *Registry) Register(c Collector, opts Options) error {
+	if _, ok := r.names[c.Name()]; ok {
+		return fmt.Errorf("%w: %s", ErrDuplicateCollector, c.Name())
+	}
+	r.names[c.Name()] = struct{}{}
+	r.entries = append(r.entries, Entry{Collector: c, Options: opts})
+	return nil
+}
+
+// Alias разрешает ссылаться в настройках на зарегистрированный коллектор name по имени alias.
+// Возвращает ErrDuplicateCollector, если имя alias уже занято, и ErrUnknownCollector, если коллектор name не зарегистрирован.
+func (r *Registry) Alias(alias, name string) error {
+	if _, ok := r.names[alias]; ok {
+		return fmt.Errorf("%w: %s", ErrDuplicateCollector, alias)
+	}
+	if _, ok := r.aliases[alias]; ok {
+		return fmt.Errorf("%w: %s", ErrDuplicateCollector, alias)
+	}
+	if _, ok := r.names[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownCollector, name)
+	}
+	r.aliases[alias] = name
+	return nil
+}
+
+// Default возвращает реестр со встроенными коллекторами агента.
+// На Linux системные метрики собирает HostCollector, а прежнее имя system остаётся его псевдонимом,
+// чтобы существующие настройки коллекторов продолжали работать.
+func Default() *Registry {
+	r := NewRegistry()
+	_ = r.Register(NewRuntimeCollector(), Options{})
+	if runtime.GOOS == "linux" {
+		_ = r.Register(NewHostCollector(DefaultProcRoot), Options{})
+		_ = r.Alias(SystemName, HostName)
+	} else {
+		_ = r.Register(NewSystemCollector(), Options{})
+	}
+	return r
+}
+
+// Select возвращает включённые коллекторы с расписанием, переопределённым настройками.
+// Возвращает ErrUnknownCollector, если настройки ссылаются на незарегистрированное имя.
+func (r *Registry) Select(s Settings) ([]Entry, error) {
+	s = s.resolve(r.aliases)
+	for _, name := range s.names() {
+		if _, ok := r.names[name]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownCollector, name)
+		}
+	}
+
+	selected := make([]Entry, 0, len(r.entries))
+	for _, e := range r.entries {
+		name := e.Collector.Name()
+		if s.Enabled != nil {
+			if _, ok := s.Enabled[name]; !ok {
+				continue
+			}
+		}
+		if d, ok := s.Intervals[name]; ok {
+			e.Options.Interval = d
+		}
+		if d, ok := s.Timeouts[name]; ok {
+			e.Options.Timeout = d
+		}
+		selected = append(selected, e)
+	}
+	return selected, nil
+}
+
+// Settings — разобранные настройки коллекторов из конфигурации агента.
+// Enabled равный nil означает, что включены все зарегистрированные коллекторы.
+type Settings struct {
+	Enabled   map[string]struct{}
+	Intervals map[string]time.Duration
+	Timeouts  map[string]time.Duration
+}
+
+// ParseSettings разбирает настройки коллекторов.
+// enabled — список имён через запятую, intervals и timeouts — пары name=duration через запятую,
+// например "runtime=2s,system=10s".
+func ParseSettings(enabled, intervals, timeouts string) (Settings, error) {
+	s := Settings{}
+	if strings.TrimSpace(enabled) != "" {
+		s.Enabled = make(map[string]struct{})
+		for _, name := range strings.Split(enabled, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			s.Enabled[name] = struct{}{}
+		}
+	}
+
+	var err error
+	if s.Intervals, err = parseDurations(intervals); err != nil {
+		return Settings{}, err
+	}
+	if s.Timeouts, err = parseDurations(timeouts); err != nil {
+		return Settings{}, err
+	}
+	return s, nil
+}
+
+// resolve заменяет псевдонимы в настройках именами коллекторов.
+// Явная настройка коллектора по текущему имени имеет приоритет над настройкой по псевдониму.
+func (s Settings) resolve(aliases map[string]string) Settings {
+	if len(aliases) == 0 {
+		return s
+	}
+	resolved := Settings{
+		Intervals: resolveDurations(s.Intervals, aliases),
+		Timeouts:  resolveDurations(s.Timeouts, aliases),
+	}
+	if s.Enabled != nil {
+		resolved.Enabled = make(map[string]struct{}, len(s.Enabled))
+		for name := range s.Enabled {
+			resolved.Enabled[cmp.Or(aliases[name], name)] = struct{}{}
+		}
+	}
+	return resolved
+}
+
+func resolveDurations(durations map[string]time.Duration, aliases map[string]string) map[string]time.Duration {
+	if durations == nil {
+		return nil
+	}
+	resolved := make(map[string]time.Duration, len(durations))
+	for name, d := range durations {
+		if target, ok := aliases[name]; ok {
+			if _, explicit := durations[target]; !explicit {
+				resolved[target] = d
+			}
+			continue
+		}
+		resolved[name] = d
+	}
+	return resolved
+}
+
+func (s Settings) names() []string {
+	names := make([]string, 0, len(s.Enabled)+len(s.Intervals)+len(s.Timeouts))
+	for name := range s.Enabled {
+		names = append(names, name)
+	}
+	for name := range s.Intervals {
+		names = append(names, name)
+	}
+	for name := range s.Timeouts {
+		names = append(names, name)
+	}
+	return names
+}
+
+func parseDurations(list string) (map[string]time.Duration, error) {
+	durations := make(map[string]time.Duration)
+	for _, pair := range strings.Split(list, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid collector setting: %s", pair)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid collector duration: %s", pair)
+		}
+		durations[strings.TrimSpace(name)] = d
+	}
+	return durations, nil
+}
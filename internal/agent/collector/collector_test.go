@@ -0,0 +1,137 @@
+package collector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubCollector struct {
+	name string
+}
+
+func (c stubCollector) Name() string {
+	return c.name
+}
+
+func (c stubCollector) Collect(_ context.Context) (Sample, error) {
+	return Sample{Gauges: map[string]float64{c.name: 1}}, nil
+}
+
+func TestRegistryRegister(t *testing.T) {
+	r := NewRegistry()
+	require.NoError(t, r.Register(stubCollector{name: "a"}, Options{}))
+	assert.ErrorIs(t, r.Register(stubCollector{name: "a"}, Options{}), ErrDuplicateCollector)
+}
+
+func TestRegistrySelect(t *testing.T) {
+	r := NewRegistry()
+	require.NoError(t, r.Register(stubCollector{name: "a"}, Options{Interval: time.Second}))
+	require.NoError(t, r.Register(stubCollector{name: "b"}, Options{}))
+
+	tests := []struct {
+		name      string
+		settings  Settings
+		expected  []string
+		intervals []time.Duration
+		wantErr   error
+	}{
+		{
+			name:      "All enabled by default",
+			expected:  []string{"a", "b"},
+			intervals: []time.Duration{time.Second, 0},
+		},
+		{
+			name:      "Only enabled",
+			settings:  Settings{Enabled: map[string]struct{}{"b": {}}},
+			expected:  []string{"b"},
+			intervals: []time.Duration{0},
+		},
+		{
+			name:      "Interval override",
+			settings:  Settings{Intervals: map[string]time.Duration{"a": 5 * time.Second}},
+			expected:  []string{"a", "b"},
+			intervals: []time.Duration{5 * time.Second, 0},
+		},
+		{
+			name:     "Unknown collector",
+			settings: Settings{Timeouts: map[string]time.Duration{"c": time.Second}},
+			wantErr:  ErrUnknownCollector,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := r.Select(tt.settings)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			names := make([]string, 0, len(entries))
+			intervals := make([]time.Duration, 0, len(entries))
+			for _, e := range entries {
+				names = append(names, e.Collector.Name())
+				intervals = append(intervals, e.Options.Interval)
+			}
+			assert.Equal(t, tt.expected, names)
+			assert.Equal(t, tt.intervals, intervals)
+		})
+	}
+}
+
+func TestParseSettings(t *testing.T) {
+	s, err := ParseSettings(" runtime, system ", "runtime=2s, system=1m", "system=500ms")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]struct{}{"runtime": {}, "system": {}}, s.Enabled)
+	assert.Equal(t, map[string]time.Duration{"runtime": 2 * time.Second, "system": time.Minute}, s.Intervals)
+	assert.Equal(t, map[string]time.Duration{"system": 500 * time.Millisecond}, s.Timeouts)
+
+	s, err = ParseSettings("", "", "")
+	require.NoError(t, err)
+	assert.Nil(t, s.Enabled, "empty list should enable all collectors")
+
+	_, err = ParseSettings("", "runtime", "")
+	assert.Error(t, err, "pair without duration should fail")
+	_, err = ParseSettings("", "", "runtime=-1s")
+	assert.Error(t, err, "negative duration should fail")
+}
+
+func TestRegistryAlias(t *testing.T) {
+	r := NewRegistry()
+	require.NoError(t, r.Register(stubCollector{name: "host"}, Options{}))
+	require.NoError(t, r.Alias("system", "host"))
+	assert.ErrorIs(t, r.Alias("host", "host"), ErrDuplicateCollector)
+	assert.ErrorIs(t, r.Alias("legacy", "missing"), ErrUnknownCollector)
+
+	s, err := ParseSettings("system", "system=10s", "system=1s,host=2s")
+	require.NoError(t, err)
+	entries, err := r.Select(s)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "host", entries[0].Collector.Name())
+	assert.Equal(t, 10*time.Second, entries[0].Options.Interval)
+	assert.Equal(t, 2*time.Second, entries[0].Options.Timeout, "setting by current name wins over alias")
+}
+
+func TestDefaultCollectors(t *testing.T) {
+	entries, err := Default().Select(Settings{})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, RuntimeName, entries[0].Collector.Name())
+	assert.Contains(t, []string{HostName, SystemName}, entries[1].Collector.Name())
+
+	legacy, err := ParseSettings("runtime,system", "system=10s", "")
+	require.NoError(t, err)
+	entries, err = Default().Select(legacy)
+	require.NoError(t, err, "system should stay a valid collector name")
+	require.Len(t, entries, 2)
+	assert.Equal(t, 10*time.Second, entries[1].Options.Interval)
+
+	sample, err := NewRuntimeCollector().Collect(context.Background())
+	require.NoError(t, err)
+	assert.Contains(t, sample.Gauges, "HeapAlloc")
+}
@@ -0,0 +1,395 @@
+package collector
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/shirou/gopsutil/disk"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	HostName        = "host"
+	DefaultProcRoot = "/proc"
+
+	// sectorSize — размер сектора в /proc/diskstats, не зависящий от устройства.
+	sectorSize = 512
+	kibibyte   = 1024
+)
+
+// UsageFunc возвращает общий и свободный объём файловой системы, смонтированной в path.
+type UsageFunc func(ctx context.Context, path string) (total, free uint64, err error)
+
+// HostCollector собирает метрики хоста из procfs: общую загрузку CPU и загрузку каждого ядра, load average,
+// память и swap, число процессов и потоков, заполненность и ввод-вывод дисков, трафик сетевых интерфейсов.
+//
+// Gauge-метрики:
+//   - TotalMemory, FreeMemory, AvailableMemory, SwapTotal, SwapFree — байты;
+//   - CPUutilization1 — общая загрузка всех ядер в процентах с предыдущего опроса, как у SystemCollector;
+//   - CPUutilization.cpu<N> — загрузка ядра N (с нуля, как в /proc/stat) в процентах с предыдущего опроса;
+//   - LoadAverage1, LoadAverage5, LoadAverage15;
+//   - ProcessCount, ThreadCount, ProcessesRunning, ProcessesBlocked;
+//   - DiskTotal.<mount>, DiskFree.<mount>, DiskUsed.<mount> — байты, "/" и прочие спецсимволы точки монтирования заменяются на "_", корень — "root".
+//
+// Counter-метрики (приращения с предыдущего опроса, первый опрос только запоминает исходные значения):
+//   - DiskReadBytes.<device>, DiskWriteBytes.<device>, DiskReads.<device>, DiskWrites.<device>;
+//   - NetBytesRecv.<iface>, NetBytesSent.<iface>, NetPacketsRecv.<iface>, NetPacketsSent.<iface>,
+//     NetErrorsIn.<iface>, NetErrorsOut.<iface>.
+type HostCollector struct {
+	mx       sync.Mutex
+	procRoot string
+	usage    UsageFunc
+	prevCPU  map[int]cpuTimes
+	prevRaw  map[string]uint64
+}
+
+// NewHostCollector создаёт коллектор, читающий procfs из каталога procRoot.
+// Заполненность файловых систем определяется через statfs.
+func NewHostCollector(procRoot string) *HostCollector {
+	return &HostCollector{
+		procRoot: procRoot,
+		usage: func(ctx context.Context, path string) (uint64, uint64, error) {
+			u, err := disk.UsageWithContext(ctx, path)
+			if err != nil {
+				return 0, 0, err
+			}
+			return u.Total, u.Free, nil
+		},
+		prevCPU: make(map[int]cpuTimes),
+		prevRaw: make(map[string]uint64),
+	}
+}
+
+// RegisterUsage заменяет способ определения заполненности файловых систем.
+func (c *HostCollector) RegisterUsage(usage UsageFunc) {
+	c.usage = usage
+}
+
+func (c *HostCollector) Name() string {
+	return HostName
+}
+
+// Collect читает procfs и возвращает выборку метрик хоста.
+// Ошибка чтения одного источника не прерывает сбор остальных: они возвращаются вместе с объединённой ошибкой.
+func (c *HostCollector) Collect(ctx context.Context) (Sample, error) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	sample := Sample{
+		Gauges:   make(map[string]float64),
+		Counters: make(map[string]int64),
+	}
+	raw := make(map[string]uint64)
+
+	errs := []error{
+		c.collectStat(sample.Gauges),
+		c.collectLoadAvg(sample.Gauges),
+		c.collectMemInfo(sample.Gauges),
+		c.collectProcesses(sample.Gauges),
+		c.collectDiskUsage(ctx, sample.Gauges),
+		c.collectDiskStats(raw),
+		c.collectNetDev(raw),
+	}
+
+	addDeltas(sample.Counters, c.prevRaw, raw)
+	c.prevRaw = raw
+
+	return sample, errors.Join(errs...)
+}
+
+// addDeltas записывает в counters приращения накопленных значений cur относительно prev.
+// Значения без предыдущего отсчёта пропускаются, уменьшение считается сбросом счётчика.
+func addDeltas(counters map[string]int64, prev, cur map[string]uint64) {
+	for name, value := range cur {
+		before, ok := prev[name]
+		if !ok {
+			continue
+		}
+		delta := value - before
+		if value < before {
+			// счётчик сброшен, например при пересоздании интерфейса
+			delta = value
+		}
+		counters[name] = int64(delta)
+	}
+}
+
+type cpuTimes struct {
+	busy  uint64
+	total uint64
+}
+
+// totalCPU — ключ prevCPU для суммарного времени всех ядер.
+const totalCPU = -1
+
+// collectStat читает /proc/stat: общую загрузку CPU, загрузку каждого ядра и число выполняющихся и заблокированных процессов.
+func (c *HostCollector) collectStat(gauges map[string]float64) error {
+	return c.scan("stat", func(fields []string) error {
+		switch {
+		case strings.HasPrefix(fields[0], "cpu"):
+			// строка cpu — сумма по всем ядрам, её загрузка сохраняет прежнее имя CPUutilization1
+			n, name := totalCPU, "CPUutilization1"
+			if fields[0] != "cpu" {
+				var err error
+				if n, err = strconv.Atoi(strings.TrimPrefix(fields[0], "cpu")); err != nil {
+					return fmt.Errorf("invalid cpu line %q", fields[0])
+				}
+				name = "CPUutilization." + fields[0]
+			}
+			cur, err := parseCPUTimes(fields[1:])
+			if err != nil {
+				return err
+			}
+			prev := c.prevCPU[n]
+			c.prevCPU[n] = cur
+			if cur.total > prev.total {
+				gauges[name] = 100 * float64(cur.busy-prev.busy) / float64(cur.total-prev.total)
+			}
+		case fields[0] == "procs_running" && len(fields) > 1:
+			return setGauge(gauges, "ProcessesRunning", fields[1])
+		case fields[0] == "procs_blocked" && len(fields) > 1:
+			return setGauge(gauges, "ProcessesBlocked", fields[1])
+		}
+		return nil
+	})
+}
+
+// parseCPUTimes суммирует время ядра в jiffies; idle и iowait считаются простоем,
+// guest и guest_nice уже входят в user и nice и не учитываются повторно.
+func parseCPUTimes(fields []string) (cpuTimes, error) {
+	if len(fields) < 4 {
+		return cpuTimes{}, errors.New("invalid cpu line")
+	}
+	var t cpuTimes
+	for i, f := range fields {
+		if i >= 8 {
+			break
+		}
+		v, err := strconv.ParseUint(f, 10, 64)
+		if err != nil {
+			return cpuTimes{}, fmt.Errorf("invalid cpu time %q", f)
+		}
+		t.total += v
+		if i != 3 && i != 4 {
+			t.busy += v
+		}
+	}
+	return t, nil
+}
+
+// collectLoadAvg читает /proc/loadavg: средние нагрузки и общее число потоков.
+func (c *HostCollector) collectLoadAvg(gauges map[string]float64) error {
+	return c.scan("loadavg", func(fields []string) error {
+		if len(fields) < 4 {
+			return errors.New("invalid loadavg")
+		}
+		for i, name := range []string{"LoadAverage1", "LoadAverage5", "LoadAverage15"} {
+			if err := setGauge(gauges, name, fields[i]); err != nil {
+				return err
+			}
+		}
+		_, threads, ok := strings.Cut(fields[3], "/")
+		if !ok {
+			return fmt.Errorf("invalid loadavg entities %q", fields[3])
+		}
+		return setGauge(gauges, "ThreadCount", threads)
+	})
+}
+
+// collectMemInfo читает /proc/meminfo, переводя значения из КиБ в байты.
+func (c *HostCollector) collectMemInfo(gauges map[string]float64) error {
+	names := map[string]string{
+		"MemTotal:":     "TotalMemory",
+		"MemFree:":      "FreeMemory",
+		"MemAvailable:": "AvailableMemory",
+		"SwapTotal:":    "SwapTotal",
+		"SwapFree:":     "SwapFree",
+	}
+	return c.scan("meminfo", func(fields []string) error {
+		name, ok := names[fields[0]]
+		if !ok || len(fields) < 2 {
+			return nil
+		}
+		v, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid meminfo value %q", fields[1])
+		}
+		gauges[name] = float64(v * kibibyte)
+		return nil
+	})
+}
+
+// collectProcesses считает процессы по числовым каталогам procfs.
+func (c *HostCollector) collectProcesses(gauges map[string]float64) error {
+	entries, err := os.ReadDir(c.procRoot)
+	if err != nil {
+		return err
+	}
+	count := 0
+	for _, e := range entries {
+		if _, err := strconv.Atoi(e.Name()); err == nil && e.IsDir() {
+			count++
+		}
+	}
+	gauges["ProcessCount"] = float64(count)
+	return nil
+}
+
+// collectDiskUsage определяет заполненность файловых систем блочных устройств из /proc/mounts.
+func (c *HostCollector) collectDiskUsage(ctx context.Context, gauges map[string]float64) error {
+	seen := make(map[string]struct{})
+	var errs []error
+	err := c.scan("mounts", func(fields []string) error {
+		if len(fields) < 2 || !strings.HasPrefix(fields[0], "/dev/") {
+			return nil
+		}
+		mount := unescapeMount(fields[1])
+		if _, ok := seen[mount]; ok {
+			return nil
+		}
+		seen[mount] = struct{}{}
+
+		total, free, err := c.usage(ctx, mount)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("disk usage %s: %w", mount, err))
+			return nil
+		}
+		suffix := mountName(mount)
+		gauges["DiskTotal."+suffix] = float64(total)
+		gauges["DiskFree."+suffix] = float64(free)
+		gauges["DiskUsed."+suffix] = float64(total - free)
+		return nil
+	})
+	return errors.Join(append(errs, err)...)
+}
+
+// collectDiskStats читает накопленные счётчики ввода-вывода из /proc/diskstats,
+// пропуская loop- и ram-устройства.
+func (c *HostCollector) collectDiskStats(raw map[string]uint64) error {
+	return c.scan("diskstats", func(fields []string) error {
+		if len(fields) < 10 {
+			return nil
+		}
+		device := fields[2]
+		if strings.HasPrefix(device, "loop") || strings.HasPrefix(device, "ram") {
+			return nil
+		}
+		values, err := parseUints(fields[3], fields[5], fields[7], fields[9])
+		if err != nil {
+			return fmt.Errorf("diskstats %s: %w", device, err)
+		}
+		raw["DiskReads."+device] = values[0]
+		raw["DiskReadBytes."+device] = values[1] * sectorSize
+		raw["DiskWrites."+device] = values[2]
+		raw["DiskWriteBytes."+device] = values[3] * sectorSize
+		return nil
+	})
+}
+
+// collectNetDev читает накопленные счётчики сетевых интерфейсов из /proc/net/dev.
+func (c *HostCollector) collectNetDev(raw map[string]uint64) error {
+	return c.scan(filepath.Join("net", "dev"), func(fields []string) error {
+		if !strings.HasSuffix(fields[0], ":") || len(fields) < 12 {
+			return nil
+		}
+		iface := strings.TrimSuffix(fields[0], ":")
+		values, err := parseUints(fields[1], fields[2], fields[3], fields[9], fields[10], fields[11])
+		if err != nil {
+			return fmt.Errorf("net/dev %s: %w", iface, err)
+		}
+		raw["NetBytesRecv."+iface] = values[0]
+		raw["NetPacketsRecv."+iface] = values[1]
+		raw["NetErrorsIn."+iface] = values[2]
+		raw["NetBytesSent."+iface] = values[3]
+		raw["NetPacketsSent."+iface] = values[4]
+		raw["NetErrorsOut."+iface] = values[5]
+		return nil
+	})
+}
+
+// scan построчно разбирает файл procfs на поля и передаёт непустые строки в handle.
+func (c *HostCollector) scan(name string, handle func(fields []string) error) error {
+	f, err := os.Open(filepath.Join(c.procRoot, name))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		// в /proc/net/dev имя интерфейса может не отделяться пробелом от первого значения
+		if i := strings.IndexByte(line, ':'); i >= 0 && name == filepath.Join("net", "dev") {
+			line = line[:i+1] + " " + line[i+1:]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if err = handle(fields); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return scanner.Err()
+}
+
+func setGauge(gauges map[string]float64, name, value string) error {
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("invalid %s value %q", name, value)
+	}
+	gauges[name] = v
+	return nil
+}
+
+func parseUints(values ...string) ([]uint64, error) {
+	parsed := make([]uint64, len(values))
+	for i, v := range values {
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q", v)
+		}
+		parsed[i] = n
+	}
+	return parsed, nil
+}
+
+// unescapeMount раскрывает восьмеричные escape-последовательности (\040 и т.п.) в путях /proc/mounts.
+func unescapeMount(path string) string {
+	if !strings.Contains(path, `\`) {
+		return path
+	}
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		if path[i] == '\\' && i+4 <= len(path) {
+			if v, err := strconv.ParseUint(path[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(path[i])
+	}
+	return b.String()
+}
+
+// mountName превращает точку монтирования в суффикс имени метрики,
+// заменяя "/" и прочие символы вне [A-Za-z0-9.-] на "_".
+func mountName(mount string) string {
+	name := strings.Trim(mount, "/")
+	if name == "" {
+		return "root"
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-':
+			return r
+		}
+		return '_'
+	}, name)
+}
@@ -0,0 +1,119 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFixtureHostCollector(snapshot string) *HostCollector {
+	c := NewHostCollector(filepath.Join("testdata", "host", snapshot))
+	c.RegisterUsage(func(_ context.Context, path string) (uint64, uint64, error) {
+		switch path {
+		case "/":
+			return 100, 40, nil
+		case "/var/lib data":
+			return 200, 50, nil
+		}
+		return 0, 0, errors.New("unexpected mount")
+	})
+	return c
+}
+
+func TestHostCollectorGauges(t *testing.T) {
+	c := newFixtureHostCollector("t0")
+
+	sample, err := c.Collect(context.Background())
+	require.NoError(t, err)
+
+	expected := map[string]float64{
+		"TotalMemory":            8000000 * 1024,
+		"FreeMemory":             2000000 * 1024,
+		"AvailableMemory":        5000000 * 1024,
+		"SwapTotal":              1000000 * 1024,
+		"SwapFree":               900000 * 1024,
+		"CPUutilization1":        30,
+		"CPUutilization.cpu0":    30,
+		"CPUutilization.cpu1":    30,
+		"LoadAverage1":           0.5,
+		"LoadAverage5":           0.4,
+		"LoadAverage15":          0.3,
+		"ProcessCount":           2,
+		"ThreadCount":            345,
+		"ProcessesRunning":       2,
+		"ProcessesBlocked":       0,
+		"DiskTotal.root":         100,
+		"DiskFree.root":          40,
+		"DiskUsed.root":          60,
+		"DiskTotal.var_lib_data": 200,
+		"DiskFree.var_lib_data":  50,
+		"DiskUsed.var_lib_data":  150,
+	}
+	assert.Equal(t, expected, sample.Gauges)
+	assert.Empty(t, sample.Counters, "first collection should only remember counter baselines")
+}
+
+func TestHostCollectorDeltas(t *testing.T) {
+	c := newFixtureHostCollector("t0")
+	_, err := c.Collect(context.Background())
+	require.NoError(t, err)
+
+	c.procRoot = filepath.Join("testdata", "host", "t1")
+	sample, err := c.Collect(context.Background())
+	require.NoError(t, err)
+
+	assert.InDelta(t, 30, sample.Gauges["CPUutilization1"], 1e-9, "total CPU keeps the old name")
+	assert.InDelta(t, 50, sample.Gauges["CPUutilization.cpu0"], 1e-9)
+	assert.InDelta(t, 62.5, sample.Gauges["CPUutilization.cpu1"], 1e-9)
+	assert.Equal(t, 1.5, sample.Gauges["LoadAverage1"])
+	assert.Equal(t, 1.0, sample.Gauges["ProcessesBlocked"])
+
+	expected := map[string]int64{
+		"DiskReads.sda":       100,
+		"DiskReadBytes.sda":   2000 * 512,
+		"DiskWrites.sda":      500,
+		"DiskWriteBytes.sda":  10000 * 512,
+		"DiskReads.sda1":      90,
+		"DiskReadBytes.sda1":  1800 * 512,
+		"DiskWrites.sda1":     490,
+		"DiskWriteBytes.sda1": 9800 * 512,
+		"NetBytesRecv.lo":     1000,
+		"NetPacketsRecv.lo":   10,
+		"NetErrorsIn.lo":      0,
+		"NetBytesSent.lo":     1000,
+		"NetPacketsSent.lo":   10,
+		"NetErrorsOut.lo":     0,
+		"NetBytesRecv.eth0":   500000,
+		"NetPacketsRecv.eth0": 400,
+		"NetErrorsIn.eth0":    2,
+		"NetBytesSent.eth0":   200000, // счётчик сброшен
+		"NetPacketsSent.eth0": 100,
+		"NetErrorsOut.eth0":   2,
+	}
+	assert.Equal(t, expected, sample.Counters)
+}
+
+func TestHostCollectorPartialFailure(t *testing.T) {
+	c := newFixtureHostCollector("t0")
+	c.RegisterUsage(func(context.Context, string) (uint64, uint64, error) {
+		return 0, 0, errors.New("statfs failed")
+	})
+
+	sample, err := c.Collect(context.Background())
+	assert.ErrorContains(t, err, "statfs failed")
+	assert.Equal(t, 0.5, sample.Gauges["LoadAverage1"], "other sources should still be collected")
+	assert.NotContains(t, sample.Gauges, "DiskTotal.root")
+
+	_, err = NewHostCollector(filepath.Join("testdata", "missing")).Collect(context.Background())
+	assert.Error(t, err)
+}
+
+func TestMountName(t *testing.T) {
+	assert.Equal(t, "root", mountName("/"))
+	assert.Equal(t, "var_lib_docker", mountName("/var/lib/docker"))
+	assert.Equal(t, "/mnt/my disk", unescapeMount(`/mnt/my\040disk`))
+}
@@ -0,0 +1,344 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ProcessName = "process"
+
+	// clockTicks — USER_HZ, единица времени CPU в /proc/<pid>/stat, на Linux всегда 100.
+	clockTicks = 100
+)
+
+// ProcessTarget описывает отслеживаемый сервис.
+// Процессы выбираются ровно одним способом: по PID-файлу, по регулярному выражению
+// для имени и командной строки либо по cgroup (с учётом вложенных групп).
+type ProcessTarget struct {
+	Name    string
+	PIDFile string
+	Pattern *regexp.Regexp
+	CGroup  string
+}
+
+// ParseProcessTargets разбирает список отслеживаемых сервисов вида
+// "nginx=pidfile:/run/nginx.pid;api=pattern:^api-server;db=cgroup:/system.slice/postgresql.service".
+// Записи разделяются ";", так как регулярные выражения могут содержать запятые.
+func ParseProcessTargets(list string) ([]ProcessTarget, error) {
+	var targets []ProcessTarget
+	seen := make(map[string]struct{})
+	for _, item := range strings.Split(list, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, spec, ok := strings.Cut(item, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid process target: %s", item)
+		}
+		if _, ok = seen[name]; ok {
+			return nil, fmt.Errorf("duplicate process target: %s", name)
+		}
+		seen[name] = struct{}{}
+
+		kind, value, ok := strings.Cut(spec, ":")
+		if !ok || value == "" {
+			return nil, fmt.Errorf("invalid process target: %s", item)
+		}
+		target := ProcessTarget{Name: name}
+		switch kind {
+		case "pidfile":
+			target.PIDFile = value
+		case "pattern":
+			re, err := regexp.Compile(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid process pattern %s: %w", name, err)
+			}
+			target.Pattern = re
+		case "cgroup":
+			target.CGroup = "/" + strings.Trim(value, "/")
+		default:
+			return nil, fmt.Errorf("unknown process selector %q in %s", kind, item)
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+// ProcessCollector собирает метрики отслеживаемых сервисов из procfs.
+// Если под цель подходит несколько процессов, их значения суммируются.
+//
+// Gauge-метрики:
+//   - ProcessInstances.<name> — число найденных процессов, 0 означает, что сервис не запущен;
+//   - ProcessCPU.<name> — загрузка CPU в процентах одного ядра с предыдущего опроса;
+//   - ProcessRSS.<name> — резидентная память в байтах;
+//   - ProcessOpenFDs.<name> — число открытых файловых дескрипторов;
+//   - ProcessThreads.<name> — число потоков.
+//
+// Counter-метрики:
+//   - ProcessRestarts.<name> — перезапуски с предыдущего опроса: процесс исчез, а вместо него появился новый.
+type ProcessCollector struct {
+	mx       sync.Mutex
+	procRoot string
+	targets  []ProcessTarget
+	now      func() time.Time
+	prev     map[string]processSnapshot
+}
+
+// processSnapshot запоминает процессы цели на момент опроса.
+type processSnapshot struct {
+	at    time.Time
+	ticks map[processID]uint64
+}
+
+// processID отличает процесс от более позднего процесса с тем же PID.
+type processID struct {
+	pid       int
+	startTime uint64
+}
+
+type processStat struct {
+	id      processID
+	ticks   uint64
+	rss     uint64
+	threads uint64
+	fds     int
+}
+
+// NewProcessCollector создаёт коллектор сервисов targets, читающий procfs из каталога procRoot.
+func NewProcessCollector(procRoot string, targets []ProcessTarget) *ProcessCollector {
+	return &ProcessCollector{
+		procRoot: procRoot,
+		targets:  targets,
+		now:      time.Now,
+		prev:     make(map[string]processSnapshot),
+	}
+}
+
+func (c *ProcessCollector) Name() string {
+	return ProcessName
+}
+
+// Collect находит процессы каждой цели и возвращает их суммарные метрики.
+// Ошибка поиска одной цели не мешает сбору остальных.
+func (c *ProcessCollector) Collect(ctx context.Context) (Sample, error) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	sample := Sample{
+		Gauges:   make(map[string]float64),
+		Counters: make(map[string]int64),
+	}
+	now := c.now()
+
+	pids, err := c.pids()
+	if err != nil {
+		return sample, err
+	}
+
+	var errs []error
+	for _, target := range c.targets {
+		if err = ctx.Err(); err != nil {
+			return sample, err
+		}
+		matched, err := c.match(target, pids)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("process %s: %w", target.Name, err))
+		}
+		c.report(target.Name, matched, now, sample)
+	}
+	return sample, errors.Join(errs...)
+}
+
+// report сводит метрики найденных процессов цели и обновляет её снимок.
+func (c *ProcessCollector) report(name string, matched []processStat, now time.Time, sample Sample) {
+	var rss, threads, fds float64
+	snapshot := processSnapshot{at: now, ticks: make(map[processID]uint64, len(matched))}
+	for _, p := range matched {
+		rss += float64(p.rss)
+		threads += float64(p.threads)
+		fds += float64(p.fds)
+		snapshot.ticks[p.id] = p.ticks
+	}
+	sample.Gauges["ProcessInstances."+name] = float64(len(matched))
+	sample.Gauges["ProcessRSS."+name] = rss
+	sample.Gauges["ProcessThreads."+name] = threads
+	sample.Gauges["ProcessOpenFDs."+name] = fds
+
+	prev, ok := c.prev[name]
+	c.prev[name] = snapshot
+	if !ok {
+		return
+	}
+
+	var used uint64
+	appeared := 0
+	for id, ticks := range snapshot.ticks {
+		before, ok := prev.ticks[id]
+		if !ok {
+			appeared++
+			continue
+		}
+		if ticks > before {
+			used += ticks - before
+		}
+	}
+	if elapsed := now.Sub(prev.at).Seconds(); elapsed > 0 {
+		sample.Gauges["ProcessCPU."+name] = 100 * float64(used) / clockTicks / elapsed
+	}
+
+	vanished := 0
+	for id := range prev.ticks {
+		if _, ok := snapshot.ticks[id]; !ok {
+			vanished++
+		}
+	}
+	sample.Counters["ProcessRestarts."+name] = int64(min(appeared, vanished))
+}
+
+// pids возвращает PID всех процессов из procfs.
+func (c *ProcessCollector) pids() ([]int, error) {
+	entries, err := os.ReadDir(c.procRoot)
+	if err != nil {
+		return nil, err
+	}
+	pids := make([]int, 0, len(entries))
+	for _, e := range entries {
+		if pid, err := strconv.Atoi(e.Name()); err == nil && e.IsDir() {
+			pids = append(pids, pid)
+		}
+	}
+	return pids, nil
+}
+
+// match выбирает процессы цели и читает их статистику.
+// Процессы, завершившиеся во время чтения, пропускаются.
+func (c *ProcessCollector) match(target ProcessTarget, pids []int) ([]processStat, error) {
+	if target.PIDFile != "" {
+		data, err := os.ReadFile(target.PIDFile)
+		if err != nil {
+			return nil, err
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid pid file %s", target.PIDFile)
+		}
+		pids = []int{pid}
+	}
+
+	var matched []processStat
+	for _, pid := range pids {
+		ok, err := c.selects(target, pid)
+		if err != nil || !ok {
+			continue
+		}
+		stat, err := c.stat(pid)
+		if err != nil {
+			continue
+		}
+		matched = append(matched, stat)
+	}
+	return matched, nil
+}
+
+func (c *ProcessCollector) selects(target ProcessTarget, pid int) (bool, error) {
+	switch {
+	case target.Pattern != nil:
+		comm, err := c.read(pid, "comm")
+		if err != nil {
+			return false, err
+		}
+		if target.Pattern.MatchString(strings.TrimSpace(comm)) {
+			return true, nil
+		}
+		cmdline, err := c.read(pid, "cmdline")
+		if err != nil {
+			return false, err
+		}
+		return target.Pattern.MatchString(strings.TrimSpace(strings.ReplaceAll(cmdline, "\x00", " "))), nil
+	case target.CGroup != "":
+		cgroups, err := c.read(pid, "cgroup")
+		if err != nil {
+			return false, err
+		}
+		for _, line := range strings.Split(cgroups, "\n") {
+			// hierarchy-ID:controllers:path
+			parts := strings.SplitN(line, ":", 3)
+			if len(parts) != 3 {
+				continue
+			}
+			if parts[2] == target.CGroup || strings.HasPrefix(parts[2], target.CGroup+"/") {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
+// stat читает /proc/<pid>/stat, status и fd.
+func (c *ProcessCollector) stat(pid int) (processStat, error) {
+	raw, err := c.read(pid, "stat")
+	if err != nil {
+		return processStat{}, err
+	}
+	// имя процесса в скобках может содержать пробелы, поля считаются после последней ")"
+	i := strings.LastIndexByte(raw, ')')
+	if i < 0 {
+		return processStat{}, fmt.Errorf("invalid stat of %d", pid)
+	}
+	fields := strings.Fields(raw[i+1:])
+	if len(fields) < 20 {
+		return processStat{}, fmt.Errorf("invalid stat of %d", pid)
+	}
+	values, err := parseUints(fields[11], fields[12], fields[19])
+	if err != nil {
+		return processStat{}, fmt.Errorf("stat of %d: %w", pid, err)
+	}
+	p := processStat{
+		id:    processID{pid: pid, startTime: values[2]},
+		ticks: values[0] + values[1],
+	}
+
+	status, err := c.read(pid, "status")
+	if err != nil {
+		return processStat{}, err
+	}
+	for _, line := range strings.Split(status, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "VmRSS:":
+			if v, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+				p.rss = v * kibibyte
+			}
+		case "Threads:":
+			if v, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+				p.threads = v
+			}
+		}
+	}
+
+	// без прав на чужой процесс каталог fd недоступен, такие процессы учитываются без дескрипторов
+	if fds, err := os.ReadDir(filepath.Join(c.procRoot, strconv.Itoa(pid), "fd")); err == nil {
+		p.fds = len(fds)
+	}
+	return p, nil
+}
+
+func (c *ProcessCollector) read(pid int, name string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(c.procRoot, strconv.Itoa(pid), name))
+	return string(data), err
+}
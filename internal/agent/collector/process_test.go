@@ -0,0 +1,85 @@
+package collector
+
+import (
+	"context"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseProcessTargets(t *testing.T) {
+	targets, err := ParseProcessTargets("nginx=pattern:^nginx$; api=pidfile:/run/api.pid;db=cgroup:system.slice/postgresql.service/")
+	require.NoError(t, err)
+	require.Len(t, targets, 3)
+	assert.Equal(t, "nginx", targets[0].Name)
+	assert.True(t, targets[0].Pattern.MatchString("nginx"))
+	assert.Equal(t, ProcessTarget{Name: "api", PIDFile: "/run/api.pid"}, targets[1])
+	assert.Equal(t, ProcessTarget{Name: "db", CGroup: "/system.slice/postgresql.service"}, targets[2])
+
+	targets, err = ParseProcessTargets("")
+	require.NoError(t, err)
+	assert.Empty(t, targets)
+
+	for _, list := range []string{"nginx", "=pattern:x", "a=pattern:(", "a=unknown:x", "a=pidfile:", "a=pattern:x;a=pattern:y"} {
+		_, err = ParseProcessTargets(list)
+		assert.Error(t, err, list)
+	}
+}
+
+func TestProcessCollector(t *testing.T) {
+	root := func(snapshot string) string {
+		return filepath.Join("testdata", "process", snapshot)
+	}
+	targets := []ProcessTarget{
+		{Name: "nginx", Pattern: regexp.MustCompile(`^nginx$`)},
+		{Name: "api", PIDFile: filepath.Join(root("t0"), "run", "api.pid")},
+		{Name: "apigroup", CGroup: "/system.slice/api.service"},
+		{Name: "cmd", Pattern: regexp.MustCompile(`api-server --port`)},
+		{Name: "absent", Pattern: regexp.MustCompile(`^redis`)},
+	}
+	c := NewProcessCollector(root("t0"), targets)
+	now := time.Unix(1700000000, 0)
+	c.now = func() time.Time { return now }
+
+	sample, err := c.Collect(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2.0, sample.Gauges["ProcessInstances.nginx"])
+	assert.Equal(t, float64((10240+20480)*1024), sample.Gauges["ProcessRSS.nginx"])
+	assert.Equal(t, 3.0, sample.Gauges["ProcessThreads.nginx"])
+	assert.Equal(t, 7.0, sample.Gauges["ProcessOpenFDs.nginx"])
+	assert.Equal(t, 1.0, sample.Gauges["ProcessInstances.api"])
+	assert.Equal(t, 16.0, sample.Gauges["ProcessOpenFDs.apigroup"])
+	assert.Equal(t, 8.0, sample.Gauges["ProcessThreads.cmd"])
+	assert.Equal(t, 0.0, sample.Gauges["ProcessInstances.absent"])
+	assert.NotContains(t, sample.Gauges, "ProcessCPU.nginx", "first collection has no CPU baseline")
+	assert.Empty(t, sample.Counters)
+
+	c.procRoot = root("t1")
+	c.targets[1].PIDFile = filepath.Join(root("t1"), "run", "api.pid")
+	now = now.Add(10 * time.Second)
+
+	sample, err = c.Collect(context.Background())
+	require.NoError(t, err)
+	assert.InDelta(t, 15, sample.Gauges["ProcessCPU.nginx"], 1e-9)
+	assert.Equal(t, int64(0), sample.Counters["ProcessRestarts.nginx"])
+	assert.Equal(t, int64(1), sample.Counters["ProcessRestarts.api"])
+	assert.Equal(t, int64(1), sample.Counters["ProcessRestarts.apigroup"])
+	assert.Equal(t, 0.0, sample.Gauges["ProcessCPU.api"], "restarted process has no CPU baseline")
+	assert.Equal(t, int64(0), sample.Counters["ProcessRestarts.absent"])
+}
+
+func TestProcessCollectorMissingPIDFile(t *testing.T) {
+	c := NewProcessCollector(filepath.Join("testdata", "process", "t0"), []ProcessTarget{
+		{Name: "api", PIDFile: filepath.Join("testdata", "missing.pid")},
+		{Name: "nginx", Pattern: regexp.MustCompile(`^nginx$`)},
+	})
+
+	sample, err := c.Collect(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, 0.0, sample.Gauges["ProcessInstances.api"])
+	assert.Equal(t, 2.0, sample.Gauges["ProcessInstances.nginx"], "other targets should still be collected")
+}
@@ -0,0 +1,28 @@
+package collector
+
+import (
+	"context"
+	"github.com/MxTrap/metrics/internal/agent/mappers"
+	"runtime"
+)
+
+const RuntimeName = "runtime"
+
+// RuntimeCollector собирает метрики памяти рантайма Go из runtime.MemStats.
+type RuntimeCollector struct{}
+
+// NewRuntimeCollector создаёт коллектор метрик рантайма Go.
+func NewRuntimeCollector() *RuntimeCollector {
+	return &RuntimeCollector{}
+}
+
+func (c *RuntimeCollector) Name() string {
+	return RuntimeName
+}
+
+// Collect читает runtime.MemStats и возвращает метрики памяти в виде gauge.
+func (c *RuntimeCollector) Collect(_ context.Context) (Sample, error) {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return Sample{Gauges: mappers.MapGaugeMetrics(ms)}, nil
+}
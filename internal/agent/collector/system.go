@@ -0,0 +1,43 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"github.com/shirou/gopsutil/cpu"
+	"github.com/shirou/gopsutil/mem"
+)
+
+const SystemName = "system"
+
+// SystemCollector собирает метрики памяти и загрузки CPU хоста с помощью gopsutil.
+// Используется на платформах без /proc, на Linux его заменяет HostCollector.
+type SystemCollector struct{}
+
+// NewSystemCollector создаёт коллектор системных метрик.
+func NewSystemCollector() *SystemCollector {
+	return &SystemCollector{}
+}
+
+func (c *SystemCollector) Name() string {
+	return SystemName
+}
+
+// Collect возвращает общий и свободный объём памяти и загрузку CPU.
+func (c *SystemCollector) Collect(ctx context.Context) (Sample, error) {
+	v, err := mem.VirtualMemoryWithContext(ctx)
+	if err != nil {
+		return Sample{}, err
+	}
+	info, err := cpu.PercentWithContext(ctx, 0, false)
+	if err != nil {
+		return Sample{}, err
+	}
+	if len(info) == 0 {
+		return Sample{}, errors.New("cpu utilization is unavailable")
+	}
+	return Sample{Gauges: map[string]float64{
+		"TotalMemory":     float64(v.Total),
+		"FreeMemory":      float64(v.Free),
+		"CPUutilization1": info[0],
+	}}, nil
+}
@@ -2,63 +2,113 @@ package grpc
 
 import (
 	"context"
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/hex"
+	"errors"
 	"fmt"
+	"github.com/MxTrap/metrics/internal/agent/remoteconfig"
+	"github.com/MxTrap/metrics/internal/agent/spool"
 	"github.com/MxTrap/metrics/internal/common/models"
+	"github.com/MxTrap/metrics/internal/common/sign"
 	"github.com/MxTrap/metrics/internal/protos/gen"
 	"github.com/MxTrap/metrics/internal/utils"
-	"github.com/gogo/protobuf/proto"
 	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 	"log"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-type metricsGetter interface {
-	GetMetrics() models.Metrics
+type Client struct {
+	conn      *grpclib.ClientConn
+	client    gen.MetricServiceClient
+	agents    gen.AgentServiceClient
+	key       string
+	token     string
+	tenant    string
+	agent     models.AgentInfo
+	backoff   utils.Backoff
+	onRetry   func()
+	streaming atomic.Bool
+
+	streamMx    sync.Mutex
+	stream      *metricStream
+	hintHandler func(reportInterval time.Duration)
 }
 
-type Client struct {
-	conn           *grpclib.ClientConn
-	client         gen.MetricServiceClient
-	service        metricsGetter
-	reportInterval int
-	key            string
-	rateLimit      int
-}
-
-func NewClient(
-	serverAddr string,
-	service metricsGetter,
-	reportInterval int,
-	key string,
-	rateLimit int,
-) (*Client, error) {
+func NewClient(serverAddr string, key string) (*Client, error) {
 	conn, err := grpclib.NewClient(serverAddr, grpclib.WithTransportCredentials(insecure.NewCredentials()))
 	if err != nil {
 		return nil, err
 	}
 
-	client := gen.NewMetricServiceClient(conn)
-
 	return &Client{
-		conn:           conn,
-		client:         client,
-		service:        service,
-		reportInterval: reportInterval,
-		key:            key,
-		rateLimit:      rateLimit,
+		conn:    conn,
+		client:  gen.NewMetricServiceClient(conn),
+		agents:  gen.NewAgentServiceClient(conn),
+		key:     key,
+		backoff: utils.DefaultBackoff,
 	}, nil
 }
 
-func (c *Client) postMetrics(ctx context.Context) error {
-	metrics := c.service.GetMetrics()
-	rMetrics := make([]*gen.Metric, len(metrics))
-	for i, m := range metrics {
+func (c *Client) Name() string {
+	return "grpc"
+}
+
+func (c *Client) Close() error {
+	c.streamMx.Lock()
+	if c.stream != nil {
+		c.stream.cancel()
+		c.stream = nil
+	}
+	c.streamMx.Unlock()
+	return c.conn.Close()
+}
+
+func (c *Client) RegisterToken(token string) {
+	c.token = token
+}
+
+func (c *Client) RegisterTenant(tenant string) {
+	c.tenant = tenant
+}
+
+// RegisterAgent задаёт сведения, которыми агент представляется серверу в каждом вызове.
+func (c *Client) RegisterAgent(info models.AgentInfo) {
+	c.agent = info
+}
+
+// RegisterBackoff заменяет задержки между повторами отправки.
+func (c *Client) RegisterBackoff(b utils.Backoff) {
+	c.backoff = b
+}
+
+// RegisterRetryHandler задаёт обработчик, вызываемый перед каждым повтором отправки.
+func (c *Client) RegisterRetryHandler(handler func()) {
+	c.onRetry = handler
+}
+
+// EnableStreaming переключает отправку на долгоживущий поток StreamMetrics.
+// Если сервер не поддерживает поток, клиент возвращается к унарному SaveAll.
+func (c *Client) EnableStreaming() {
+	c.streaming.Store(true)
+}
+
+// RegisterHintHandler задаёт обработчик интервала отправки, который сервер предлагает в потоке.
+func (c *Client) RegisterHintHandler(handler func(reportInterval time.Duration)) {
+	c.streamMx.Lock()
+	c.hintHandler = handler
+	c.streamMx.Unlock()
+}
+
+// Send отправляет пакет с повторами при недоступности сервера, выжидая между попытками задержку со случайным разбросом.
+// AlreadyExists означает, что пакет уже применён, а ошибки, которые не исчезнут при повторе, оборачивают spool.ErrRejected.
+func (c *Client) Send(ctx context.Context, batch spool.Batch) error {
+	rMetrics := make([]*gen.Metric, len(batch.Metrics))
+	for i, m := range batch.Metrics {
 		rMetrics[i] = &gen.Metric{
 			Id:    m.ID,
 			Type:  m.MType,
@@ -70,82 +120,123 @@ func (c *Client) postMetrics(ctx context.Context) error {
 		Metrics: rMetrics,
 	}
 
-	md := metadata.New(map[string]string{})
-	md.Set("X-Real-IP", utils.GetLocalIP())
-
+	var marshal []byte
 	if c.key != "" {
-		h := hmac.New(sha256.New, []byte(c.key))
-		marshal, err := proto.Marshal(reqBody)
+		var err error
+		marshal, err = proto.MarshalOptions{Deterministic: true}.Marshal(reqBody)
 		if err != nil {
 			return err
 		}
+	}
 
-		h.Write(marshal)
-		dst := h.Sum(nil)
-
-		md.Set("HashSHA256", hex.EncodeToString(dst))
-		ctx = metadata.NewOutgoingContext(ctx, md)
+	if c.streaming.Load() {
+		err := c.sendStream(ctx, batch.ID, reqBody, marshal)
+		if status.Code(err) != codes.Unimplemented {
+			return classify(err)
+		}
+		log.Printf("server does not support metric streams, falling back to unary calls")
+		c.streaming.Store(false)
 	}
 
-	err := utils.Retry(func() error {
-		_, err := c.client.SaveAll(ctx, reqBody)
+	var rejected error
+	attempt := 0
+	err := utils.RetryContext(ctx, c.backoff, func() error {
+		if attempt++; attempt > 1 && c.onRetry != nil {
+			c.onRetry()
+		}
+		// подпись создаётся заново для каждой попытки, повтор одноразового nonce сервер отверг бы
+		md, err := c.metadata(batch.ID, marshal)
 		if err != nil {
 			return err
 		}
-		return nil
-	}, 3)
+		_, err = c.client.SaveAll(metadata.NewOutgoingContext(ctx, md), reqBody)
+		if status.Code(err) == codes.AlreadyExists {
+			return nil
+		}
+		err = classify(err)
+		if errors.Is(err, spool.ErrRejected) {
+			rejected = err
+			return nil
+		}
+		return err
+	})
 	if err != nil {
 		return err
 	}
 
-	return nil
-}
-
-func (c *Client) Run(ctx context.Context) {
-	ticker := time.NewTicker(time.Second * time.Duration(c.reportInterval))
-	inCh := make(chan struct{})
-	errCh := make(chan error)
-	wg := &sync.WaitGroup{}
-	go func() {
-		for {
-			select {
-			case <-ctx.Done():
-				close(inCh)
-				return
-			case <-ticker.C:
-				inCh <- struct{}{}
-			}
-		}
-	}()
-	go func() {
-		for i := 0; i < c.rateLimit; i++ {
-			wg.Add(1)
-			go func(i int) {
-				for {
-					select {
-					case <-ctx.Done():
-						wg.Done()
-						return
-					case <-inCh:
-						err := c.postMetrics(ctx)
-						if err != nil {
-							errCh <- fmt.Errorf("error from gorutine %d: %w", i, err)
-						}
-					}
-				}
-			}(i)
-			wg.Wait()
-			close(errCh)
+	return rejected
+}
+
+// classify оборачивает в spool.ErrRejected ошибки, которые не исчезнут при повторе.
+func classify(err error) error {
+	switch status.Code(err) {
+	case codes.InvalidArgument, codes.Unauthenticated, codes.PermissionDenied, codes.FailedPrecondition:
+		return fmt.Errorf("%w: %w", spool.ErrRejected, err)
+	}
+	return err
+}
+
+// baseMetadata возвращает метаданные, общие для всех вызовов агента: адрес и сведения агента, токен и арендатора.
+func (c *Client) baseMetadata() metadata.MD {
+	md := metadata.New(map[string]string{})
+	md.Set("X-Real-IP", utils.GetLocalIP())
+	if c.agent.ID != "" {
+		md.Set("X-Agent-ID", c.agent.ID)
+		md.Set("X-Agent-Hostname", c.agent.Hostname)
+		md.Set("X-Agent-Version", c.agent.Version)
+		md.Set("X-Agent-OS", c.agent.OS)
+	}
+	if c.token != "" {
+		md.Set("Authorization", "Bearer "+c.token)
+	}
+	if c.tenant != "" {
+		md.Set("X-Tenant-ID", c.tenant)
+	}
+	return md
+}
+
+func (c *Client) metadata(batchID string, marshal []byte) (metadata.MD, error) {
+	md := c.baseMetadata()
+	md.Set("X-Batch-ID", batchID)
+
+	if c.key != "" {
+		timestamp, nonce, signature, err := sign.Request(c.key, marshal)
+		if err != nil {
+			return nil, err
 		}
-	}()
+		md.Set("X-Timestamp", timestamp)
+		md.Set("X-Nonce", nonce)
+		md.Set("HashSHA256", signature)
+	}
+	return md, nil
+}
 
-	for res := range errCh {
-		fmt.Println(res)
+// FetchConfig запрашивает у сервера настройки агента.
+// Возвращает remoteconfig.ErrNoConfig, если для агента настройки не заданы.
+func (c *Client) FetchConfig(ctx context.Context, agentID, group string) (models.AgentConfig, error) {
+	resp, err := c.agents.GetConfig(
+		metadata.NewOutgoingContext(ctx, c.baseMetadata()),
+		&gen.AgentConfigRequest{AgentId: agentID, Group: group},
+	)
+	if status.Code(err) == codes.NotFound {
+		return models.AgentConfig{}, remoteconfig.ErrNoConfig
 	}
-	ticker.Stop()
-	err := c.conn.Close()
 	if err != nil {
-		log.Printf("error closing grpc client: %v", err)
-		return
+		return models.AgentConfig{}, err
 	}
+	return models.AgentConfig{
+		Version:        resp.GetVersion(),
+		PollInterval:   int(resp.GetPollIntervalSeconds()),
+		ReportInterval: int(resp.GetReportIntervalSeconds()),
+		Collectors:     resp.GetCollectors(),
+	}, nil
+}
+
+// ReportApplied сообщает серверу версию применённых настроек.
+func (c *Client) ReportApplied(ctx context.Context, applied models.AppliedConfig) error {
+	_, err := c.agents.ReportApplied(
+		metadata.NewOutgoingContext(ctx, c.baseMetadata()),
+		&gen.AppliedConfig{AgentId: applied.AgentID, Version: applied.Version, Error: applied.Error},
+	)
+	return err
 }
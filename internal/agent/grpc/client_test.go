@@ -1,30 +1,166 @@
 package grpc
 
 import (
+	"context"
+	"github.com/MxTrap/metrics/internal/agent/remoteconfig"
+	"github.com/MxTrap/metrics/internal/agent/spool"
 	"github.com/MxTrap/metrics/internal/common/models"
+	"github.com/MxTrap/metrics/internal/protos/gen"
+	"github.com/MxTrap/metrics/internal/utils"
 	"github.com/stretchr/testify/assert"
-	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"net"
 	"testing"
+	"time"
 )
 
-type mockMetricsGetter struct {
-	mock.Mock
-}
-
-func (m *mockMetricsGetter) GetMetrics() models.Metrics {
-	args := m.Called()
-	return args.Get(0).(models.Metrics)
-}
-
 func TestNewClient(t *testing.T) {
-	service := &mockMetricsGetter{}
-	client, err := NewClient("test-addr", service, 5, "secret", 2)
+	client, err := NewClient("test-addr", "secret")
 	require.NoError(t, err)
 	assert.NotNil(t, client)
 	assert.NotNil(t, client.client)
-	assert.Equal(t, service, client.service)
-	assert.Equal(t, 5, client.reportInterval)
 	assert.Equal(t, "secret", client.key)
-	assert.Equal(t, 2, client.rateLimit)
+	assert.Equal(t, "grpc", client.Name())
+	assert.NoError(t, client.Close())
+}
+
+func TestRegisterToken(t *testing.T) {
+	client, err := NewClient("test-addr", "")
+	require.NoError(t, err)
+	client.RegisterToken("agent-token")
+	assert.Equal(t, "agent-token", client.token)
+	client.RegisterTenant("team-a")
+	assert.Equal(t, "team-a", client.tenant)
+}
+
+func TestRegisterAgent(t *testing.T) {
+	client, err := NewClient("test-addr", "")
+	require.NoError(t, err)
+	assert.Empty(t, client.baseMetadata().Get("x-agent-id"), "anonymous client should not send agent metadata")
+
+	client.RegisterAgent(models.AgentInfo{ID: "host-1", Hostname: "host-1.local", Version: "v1.2.0", OS: "linux/amd64"})
+	md := client.baseMetadata()
+	assert.Equal(t, []string{"host-1"}, md.Get("x-agent-id"))
+	assert.Equal(t, []string{"host-1.local"}, md.Get("x-agent-hostname"))
+	assert.Equal(t, []string{"v1.2.0"}, md.Get("x-agent-version"))
+	assert.Equal(t, []string{"linux/amd64"}, md.Get("x-agent-os"))
+}
+
+type stubMetricServer struct {
+	gen.UnimplementedMetricServiceServer
+	err      error
+	batchIDs []string
+}
+
+func (s *stubMetricServer) SaveAll(ctx context.Context, _ *gen.SaveAllRequest) (*emptypb.Empty, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	s.batchIDs = append(s.batchIDs, md.Get("x-batch-id")...)
+	return &emptypb.Empty{}, s.err
+}
+
+func startServer(t *testing.T, srv gen.MetricServiceServer) string {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := grpclib.NewServer()
+	gen.RegisterMetricServiceServer(server, srv)
+	go func() {
+		_ = server.Serve(lis)
+	}()
+	t.Cleanup(server.Stop)
+	return lis.Addr().String()
+}
+
+func TestSend(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		wantErr error
+	}{
+		{name: "applied"},
+		{name: "already applied", err: status.Error(codes.AlreadyExists, "batch already applied")},
+		{name: "rejected", err: status.Error(codes.InvalidArgument, "invalid metric"), wantErr: spool.ErrRejected},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := &stubMetricServer{err: tt.err}
+			client, err := NewClient(startServer(t, srv), "")
+			require.NoError(t, err)
+			defer client.Close()
+
+			err = client.Send(context.Background(), spool.Batch{ID: "batch-1", Metrics: models.Metrics{
+				{ID: "PollCount", MType: models.Counter, Delta: utils.MakePointer[int64](1)},
+			}})
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, []string{"batch-1"}, srv.batchIDs, "batch should be sent once with its id")
+		})
+	}
+}
+
+func TestSendRetriesUnavailable(t *testing.T) {
+	srv := &stubMetricServer{err: status.Error(codes.Unavailable, "overloaded")}
+	client, err := NewClient(startServer(t, srv), "")
+	require.NoError(t, err)
+	defer client.Close()
+	client.RegisterBackoff(utils.Backoff{Attempts: 3, Initial: 10 * time.Millisecond, Jitter: 0.5})
+	retries := 0
+	client.RegisterRetryHandler(func() { retries++ })
+
+	err = client.Send(context.Background(), spool.Batch{ID: "batch-1"})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.NotErrorIs(t, err, spool.ErrRejected, "unavailable server should not reject the batch")
+	assert.Equal(t, []string{"batch-1", "batch-1", "batch-1"}, srv.batchIDs, "each attempt should carry the same batch id")
+	assert.Equal(t, 2, retries, "each retry should be reported")
+}
+
+type stubAgentServer struct {
+	gen.UnimplementedAgentServiceServer
+	applied []*gen.AppliedConfig
+}
+
+func (s *stubAgentServer) GetConfig(_ context.Context, in *gen.AgentConfigRequest) (*gen.AgentConfig, error) {
+	if in.GetAgentId() != "host-1" {
+		return nil, status.Error(codes.NotFound, "agent config not found")
+	}
+	return &gen.AgentConfig{Version: "v1", PollIntervalSeconds: 5, Collectors: []string{"runtime"}}, nil
+}
+
+func (s *stubAgentServer) ReportApplied(_ context.Context, in *gen.AppliedConfig) (*emptypb.Empty, error) {
+	s.applied = append(s.applied, in)
+	return &emptypb.Empty{}, nil
+}
+
+func TestRemoteConfig(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := grpclib.NewServer()
+	agents := &stubAgentServer{}
+	gen.RegisterAgentServiceServer(server, agents)
+	go func() {
+		_ = server.Serve(lis)
+	}()
+	t.Cleanup(server.Stop)
+
+	client, err := NewClient(lis.Addr().String(), "")
+	require.NoError(t, err)
+	defer client.Close()
+
+	cfg, err := client.FetchConfig(context.Background(), "host-1", "edge")
+	require.NoError(t, err)
+	assert.Equal(t, models.AgentConfig{Version: "v1", PollInterval: 5, Collectors: []string{"runtime"}}, cfg)
+
+	_, err = client.FetchConfig(context.Background(), "host-2", "")
+	assert.ErrorIs(t, err, remoteconfig.ErrNoConfig)
+
+	require.NoError(t, client.ReportApplied(context.Background(), models.AppliedConfig{AgentID: "host-1", Version: "v1", Error: "boom"}))
+	require.Len(t, agents.applied, 1)
+	assert.Equal(t, "boom", agents.applied[0].GetError())
 }
@@ -0,0 +1,184 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/MxTrap/metrics/internal/agent/spool"
+	"github.com/MxTrap/metrics/internal/common/sign"
+	"github.com/MxTrap/metrics/internal/protos/gen"
+	"google.golang.org/grpc/metadata"
+	"io"
+	"sync"
+	"time"
+)
+
+// ackTimeout — время ожидания подтверждения пакета, после которого поток считается зависшим.
+const ackTimeout = 30 * time.Second
+
+var (
+	errAckTimeout = errors.New("batch acknowledgement timed out")
+	errRetryBatch = errors.New("server asked to retry batch")
+)
+
+// metricStream — открытый поток StreamMetrics.
+// Ответы читаются отдельной горутиной: подтверждения передаются ожидающим их отправкам по идентификатору пакета,
+// подсказки конфигурации — обработчику клиента.
+type metricStream struct {
+	stream gen.MetricService_StreamMetricsClient
+	cancel context.CancelFunc
+	sendMx sync.Mutex
+
+	mx      sync.Mutex
+	waiters map[string]chan *gen.BatchAck
+
+	done chan struct{}
+	err  error
+}
+
+func (c *Client) openStream() (*metricStream, error) {
+	c.streamMx.Lock()
+	defer c.streamMx.Unlock()
+	if c.stream != nil {
+		return c.stream, nil
+	}
+
+	ctx, cancel := context.WithCancel(metadata.NewOutgoingContext(context.Background(), c.baseMetadata()))
+	stream, err := c.client.StreamMetrics(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	s := &metricStream{
+		stream:  stream,
+		cancel:  cancel,
+		waiters: map[string]chan *gen.BatchAck{},
+		done:    make(chan struct{}),
+	}
+	go s.receive(c.onHint)
+	c.stream = s
+	return s, nil
+}
+
+// dropStream закрывает поток s, чтобы следующая отправка открыла новый.
+func (c *Client) dropStream(s *metricStream) {
+	c.streamMx.Lock()
+	if c.stream == s {
+		c.stream = nil
+	}
+	c.streamMx.Unlock()
+	s.cancel()
+}
+
+func (c *Client) onHint(hint *gen.ConfigHint) {
+	c.streamMx.Lock()
+	handler := c.hintHandler
+	c.streamMx.Unlock()
+	if handler != nil && hint.GetReportIntervalSeconds() > 0 {
+		handler(time.Duration(hint.GetReportIntervalSeconds()) * time.Second)
+	}
+}
+
+func (s *metricStream) receive(onHint func(*gen.ConfigHint)) {
+	defer close(s.done)
+	for {
+		resp, err := s.stream.Recv()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			s.err = err
+			return
+		}
+		if hint := resp.GetHint(); hint != nil {
+			onHint(hint)
+		}
+		if ack := resp.GetAck(); ack != nil {
+			s.mx.Lock()
+			if ch, ok := s.waiters[ack.GetId()]; ok {
+				ch <- ack
+				delete(s.waiters, ack.GetId())
+			}
+			s.mx.Unlock()
+		}
+	}
+}
+
+// send отправляет пакет в поток и ждёт его подтверждения.
+// Возвращает ошибку потока, если поток оборвался до подтверждения.
+func (s *metricStream) send(ctx context.Context, batch *gen.MetricBatch) (*gen.BatchAck, error) {
+	ch := make(chan *gen.BatchAck, 1)
+	s.mx.Lock()
+	s.waiters[batch.GetId()] = ch
+	s.mx.Unlock()
+	defer func() {
+		s.mx.Lock()
+		delete(s.waiters, batch.GetId())
+		s.mx.Unlock()
+	}()
+
+	s.sendMx.Lock()
+	err := s.stream.Send(batch)
+	s.sendMx.Unlock()
+	// при обрыве Send возвращает io.EOF, а настоящую ошибку потока получает Recv
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	timer := time.NewTimer(ackTimeout)
+	defer timer.Stop()
+	select {
+	case ack := <-ch:
+		return ack, nil
+	case <-s.done:
+		return nil, s.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timer.C:
+		return nil, errAckTimeout
+	}
+}
+
+// sendStream отправляет пакет через поток StreamMetrics.
+// Оборванный поток переоткрывается один раз, ошибка повторного обрыва возвращается вызывающему.
+func (c *Client) sendStream(ctx context.Context, batchID string, req *gen.SaveAllRequest, marshal []byte) error {
+	batch := &gen.MetricBatch{Id: batchID, Metrics: req.GetMetrics()}
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if c.key != "" {
+			batch.Timestamp, batch.Nonce, batch.Signature, err = sign.Request(c.key, marshal)
+			if err != nil {
+				return err
+			}
+		}
+		var s *metricStream
+		s, err = c.openStream()
+		if err != nil {
+			continue
+		}
+		var ack *gen.BatchAck
+		ack, err = s.send(ctx, batch)
+		if err == nil {
+			return ackError(ack)
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		c.dropStream(s)
+	}
+	return err
+}
+
+func ackError(ack *gen.BatchAck) error {
+	switch ack.GetStatus() {
+	case gen.BatchAck_APPLIED, gen.BatchAck_DUPLICATE:
+		return nil
+	case gen.BatchAck_REJECTED:
+		return fmt.Errorf("%w: %s", spool.ErrRejected, ack.GetError())
+	}
+	if ack.GetError() != "" {
+		return fmt.Errorf("%w: %s", errRetryBatch, ack.GetError())
+	}
+	return errRetryBatch
+}
@@ -0,0 +1,98 @@
+package grpc
+
+import (
+	"context"
+	"github.com/MxTrap/metrics/internal/agent/spool"
+	"github.com/MxTrap/metrics/internal/common/models"
+	"github.com/MxTrap/metrics/internal/protos/gen"
+	"github.com/MxTrap/metrics/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+type streamMetricServer struct {
+	stubMetricServer
+	mx       sync.Mutex
+	statuses map[string]gen.BatchAck_Status
+	hint     *gen.ConfigHint
+	received []string
+	streams  int
+}
+
+func (s *streamMetricServer) StreamMetrics(stream gen.MetricService_StreamMetricsServer) error {
+	s.mx.Lock()
+	s.streams++
+	s.mx.Unlock()
+	for {
+		batch, err := stream.Recv()
+		if err != nil {
+			return nil
+		}
+		s.mx.Lock()
+		s.received = append(s.received, batch.GetId())
+		status := s.statuses[batch.GetId()]
+		s.mx.Unlock()
+		if s.hint != nil {
+			if err := stream.Send(&gen.StreamResponse{Payload: &gen.StreamResponse_Hint{Hint: s.hint}}); err != nil {
+				return err
+			}
+		}
+		ack := &gen.BatchAck{Id: batch.GetId(), Status: status}
+		if err := stream.Send(&gen.StreamResponse{Payload: &gen.StreamResponse_Ack{Ack: ack}}); err != nil {
+			return err
+		}
+	}
+}
+
+func testBatch(id string) spool.Batch {
+	return spool.Batch{ID: id, Metrics: models.Metrics{
+		{ID: "PollCount", MType: models.Counter, Delta: utils.MakePointer[int64](1)},
+	}}
+}
+
+func TestSendStream(t *testing.T) {
+	srv := &streamMetricServer{
+		statuses: map[string]gen.BatchAck_Status{
+			"dup":      gen.BatchAck_DUPLICATE,
+			"rejected": gen.BatchAck_REJECTED,
+			"retry":    gen.BatchAck_RETRY,
+		},
+		hint: &gen.ConfigHint{ReportIntervalSeconds: 20, Reason: "rate limit exceeded"},
+	}
+	client, err := NewClient(startServer(t, srv), "secret")
+	require.NoError(t, err)
+	defer client.Close()
+	client.EnableStreaming()
+	hints := make(chan time.Duration, 10)
+	client.RegisterHintHandler(func(d time.Duration) {
+		hints <- d
+	})
+
+	ctx := context.Background()
+	assert.NoError(t, client.Send(ctx, testBatch("applied")))
+	assert.NoError(t, client.Send(ctx, testBatch("dup")))
+	assert.ErrorIs(t, client.Send(ctx, testBatch("rejected")), spool.ErrRejected)
+	err = client.Send(ctx, testBatch("retry"))
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, spool.ErrRejected)
+
+	assert.Equal(t, []string{"applied", "dup", "rejected", "retry"}, srv.received)
+	assert.Equal(t, 1, srv.streams, "batches should share one stream")
+	assert.Empty(t, srv.batchIDs, "unary SaveAll should not be used")
+	assert.Equal(t, 20*time.Second, <-hints)
+}
+
+func TestSendStreamFallsBackToUnary(t *testing.T) {
+	srv := &stubMetricServer{}
+	client, err := NewClient(startServer(t, srv), "")
+	require.NoError(t, err)
+	defer client.Close()
+	client.EnableStreaming()
+
+	require.NoError(t, client.Send(context.Background(), testBatch("batch-1")))
+	assert.Equal(t, []string{"batch-1"}, srv.batchIDs)
+	assert.False(t, client.streaming.Load())
+}
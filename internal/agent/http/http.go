@@ -4,103 +4,75 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"github.com/MxTrap/metrics/internal/agent/remoteconfig"
+	"github.com/MxTrap/metrics/internal/agent/spool"
 	"github.com/MxTrap/metrics/internal/common/models"
+	"github.com/MxTrap/metrics/internal/common/sign"
 	"github.com/MxTrap/metrics/internal/utils"
 
 	"github.com/mailru/easyjson"
-	"io"
 	"net/http"
-	"sync"
-	"time"
+	"net/url"
 )
 
-type metricsGetter interface {
-	GetMetrics() models.Metrics
-}
-
 type encrypter interface {
 	Encrypt(plaintext []byte) ([]byte, error)
 }
 
 type HTTPClient struct {
-	serverURL      string
-	client         *http.Client
-	service        metricsGetter
-	reportInterval int
-	key            string
-	rateLimit      int
-	encrypter      encrypter
-}
-
-func NewClient(
-	service metricsGetter,
-	serverURL string,
-	reportInterval int,
-	key string,
-	rateLimit int,
-) *HTTPClient {
+	serverURL string
+	client    *http.Client
+	key       string
+	encrypter encrypter
+	token     string
+	tenant    string
+	agent     models.AgentInfo
+	backoff   utils.Backoff
+	onRetry   func()
+}
+
+func NewClient(serverURL string, key string) *HTTPClient {
 	client := &http.Client{}
 
 	return &HTTPClient{
-		client:         client,
-		service:        service,
-		reportInterval: reportInterval,
-		serverURL:      serverURL,
-		key:            key,
-		rateLimit:      rateLimit,
+		client:    client,
+		serverURL: serverURL,
+		key:       key,
+		backoff:   utils.DefaultBackoff,
 	}
 }
 
+func (c *HTTPClient) Name() string {
+	return "http"
+}
+
 func (c *HTTPClient) RegisterEncrypter(e encrypter) {
 	c.encrypter = e
 }
 
-func (c *HTTPClient) Run(ctx context.Context) {
-	ticker := time.NewTicker(time.Second * time.Duration(c.reportInterval))
-	inCh := make(chan struct{})
-	errCh := make(chan error)
-	wg := &sync.WaitGroup{}
-	go func() {
-		for {
-			select {
-			case <-ctx.Done():
-				close(inCh)
-				return
-			case <-ticker.C:
-				inCh <- struct{}{}
-			}
-		}
-	}()
-	go func() {
-		for i := 0; i < c.rateLimit; i++ {
-			wg.Add(1)
-			go func(i int) {
-				for {
-					select {
-					case <-ctx.Done():
-						wg.Done()
-						return
-					case <-inCh:
-						err := c.postMetric(ctx)
-						if err != nil {
-							errCh <- fmt.Errorf("error from gorutine %d: %w", i, err)
-						}
-					}
-				}
-			}(i)
-			wg.Wait()
-			close(errCh)
-		}
-	}()
+func (c *HTTPClient) RegisterToken(token string) {
+	c.token = token
+}
 
-	for res := range errCh {
-		fmt.Println(res)
-	}
-	ticker.Stop()
+func (c *HTTPClient) RegisterTenant(tenant string) {
+	c.tenant = tenant
+}
+
+// RegisterAgent задаёт сведения, которыми агент представляется серверу в каждом запросе.
+func (c *HTTPClient) RegisterAgent(info models.AgentInfo) {
+	c.agent = info
+}
+
+// RegisterBackoff заменяет задержки между повторами отправки.
+func (c *HTTPClient) RegisterBackoff(b utils.Backoff) {
+	c.backoff = b
+}
+
+// RegisterRetryHandler задаёт обработчик, вызываемый перед каждым повтором отправки.
+func (c *HTTPClient) RegisterRetryHandler(handler func()) {
+	c.onRetry = handler
 }
 
 func (*HTTPClient) compress(data []byte) (*bytes.Buffer, error) {
@@ -130,10 +102,10 @@ func (*HTTPClient) compress(data []byte) (*bytes.Buffer, error) {
 	return &b, nil
 }
 
-func (c *HTTPClient) postMetric(ctx context.Context) error {
-	metric := c.service.GetMetrics()
-	body, err := easyjson.Marshal(metric)
-
+// Send отправляет пакет с повторами при сетевых ошибках и ответах 5xx, выжидая между попытками задержку со случайным разбросом.
+// Ответ 4xx, кроме 409 и 429, означает, что сервер не примет пакет и при повторе, ошибка оборачивает spool.ErrRejected.
+func (c *HTTPClient) Send(ctx context.Context, batch spool.Batch) error {
+	body, err := easyjson.Marshal(batch.Metrics)
 	if err != nil {
 		return err
 	}
@@ -146,54 +118,162 @@ func (c *HTTPClient) postMetric(ctx context.Context) error {
 	}
 
 	compressed, err := c.compress(body)
+	if err != nil {
+		return err
+	}
+	payload := compressed.Bytes()
 
+	var rejected error
+	attempt := 0
+	err = utils.RetryContext(ctx, c.backoff, func() error {
+		if attempt++; attempt > 1 && c.onRetry != nil {
+			c.onRetry()
+		}
+		// запрос и подпись создаются заново для каждой попытки: тело прочитанного запроса уже израсходовано,
+		// а повтор одноразового nonce сервер отверг бы как повтор запроса
+		req, err := c.newRequest(ctx, batch.ID, payload)
+		if err != nil {
+			return err
+		}
+		response, err := c.client.Do(req)
+		if err != nil {
+			return err
+		}
+		err = response.Body.Close()
+		if err != nil {
+			return err
+		}
+		switch code := response.StatusCode; {
+		case code < http.StatusMultipleChoices:
+			return nil
+		case code == http.StatusConflict || code == http.StatusTooManyRequests || code >= http.StatusInternalServerError:
+			return fmt.Errorf("server responded %s", response.Status)
+		}
+		rejected = fmt.Errorf("%w: %s", spool.ErrRejected, response.Status)
+		return nil
+	})
 	if err != nil {
 		return err
 	}
 
+	return rejected
+}
+
+func (c *HTTPClient) newRequest(ctx context.Context, batchID string, payload []byte) (*http.Request, error) {
 	req, err := http.NewRequestWithContext(
 		ctx,
 		http.MethodPost,
 		fmt.Sprintf("http://%s/updates/", c.serverURL),
-		compressed,
+		bytes.NewReader(payload),
 	)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Content-Encoding", "gzip")
-	req.Header.Set("X-Real-IP", utils.GetLocalIP())
+	req.Header.Set("X-Batch-ID", batchID)
+	c.setHeaders(req)
 
 	if c.key != "" {
-		h := hmac.New(sha256.New, []byte(c.key))
-		var cBody io.ReadCloser
-		cBody, err = req.GetBody()
+		timestamp, nonce, signature, err := sign.Request(c.key, payload)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		all, err := io.ReadAll(cBody)
-		if err != nil {
-			return err
-		}
-		h.Write(all)
-		dst := h.Sum(nil)
-		req.Header.Set("HashSHA256", hex.EncodeToString(dst))
+		req.Header.Set("X-Timestamp", timestamp)
+		req.Header.Set("X-Nonce", nonce)
+		req.Header.Set("HashSHA256", signature)
+	}
+	return req, nil
+}
+
+// setHeaders задаёт заголовки, общие для всех запросов агента: адрес и сведения агента, токен и арендатора.
+func (c *HTTPClient) setHeaders(req *http.Request) {
+	req.Header.Set("X-Real-IP", utils.GetLocalIP())
+	if c.agent.ID != "" {
+		req.Header.Set("X-Agent-ID", c.agent.ID)
+		req.Header.Set("X-Agent-Hostname", c.agent.Hostname)
+		req.Header.Set("X-Agent-Version", c.agent.Version)
+		req.Header.Set("X-Agent-OS", c.agent.OS)
 	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if c.tenant != "" {
+		req.Header.Set("X-Tenant-ID", c.tenant)
+	}
+}
 
-	err = utils.Retry(func() error {
-		response, err := c.client.Do(req)
-		if err != nil {
-			return err
-		}
-		err = response.Body.Close()
+// FetchConfig запрашивает у сервера настройки агента.
+// Возвращает remoteconfig.ErrNoConfig, если для агента настройки не заданы.
+func (c *HTTPClient) FetchConfig(ctx context.Context, agentID, group string) (models.AgentConfig, error) {
+	query := url.Values{"agent_id": {agentID}}
+	if group != "" {
+		query.Set("group", group)
+	}
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodGet,
+		fmt.Sprintf("http://%s/agent/config?%s", c.serverURL, query.Encode()),
+		nil,
+	)
+	if err != nil {
+		return models.AgentConfig{}, err
+	}
+	c.setHeaders(req)
+
+	response, err := c.client.Do(req)
+	if err != nil {
+		return models.AgentConfig{}, err
+	}
+	defer response.Body.Close()
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return models.AgentConfig{}, remoteconfig.ErrNoConfig
+	default:
+		return models.AgentConfig{}, fmt.Errorf("server responded %s", response.Status)
+	}
+
+	var cfg models.AgentConfig
+	if err = json.NewDecoder(response.Body).Decode(&cfg); err != nil {
+		return models.AgentConfig{}, err
+	}
+	return cfg, nil
+}
+
+// ReportApplied сообщает серверу версию применённых настроек.
+func (c *HTTPClient) ReportApplied(ctx context.Context, applied models.AppliedConfig) error {
+	body, err := json.Marshal(applied)
+	if err != nil {
+		return err
+	}
+	if c.encrypter != nil {
+		body, err = c.encrypter.Encrypt(body)
 		if err != nil {
 			return err
 		}
-		return nil
-	}, 3)
+	}
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		fmt.Sprintf("http://%s/agent/config/applied", c.serverURL),
+		bytes.NewReader(body),
+	)
 	if err != nil {
 		return err
 	}
+	req.Header.Set("Content-Type", "application/json")
+	c.setHeaders(req)
 
+	response, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	if err = response.Body.Close(); err != nil {
+		return err
+	}
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("server responded %s", response.Status)
+	}
 	return nil
 }
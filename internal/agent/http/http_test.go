@@ -1,9 +1,14 @@
 package http
 
 import (
+	"bytes"
 	"compress/gzip"
 	"context"
+	"encoding/hex"
+	"github.com/MxTrap/metrics/internal/agent/remoteconfig"
+	"github.com/MxTrap/metrics/internal/agent/spool"
 	commonmodels "github.com/MxTrap/metrics/internal/common/models"
+	"github.com/MxTrap/metrics/internal/common/sign"
 	"github.com/MxTrap/metrics/internal/utils"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -15,15 +20,6 @@ import (
 	"time"
 )
 
-type mockMetricsObserver struct {
-	mock.Mock
-}
-
-func (m *mockMetricsObserver) GetMetrics() commonmodels.Metrics {
-	args := m.Called()
-	return args.Get(0).(commonmodels.Metrics)
-}
-
 type mockEncrypter struct {
 	mock.Mock
 }
@@ -34,16 +30,13 @@ func (m *mockEncrypter) Encrypt(plaintext []byte) ([]byte, error) {
 }
 
 func TestNewHTTPClient(t *testing.T) {
-	observer := &mockMetricsObserver{}
-	client := NewClient(observer, "localhost:8080", 2, "testkey", 1)
+	client := NewClient("localhost:8080", "testkey")
 
 	assert.NotNil(t, client, "client should not be nil")
 	assert.NotNil(t, client.client, "http client should not be nil")
-	assert.Equal(t, observer, client.service, "service should match")
 	assert.Equal(t, "localhost:8080", client.serverURL, "serverURL should match")
-	assert.Equal(t, 2, client.reportInterval, "reportInterval should match")
 	assert.Equal(t, "testkey", client.key, "key should match")
-	assert.Equal(t, 1, client.rateLimit, "rateLimit should match")
+	assert.Equal(t, "http", client.Name())
 }
 
 func TestCompress(t *testing.T) {
@@ -63,19 +56,27 @@ func TestCompress(t *testing.T) {
 }
 
 func TestRegisterEncrypter(t *testing.T) {
-	getter := &mockMetricsObserver{}
 	encrypter := &mockEncrypter{}
-	client := NewClient(getter, "localhost:8080", 2, "secret", 3)
+	client := NewClient("localhost:8080", "secret")
 	client.RegisterEncrypter(encrypter)
 	assert.Equal(t, encrypter, client.encrypter)
 }
 
-func TestPostMetric(t *testing.T) {
+func TestSend(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "batch-1", r.Header.Get("X-Batch-ID"), "X-Batch-ID should carry the batch id")
 		assert.Equal(t, "application/json", r.Header.Get("Content-Type"), "Content-Type should be application/json")
 		assert.Equal(t, "gzip", r.Header.Get("Content-Encoding"), "Content-Encoding should be gzip")
 
-		reader, err := gzip.NewReader(r.Body)
+		raw, err := io.ReadAll(r.Body)
+		require.NoError(t, err, "failed to read request body")
+		ts, nonce := r.Header.Get("X-Timestamp"), r.Header.Get("X-Nonce")
+		assert.NotEmpty(t, ts, "X-Timestamp should be set")
+		assert.NotEmpty(t, nonce, "X-Nonce should be set")
+		expected := hex.EncodeToString(sign.Sum("testkey", sign.Payload(ts, nonce, raw)))
+		assert.Equal(t, expected, r.Header.Get("HashSHA256"), "signature should cover timestamp, nonce and body")
+
+		reader, err := gzip.NewReader(bytes.NewReader(raw))
 		require.NoError(t, err, "failed to create gzip reader")
 		defer reader.Close()
 		require.NoError(t, err, "failed to read request body")
@@ -88,18 +89,32 @@ func TestPostMetric(t *testing.T) {
 		{ID: "testGauge", MType: commonmodels.Gauge, Value: utils.MakePointer(42.0)},
 	}
 
-	key := "testkey"
-	observer := &mockMetricsObserver{}
-	observer.On("GetMetrics").Return(metrics)
-	client := NewClient(observer, server.URL[7:], 2, key, 1)
+	client := NewClient(server.URL[7:], "testkey")
 
-	ctx := context.Background()
-	err := client.postMetric(ctx)
-	require.NoError(t, err, "postMetric should succeed")
+	err := client.Send(context.Background(), spool.Batch{ID: "batch-1", Metrics: metrics})
+	require.NoError(t, err, "Send should succeed")
+}
 
+func TestSendWithToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer agent-token", r.Header.Get("Authorization"), "Authorization should carry the token")
+		assert.Equal(t, "team-a", r.Header.Get("X-Tenant-ID"), "X-Tenant-ID should carry the tenant")
+		assert.Equal(t, "host-1", r.Header.Get("X-Agent-ID"), "X-Agent-ID should carry the agent id")
+		assert.Equal(t, "v1.2.0", r.Header.Get("X-Agent-Version"), "X-Agent-Version should carry the build version")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL[7:], "")
+	client.RegisterToken("agent-token")
+	client.RegisterTenant("team-a")
+	client.RegisterAgent(commonmodels.AgentInfo{ID: "host-1", Hostname: "host-1", Version: "v1.2.0", OS: "linux/amd64"})
+
+	err := client.Send(context.Background(), spool.NewBatch(commonmodels.Metrics{}))
+	require.NoError(t, err, "Send should succeed")
 }
 
-func TestPostMetricWithRetries(t *testing.T) {
+func TestSendWithRetries(t *testing.T) {
 	attempts := 0
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		attempts++
@@ -111,37 +126,87 @@ func TestPostMetricWithRetries(t *testing.T) {
 	}))
 	defer server.Close()
 
-	observer := &mockMetricsObserver{}
-	observer.On("GetMetrics").Return(commonmodels.Metrics{})
-	client := NewClient(observer, server.URL[7:], 2, "", 1)
-	ctx := context.Background()
+	client := NewClient(server.URL[7:], "")
+	client.RegisterBackoff(utils.Backoff{Attempts: 3, Initial: 10 * time.Millisecond, Jitter: 0.5})
+	retries := 0
+	client.RegisterRetryHandler(func() { retries++ })
 
-	err := client.postMetric(ctx)
-	require.NoError(t, err, "postMetric should succeed after retries")
+	err := client.Send(context.Background(), spool.NewBatch(commonmodels.Metrics{}))
+	require.NoError(t, err, "Send should succeed after retries")
+	assert.Equal(t, 3, attempts, "5xx responses should be retried")
+	assert.Equal(t, 2, retries, "each retry should be reported")
 }
 
-func TestRun(t *testing.T) {
-	observer := &mockMetricsObserver{}
-	metrics := commonmodels.Metrics{
-		{ID: "PollCount", MType: commonmodels.Counter, Value: utils.MakePointer[float64](100)},
-	}
-	observer.On("GetMetrics").Return(metrics).Times(2) // Ожидаем минимум 2 вызова
-
-	var requestCount int
+func TestSendStopsRetryingOnCancel(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		requestCount++
-		w.WriteHeader(http.StatusOK)
+		http.Error(w, "server error", http.StatusInternalServerError)
 	}))
 	defer server.Close()
 
-	client := NewClient(observer, server.URL[7:], 1, "", 1)
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	client := NewClient(server.URL[7:], "")
+	client.RegisterBackoff(utils.Backoff{Attempts: 3, Initial: time.Hour})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
 	defer cancel()
+	err := client.Send(ctx, spool.NewBatch(commonmodels.Metrics{}))
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "cancellation should interrupt the backoff")
+	assert.NotErrorIs(t, err, spool.ErrRejected)
+}
+
+func TestSendRejected(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		http.Error(w, "bad request", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL[7:], "")
 
-	go client.Run(ctx)
+	err := client.Send(context.Background(), spool.NewBatch(commonmodels.Metrics{}))
+	assert.ErrorIs(t, err, spool.ErrRejected)
+	assert.Equal(t, 1, attempts, "rejected batch should not be retried")
+}
+
+func TestFetchConfig(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/agent/config", r.URL.Path)
+		assert.Equal(t, "Bearer agent-token", r.Header.Get("Authorization"), "Authorization should carry the token")
+		if r.URL.Query().Get("agent_id") != "host-1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		assert.Equal(t, "edge", r.URL.Query().Get("group"))
+		_, _ = w.Write([]byte(`{"version":"v1","report_interval":30,"collectors":["runtime"]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL[7:], "")
+	client.RegisterToken("agent-token")
+
+	cfg, err := client.FetchConfig(context.Background(), "host-1", "edge")
+	require.NoError(t, err)
+	assert.Equal(t, commonmodels.AgentConfig{Version: "v1", ReportInterval: 30, Collectors: []string{"runtime"}}, cfg)
+
+	_, err = client.FetchConfig(context.Background(), "host-2", "")
+	assert.ErrorIs(t, err, remoteconfig.ErrNoConfig)
+}
+
+func TestReportApplied(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/agent/config/applied", r.URL.Path)
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"agent_id":"host-1","version":"v1","applied_at":"0001-01-01T00:00:00Z"}`, string(body))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
 
-	time.Sleep(2500 * time.Millisecond)
+	client := NewClient(server.URL[7:], "")
+	applied := commonmodels.AppliedConfig{AgentID: "host-1", Version: "v1"}
+	assert.NoError(t, client.ReportApplied(context.Background(), applied))
 
-	assert.GreaterOrEqual(t, requestCount, 2, "should send at least 2 requests")
-	observer.AssertExpectations(t)
+	status = http.StatusBadRequest
+	assert.Error(t, client.ReportApplied(context.Background(), applied))
 }
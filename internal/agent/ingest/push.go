@@ -0,0 +1,108 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/MxTrap/metrics/internal/agent/models"
+	common "github.com/MxTrap/metrics/internal/common/models"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// Timer — тип метрики для выборок длительности, сводимых агентом за окно отчёта.
+	Timer = "timer"
+
+	maxPushBody     = 1 << 20
+	shutdownTimeout = 5 * time.Second
+)
+
+// PushServer принимает метрики приложений по HTTP.
+type PushServer struct {
+	server *http.Server
+	sink   Sink
+}
+
+// NewPushServer создаёт HTTP-сервер с обработчиком POST /push, слушающий addr.
+func NewPushServer(addr string, sink Sink) *PushServer {
+	s := &PushServer{sink: sink}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /push", s.push)
+	s.server = &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: shutdownTimeout,
+	}
+	return s
+}
+
+// Handler возвращает обработчик запросов сервера.
+func (s *PushServer) Handler() http.Handler {
+	return s.server.Handler
+}
+
+// Run запускает сервер и останавливает его при отмене контекста.
+func (s *PushServer) Run(ctx context.Context) {
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		_ = s.server.Shutdown(shutdownCtx)
+	}()
+	if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("push: %v", err)
+	}
+}
+
+// push принимает JSON-массив метрик в формате сервера, где помимо gauge и counter допускается тип timer,
+// либо строки StatsD при Content-Type text/plain.
+// Запрос с хотя бы одной некорректной метрикой отклоняется целиком.
+func (s *PushServer) push(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPushBody))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	p := models.NewPush()
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "text/plain") {
+		err = ParseStatsD(body, p)
+	} else {
+		err = decodeMetrics(body, p)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.sink.SavePush(p)
+	w.WriteHeader(http.StatusOK)
+}
+
+func decodeMetrics(body []byte, p models.Push) error {
+	var metrics []common.Metric
+	if err := json.Unmarshal(body, &metrics); err != nil {
+		return err
+	}
+	for _, m := range metrics {
+		if m.ID == "" {
+			return errors.New("metric id is required")
+		}
+		switch {
+		case m.MType == common.Gauge && m.Value != nil:
+			p.Gauges[m.ID] = *m.Value
+		case m.MType == common.Counter && m.Delta != nil:
+			p.Counters[m.ID] += *m.Delta
+		case m.MType == Timer && m.Value != nil:
+			p.Timings[m.ID] = append(p.Timings[m.ID], *m.Value)
+		default:
+			return fmt.Errorf("invalid metric %q of type %q", m.ID, m.MType)
+		}
+	}
+	return nil
+}
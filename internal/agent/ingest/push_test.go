@@ -0,0 +1,79 @@
+package ingest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPushServer(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		status      int
+		saved       bool
+	}{
+		{
+			name:   "JSON metrics",
+			method: http.MethodPost,
+			body: `[{"id":"queue.size","type":"gauge","value":4},` +
+				`{"id":"jobs.done","type":"counter","delta":2},` +
+				`{"id":"job.duration","type":"timer","value":15}]`,
+			status: http.StatusOK,
+			saved:  true,
+		},
+		{
+			name:        "StatsD lines",
+			method:      http.MethodPost,
+			contentType: "text/plain; charset=utf-8",
+			body:        "queue.size:4|g\njobs.done:2|c\njob.duration:15|ms",
+			status:      http.StatusOK,
+			saved:       true,
+		},
+		{name: "Invalid JSON", method: http.MethodPost, body: `{`, status: http.StatusBadRequest},
+		{name: "Gauge without value", method: http.MethodPost, body: `[{"id":"a","type":"gauge"}]`, status: http.StatusBadRequest},
+		{name: "Unknown type", method: http.MethodPost, body: `[{"id":"a","type":"set","value":1}]`, status: http.StatusBadRequest},
+		{name: "Wrong method", method: http.MethodGet, status: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := &sinkMock{}
+			req := httptest.NewRequest(tt.method, "/push", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			w := httptest.NewRecorder()
+
+			NewPushServer("", sink).Handler().ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			if !tt.saved {
+				assert.Empty(t, sink.saved())
+				return
+			}
+			require.Len(t, sink.saved(), 1)
+			push := sink.saved()[0]
+			assert.Equal(t, 4.0, push.Gauges["queue.size"])
+			assert.Equal(t, int64(2), push.Counters["jobs.done"])
+			assert.Equal(t, []float64{15}, push.Timings["job.duration"])
+		})
+	}
+}
+
+func TestPushServerBodyLimit(t *testing.T) {
+	sink := &sinkMock{}
+	req := httptest.NewRequest(http.MethodPost, "/push", strings.NewReader(strings.Repeat(" ", maxPushBody+1)))
+	w := httptest.NewRecorder()
+
+	NewPushServer("", sink).Handler().ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Empty(t, sink.saved())
+}
@@ -0,0 +1,130 @@
+// Package ingest принимает метрики от приложений на хосте агента:
+// по UDP в формате StatsD и по HTTP через POST /push.
+// Принятые метрики накапливаются в хранилище агента и уходят на сервер со следующим отчётом.
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/MxTrap/metrics/internal/agent/models"
+	"log"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// maxPacketSize — максимальный размер UDP-датаграммы.
+const maxPacketSize = 64 * 1024
+
+var ErrInvalidLine = errors.New("invalid statsd line")
+
+// Sink — хранилище, принимающее переданные приложениями метрики.
+type Sink interface {
+	SavePush(models.Push)
+}
+
+// ParseStatsD разбирает строки StatsD вида "name:value|type[|@rate][|#tags]" и добавляет их в push.
+// Поддерживаются типы g (с "+" или "-" перед значением — изменение gauge), c, ms, h и d.
+// Некорректные строки пропускаются, ошибки по ним возвращаются объединёнными.
+func ParseStatsD(data []byte, push models.Push) error {
+	var errs []error
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if err := parseLine(line, push); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func parseLine(line string, push models.Push) error {
+	name, rest, ok := strings.Cut(line, ":")
+	if !ok || name == "" {
+		return fmt.Errorf("%w: %q", ErrInvalidLine, line)
+	}
+	parts := strings.Split(rest, "|")
+	if len(parts) < 2 {
+		return fmt.Errorf("%w: %q", ErrInvalidLine, line)
+	}
+	raw, kind := parts[0], parts[1]
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return fmt.Errorf("%w: %q", ErrInvalidLine, line)
+	}
+
+	rate := 1.0
+	for _, p := range parts[2:] {
+		if r, found := strings.CutPrefix(p, "@"); found {
+			rate, err = strconv.ParseFloat(r, 64)
+			if err != nil || rate <= 0 || rate > 1 {
+				return fmt.Errorf("%w: %q", ErrInvalidLine, line)
+			}
+		}
+	}
+
+	switch kind {
+	case "g":
+		if strings.HasPrefix(raw, "+") || strings.HasPrefix(raw, "-") {
+			push.GaugeDeltas[name] += value
+		} else {
+			push.Gauges[name] = value
+		}
+	case "c":
+		push.Counters[name] += int64(math.Round(value / rate))
+	case "ms", "h", "d":
+		push.Timings[name] = append(push.Timings[name], value)
+	default:
+		return fmt.Errorf("%w: unsupported type %q", ErrInvalidLine, kind)
+	}
+	return nil
+}
+
+// StatsDServer принимает метрики StatsD по UDP.
+type StatsDServer struct {
+	addr string
+	sink Sink
+}
+
+// NewStatsDServer создаёт сервер StatsD, слушающий addr, например "127.0.0.1:8125".
+func NewStatsDServer(addr string, sink Sink) *StatsDServer {
+	return &StatsDServer{addr: addr, sink: sink}
+}
+
+// Run открывает UDP-порт и принимает метрики до отмены контекста.
+func (s *StatsDServer) Run(ctx context.Context) {
+	conn, err := net.ListenPacket("udp", s.addr)
+	if err != nil {
+		log.Printf("statsd: %v", err)
+		return
+	}
+	s.Serve(ctx, conn)
+}
+
+// Serve принимает датаграммы из conn до отмены контекста и закрывает соединение.
+func (s *StatsDServer) Serve(ctx context.Context, conn net.PacketConn) {
+	go func() {
+		<-ctx.Done()
+		_ = conn.Close()
+	}()
+
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() == nil && !errors.Is(err, net.ErrClosed) {
+				log.Printf("statsd: %v", err)
+			}
+			return
+		}
+		push := models.NewPush()
+		if err = ParseStatsD(buf[:n], push); err != nil {
+			log.Printf("statsd: %v", err)
+		}
+		s.sink.SavePush(push)
+	}
+}
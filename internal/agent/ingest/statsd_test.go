@@ -0,0 +1,93 @@
+package ingest
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MxTrap/metrics/internal/agent/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sinkMock struct {
+	mx     sync.Mutex
+	pushes []models.Push
+}
+
+func (s *sinkMock) SavePush(p models.Push) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.pushes = append(s.pushes, p)
+}
+
+func (s *sinkMock) saved() []models.Push {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.pushes
+}
+
+func TestParseStatsD(t *testing.T) {
+	push := models.NewPush()
+	err := ParseStatsD([]byte(
+		"queue.size:10|g\n"+
+			"queue.size:-3|g\n"+
+			"cache.hit_ratio:+0.5|g\n"+
+			"jobs.done:2|c\n"+
+			"jobs.done:1|c|@0.1\n"+
+			"job.duration:12.5|ms|#env:prod\n"+
+			"job.duration:20|h\n"+
+			"\n",
+	), push)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]float64{"queue.size": 10}, push.Gauges)
+	assert.Equal(t, map[string]float64{"queue.size": -3, "cache.hit_ratio": 0.5}, push.GaugeDeltas)
+	assert.Equal(t, map[string]int64{"jobs.done": 12}, push.Counters)
+	assert.Equal(t, map[string][]float64{"job.duration": {12.5, 20}}, push.Timings)
+}
+
+func TestParseStatsDInvalid(t *testing.T) {
+	for _, line := range []string{"novalue", ":1|c", "a:x|c", "a:1", "a:1|s", "a:1|c|@0", "a:NaN|g"} {
+		push := models.NewPush()
+		assert.ErrorIs(t, ParseStatsD([]byte(line), push), ErrInvalidLine, line)
+	}
+
+	push := models.NewPush()
+	err := ParseStatsD([]byte("bad\nok:1|c"), push)
+	assert.Error(t, err)
+	assert.Equal(t, int64(1), push.Counters["ok"], "valid lines should survive invalid neighbours")
+}
+
+func TestStatsDServer(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	sink := &sinkMock{}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		NewStatsDServer("", sink).Serve(ctx, conn)
+		close(done)
+	}()
+
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	require.NoError(t, err)
+	defer client.Close()
+	_, err = client.Write([]byte("requests:5|c\nlatency:30|ms"))
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool { return len(sink.saved()) == 1 }, time.Second, 10*time.Millisecond)
+	push := sink.saved()[0]
+	assert.Equal(t, int64(5), push.Counters["requests"])
+	assert.Equal(t, []float64{30}, push.Timings["latency"])
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("statsd server did not stop")
+	}
+}
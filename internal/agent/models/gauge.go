@@ -36,6 +36,12 @@ func (c *GaugeMetrics) Set(key string, value float64) {
 	c.Metrics[key] = value
 }
 
+func (c *GaugeMetrics) Add(key string, delta float64) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.Metrics[key] += delta
+}
+
 func (c *GaugeMetrics) Range(callback func(key string, value float64)) {
 	c.mx.Lock()
 	defer c.mx.Unlock()
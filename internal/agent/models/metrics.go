@@ -2,9 +2,13 @@ package models
 
 type CounterMetrics struct {
 	PollCount int64
+	Metrics   map[string]int64
 }
 
 type Metrics struct {
 	Counter CounterMetrics
 	Gauge   GaugeMetrics
+	Timers  map[string]Summary
+	// GaugeWindows — сводка опрошенных значений gauge с предыдущего отчёта, заполняется при включённой агрегации.
+	GaugeWindows map[string]Summary
 }
@@ -0,0 +1,49 @@
+package models
+
+import "math"
+
+// Push — метрики, переданные агенту приложениями через StatsD или HTTP.
+// GaugeDeltas изменяют текущее значение gauge, а не заменяют его.
+type Push struct {
+	Gauges      map[string]float64
+	GaugeDeltas map[string]float64
+	Counters    map[string]int64
+	Timings     map[string][]float64
+}
+
+// NewPush создаёт пустой набор переданных метрик.
+func NewPush() Push {
+	return Push{
+		Gauges:      make(map[string]float64),
+		GaugeDeltas: make(map[string]float64),
+		Counters:    make(map[string]int64),
+		Timings:     make(map[string][]float64),
+	}
+}
+
+// Summary — сводка выборок за окно отчёта.
+type Summary struct {
+	Count int64
+	Sum   float64
+	Min   float64
+	Max   float64
+}
+
+// Observe добавляет выборку в сводку.
+func (s *Summary) Observe(v float64) {
+	if s.Count == 0 {
+		s.Min, s.Max = v, v
+	}
+	s.Count++
+	s.Sum += v
+	s.Min = math.Min(s.Min, v)
+	s.Max = math.Max(s.Max, v)
+}
+
+// Avg возвращает среднее значение выборок.
+func (s Summary) Avg() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Sum / float64(s.Count)
+}
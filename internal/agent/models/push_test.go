@@ -0,0 +1,28 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummary(t *testing.T) {
+	var s Summary
+	assert.Equal(t, 0.0, s.Avg(), "empty summary should have zero average")
+
+	for _, v := range []float64{5, -1, 8} {
+		s.Observe(v)
+	}
+	assert.Equal(t, Summary{Count: 3, Sum: 12, Min: -1, Max: 8}, s)
+	assert.Equal(t, 4.0, s.Avg())
+}
+
+func TestGaugeMetrics_Add(t *testing.T) {
+	gm := NewGaugeMetrics()
+	gm.Add("metric1", 2)
+	gm.Add("metric1", -0.5)
+
+	val, ok := gm.Get("metric1")
+	assert.True(t, ok)
+	assert.Equal(t, 1.5, val)
+}
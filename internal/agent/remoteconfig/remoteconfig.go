@@ -0,0 +1,101 @@
+// Package remoteconfig применяет к работающему агенту настройки, которые раздаёт сервер.
+// Watcher запрашивает настройки при запуске и затем периодически, применяет новую версию
+// без перезапуска агента и сообщает серверу, какая версия применена.
+package remoteconfig
+
+import (
+	"context"
+	"errors"
+	"github.com/MxTrap/metrics/internal/common/models"
+	"log"
+	"time"
+)
+
+// ErrNoConfig означает, что сервер не задал настройки для агента.
+var ErrNoConfig = errors.New("no remote config for agent")
+
+// Source получает настройки агента с сервера и принимает отчёты об их применении.
+// FetchConfig возвращает ErrNoConfig, если настройки для агента не заданы.
+type Source interface {
+	FetchConfig(ctx context.Context, agentID, group string) (models.AgentConfig, error)
+	ReportApplied(ctx context.Context, applied models.AppliedConfig) error
+}
+
+// ApplyFunc применяет настройки к агенту. Нулевые поля настроек означают значения из локальной конфигурации.
+type ApplyFunc func(cfg models.AgentConfig) error
+
+type Watcher struct {
+	source   Source
+	agentID  string
+	group    string
+	interval time.Duration
+	apply    ApplyFunc
+	// version — последняя обработанная версия настроек, пустая строка соответствует локальной конфигурации
+	version string
+	// pending — отчёт, который не удалось доставить на сервер
+	pending *models.AppliedConfig
+}
+
+// NewWatcher создаёт Watcher, который каждые interval запрашивает у source настройки агента agentID из группы group
+// и передаёт новые версии в apply.
+func NewWatcher(source Source, agentID, group string, interval time.Duration, apply ApplyFunc) *Watcher {
+	return &Watcher{
+		source:   source,
+		agentID:  agentID,
+		group:    group,
+		interval: interval,
+		apply:    apply,
+	}
+}
+
+// Run запрашивает настройки сразу и затем каждые interval до отмены контекста.
+// Источник не закрывается: им владеет тот, кто его создал.
+func (w *Watcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		if err := w.check(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("remote config: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check запрашивает настройки и применяет их, если версия изменилась.
+// Если для агента настройки больше не заданы, агент возвращается к локальной конфигурации.
+// Версия, которую не удалось применить, не применяется повторно, пока сервер её не сменит.
+func (w *Watcher) check(ctx context.Context) error {
+	if w.pending != nil {
+		if err := w.source.ReportApplied(ctx, *w.pending); err != nil {
+			return err
+		}
+		w.pending = nil
+	}
+
+	cfg, err := w.source.FetchConfig(ctx, w.agentID, w.group)
+	if errors.Is(err, ErrNoConfig) {
+		cfg, err = models.AgentConfig{}, nil
+	}
+	if err != nil {
+		return err
+	}
+	if cfg.Version == w.version {
+		return nil
+	}
+
+	applied := models.AppliedConfig{AgentID: w.agentID, Version: cfg.Version}
+	if err = w.apply(cfg); err != nil {
+		log.Printf("could not apply remote config %s: %v", cfg.Version, err)
+		applied.Error = err.Error()
+	}
+	w.version = cfg.Version
+	if err = w.source.ReportApplied(ctx, applied); err != nil {
+		w.pending = &applied
+		return err
+	}
+	return nil
+}
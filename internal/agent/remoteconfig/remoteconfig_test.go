@@ -0,0 +1,102 @@
+package remoteconfig
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/MxTrap/metrics/internal/common/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type mockSource struct {
+	mock.Mock
+}
+
+func (m *mockSource) FetchConfig(ctx context.Context, agentID, group string) (models.AgentConfig, error) {
+	args := m.Called(ctx, agentID, group)
+	return args.Get(0).(models.AgentConfig), args.Error(1)
+}
+
+func (m *mockSource) ReportApplied(ctx context.Context, applied models.AppliedConfig) error {
+	args := m.Called(ctx, applied)
+	return args.Error(0)
+}
+
+func TestWatcherAppliesNewVersions(t *testing.T) {
+	v1 := models.AgentConfig{Version: "v1", ReportInterval: 30}
+	v2 := models.AgentConfig{Version: "v2", Collectors: []string{"missing"}}
+	source := &mockSource{}
+	source.On("FetchConfig", mock.Anything, "host-1", "edge").Return(v1, nil).Twice()
+	source.On("FetchConfig", mock.Anything, "host-1", "edge").Return(v2, nil).Twice()
+	source.On("FetchConfig", mock.Anything, "host-1", "edge").Return(models.AgentConfig{}, ErrNoConfig).Once()
+	source.On("ReportApplied", mock.Anything, models.AppliedConfig{AgentID: "host-1", Version: "v1"}).Return(nil).Once()
+	source.On("ReportApplied", mock.Anything, models.AppliedConfig{AgentID: "host-1", Version: "v2", Error: "unknown collector"}).Return(nil).Once()
+	source.On("ReportApplied", mock.Anything, models.AppliedConfig{AgentID: "host-1"}).Return(nil).Once()
+
+	var applied []models.AgentConfig
+	w := NewWatcher(source, "host-1", "edge", time.Minute, func(cfg models.AgentConfig) error {
+		applied = append(applied, cfg)
+		if len(cfg.Collectors) > 0 {
+			return errors.New("unknown collector")
+		}
+		return nil
+	})
+
+	ctx := context.Background()
+	for range 5 {
+		require.NoError(t, w.check(ctx))
+	}
+
+	assert.Equal(t, []models.AgentConfig{v1, v2, {}}, applied, "each version is applied once, removed config restores local settings")
+	source.AssertExpectations(t)
+}
+
+func TestWatcherRetriesFailedReport(t *testing.T) {
+	cfg := models.AgentConfig{Version: "v1"}
+	report := models.AppliedConfig{AgentID: "host-1", Version: "v1"}
+	source := &mockSource{}
+	source.On("FetchConfig", mock.Anything, "host-1", "").Return(cfg, nil)
+	source.On("ReportApplied", mock.Anything, report).Return(errors.New("connection refused")).Once()
+	source.On("ReportApplied", mock.Anything, report).Return(nil).Once()
+
+	calls := 0
+	w := NewWatcher(source, "host-1", "", time.Minute, func(models.AgentConfig) error {
+		calls++
+		return nil
+	})
+
+	assert.Error(t, w.check(context.Background()))
+	assert.NoError(t, w.check(context.Background()))
+	assert.Equal(t, 1, calls, "config should not be applied again")
+	source.AssertExpectations(t)
+}
+
+func TestWatcherRunChecksOnStart(t *testing.T) {
+	fetched := make(chan struct{})
+	source := &mockSource{}
+	source.On("FetchConfig", mock.Anything, "host-1", "").Return(models.AgentConfig{}, ErrNoConfig).Once().Run(func(mock.Arguments) {
+		close(fetched)
+	})
+	w := NewWatcher(source, "host-1", "", time.Hour, func(models.AgentConfig) error {
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx)
+		close(done)
+	}()
+	select {
+	case <-fetched:
+	case <-time.After(time.Second):
+		t.Fatal("config should be fetched on start")
+	}
+	cancel()
+	<-done
+	source.AssertExpectations(t)
+}
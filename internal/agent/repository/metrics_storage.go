@@ -4,11 +4,18 @@ package repository
 
 import (
 	"github.com/MxTrap/metrics/internal/agent/models"
+	"maps"
 	"math/rand"
+	"sync"
 )
 
 type MetricsStorage struct {
+	mx      sync.Mutex
 	storage models.Metrics
+	// timers накапливает выборки таймеров между отчётами.
+	timers map[string]*models.Summary
+	// gaugeWindows накапливает значения gauge между отчётами, nil при выключенной агрегации.
+	gaugeWindows map[string]*models.Summary
 }
 
 // NewMetricsStorage создаёт новое хранилище метрик с инициализированными структурами gauge и счетчиков.
@@ -22,16 +29,141 @@ func NewMetricsStorage() *MetricsStorage {
 	}
 }
 
-// SaveMetrics сохраняет метрики в хранилище.
-// Принимает карту значений gauge, добавляет случайное значение RandomValue и инкрементирует счетчик PollCount.
-func (s *MetricsStorage) SaveMetrics(m map[string]float64) {
+// EnableGaugeAggregation включает сводку min/max/avg опрошенных значений gauge за период между отчётами.
+// Без неё в отчёт попадает только последнее значение, и кратковременные всплески между отчётами теряются.
+func (s *MetricsStorage) EnableGaugeAggregation() {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.gaugeWindows == nil {
+		s.gaugeWindows = make(map[string]*models.Summary)
+	}
+}
+
+// SaveGauges сохраняет значения gauge, полученные коллектором, и добавляет их в сводку текущего периода.
+func (s *MetricsStorage) SaveGauges(m map[string]float64) {
 	s.storage.Gauge.Load(m)
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.gaugeWindows == nil {
+		return
+	}
+	for name, v := range m {
+		summary, ok := s.gaugeWindows[name]
+		if !ok {
+			summary = &models.Summary{}
+			s.gaugeWindows[name] = summary
+		}
+		summary.Observe(v)
+	}
+}
+
+// SavePoll отмечает очередной опрос: обновляет случайное значение RandomValue и инкрементирует счетчик PollCount.
+// Вызывается один раз за интервал опроса независимо от числа коллекторов.
+func (s *MetricsStorage) SavePoll() {
 	s.storage.Gauge.Set("RandomValue", rand.Float64())
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
 	s.storage.Counter.PollCount += 1
 }
 
+// SaveCounters прибавляет приращения счётчиков к накопленным значениям.
+func (s *MetricsStorage) SaveCounters(m map[string]int64) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.storage.Counter.Metrics == nil {
+		s.storage.Counter.Metrics = make(map[string]int64, len(m))
+	}
+	for name, delta := range m {
+		s.storage.Counter.Metrics[name] += delta
+	}
+}
+
+// SavePush сохраняет метрики, переданные приложениями.
+// Gauge заменяются или изменяются на дельту, счётчики накапливаются, выборки таймеров попадают в текущее окно.
+// В отличие от SavePoll не увеличивает PollCount.
+func (s *MetricsStorage) SavePush(p models.Push) {
+	s.storage.Gauge.Load(p.Gauges)
+	for name, delta := range p.GaugeDeltas {
+		s.storage.Gauge.Add(name, delta)
+	}
+	if len(p.Counters) > 0 {
+		s.SaveCounters(p.Counters)
+	}
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.timers == nil {
+		s.timers = make(map[string]*models.Summary, len(p.Timings))
+	}
+	for name, values := range p.Timings {
+		summary, ok := s.timers[name]
+		if !ok {
+			summary = &models.Summary{}
+			s.timers[name] = summary
+		}
+		for _, v := range values {
+			summary.Observe(v)
+		}
+	}
+}
+
 // GetMetrics возвращает все метрики из хранилища.
 // Возвращает структуру models.Metrics, содержащую значения gauge и счетчики.
 func (s *MetricsStorage) GetMetrics() models.Metrics {
-	return s.storage
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	metrics := s.storage
+	metrics.Counter.Metrics = maps.Clone(s.storage.Counter.Metrics)
+	metrics.Timers = summarize(s.timers)
+	metrics.GaugeWindows = summarize(s.gaugeWindows)
+	return metrics
+}
+
+// TakeMetrics возвращает все метрики из хранилища и обнуляет счётчики:
+// накопленные приращения переходят к вызывающему, который отправляет их на сервер.
+// Если отправить их не удалось, отправитель вызывает ReturnMetrics сервиса, и приращения возвращаются через ReturnCounters.
+// Сводки таймеров и gauge начинаются заново, поэтому каждое окно попадает ровно в один отчёт;
+// в случае неудачной отправки они не возвращаются.
+func (s *MetricsStorage) TakeMetrics() models.Metrics {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	metrics := s.storage
+	metrics.Timers = summarize(s.timers)
+	metrics.GaugeWindows = summarize(s.gaugeWindows)
+	s.storage.Counter = models.CounterMetrics{}
+	s.timers = nil
+	if s.gaugeWindows != nil {
+		s.gaugeWindows = make(map[string]*models.Summary, len(s.gaugeWindows))
+	}
+	return metrics
+}
+
+// summarize копирует сводки текущего периода. Вызывается под s.mx.
+func summarize(summaries map[string]*models.Summary) map[string]models.Summary {
+	if len(summaries) == 0 {
+		return nil
+	}
+	windows := make(map[string]models.Summary, len(summaries))
+	for name, summary := range summaries {
+		windows[name] = *summary
+	}
+	return windows
+}
+
+// ReturnCounters возвращает в хранилище приращения счётчиков, изъятые TakeMetrics, но не доставленные на сервер.
+func (s *MetricsStorage) ReturnCounters(c models.CounterMetrics) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.storage.Counter.PollCount += c.PollCount
+	if len(c.Metrics) == 0 {
+		return
+	}
+	if s.storage.Counter.Metrics == nil {
+		s.storage.Counter.Metrics = make(map[string]int64, len(c.Metrics))
+	}
+	for name, delta := range c.Metrics {
+		s.storage.Counter.Metrics[name] += delta
+	}
 }
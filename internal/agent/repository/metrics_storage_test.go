@@ -49,7 +49,7 @@ func TestMetricsStorage_GetMetrics(t *testing.T) {
 	}
 }
 
-func TestMetricsStorage_SaveMetrics(t *testing.T) {
+func TestMetricsStorage_SavePoll(t *testing.T) {
 	tests := []struct {
 		name string
 		args []map[string]float64
@@ -106,9 +106,10 @@ func TestMetricsStorage_SaveMetrics(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			s := *NewMetricsStorage()
+			s := NewMetricsStorage()
 			for _, val := range tt.args {
-				s.SaveMetrics(val)
+				s.SaveGauges(val)
+				s.SavePoll()
 				s.storage.Gauge.Metrics["RandomValue"] = 1
 			}
 			assert.Equal(t, tt.want.Gauge.Metrics, s.storage.Gauge.Metrics)
@@ -117,6 +118,105 @@ func TestMetricsStorage_SaveMetrics(t *testing.T) {
 	}
 }
 
+func TestMetricsStorage_SaveGauges(t *testing.T) {
+	s := NewMetricsStorage()
+	s.SaveGauges(map[string]float64{"Alloc": 1})
+	s.SaveGauges(map[string]float64{"CPUutilization1": 10})
+
+	metrics := s.GetMetrics()
+	assert.Equal(t, int64(0), metrics.Counter.PollCount, "collector results should not count as polls")
+	_, ok := metrics.Gauge.Get("RandomValue")
+	assert.False(t, ok, "RandomValue should be set only by SavePoll")
+}
+
+func TestMetricsStorage_SaveCounters(t *testing.T) {
+	s := NewMetricsStorage()
+	s.SaveCounters(map[string]int64{"NetBytesSent.eth0": 10})
+	s.SaveCounters(map[string]int64{"NetBytesSent.eth0": 5, "DiskReads.sda": 1})
+
+	metrics := s.GetMetrics()
+	assert.Equal(t, map[string]int64{"NetBytesSent.eth0": 15, "DiskReads.sda": 1}, metrics.Counter.Metrics)
+
+	metrics.Counter.Metrics["DiskReads.sda"] = 100
+	assert.Equal(t, int64(1), s.GetMetrics().Counter.Metrics["DiskReads.sda"], "GetMetrics should return a copy of counters")
+}
+
+func TestMetricsStorage_TakeMetrics(t *testing.T) {
+	s := NewMetricsStorage()
+	s.SaveGauges(map[string]float64{"Alloc": 1})
+	s.SavePoll()
+	s.SaveGauges(map[string]float64{"Alloc": 2})
+	s.SavePoll()
+	s.SaveCounters(map[string]int64{"DiskReads.sda": 5})
+
+	taken := s.TakeMetrics()
+	assert.Equal(t, int64(2), taken.Counter.PollCount)
+	assert.Equal(t, map[string]int64{"DiskReads.sda": 5}, taken.Counter.Metrics)
+
+	next := s.TakeMetrics()
+	assert.Equal(t, int64(0), next.Counter.PollCount, "taken increments should not be sent again")
+	assert.Empty(t, next.Counter.Metrics)
+	v, ok := next.Gauge.Get("Alloc")
+	assert.True(t, ok, "gauges should stay in the storage")
+	assert.Equal(t, 2.0, v)
+
+	s.SaveGauges(map[string]float64{"Alloc": 3})
+	s.SavePoll()
+	s.SaveCounters(map[string]int64{"DiskReads.sda": 1})
+	s.ReturnCounters(taken.Counter)
+	restored := s.TakeMetrics()
+	assert.Equal(t, int64(3), restored.Counter.PollCount, "returned increments should be added to new ones")
+	assert.Equal(t, map[string]int64{"DiskReads.sda": 6}, restored.Counter.Metrics)
+}
+
+func TestMetricsStorage_GaugeAggregation(t *testing.T) {
+	s := NewMetricsStorage()
+	s.SaveGauges(map[string]float64{"CPUutilization1": 10})
+	assert.Nil(t, s.TakeMetrics().GaugeWindows, "aggregation should be off by default")
+
+	s.EnableGaugeAggregation()
+	for _, v := range []float64{10, 90, 20} {
+		s.SaveGauges(map[string]float64{"CPUutilization1": v})
+	}
+	assert.Equal(t, map[string]models.Summary{"CPUutilization1": {Count: 3, Sum: 120, Min: 10, Max: 90}}, s.GetMetrics().GaugeWindows)
+
+	taken := s.TakeMetrics()
+	assert.Equal(t, map[string]models.Summary{"CPUutilization1": {Count: 3, Sum: 120, Min: 10, Max: 90}}, taken.GaugeWindows)
+	last, _ := taken.Gauge.Get("CPUutilization1")
+	assert.Equal(t, 20.0, last)
+
+	assert.Nil(t, s.TakeMetrics().GaugeWindows, "window should start over after report")
+}
+
+func TestMetricsStorage_SavePush(t *testing.T) {
+	s := NewMetricsStorage()
+	s.SavePush(models.Push{
+		Gauges:   map[string]float64{"queue.size": 10},
+		Counters: map[string]int64{"jobs.done": 3},
+		Timings:  map[string][]float64{"job.duration": {10, 30}},
+	})
+	s.SavePush(models.Push{
+		GaugeDeltas: map[string]float64{"queue.size": -4},
+		Counters:    map[string]int64{"jobs.done": 2},
+		Timings:     map[string][]float64{"job.duration": {20}},
+	})
+
+	metrics := s.GetMetrics()
+	value, _ := metrics.Gauge.Get("queue.size")
+	assert.Equal(t, 6.0, value)
+	assert.Equal(t, int64(5), metrics.Counter.Metrics["jobs.done"])
+	assert.Equal(t, int64(0), metrics.Counter.PollCount, "pushed metrics should not count as polls")
+	assert.Equal(t, map[string]models.Summary{"job.duration": {Count: 3, Sum: 60, Min: 10, Max: 30}}, metrics.Timers)
+
+	taken := s.TakeMetrics()
+	assert.Equal(t, map[string]models.Summary{"job.duration": {Count: 3, Sum: 60, Min: 10, Max: 30}}, taken.Timers)
+	assert.Empty(t, s.TakeMetrics().Timers, "each timer window should be reported once")
+
+	s.SavePush(models.Push{Timings: map[string][]float64{"job.duration": {50}}})
+	assert.Equal(t, map[string]models.Summary{"job.duration": {Count: 1, Sum: 50, Min: 50, Max: 50}}, s.TakeMetrics().Timers,
+		"next window should start over")
+}
+
 func TestNewMetricsStorage(t *testing.T) {
 	tests := []struct {
 		name string
@@ -0,0 +1,125 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/MxTrap/metrics/internal/agent/spool"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultRetryAfter — время, на которое транспорт исключается из отправки после сбоя.
+const DefaultRetryAfter = 30 * time.Second
+
+// Failover отправляет пакет через первый исправный транспорт из списка, переключаясь на следующий при сбое.
+// Сбойный транспорт пропускается в течение retryAfter, после чего снова пробуется первым,
+// так что отправка возвращается на основной транспорт, когда он восстанавливается.
+// Сервер отбрасывает повтор пакета по идентификатору, поэтому пакет, доставленный до сбоя ответа,
+// не применяется повторно при отправке через другой транспорт.
+type Failover struct {
+	mx         sync.Mutex
+	transports []Transport
+	retryAfter time.Duration
+	// downUntil — момент, до которого транспорт с тем же индексом считается неисправным
+	downUntil []time.Time
+	now       func() time.Time
+}
+
+// NewFailover создаёт Failover с транспортами в порядке приоритета.
+func NewFailover(retryAfter time.Duration, transports ...Transport) *Failover {
+	return &Failover{
+		transports: transports,
+		retryAfter: retryAfter,
+		downUntil:  make([]time.Time, len(transports)),
+		now:        time.Now,
+	}
+}
+
+func (f *Failover) Name() string {
+	names := make([]string, len(f.transports))
+	for i, t := range f.transports {
+		names[i] = t.Name()
+	}
+	return strings.Join(names, "+")
+}
+
+// Send пробует транспорты по порядку, начиная с исправных.
+// Если неисправны все, пробуются все: лучше попытаться отправить, чем ждать восстановления.
+// Отказ сервера принять пакет не считается сбоем транспорта и возвращается сразу.
+func (f *Failover) Send(ctx context.Context, batch spool.Batch) error {
+	var errs []error
+	for _, i := range f.order() {
+		err := f.transports[i].Send(ctx, batch)
+		if err == nil || errors.Is(err, spool.ErrRejected) {
+			f.mark(i, true)
+			return err
+		}
+		f.mark(i, false)
+		errs = append(errs, fmt.Errorf("%s: %w", f.transports[i].Name(), err))
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close закрывает транспорты, реализующие io.Closer.
+func (f *Failover) Close() error {
+	var errs []error
+	for _, t := range f.transports {
+		if closer, ok := t.(io.Closer); ok {
+			errs = append(errs, closer.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RegisterHintHandler передаёт обработчик подсказок сервера транспортам, которые их поддерживают.
+func (f *Failover) RegisterHintHandler(handler func(reportInterval time.Duration)) {
+	for _, t := range f.transports {
+		if h, ok := t.(hinter); ok {
+			h.RegisterHintHandler(handler)
+		}
+	}
+}
+
+// RegisterRetryHandler передаёт обработчик повторов транспортам, которые о них сообщают.
+func (f *Failover) RegisterRetryHandler(handler func()) {
+	for _, t := range f.transports {
+		if r, ok := t.(retrier); ok {
+			r.RegisterRetryHandler(handler)
+		}
+	}
+}
+
+// order возвращает индексы транспортов: сначала исправные, затем неисправные, каждые в порядке приоритета.
+func (f *Failover) order() []int {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+
+	now := f.now()
+	healthy := make([]int, 0, len(f.transports))
+	var down []int
+	for i := range f.transports {
+		if now.Before(f.downUntil[i]) {
+			down = append(down, i)
+			continue
+		}
+		healthy = append(healthy, i)
+	}
+	return append(healthy, down...)
+}
+
+func (f *Failover) mark(i int, ok bool) {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+
+	if ok {
+		f.downUntil[i] = time.Time{}
+		return
+	}
+	f.downUntil[i] = f.now().Add(f.retryAfter)
+}
@@ -0,0 +1,58 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"github.com/MxTrap/metrics/internal/agent/spool"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestFailover(t *testing.T) {
+	unavailable := errors.New("connection refused")
+	primary := &stubTransport{name: "grpc", errs: []error{unavailable}}
+	fallback := &stubTransport{name: "http"}
+	f := NewFailover(time.Minute, primary, fallback)
+	now := time.Now()
+	f.now = func() time.Time { return now }
+	assert.Equal(t, "grpc+http", f.Name())
+
+	assert.NoError(t, f.Send(context.Background(), spool.Batch{ID: "1"}))
+	assert.Equal(t, []string{"1"}, primary.sent)
+	assert.Equal(t, []string{"1"}, fallback.sent, "batch should be delivered over the fallback")
+
+	assert.NoError(t, f.Send(context.Background(), spool.Batch{ID: "2"}))
+	assert.Equal(t, []string{"1"}, primary.sent, "failed transport should be skipped while it is down")
+	assert.Equal(t, []string{"1", "2"}, fallback.sent)
+
+	now = now.Add(time.Minute)
+	assert.NoError(t, f.Send(context.Background(), spool.Batch{ID: "3"}))
+	assert.Equal(t, []string{"1", "3"}, primary.sent, "primary should be retried after retryAfter")
+	assert.Equal(t, []string{"1", "2"}, fallback.sent)
+
+	assert.NoError(t, f.Close())
+	assert.True(t, primary.closed)
+	assert.True(t, fallback.closed)
+}
+
+func TestFailoverRejected(t *testing.T) {
+	primary := &stubTransport{name: "grpc", errs: []error{spool.ErrRejected}}
+	fallback := &stubTransport{name: "http"}
+	f := NewFailover(time.Minute, primary, fallback)
+
+	assert.ErrorIs(t, f.Send(context.Background(), spool.Batch{ID: "1"}), spool.ErrRejected)
+	assert.Empty(t, fallback.sent, "rejected batch should not be sent over another transport")
+}
+
+func TestFailoverAllDown(t *testing.T) {
+	unavailable := errors.New("connection refused")
+	primary := &stubTransport{name: "grpc", errs: []error{unavailable, unavailable}}
+	fallback := &stubTransport{name: "http", errs: []error{unavailable}}
+	f := NewFailover(time.Minute, primary, fallback)
+
+	assert.ErrorIs(t, f.Send(context.Background(), spool.Batch{ID: "1"}), unavailable)
+	assert.NoError(t, f.Send(context.Background(), spool.Batch{ID: "2"}), "down transports should still be tried")
+	assert.Equal(t, []string{"1", "2"}, primary.sent)
+	assert.Equal(t, []string{"1", "2"}, fallback.sent)
+}
@@ -0,0 +1,155 @@
+package sender
+
+import (
+	"github.com/MxTrap/metrics/internal/common/models"
+	"hash/fnv"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// virtualNodes — число точек каждого узла на кольце консистентного хеширования,
+// сглаживает неравномерность распределения метрик между узлами.
+const virtualNodes = 128
+
+// Router возвращает номера получателей метрики с именем id.
+type Router func(id string) []int
+
+// Replicate возвращает Router, отправляющий каждую метрику всем n получателям.
+func Replicate(n int) Router {
+	all := make([]int, n)
+	for i := range all {
+		all[i] = i
+	}
+	return func(string) []int {
+		return all
+	}
+}
+
+// Shard возвращает Router, закрепляющий каждую метрику за одним получателем по консистентному хешу имени.
+// Узлы кольца вычисляются по именам получателей, поэтому добавление получателя
+// переносит на него только часть метрик, не перемешивая остальные.
+func Shard(names []string) Router {
+	type point struct {
+		hash uint32
+		node int
+	}
+	ring := make([]point, 0, len(names)*virtualNodes)
+	for node, name := range names {
+		for v := 0; v < virtualNodes; v++ {
+			ring = append(ring, point{hash: hash(name + "#" + strconv.Itoa(v)), node: node})
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool {
+		return ring[i].hash < ring[j].hash
+	})
+	return func(id string) []int {
+		if len(ring) == 0 {
+			return nil
+		}
+		h := hash(id)
+		i := sort.Search(len(ring), func(i int) bool {
+			return ring[i].hash >= h
+		})
+		if i == len(ring) {
+			i = 0
+		}
+		return []int{ring[i].node}
+	}
+}
+
+func hash(s string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(s))
+	return h.Sum32()
+}
+
+// Fanout раздаёт метрики одного источника нескольким независимым конвейерам отправки.
+// Источник отдаёт приращения счётчиков только один раз, поэтому Fanout забирает их сам
+// и копит отдельно для каждого получателя, пока тот их не заберёт:
+// недоступный сервер не задерживает и не лишает метрик остальные.
+type Fanout struct {
+	mx      sync.Mutex
+	source  metricsSource
+	route   Router
+	pending []map[string]models.Metric
+}
+
+// NewFanout создаёт Fanout источника source на n получателей, распределяя метрики через route.
+func NewFanout(source metricsSource, n int, route Router) *Fanout {
+	pending := make([]map[string]models.Metric, n)
+	for i := range pending {
+		pending[i] = make(map[string]models.Metric)
+	}
+	return &Fanout{
+		source:  source,
+		route:   route,
+		pending: pending,
+	}
+}
+
+// Branch возвращает источник метрик i-го получателя.
+func (f *Fanout) Branch(i int) *Branch {
+	return &Branch{fanout: f, i: i}
+}
+
+// Branch — источник метрик одного получателя Fanout.
+type Branch struct {
+	fanout *Fanout
+	i      int
+}
+
+// TakeMetrics забирает новые метрики источника и возвращает накопленные для получателя.
+func (b *Branch) TakeMetrics() models.Metrics {
+	f := b.fanout
+	f.mx.Lock()
+	defer f.mx.Unlock()
+
+	for _, m := range f.source.TakeMetrics() {
+		for _, i := range f.route(m.ID) {
+			merge(f.pending[i], m)
+		}
+	}
+	taken := f.pending[b.i]
+	f.pending[b.i] = make(map[string]models.Metric, len(taken))
+
+	m := make(models.Metrics, 0, len(taken))
+	for _, metric := range taken {
+		m = append(m, metric)
+	}
+	slices.SortFunc(m, func(a, b models.Metric) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	return m
+}
+
+// ReturnMetrics возвращает получателю приращения счётчиков из недоставленного пакета.
+func (b *Branch) ReturnMetrics(m models.Metrics) {
+	f := b.fanout
+	f.mx.Lock()
+	defer f.mx.Unlock()
+
+	for _, metric := range m {
+		if metric.MType == models.Counter {
+			merge(f.pending[b.i], metric)
+		}
+	}
+}
+
+// merge добавляет метрику к накопленным: приращения счётчиков суммируются, gauge заменяется новым значением.
+func merge(pending map[string]models.Metric, m models.Metric) {
+	switch {
+	case m.MType == models.Counter && m.Delta != nil:
+		delta := *m.Delta
+		if prev, ok := pending[m.ID]; ok && prev.Delta != nil {
+			delta += *prev.Delta
+		}
+		m.Delta = &delta
+	case m.Value != nil:
+		value := *m.Value
+		m.Value = &value
+	}
+	pending[m.ID] = m
+}
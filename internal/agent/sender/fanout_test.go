@@ -0,0 +1,89 @@
+package sender
+
+import (
+	"fmt"
+	"github.com/MxTrap/metrics/internal/common/models"
+	"github.com/MxTrap/metrics/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestShard(t *testing.T) {
+	route := Shard([]string{"a", "b", "c"})
+	counts := make([]int, 3)
+	for i := 0; i < 3000; i++ {
+		id := fmt.Sprintf("metric%d", i)
+		nodes := route(id)
+		require.Len(t, nodes, 1)
+		assert.Equal(t, nodes, route(id), "metric should always go to the same node")
+		counts[nodes[0]]++
+	}
+	for node, n := range counts {
+		assert.Greater(t, n, 500, "node %d should get a fair share of metrics", node)
+	}
+
+	// добавление узла переносит на него только часть метрик
+	grown := Shard([]string{"a", "b", "c", "d"})
+	moved := 0
+	for i := 0; i < 3000; i++ {
+		id := fmt.Sprintf("metric%d", i)
+		if before, after := route(id)[0], grown(id)[0]; before != after {
+			assert.Equal(t, 3, after, "metric may only move to the new node")
+			moved++
+		}
+	}
+	assert.Less(t, moved, 1500)
+}
+
+func TestFanoutReplicate(t *testing.T) {
+	source := &mockSource{}
+	source.On("TakeMetrics").Return(models.Metrics{
+		{ID: "Alloc", MType: models.Gauge, Value: utils.MakePointer(1.0)},
+		{ID: "PollCount", MType: models.Counter, Delta: utils.MakePointer[int64](2)},
+	}).Once()
+	source.On("TakeMetrics").Return(models.Metrics{
+		{ID: "Alloc", MType: models.Gauge, Value: utils.MakePointer(5.0)},
+		{ID: "PollCount", MType: models.Counter, Delta: utils.MakePointer[int64](3)},
+	}).Once()
+	source.On("TakeMetrics").Return(models.Metrics{})
+	f := NewFanout(source, 2, Replicate(2))
+	primary, secondary := f.Branch(0), f.Branch(1)
+
+	assert.Equal(t, models.Metrics{
+		{ID: "Alloc", MType: models.Gauge, Value: utils.MakePointer(1.0)},
+		{ID: "PollCount", MType: models.Counter, Delta: utils.MakePointer[int64](2)},
+	}, primary.TakeMetrics())
+	// primary не смог доставить пакет, secondary не забирал метрики
+	primary.ReturnMetrics(models.Metrics{
+		{ID: "Alloc", MType: models.Gauge, Value: utils.MakePointer(1.0)},
+		{ID: "PollCount", MType: models.Counter, Delta: utils.MakePointer[int64](2)},
+	})
+	assert.Equal(t, models.Metrics{
+		{ID: "Alloc", MType: models.Gauge, Value: utils.MakePointer(5.0)},
+		{ID: "PollCount", MType: models.Counter, Delta: utils.MakePointer[int64](5)},
+	}, primary.TakeMetrics(), "returned increments should be added, gauges replaced")
+	assert.Equal(t, models.Metrics{
+		{ID: "Alloc", MType: models.Gauge, Value: utils.MakePointer(5.0)},
+		{ID: "PollCount", MType: models.Counter, Delta: utils.MakePointer[int64](5)},
+	}, secondary.TakeMetrics(), "each branch should get every increment once")
+}
+
+func TestFanoutShard(t *testing.T) {
+	source := &mockSource{}
+	source.On("TakeMetrics").Return(models.Metrics{
+		{ID: "a", MType: models.Counter, Delta: utils.MakePointer[int64](1)},
+		{ID: "b", MType: models.Counter, Delta: utils.MakePointer[int64](1)},
+	}).Once()
+	source.On("TakeMetrics").Return(models.Metrics{})
+	route := func(id string) []int {
+		if id == "a" {
+			return []int{0}
+		}
+		return []int{1}
+	}
+	f := NewFanout(source, 2, route)
+
+	assert.Equal(t, models.Metrics{{ID: "a", MType: models.Counter, Delta: utils.MakePointer[int64](1)}}, f.Branch(0).TakeMetrics())
+	assert.Equal(t, models.Metrics{{ID: "b", MType: models.Counter, Delta: utils.MakePointer[int64](1)}}, f.Branch(1).TakeMetrics())
+}
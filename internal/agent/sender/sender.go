@@ -0,0 +1,308 @@
+// Package sender отправляет собранные метрики агента на сервер.
+// Sender — единый конвейер отправки: по расписанию забирает метрики из сервиса, сохраняет пакет в буфер
+// и доставляет его через один транспорт, поэтому каждый пакет применяется на сервере ровно один раз.
+package sender
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	agentmodels "github.com/MxTrap/metrics/internal/agent/models"
+	"github.com/MxTrap/metrics/internal/agent/spool"
+	"github.com/MxTrap/metrics/internal/common/models"
+	"io"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Transport доставляет пакет на сервер.
+// Ошибка, оборачивающая spool.ErrRejected, означает, что сервер не примет пакет и при повторе.
+type Transport interface {
+	Name() string
+	Send(ctx context.Context, batch spool.Batch) error
+}
+
+// hinter — транспорт, получающий от сервера предлагаемый интервал отправки.
+type hinter interface {
+	RegisterHintHandler(handler func(reportInterval time.Duration))
+}
+
+// retrier — транспорт, сообщающий о каждом повторе отправки.
+type retrier interface {
+	RegisterRetryHandler(handler func())
+}
+
+type metricsSource interface {
+	TakeMetrics() models.Metrics
+	ReturnMetrics(models.Metrics)
+}
+
+// statsSink принимает собственные метрики конвейера, которые уходят на сервер вместе с остальными.
+type statsSink interface {
+	SavePush(agentmodels.Push)
+}
+
+// SendError описывает неудачную доставку пакета.
+// Rejected означает, что сервер отверг пакет и он не будет отправлен повторно.
+type SendError struct {
+	Transport string
+	BatchID   string
+	Rejected  bool
+	Err       error
+}
+
+func (e *SendError) Error() string {
+	return fmt.Sprintf("send batch %s via %s: %v", e.BatchID, e.Transport, e.Err)
+}
+
+func (e *SendError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorHandler получает ошибки отправки. Ошибки доставки отдельных пакетов имеют тип *SendError.
+type ErrorHandler func(err error)
+
+// Stats — счётчики доставки пакетов конвейером с момента запуска.
+type Stats struct {
+	Sent     int64 // Доставленные пакеты.
+	Failed   int64 // Пакеты, не доставленные из-за сбоя сервера или сети.
+	Rejected int64 // Пакеты, отвергнутые сервером.
+}
+
+type Sender struct {
+	source         metricsSource
+	transport      Transport
+	spool          *spool.Spool
+	reportInterval int
+	rateLimit      int
+	// mx защищает reportInterval, который меняется при применении удалённой конфигурации
+	mx sync.Mutex
+	// intervals передаёт в Run новый интервал отправки
+	intervals chan time.Duration
+	onError   ErrorHandler
+	sink      statsSink
+
+	sent     atomic.Int64
+	failed   atomic.Int64
+	rejected atomic.Int64
+	// dropped — число пакетов, вытесненных из буфера, уже учтённых в собственных метриках
+	dropped atomic.Int64
+}
+
+// NewSender создаёт конвейер, который каждые reportInterval секунд отправляет метрики source через transport
+// не более чем rateLimit запросами одновременно.
+// Если транспорт получает от сервера подсказки интервала, конвейер подстраивается под них.
+func NewSender(source metricsSource, transport Transport, reportInterval int, rateLimit int) *Sender {
+	s := &Sender{
+		source:         source,
+		transport:      transport,
+		reportInterval: reportInterval,
+		rateLimit:      rateLimit,
+		intervals:      make(chan time.Duration, 1),
+		onError: func(err error) {
+			log.Printf("%v", err)
+		},
+	}
+	if h, ok := transport.(hinter); ok {
+		h.RegisterHintHandler(s.SetReportInterval)
+	}
+	if r, ok := transport.(retrier); ok {
+		r.RegisterRetryHandler(func() {
+			s.record(agentmodels.Push{Counters: map[string]int64{"SendRetries": 1}})
+		})
+	}
+	return s
+}
+
+// SetReportInterval меняет интервал отправки работающего конвейера.
+// Интервал не становится меньше заданного при создании: сервер может только замедлить отправку.
+func (s *Sender) SetReportInterval(d time.Duration) {
+	d = max(d, s.baseInterval())
+	s.pushInterval(d)
+}
+
+// UpdateReportInterval заменяет заданный интервал отправки в секундах и сразу применяет его.
+// Подсказки сервера после этого ограничиваются снизу новым значением.
+func (s *Sender) UpdateReportInterval(reportInterval int) {
+	s.mx.Lock()
+	s.reportInterval = reportInterval
+	s.mx.Unlock()
+	s.pushInterval(s.baseInterval())
+}
+
+func (s *Sender) baseInterval() time.Duration {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return time.Second * time.Duration(s.reportInterval)
+}
+
+// pushInterval передаёт интервал в Run.
+func (s *Sender) pushInterval(d time.Duration) {
+	for {
+		select {
+		case s.intervals <- d:
+			return
+		default:
+		}
+		// более раннее, ещё не применённое значение заменяется новым
+		select {
+		case <-s.intervals:
+		default:
+		}
+	}
+}
+
+// RegisterErrorHandler заменяет обработчик ошибок отправки, по умолчанию ошибки пишутся в лог.
+func (s *Sender) RegisterErrorHandler(handler ErrorHandler) {
+	s.onError = handler
+}
+
+// RegisterStatsSink включает собственные метрики конвейера: счётчики SendSuccess, SendFailure, SendRejected,
+// SendRetries и DroppedBatches, таймер SendDuration с длительностью доставки пакетов в секундах,
+// gauge BatchSize с числом метрик в последнем пакете и SpoolDepth с числом пакетов в буфере.
+// Метрики сохраняются в sink и уходят на сервер со следующим пакетом.
+func (s *Sender) RegisterStatsSink(sink statsSink) {
+	s.sink = sink
+}
+
+// Stats возвращает счётчики доставки пакетов.
+func (s *Sender) Stats() Stats {
+	return Stats{
+		Sent:     s.sent.Load(),
+		Failed:   s.failed.Load(),
+		Rejected: s.rejected.Load(),
+	}
+}
+
+// RegisterSpool включает буфер неотправленных пакетов на диске.
+func (s *Sender) RegisterSpool(sp *spool.Spool) {
+	s.spool = sp
+}
+
+// Run отправляет метрики по расписанию до отмены контекста, дожидается завершения начатых отправок
+// и закрывает транспорт, если он реализует io.Closer.
+func (s *Sender) Run(ctx context.Context) {
+	interval := s.baseInterval()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	jobs := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < max(s.rateLimit, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range jobs {
+				if err := s.send(ctx); err != nil {
+					s.onError(err)
+				}
+			}
+		}()
+	}
+
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case d := <-s.intervals:
+			if d != interval {
+				log.Printf("report interval of %s transport changed to %s", s.transport.Name(), d)
+				interval = d
+				ticker.Reset(d)
+			}
+		case <-ticker.C:
+			select {
+			case jobs <- struct{}{}:
+			case <-ctx.Done():
+				break loop
+			}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if closer, ok := s.transport.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.Printf("error closing %s transport: %v", s.transport.Name(), err)
+		}
+	}
+}
+
+// send отправляет на сервер текущие метрики.
+// Если буфер задан, пакет сначала сохраняется в него и отправляется вместе с ранее не доставленными пакетами по порядку.
+func (s *Sender) send(ctx context.Context) error {
+	metrics := s.source.TakeMetrics()
+	batch := spool.NewBatch(metrics)
+	if s.spool != nil {
+		// пакет в буфере считается принятым: его приращения счётчиков уже не вернутся в хранилище
+		err := s.spool.Push(batch)
+		if err == nil {
+			err = s.spool.Drain(func(b spool.Batch) error {
+				return s.deliver(ctx, b)
+			})
+			s.recordSpool()
+			return err
+		}
+		s.onError(fmt.Errorf("could not spool batch: %w", err))
+	}
+	err := s.deliver(ctx, batch)
+	// приращения недоставленного пакета уйдут со следующим, отвергнутый сервером пакет не повторяется
+	if err != nil && !errors.Is(err, spool.ErrRejected) {
+		s.source.ReturnMetrics(metrics)
+	}
+	return err
+}
+
+// deliver отправляет пакет через транспорт и учитывает результат в собственных метриках.
+// Ошибка доставки возвращается как *SendError.
+func (s *Sender) deliver(ctx context.Context, batch spool.Batch) error {
+	start := time.Now()
+	err := s.transport.Send(ctx, batch)
+	rejected := errors.Is(err, spool.ErrRejected)
+
+	counter := "SendSuccess"
+	switch {
+	case err == nil:
+		s.sent.Add(1)
+	case rejected:
+		s.rejected.Add(1)
+		counter = "SendRejected"
+	default:
+		s.failed.Add(1)
+		counter = "SendFailure"
+	}
+	stats := agentmodels.Push{
+		Counters: map[string]int64{counter: 1},
+		Gauges:   map[string]float64{"BatchSize": float64(len(batch.Metrics))},
+		Timings:  map[string][]float64{"SendDuration": {time.Since(start).Seconds()}},
+	}
+	if rejected {
+		stats.Counters["DroppedBatches"] = 1
+	}
+	s.record(stats)
+
+	if err != nil {
+		return &SendError{Transport: s.transport.Name(), BatchID: batch.ID, Rejected: rejected, Err: err}
+	}
+	return nil
+}
+
+// recordSpool сообщает глубину буфера и число пакетов, вытесненных из него с прошлого вызова.
+func (s *Sender) recordSpool() {
+	stats := agentmodels.Push{Gauges: map[string]float64{"SpoolDepth": float64(s.spool.Len())}}
+	total := int64(s.spool.Dropped())
+	if delta := total - s.dropped.Swap(total); delta > 0 {
+		stats.Counters = map[string]int64{"DroppedBatches": delta}
+	}
+	s.record(stats)
+}
+
+// record сохраняет собственные метрики конвейера, если они включены.
+func (s *Sender) record(stats agentmodels.Push) {
+	if s.sink != nil {
+		s.sink.SavePush(stats)
+	}
+}
@@ -0,0 +1,276 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	agentmodels "github.com/MxTrap/metrics/internal/agent/models"
+	"github.com/MxTrap/metrics/internal/agent/spool"
+	"github.com/MxTrap/metrics/internal/common/models"
+	"github.com/MxTrap/metrics/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+type mockSource struct {
+	mock.Mock
+}
+
+func (m *mockSource) TakeMetrics() models.Metrics {
+	args := m.Called()
+	return args.Get(0).(models.Metrics)
+}
+
+func (m *mockSource) ReturnMetrics(metrics models.Metrics) {
+	m.Called(metrics)
+}
+
+// stubTransport возвращает ошибки из errs по очереди, а после них — nil.
+type stubTransport struct {
+	mx     sync.Mutex
+	name   string
+	errs   []error
+	sent   []string
+	closed bool
+}
+
+func (t *stubTransport) Name() string {
+	return t.name
+}
+
+func (t *stubTransport) Send(_ context.Context, batch spool.Batch) error {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.sent = append(t.sent, batch.ID)
+	if len(t.errs) == 0 {
+		return nil
+	}
+	err := t.errs[0]
+	t.errs = t.errs[1:]
+	return err
+}
+
+func (t *stubTransport) Close() error {
+	t.closed = true
+	return nil
+}
+
+func (t *stubTransport) batches() int {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	return len(t.sent)
+}
+
+var pollCount = models.Metrics{
+	{ID: "PollCount", MType: models.Counter, Delta: utils.MakePointer[int64](2)},
+}
+
+func TestSendReturnsUndeliveredCounters(t *testing.T) {
+	source := &mockSource{}
+	source.On("TakeMetrics").Return(pollCount)
+	source.On("ReturnMetrics", pollCount).Return().Once()
+	transport := &stubTransport{name: "http", errs: []error{errors.New("connection refused")}}
+	s := NewSender(source, transport, 1, 1)
+
+	assert.Error(t, s.send(context.Background()))
+	assert.NoError(t, s.send(context.Background()))
+	source.AssertExpectations(t)
+}
+
+func TestSendDropsRejectedBatch(t *testing.T) {
+	source := &mockSource{}
+	source.On("TakeMetrics").Return(pollCount)
+	transport := &stubTransport{name: "http", errs: []error{errors.Join(spool.ErrRejected, errors.New("400 Bad Request"))}}
+	s := NewSender(source, transport, 1, 1)
+
+	assert.ErrorIs(t, s.send(context.Background()), spool.ErrRejected)
+	source.AssertNotCalled(t, "ReturnMetrics", mock.Anything)
+}
+
+func TestSendReplaysSpool(t *testing.T) {
+	sp, err := spool.Open(t.TempDir(), 0)
+	require.NoError(t, err)
+	require.NoError(t, sp.Push(spool.Batch{ID: "first"}))
+
+	source := &mockSource{}
+	source.On("TakeMetrics").Return(pollCount)
+	transport := &stubTransport{name: "http", errs: []error{errors.New("connection refused")}}
+	s := NewSender(source, transport, 1, 1)
+	s.RegisterSpool(sp)
+
+	assert.Error(t, s.send(context.Background()))
+	assert.Equal(t, 2, sp.Len(), "undelivered batches should stay in the spool")
+
+	require.NoError(t, s.send(context.Background()))
+	require.Len(t, transport.sent, 4)
+	assert.Equal(t, []string{"first", "first"}, transport.sent[:2], "spooled batches should be replayed in order")
+	assert.NotEqual(t, transport.sent[2], transport.sent[3], "each batch should have its own id")
+	assert.Equal(t, 0, sp.Len())
+	source.AssertNotCalled(t, "ReturnMetrics", mock.Anything)
+}
+
+type stubSink struct {
+	mx     sync.Mutex
+	pushes []agentmodels.Push
+}
+
+func (s *stubSink) SavePush(p agentmodels.Push) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.pushes = append(s.pushes, p)
+}
+
+func TestSendReportsStats(t *testing.T) {
+	source := &mockSource{}
+	source.On("TakeMetrics").Return(pollCount)
+	source.On("ReturnMetrics", pollCount).Return()
+	transport := &stubTransport{name: "grpc", errs: []error{
+		errors.New("connection refused"),
+		errors.Join(spool.ErrRejected, errors.New("invalid metric")),
+	}}
+	sink := &stubSink{}
+	s := NewSender(source, transport, 1, 1)
+	s.RegisterStatsSink(sink)
+
+	err := s.send(context.Background())
+	var sendErr *SendError
+	require.ErrorAs(t, err, &sendErr, "delivery errors should be structured")
+	assert.Equal(t, "grpc", sendErr.Transport)
+	assert.Equal(t, transport.sent[0], sendErr.BatchID)
+	assert.False(t, sendErr.Rejected)
+
+	err = s.send(context.Background())
+	require.ErrorAs(t, err, &sendErr)
+	assert.True(t, sendErr.Rejected)
+	assert.ErrorIs(t, err, spool.ErrRejected)
+
+	require.NoError(t, s.send(context.Background()))
+
+	assert.Equal(t, Stats{Sent: 1, Failed: 1, Rejected: 1}, s.Stats())
+	require.Len(t, sink.pushes, 3)
+	counters := map[string]int64{}
+	for _, p := range sink.pushes {
+		for name, delta := range p.Counters {
+			counters[name] += delta
+		}
+		assert.Len(t, p.Timings["SendDuration"], 1, "each delivery should be timed")
+	}
+	assert.Equal(t, map[string]int64{"SendFailure": 1, "SendRejected": 1, "SendSuccess": 1, "DroppedBatches": 1}, counters)
+	assert.Equal(t, float64(len(pollCount)), sink.pushes[2].Gauges["BatchSize"])
+}
+
+type retryTransport struct {
+	stubTransport
+	handler func()
+}
+
+func (t *retryTransport) RegisterRetryHandler(handler func()) {
+	t.handler = handler
+}
+
+func TestSenderCountsRetries(t *testing.T) {
+	transport := &retryTransport{stubTransport: stubTransport{name: "http"}}
+	sink := &stubSink{}
+	s := NewSender(&mockSource{}, NewFailover(DefaultRetryAfter, transport), 1, 1)
+	s.RegisterStatsSink(sink)
+	require.NotNil(t, transport.handler, "sender should subscribe to transport retries through failover")
+
+	transport.handler()
+	require.Len(t, sink.pushes, 1)
+	assert.Equal(t, map[string]int64{"SendRetries": 1}, sink.pushes[0].Counters)
+}
+
+func TestSendReportsSpool(t *testing.T) {
+	sp, err := spool.Open(t.TempDir(), 1)
+	require.NoError(t, err)
+
+	source := &mockSource{}
+	source.On("TakeMetrics").Return(pollCount)
+	transport := &stubTransport{name: "http", errs: []error{errors.New("connection refused"), errors.New("connection refused")}}
+	sink := &stubSink{}
+	s := NewSender(source, transport, 1, 1)
+	s.RegisterSpool(sp)
+	s.RegisterStatsSink(sink)
+
+	assert.Error(t, s.send(context.Background()))
+	assert.Error(t, s.send(context.Background()), "second batch should evict the first one")
+
+	last := sink.pushes[len(sink.pushes)-1]
+	assert.Equal(t, float64(1), last.Gauges["SpoolDepth"])
+	assert.Equal(t, map[string]int64{"DroppedBatches": 1}, last.Counters)
+}
+
+func TestRunReportsErrors(t *testing.T) {
+	source := &mockSource{}
+	source.On("TakeMetrics").Return(pollCount)
+	source.On("ReturnMetrics", pollCount).Return()
+	transport := &stubTransport{name: "http", errs: []error{errors.New("connection refused")}}
+	s := NewSender(source, transport, 1, 2)
+	var mx sync.Mutex
+	var reported []error
+	s.RegisterErrorHandler(func(err error) {
+		mx.Lock()
+		defer mx.Unlock()
+		reported = append(reported, err)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1500*time.Millisecond)
+	defer cancel()
+	s.Run(ctx)
+
+	mx.Lock()
+	defer mx.Unlock()
+	require.Len(t, reported, 1)
+	assert.EqualError(t, reported[0], "send batch "+transport.sent[0]+" via http: connection refused")
+}
+
+func TestRun(t *testing.T) {
+	source := &mockSource{}
+	source.On("TakeMetrics").Return(pollCount)
+	transport := &stubTransport{name: "http"}
+	s := NewSender(source, transport, 1, 2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2500*time.Millisecond)
+	defer cancel()
+	s.Run(ctx)
+
+	assert.GreaterOrEqual(t, transport.batches(), 2, "should send at least 2 batches")
+	assert.True(t, transport.closed, "transport should be closed after run")
+}
+
+type hintTransport struct {
+	stubTransport
+	handler func(time.Duration)
+}
+
+func (t *hintTransport) RegisterHintHandler(handler func(time.Duration)) {
+	t.handler = handler
+}
+
+func TestSenderFollowsReportIntervalHints(t *testing.T) {
+	transport := &hintTransport{stubTransport: stubTransport{name: "grpc"}}
+	s := NewSender(&mockSource{}, NewFailover(DefaultRetryAfter, transport), 2, 1)
+	require.NotNil(t, transport.handler, "sender should subscribe to transport hints through failover")
+
+	transport.handler(time.Second)
+	assert.Equal(t, 2*time.Second, <-s.intervals, "hint cannot make reports more frequent than configured")
+
+	transport.handler(10 * time.Second)
+	transport.handler(20 * time.Second)
+	assert.Equal(t, 20*time.Second, <-s.intervals, "only the latest hint is applied")
+	assert.Empty(t, s.intervals)
+}
+
+func TestUpdateReportInterval(t *testing.T) {
+	transport := &hintTransport{stubTransport: stubTransport{name: "grpc"}}
+	s := NewSender(&mockSource{}, transport, 2, 1)
+
+	s.UpdateReportInterval(5)
+	assert.Equal(t, 5*time.Second, <-s.intervals, "new interval should be applied immediately")
+
+	transport.handler(3 * time.Second)
+	assert.Equal(t, 5*time.Second, <-s.intervals, "hints are bounded by the updated interval")
+}
@@ -1,97 +1,259 @@
 // Package service предоставляет сервис для сбора и хранения системных метрик.
-// Реализует MetricsObserverService, который периодически собирает метрики памяти и CPU, сохраняя их в хранилище.
+// Реализует MetricsObserverService, который периодически опрашивает зарегистрированные коллекторы, сохраняя метрики в хранилище.
 package service
 
 import (
 	"context"
-	"github.com/MxTrap/metrics/internal/agent/mappers"
+	"github.com/MxTrap/metrics/internal/agent/collector"
 	"github.com/MxTrap/metrics/internal/agent/models"
 	common "github.com/MxTrap/metrics/internal/common/models"
-	"github.com/shirou/gopsutil/cpu"
-	"github.com/shirou/gopsutil/mem"
-	"runtime"
+	"log"
+	"sync"
 	"time"
 )
 
 type MetricsStorage interface {
-	SaveMetrics(map[string]float64)
+	SaveGauges(map[string]float64)
+	SavePoll()
+	SaveCounters(map[string]int64)
 	GetMetrics() models.Metrics
+	TakeMetrics() models.Metrics
+	ReturnCounters(models.CounterMetrics)
 }
 
+// statsSink принимает собственные метрики сервиса, которые уходят на сервер вместе с остальными.
+type statsSink interface {
+	SavePush(models.Push)
+}
+
+// ErrorHandler получает ошибки сбора метрик вместе с именем коллектора.
+type ErrorHandler func(collector string, err error)
+
 type MetricsObserverService struct {
 	storage      MetricsStorage
 	pollInterval int
+	collectors   []collector.Entry
+	onError      ErrorHandler
+	sink         statsSink
+	// mx защищает настройки опроса, которые Reconfigure меняет во время работы.
+	mx sync.Mutex
+	// reload сигнализирует Run о необходимости перезапустить коллекторы с новыми настройками.
+	reload chan struct{}
 }
 
 // NewMetricsObserverService создаёт новый MetricsObserverService с указанным хранилищем и интервалом опроса.
+// Интервал опроса используется для коллекторов, у которых собственный интервал не задан.
 // Возвращает указатель на инициализированный MetricsObserverService.
 func NewMetricsObserverService(service MetricsStorage, pollInterval int) *MetricsObserverService {
 	return &MetricsObserverService{
 		storage:      service,
 		pollInterval: pollInterval,
+		reload:       make(chan struct{}, 1),
+		onError: func(name string, err error) {
+			log.Printf("collector %s: %v", name, err)
+		},
 	}
 }
 
-// Run запускает сервис, периодически собирая метрики памяти и CPU.
-// Выполняется до отмены контекста, после чего останавливает сбор метрик.
+// RegisterCollector добавляет коллектор, который будет опрашиваться при запуске сервиса.
+func (s *MetricsObserverService) RegisterCollector(c collector.Collector, opts collector.Options) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.collectors = append(s.collectors, collector.Entry{Collector: c, Options: opts})
+}
+
+// RegisterErrorHandler заменяет обработчик ошибок сбора метрик, по умолчанию ошибки пишутся в лог.
+func (s *MetricsObserverService) RegisterErrorHandler(handler ErrorHandler) {
+	s.onError = handler
+}
+
+// RegisterStatsSink включает собственные метрики опроса: таймер CollectDuration.<коллектор> с длительностью
+// опроса в секундах и счётчик CollectErrors.<коллектор> с числом неудачных опросов.
+func (s *MetricsObserverService) RegisterStatsSink(sink statsSink) {
+	s.sink = sink
+}
+
+// Reconfigure заменяет интервал опроса и набор коллекторов.
+// Если сервис запущен, коллекторы перезапускаются с новыми настройками, накопленные метрики сохраняются.
+func (s *MetricsObserverService) Reconfigure(pollInterval int, collectors []collector.Entry) {
+	s.mx.Lock()
+	s.pollInterval = pollInterval
+	s.collectors = collectors
+	s.mx.Unlock()
+
+	select {
+	case s.reload <- struct{}{}:
+	default:
+	}
+}
+
+// Run запускает опрос всех зарегистрированных коллекторов, каждый по своему расписанию,
+// и счётчик опросов PollCount с интервалом опроса.
+// Выполняется до отмены контекста, после чего дожидается остановки всех коллекторов.
+// После Reconfigure коллекторы останавливаются и запускаются заново с новыми настройками.
 func (s *MetricsObserverService) Run(ctx context.Context) {
-	ticker := time.NewTicker(time.Second * time.Duration(s.pollInterval))
-	go func(service *MetricsObserverService) {
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-ticker.C:
-				service.collectMemStatMetrics()
-			}
+	for {
+		runCtx, cancel := context.WithCancel(ctx)
+		wg := s.start(runCtx)
+
+		select {
+		case <-ctx.Done():
+		case <-s.reload:
 		}
-	}(s)
-	go func(service *MetricsObserverService) {
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-ticker.C:
-				service.collectGopsutilMetrics()
-			}
+		cancel()
+		wg.Wait()
+
+		if ctx.Err() != nil {
+			return
 		}
-	}(s)
+	}
+}
 
-	<-ctx.Done()
-	ticker.Stop()
+// start запускает коллекторы и счётчик опросов по текущим настройкам.
+func (s *MetricsObserverService) start(ctx context.Context) *sync.WaitGroup {
+	s.mx.Lock()
+	pollInterval, collectors := s.pollInterval, s.collectors
+	s.mx.Unlock()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.poll(ctx, pollInterval)
+	}()
+	for _, e := range collectors {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.runCollector(ctx, e, pollInterval)
+		}()
+	}
+	return &wg
 }
 
-// collectMemStatMetrics собирает метрики памяти с использованием runtime.MemStats.
-// Сохраняет метрики в хранилище через SaveMetrics.
-func (s *MetricsObserverService) collectMemStatMetrics() {
-	var ms runtime.MemStats
-	runtime.ReadMemStats(&ms)
-	s.storage.SaveMetrics(mappers.MapGaugeMetrics(ms))
+// poll отмечает опрос в хранилище каждый интервал опроса до отмены контекста.
+func (s *MetricsObserverService) poll(ctx context.Context, pollInterval int) {
+	ticker := time.NewTicker(time.Second * time.Duration(pollInterval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.storage.SavePoll()
+		}
+	}
 }
 
-// collectGopsutilMetrics собирает метрики памяти и CPU с использованием gopsutil.
-// Сохраняет метрики общей и свободной памяти, а также использования CPU в хранилище.
-func (s *MetricsObserverService) collectGopsutilMetrics() {
-	v, err := mem.VirtualMemory()
-	if err != nil {
-		return
+// runCollector опрашивает один коллектор с его интервалом до отмены контекста.
+func (s *MetricsObserverService) runCollector(ctx context.Context, e collector.Entry, pollInterval int) {
+	interval := e.Options.Interval
+	if interval <= 0 {
+		interval = time.Second * time.Duration(pollInterval)
 	}
-	info, err := cpu.Percent(0, false)
-	if err != nil || len(info) == 0 {
-		return
+	timeout := e.Options.Timeout
+	if timeout <= 0 {
+		timeout = interval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.collect(ctx, e.Collector, timeout)
+		}
+	}
+}
+
+type collectResult struct {
+	sample collector.Sample
+	err    error
+}
+
+// collect выполняет один опрос коллектора с таймаутом и сохраняет результат в хранилище.
+// Результат, полученный после истечения таймаута, отбрасывается; частичный результат вместе с ошибкой сохраняется.
+func (s *MetricsObserverService) collect(ctx context.Context, c collector.Collector, timeout time.Duration) {
+	collectCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	start := time.Now()
+	failed := false
+	defer func() {
+		if s.sink == nil || ctx.Err() != nil {
+			return
+		}
+		stats := models.Push{Timings: map[string][]float64{"CollectDuration." + c.Name(): {time.Since(start).Seconds()}}}
+		if failed {
+			stats.Counters = map[string]int64{"CollectErrors." + c.Name(): 1}
+		}
+		s.sink.SavePush(stats)
+	}()
+
+	done := make(chan collectResult, 1)
+	go func() {
+		sample, err := c.Collect(collectCtx)
+		done <- collectResult{sample: sample, err: err}
+	}()
+
+	select {
+	case <-collectCtx.Done():
+		if ctx.Err() == nil {
+			failed = true
+			s.onError(c.Name(), collectCtx.Err())
+		}
+	case res := <-done:
+		if len(res.sample.Gauges) > 0 {
+			s.storage.SaveGauges(res.sample.Gauges)
+		}
+		if len(res.sample.Counters) > 0 {
+			s.storage.SaveCounters(res.sample.Counters)
+		}
+		if res.err != nil {
+			failed = true
+			s.onError(c.Name(), res.err)
+		}
 	}
-	s.storage.SaveMetrics(map[string]float64{
-		"TotalMemory":     float64(v.Total),
-		"FreeMemory":      float64(v.Free),
-		"CPUutilization1": info[0],
-	})
 }
 
 // GetMetrics возвращает все метрики из хранилища.
 // Возвращает массив models.Metrics, содержащую сохранённые метрики.
+// Счётчики остаются в хранилище, для отправки на сервер используется TakeMetrics.
 func (s *MetricsObserverService) GetMetrics() common.Metrics {
-	metrics := s.storage.GetMetrics()
-	m := make([]common.Metric, 0, len(metrics.Gauge.Metrics)+1)
+	return toCommon(s.storage.GetMetrics())
+}
+
+// TakeMetrics возвращает метрики для отправки на сервер.
+// Counter-метрики содержат приращения с предыдущего вызова: изъятые значения больше не хранятся,
+// поэтому несколько клиентов и обработчиков отправляют каждое приращение ровно один раз.
+// Если пакет не доставлен, его нужно вернуть через ReturnMetrics.
+func (s *MetricsObserverService) TakeMetrics() common.Metrics {
+	return toCommon(s.storage.TakeMetrics())
+}
+
+// ReturnMetrics возвращает в хранилище приращения счётчиков из недоставленного пакета,
+// чтобы они ушли со следующим пакетом. Остальные метрики пакета отбрасываются.
+func (s *MetricsObserverService) ReturnMetrics(m common.Metrics) {
+	var counters models.CounterMetrics
+	for _, metric := range m {
+		if metric.MType != common.Counter || metric.Delta == nil {
+			continue
+		}
+		if metric.ID == "PollCount" {
+			counters.PollCount += *metric.Delta
+			continue
+		}
+		if counters.Metrics == nil {
+			counters.Metrics = make(map[string]int64)
+		}
+		counters.Metrics[metric.ID] += *metric.Delta
+	}
+	s.storage.ReturnCounters(counters)
+}
+
+func toCommon(metrics models.Metrics) common.Metrics {
+	m := make([]common.Metric, 0, len(metrics.Gauge.Metrics)+len(metrics.Counter.Metrics)+4*len(metrics.Timers)+3*len(metrics.GaugeWindows)+1)
 
 	metrics.Gauge.Range(func(key string, value float64) {
 		m = append(m, common.Metric{
@@ -101,6 +263,45 @@ func (s *MetricsObserverService) GetMetrics() common.Metrics {
 		})
 	})
 
+	for key, delta := range metrics.Counter.Metrics {
+		m = append(m, common.Metric{
+			ID:    key,
+			MType: common.Counter,
+			Delta: &delta,
+		})
+	}
+
+	// сводка таймера за окно передаётся как gauge <name>.min, <name>.max, <name>.avg и <name>.count
+	for key, summary := range metrics.Timers {
+		for suffix, value := range map[string]float64{
+			"min":   summary.Min,
+			"max":   summary.Max,
+			"avg":   summary.Avg(),
+			"count": float64(summary.Count),
+		} {
+			m = append(m, common.Metric{
+				ID:    key + "." + suffix,
+				MType: common.Gauge,
+				Value: &value,
+			})
+		}
+	}
+
+	// сводка gauge за период между отчётами дополняет последнее значение метриками <name>.min, <name>.max и <name>.avg
+	for key, summary := range metrics.GaugeWindows {
+		for suffix, value := range map[string]float64{
+			"min": summary.Min,
+			"max": summary.Max,
+			"avg": summary.Avg(),
+		} {
+			m = append(m, common.Metric{
+				ID:    key + "." + suffix,
+				MType: common.Gauge,
+				Value: &value,
+			})
+		}
+	}
+
 	m = append(m, common.Metric{
 		ID:    "PollCount",
 		MType: common.Counter,
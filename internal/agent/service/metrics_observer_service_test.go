@@ -2,11 +2,16 @@ package service
 
 import (
 	"context"
+	"errors"
+	"github.com/MxTrap/metrics/internal/agent/collector"
 	"github.com/MxTrap/metrics/internal/agent/models"
 	common "github.com/MxTrap/metrics/internal/common/models"
 	"github.com/MxTrap/metrics/internal/utils"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -15,7 +20,15 @@ type MockMetricsStorage struct {
 	mock.Mock
 }
 
-func (m *MockMetricsStorage) SaveMetrics(metrics map[string]float64) {
+func (m *MockMetricsStorage) SaveGauges(metrics map[string]float64) {
+	m.Called(metrics)
+}
+
+func (m *MockMetricsStorage) SavePoll() {
+	m.Called()
+}
+
+func (m *MockMetricsStorage) SaveCounters(metrics map[string]int64) {
 	m.Called(metrics)
 }
 
@@ -24,6 +37,35 @@ func (m *MockMetricsStorage) GetMetrics() models.Metrics {
 	return args.Get(0).(models.Metrics)
 }
 
+func (m *MockMetricsStorage) TakeMetrics() models.Metrics {
+	args := m.Called()
+	return args.Get(0).(models.Metrics)
+}
+
+func (m *MockMetricsStorage) ReturnCounters(c models.CounterMetrics) {
+	m.Called(c)
+}
+
+type stubCollector struct {
+	name   string
+	sample collector.Sample
+	err    error
+	delay  time.Duration
+}
+
+func (c *stubCollector) Name() string {
+	return c.name
+}
+
+func (c *stubCollector) Collect(ctx context.Context) (collector.Sample, error) {
+	select {
+	case <-time.After(c.delay):
+	case <-ctx.Done():
+		return collector.Sample{}, ctx.Err()
+	}
+	return c.sample, c.err
+}
+
 func TestGetMetrics(t *testing.T) {
 	storage := &MockMetricsStorage{}
 	service := NewMetricsObserverService(storage, 2)
@@ -55,33 +97,33 @@ func TestGetMetrics(t *testing.T) {
 func TestRun(t *testing.T) {
 	mockStorage := &MockMetricsStorage{}
 	s := NewMetricsObserverService(mockStorage, 1) // Маленький интервал для быстрого теста
+	s.RegisterCollector(&stubCollector{name: "stub", sample: collector.Sample{Gauges: map[string]float64{"Alloc": 1}}}, collector.Options{})
 
-	// Подготовка контекста с таймаутом
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// Ожидаем вызов SaveMetrics хотя бы один раз
-	mockStorage.On("SaveMetrics", mock.Anything).Return()
-
-	// Запуск Run в отдельной горутине
-	go s.Run(ctx)
+	// Ожидаем вызов SaveGauges и SavePoll хотя бы один раз
+	var gauges, polls atomic.Int32
+	mockStorage.On("SaveGauges", mock.Anything).Run(func(mock.Arguments) { gauges.Add(1) }).Return()
+	mockStorage.On("SavePoll").Run(func(mock.Arguments) { polls.Add(1) }).Return()
 
-	// Ждем немного, чтобы горутины выполнили хотя бы один цикл
-	time.Sleep(1500 * time.Millisecond)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Run(ctx)
+	}()
 
-	// Отменяем контекст
+	assert.Eventually(t, func() bool {
+		return gauges.Load() > 0 && polls.Load() > 0
+	}, 5*time.Second, 10*time.Millisecond, "collector and poll counter should run")
 	cancel()
-
-	// Даем время на завершение
-	time.Sleep(100 * time.Millisecond)
-
-	// Проверка, что SaveMetrics был вызван
-	mockStorage.AssertCalled(t, "SaveMetrics", mock.Anything)
+	<-done
 }
 
 func TestRun_ContextCancellation(t *testing.T) {
 	mockStorage := &MockMetricsStorage{}
 	s := NewMetricsObserverService(mockStorage, 1)
+	s.RegisterCollector(&stubCollector{name: "stub"}, collector.Options{})
 
 	// Контекст с немедленной отменой
 	ctx, cancel := context.WithCancel(context.Background())
@@ -90,6 +132,221 @@ func TestRun_ContextCancellation(t *testing.T) {
 	// Запуск Run
 	s.Run(ctx)
 
-	// Проверка, что SaveMetrics не был вызван
-	mockStorage.AssertNotCalled(t, "SaveMetrics")
+	// Проверка, что SaveGauges и SavePoll не были вызваны
+	mockStorage.AssertNotCalled(t, "SaveGauges")
+	mockStorage.AssertNotCalled(t, "SavePoll")
+}
+
+func TestCollectErrors(t *testing.T) {
+	mockStorage := &MockMetricsStorage{}
+	s := NewMetricsObserverService(mockStorage, 1)
+	failing := &stubCollector{name: "failing", err: errors.New("boom")}
+	slow := &stubCollector{name: "slow", sample: collector.Sample{Gauges: map[string]float64{"Slow": 1}}, delay: time.Second}
+
+	reported := map[string]error{}
+	s.RegisterErrorHandler(func(name string, err error) {
+		reported[name] = err
+	})
+
+	s.collect(context.Background(), failing, time.Second)
+	s.collect(context.Background(), slow, 10*time.Millisecond)
+
+	assert.EqualError(t, reported["failing"], "boom")
+	assert.ErrorIs(t, reported["slow"], context.DeadlineExceeded)
+	mockStorage.AssertNotCalled(t, "SaveGauges")
+}
+
+func TestRunPerCollectorInterval(t *testing.T) {
+	mockStorage := &MockMetricsStorage{}
+	fast := map[string]float64{"Fast": 1}
+	mockStorage.On("SaveGauges", fast).Return()
+
+	s := NewMetricsObserverService(mockStorage, 10)
+	s.RegisterCollector(&stubCollector{name: "fast", sample: collector.Sample{Gauges: fast}}, collector.Options{Interval: 50 * time.Millisecond})
+	s.RegisterCollector(&stubCollector{name: "slow", sample: collector.Sample{Gauges: map[string]float64{"Slow": 1}}}, collector.Options{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	s.Run(ctx)
+
+	mockStorage.AssertCalled(t, "SaveGauges", fast)
+	mockStorage.AssertNotCalled(t, "SaveGauges", map[string]float64{"Slow": 1})
+}
+
+func TestCollectDoesNotCountPolls(t *testing.T) {
+	mockStorage := &MockMetricsStorage{}
+	mockStorage.On("SaveGauges", mock.Anything).Return()
+
+	s := NewMetricsObserverService(mockStorage, 1)
+	for _, name := range []string{"runtime", "system", "network"} {
+		s.collect(context.Background(), &stubCollector{name: name, sample: collector.Sample{Gauges: map[string]float64{name: 1}}}, time.Second)
+	}
+
+	mockStorage.AssertNumberOfCalls(t, "SaveGauges", 3)
+	mockStorage.AssertNotCalled(t, "SavePoll")
+}
+
+func TestCollectPartialSample(t *testing.T) {
+	mockStorage := &MockMetricsStorage{}
+	gauges := map[string]float64{"LoadAverage1": 0.5}
+	counters := map[string]int64{"NetBytesSent.eth0": 10}
+	mockStorage.On("SaveGauges", gauges).Return()
+	mockStorage.On("SaveCounters", counters).Return()
+
+	s := NewMetricsObserverService(mockStorage, 1)
+	var reported error
+	s.RegisterErrorHandler(func(_ string, err error) {
+		reported = err
+	})
+	partial := &stubCollector{
+		name:   "partial",
+		sample: collector.Sample{Gauges: gauges, Counters: counters},
+		err:    errors.New("diskstats unavailable"),
+	}
+
+	s.collect(context.Background(), partial, time.Second)
+
+	assert.EqualError(t, reported, "diskstats unavailable")
+	mockStorage.AssertExpectations(t)
+}
+
+func TestGetMetricsCounters(t *testing.T) {
+	storage := &MockMetricsStorage{}
+	service := NewMetricsObserverService(storage, 2)
+	storage.On("GetMetrics").Return(models.Metrics{
+		Gauge: *models.NewGaugeMetrics(),
+		Counter: models.CounterMetrics{
+			PollCount: 1,
+			Metrics:   map[string]int64{"DiskReads.sda": 7},
+		},
+	})
+
+	expected := []common.Metric{
+		{ID: "DiskReads.sda", MType: common.Counter, Delta: utils.MakePointer[int64](7)},
+		{ID: "PollCount", MType: common.Counter, Delta: utils.MakePointer[int64](1)},
+	}
+	assert.ElementsMatch(t, expected, service.GetMetrics())
+}
+
+func TestGetMetricsTimers(t *testing.T) {
+	storage := &MockMetricsStorage{}
+	service := NewMetricsObserverService(storage, 2)
+	storage.On("GetMetrics").Return(models.Metrics{
+		Gauge:  *models.NewGaugeMetrics(),
+		Timers: map[string]models.Summary{"api.latency": {Count: 4, Sum: 100, Min: 10, Max: 40}},
+	})
+
+	expected := []common.Metric{
+		{ID: "api.latency.min", MType: common.Gauge, Value: utils.MakePointer(10.0)},
+		{ID: "api.latency.max", MType: common.Gauge, Value: utils.MakePointer(40.0)},
+		{ID: "api.latency.avg", MType: common.Gauge, Value: utils.MakePointer(25.0)},
+		{ID: "api.latency.count", MType: common.Gauge, Value: utils.MakePointer(4.0)},
+		{ID: "PollCount", MType: common.Counter, Delta: utils.MakePointer[int64](0)},
+	}
+	assert.ElementsMatch(t, expected, service.GetMetrics())
+}
+
+func TestTakeAndReturnMetrics(t *testing.T) {
+	storage := &MockMetricsStorage{}
+	service := NewMetricsObserverService(storage, 1)
+	storage.On("TakeMetrics").Return(models.Metrics{
+		Gauge: *models.NewGaugeMetrics(),
+		Counter: models.CounterMetrics{
+			PollCount: 3,
+			Metrics:   map[string]int64{"DiskReads.sda": 7},
+		},
+	})
+	storage.On("ReturnCounters", models.CounterMetrics{
+		PollCount: 3,
+		Metrics:   map[string]int64{"DiskReads.sda": 7},
+	}).Return()
+
+	taken := service.TakeMetrics()
+	assert.ElementsMatch(t, common.Metrics{
+		{ID: "DiskReads.sda", MType: common.Counter, Delta: utils.MakePointer[int64](7)},
+		{ID: "PollCount", MType: common.Counter, Delta: utils.MakePointer[int64](3)},
+	}, taken)
+
+	service.ReturnMetrics(append(taken, common.Metric{ID: "Alloc", MType: common.Gauge, Value: utils.MakePointer(1.0)}))
+	storage.AssertExpectations(t)
+}
+
+func TestGetMetricsGaugeWindows(t *testing.T) {
+	storage := &MockMetricsStorage{}
+	service := NewMetricsObserverService(storage, 1)
+	storage.On("GetMetrics").Return(models.Metrics{
+		Gauge:        *models.NewGaugeMetrics(),
+		GaugeWindows: map[string]models.Summary{"CPU": {Count: 2, Sum: 100, Min: 10, Max: 90}},
+	})
+	expected := common.Metrics{
+		{ID: "CPU.min", MType: common.Gauge, Value: utils.MakePointer(10.0)},
+		{ID: "CPU.max", MType: common.Gauge, Value: utils.MakePointer(90.0)},
+		{ID: "CPU.avg", MType: common.Gauge, Value: utils.MakePointer(50.0)},
+		{ID: "PollCount", MType: common.Counter, Delta: utils.MakePointer[int64](0)},
+	}
+	assert.ElementsMatch(t, expected, service.GetMetrics())
+}
+
+func TestReconfigure(t *testing.T) {
+	mockStorage := &MockMetricsStorage{}
+	before := map[string]float64{"Before": 1}
+	after := map[string]float64{"After": 1}
+	mockStorage.On("SaveGauges", before).Return()
+	collected := make(chan struct{})
+	var once sync.Once
+	mockStorage.On("SaveGauges", after).Run(func(mock.Arguments) {
+		once.Do(func() { close(collected) })
+	}).Return()
+
+	s := NewMetricsObserverService(mockStorage, 10)
+	s.RegisterCollector(&stubCollector{name: "before", sample: collector.Sample{Gauges: before}}, collector.Options{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Run(ctx)
+	}()
+
+	s.Reconfigure(10, []collector.Entry{{
+		Collector: &stubCollector{name: "after", sample: collector.Sample{Gauges: after}},
+		Options:   collector.Options{Interval: 20 * time.Millisecond},
+	}})
+
+	select {
+	case <-collected:
+	case <-time.After(time.Second):
+		t.Fatal("reconfigured collector was not polled")
+	}
+	cancel()
+	<-done
+
+	mockStorage.AssertNotCalled(t, "SaveGauges", before)
+}
+
+type stubSink struct {
+	pushes []models.Push
+}
+
+func (s *stubSink) SavePush(p models.Push) {
+	s.pushes = append(s.pushes, p)
+}
+
+func TestCollectReportsStats(t *testing.T) {
+	mockStorage := &MockMetricsStorage{}
+	gauges := map[string]float64{"Alloc": 1}
+	mockStorage.On("SaveGauges", gauges).Return()
+	sink := &stubSink{}
+	s := NewMetricsObserverService(mockStorage, 1)
+	s.RegisterErrorHandler(func(string, error) {})
+	s.RegisterStatsSink(sink)
+
+	s.collect(context.Background(), &stubCollector{name: "runtime", sample: collector.Sample{Gauges: gauges}}, time.Second)
+	s.collect(context.Background(), &stubCollector{name: "slow", delay: time.Second}, 10*time.Millisecond)
+
+	require.Len(t, sink.pushes, 2)
+	assert.Len(t, sink.pushes[0].Timings["CollectDuration.runtime"], 1, "collection should be timed")
+	assert.Empty(t, sink.pushes[0].Counters)
+	assert.GreaterOrEqual(t, sink.pushes[1].Timings["CollectDuration.slow"][0], 0.01)
+	assert.Equal(t, map[string]int64{"CollectErrors.slow": 1}, sink.pushes[1].Counters)
 }
@@ -0,0 +1,207 @@
+// Package spool хранит на диске пакеты метрик, которые агент не смог отправить на сервер.
+// Пакеты отправляются повторно в порядке поступления, когда сервер снова доступен.
+// Каждый пакет несёт идентификатор, по которому сервер отбрасывает уже применённые повторы,
+// поэтому счётчики не удваиваются, если ответ на успешную отправку был потерян.
+package spool
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/MxTrap/metrics/internal/common/models"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrRejected оборачивает ошибку отправки пакета, который сервер отверг и повторять который бессмысленно.
+var ErrRejected = errors.New("batch rejected by server")
+
+const batchExt = ".json"
+
+// Batch — пакет метрик с идентификатором для дедупликации на сервере.
+type Batch struct {
+	ID      string         `json:"id"`
+	Metrics models.Metrics `json:"metrics"`
+	// file — имя файла пакета в буфере, пусто для пакета, ещё не сохранённого на диск
+	file string
+}
+
+// NewBatch создаёт пакет со случайным идентификатором.
+func NewBatch(metrics models.Metrics) Batch {
+	return Batch{ID: rand.Text(), Metrics: metrics}
+}
+
+// Spool — ограниченная очередь пакетов на диске, по файлу на пакет.
+// Имена файлов — возрастающие порядковые номера, поэтому порядок пакетов сохраняется между перезапусками агента.
+// При переполнении вытесняются самые старые пакеты.
+type Spool struct {
+	mx         sync.Mutex
+	dir        string
+	maxBatches int
+	seq        uint64
+	files      []string
+	// dropped — число пакетов, вытесненных при переполнении или удалённых как повреждённые
+	dropped uint64
+	// drainMx не даёт нескольким отправителям повторять одни и те же пакеты одновременно
+	drainMx sync.Mutex
+}
+
+// Open открывает буфер в каталоге dir, создавая его при необходимости, и подхватывает пакеты,
+// оставшиеся от предыдущего запуска. maxBatches ограничивает число хранимых пакетов, 0 снимает ограничение.
+func Open(dir string, maxBatches int) (*Spool, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	s := &Spool{dir: dir, maxBatches: maxBatches}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, batchExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, batchExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		s.seq = max(s.seq, seq)
+		s.files = append(s.files, name)
+	}
+	sort.Strings(s.files)
+	return s, nil
+}
+
+// Len возвращает число пакетов в буфере.
+func (s *Spool) Len() int {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return len(s.files)
+}
+
+// Dropped возвращает число пакетов, потерянных буфером с момента открытия:
+// вытесненных при переполнении или удалённых как повреждённые.
+func (s *Spool) Dropped() uint64 {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.dropped
+}
+
+// Push сохраняет пакет в конец очереди, вытесняя самые старые пакеты при переполнении.
+// Файл записывается во временный и переименовывается, поэтому при сбое агента в очереди не остаётся обрезанных пакетов.
+// Ошибка возвращается, только если пакет не попал в очередь: после переименования пакет принадлежит буферу,
+// а ошибки удаления вытесненных файлов пишутся в лог.
+func (s *Spool) Push(b Batch) error {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	s.seq++
+	name := fmt.Sprintf("%020d%s", s.seq, batchExt)
+	tmp := filepath.Join(s.dir, name+".tmp")
+	if err = os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, filepath.Join(s.dir, name)); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	s.files = append(s.files, name)
+
+	for s.maxBatches > 0 && len(s.files) > s.maxBatches {
+		evicted := s.files[0]
+		if err = s.remove(evicted); err != nil {
+			log.Printf("spool: could not remove evicted batch %s: %v", evicted, err)
+		}
+		s.dropped++
+	}
+	return nil
+}
+
+// Peek возвращает самый старый пакет, не удаляя его. Второе значение false означает, что очередь пуста.
+// Повреждённые файлы удаляются, чтобы не блокировать очередь.
+func (s *Spool) Peek() (Batch, bool, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	for len(s.files) > 0 {
+		name := s.files[0]
+		data, err := os.ReadFile(filepath.Join(s.dir, name))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return Batch{}, false, err
+		}
+		var b Batch
+		if err == nil && json.Unmarshal(data, &b) == nil {
+			b.file = name
+			return b, true, nil
+		}
+		if err = s.remove(name); err != nil {
+			return Batch{}, false, err
+		}
+		s.dropped++
+	}
+	return Batch{}, false, nil
+}
+
+// Ack удаляет из очереди пакет, полученный через Peek.
+func (s *Spool) Ack(b Batch) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if b.file == "" {
+		return nil
+	}
+	return s.remove(b.file)
+}
+
+// Drain отправляет пакеты из очереди по порядку, пока она не опустеет или отправка не завершится ошибкой.
+// Пакет, отвергнутый сервером (ошибка оборачивает ErrRejected), удаляется, и отправка продолжается;
+// при прочих ошибках пакет остаётся в очереди до следующего вызова.
+func (s *Spool) Drain(send func(Batch) error) error {
+	s.drainMx.Lock()
+	defer s.drainMx.Unlock()
+
+	var errs []error
+	for {
+		b, ok, err := s.Peek()
+		if err != nil || !ok {
+			return errors.Join(append(errs, err)...)
+		}
+		err = send(b)
+		if err != nil && !errors.Is(err, ErrRejected) {
+			return errors.Join(append(errs, err)...)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("dropping batch %s: %w", b.ID, err))
+		}
+		if err = s.Ack(b); err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+	}
+}
+
+// remove удаляет файл пакета и вычёркивает его из очереди. Вызывается под s.mx.
+func (s *Spool) remove(name string) error {
+	for i, f := range s.files {
+		if f == name {
+			s.files = append(s.files[:i], s.files[i+1:]...)
+			break
+		}
+	}
+	err := os.Remove(filepath.Join(s.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
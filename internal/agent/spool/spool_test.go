@@ -0,0 +1,124 @@
+package spool
+
+import (
+	"errors"
+	"github.com/MxTrap/metrics/internal/common/models"
+	"github.com/MxTrap/metrics/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func batch(id string) Batch {
+	return Batch{ID: id, Metrics: models.Metrics{
+		{ID: "PollCount", MType: models.Counter, Delta: utils.MakePointer[int64](1)},
+	}}
+}
+
+func ids(t *testing.T, s *Spool) []string {
+	var got []string
+	err := s.Drain(func(b Batch) error {
+		got = append(got, b.ID)
+		return nil
+	})
+	require.NoError(t, err)
+	return got
+}
+
+func TestNewBatch(t *testing.T) {
+	a, b := NewBatch(nil), NewBatch(nil)
+	assert.NotEmpty(t, a.ID)
+	assert.NotEqual(t, a.ID, b.ID)
+}
+
+func TestSpoolKeepsOrderAcrossReopen(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir, 0)
+	require.NoError(t, err)
+	for _, id := range []string{"a", "b", "c"} {
+		require.NoError(t, s.Push(batch(id)))
+	}
+
+	reopened, err := Open(dir, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 3, reopened.Len())
+	require.NoError(t, reopened.Push(batch("d")))
+
+	b, ok, err := reopened.Peek()
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, batch("a").Metrics, b.Metrics)
+
+	assert.Equal(t, []string{"a", "b", "c", "d"}, ids(t, reopened))
+	assert.Equal(t, 0, reopened.Len())
+}
+
+func TestSpoolDropsOldestWhenFull(t *testing.T) {
+	s, err := Open(t.TempDir(), 2)
+	require.NoError(t, err)
+	for _, id := range []string{"a", "b", "c"} {
+		require.NoError(t, s.Push(batch(id)))
+	}
+	assert.Equal(t, []string{"b", "c"}, ids(t, s))
+	assert.Equal(t, uint64(1), s.Dropped(), "evicted batch should be counted")
+}
+
+func TestSpoolPushIgnoresEvictionErrors(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir, 1)
+	require.NoError(t, err)
+	require.NoError(t, s.Push(batch("a")))
+
+	// непустой каталог на месте файла пакета не даёт его удалить
+	oldest := filepath.Join(dir, s.files[0])
+	require.NoError(t, os.Remove(oldest))
+	require.NoError(t, os.MkdirAll(filepath.Join(oldest, "locked"), 0o700))
+
+	require.NoError(t, s.Push(batch("b")), "spooled batch should be reported as stored")
+	assert.Equal(t, uint64(1), s.Dropped())
+	assert.Equal(t, []string{"b"}, ids(t, s))
+}
+
+func TestSpoolDrain(t *testing.T) {
+	s, err := Open(t.TempDir(), 0)
+	require.NoError(t, err)
+	for _, id := range []string{"a", "b", "c"} {
+		require.NoError(t, s.Push(batch(id)))
+	}
+
+	unavailable := errors.New("connection refused")
+	var sent []string
+	err = s.Drain(func(b Batch) error {
+		sent = append(sent, b.ID)
+		switch b.ID {
+		case "a":
+			return nil
+		case "b":
+			return errors.Join(ErrRejected, errors.New("400 Bad Request"))
+		}
+		return unavailable
+	})
+	assert.ErrorIs(t, err, unavailable)
+	assert.ErrorIs(t, err, ErrRejected)
+	assert.Equal(t, []string{"a", "b", "c"}, sent)
+	assert.Equal(t, 1, s.Len(), "batch that failed to send must stay in the spool")
+
+	assert.Equal(t, []string{"c"}, ids(t, s))
+}
+
+func TestSpoolSkipsCorruptedBatch(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir, 0)
+	require.NoError(t, err)
+	require.NoError(t, s.Push(batch("a")))
+	require.NoError(t, s.Push(batch("b")))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, s.files[0]), []byte("{"), 0o600))
+
+	assert.Equal(t, []string{"b"}, ids(t, s))
+	assert.Equal(t, uint64(1), s.Dropped(), "corrupted batch should be counted")
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
@@ -0,0 +1,127 @@
+// Package telemetry собирает собственные метрики агента о работе его конвейера:
+// длительность опроса коллекторов, размер и задержку отправки пакетов, повторы, потерянные пакеты и глубину очереди.
+// Метрики уходят на сервер в тех же пакетах, что и остальные, и доступны локально по HTTP в текстовом формате Prometheus.
+package telemetry
+
+import (
+	"fmt"
+	"github.com/MxTrap/metrics/internal/agent/models"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// sink принимает метрики, которые отправляются на сервер.
+type sink interface {
+	SavePush(models.Push)
+}
+
+// Telemetry передаёт собственные метрики агента в хранилище для отправки на сервер
+// и накапливает их значения с момента запуска для страницы /metrics.
+type Telemetry struct {
+	sink     sink
+	mx       sync.Mutex
+	counters map[string]int64
+	gauges   map[string]float64
+	timings  map[string]*models.Summary
+}
+
+// New создаёт Telemetry, сохраняющую метрики в sink.
+func New(sink sink) *Telemetry {
+	return &Telemetry{
+		sink:     sink,
+		counters: make(map[string]int64),
+		gauges:   make(map[string]float64),
+		timings:  make(map[string]*models.Summary),
+	}
+}
+
+// SavePush сохраняет метрики для отправки на сервер и учитывает их в накопленных значениях.
+func (t *Telemetry) SavePush(p models.Push) {
+	t.sink.SavePush(p)
+
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	for name, delta := range p.Counters {
+		t.counters[name] += delta
+	}
+	for name, value := range p.Gauges {
+		t.gauges[name] = value
+	}
+	for name, values := range p.Timings {
+		summary, ok := t.timings[name]
+		if !ok {
+			summary = &models.Summary{}
+			t.timings[name] = summary
+		}
+		for _, v := range values {
+			summary.Observe(v)
+		}
+	}
+}
+
+// Count прибавляет delta к счётчику name.
+func (t *Telemetry) Count(name string, delta int64) {
+	t.SavePush(models.Push{Counters: map[string]int64{name: delta}})
+}
+
+// Gauge задаёт текущее значение name.
+func (t *Telemetry) Gauge(name string, value float64) {
+	t.SavePush(models.Push{Gauges: map[string]float64{name: value}})
+}
+
+// Observe добавляет выборку value, например длительность в секундах, в сводку name.
+func (t *Telemetry) Observe(name string, value float64) {
+	t.SavePush(models.Push{Timings: map[string][]float64{name: {value}}})
+}
+
+// ServeHTTP отдаёт накопленные метрики в текстовом формате Prometheus.
+// Сводки выводятся как summary с суммой и числом выборок.
+func (t *Telemetry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	var b strings.Builder
+
+	t.mx.Lock()
+	for _, name := range sortedKeys(t.counters) {
+		writeMetric(&b, name, "counter", "", float64(t.counters[name]))
+	}
+	for _, name := range sortedKeys(t.gauges) {
+		writeMetric(&b, name, "gauge", "", t.gauges[name])
+	}
+	for _, name := range sortedKeys(t.timings) {
+		summary := t.timings[name]
+		writeMetric(&b, name, "summary", "_sum", summary.Sum)
+		fmt.Fprintf(&b, "%s_count %d\n", metricName(name), summary.Count)
+	}
+	t.mx.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = w.Write([]byte(b.String()))
+}
+
+func writeMetric(b *strings.Builder, name, kind, suffix string, value float64) {
+	name = metricName(name)
+	fmt.Fprintf(b, "# TYPE %s %s\n", name, kind)
+	fmt.Fprintf(b, "%s%s %s\n", name, suffix, strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+// metricName добавляет к имени префикс agent_ и приводит его к допустимому в Prometheus:
+// символы, кроме букв, цифр, '_' и ':', заменяются на '_'.
+func metricName(name string) string {
+	return "agent_" + strings.Map(func(r rune) rune {
+		if r == '_' || r == ':' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
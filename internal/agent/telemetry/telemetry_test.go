@@ -0,0 +1,56 @@
+package telemetry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/MxTrap/metrics/internal/agent/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubSink struct {
+	pushes []models.Push
+}
+
+func (s *stubSink) SavePush(p models.Push) {
+	s.pushes = append(s.pushes, p)
+}
+
+func TestTelemetryForwardsToSink(t *testing.T) {
+	sink := &stubSink{}
+	tel := New(sink)
+
+	tel.Count("SendSuccess", 1)
+	tel.Gauge("SpoolDepth", 3)
+	tel.Observe("SendDuration", 0.5)
+
+	require.Len(t, sink.pushes, 3, "every metric should be sent to the server")
+	assert.Equal(t, map[string]int64{"SendSuccess": 1}, sink.pushes[0].Counters)
+	assert.Equal(t, map[string]float64{"SpoolDepth": 3}, sink.pushes[1].Gauges)
+	assert.Equal(t, map[string][]float64{"SendDuration": {0.5}}, sink.pushes[2].Timings)
+}
+
+func TestTelemetryServeHTTP(t *testing.T) {
+	tel := New(&stubSink{})
+	tel.Count("SendSuccess", 1)
+	tel.Count("SendSuccess", 2)
+	tel.Gauge("SpoolDepth", 3)
+	tel.Gauge("SpoolDepth", 1)
+	tel.SavePush(models.Push{Timings: map[string][]float64{"CollectDuration.runtime": {0.25, 0.5}}})
+
+	w := httptest.NewRecorder()
+	tel.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/plain")
+	assert.Equal(t, `# TYPE agent_SendSuccess counter
+agent_SendSuccess 3
+# TYPE agent_SpoolDepth gauge
+agent_SpoolDepth 1
+# TYPE agent_CollectDuration_runtime summary
+agent_CollectDuration_runtime_sum 0.75
+agent_CollectDuration_runtime_count 2
+`, w.Body.String())
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// AgentConfig — настройки агента, которые раздаёт сервер.
+// Нулевые поля означают, что агент сохраняет значения из собственной конфигурации.
+type AgentConfig struct {
+	Version        string   `json:"version"`                   // Версия настроек, агент применяет настройки при её смене.
+	PollInterval   int      `json:"poll_interval,omitempty"`   // Интервал опроса коллекторов в секундах.
+	ReportInterval int      `json:"report_interval,omitempty"` // Интервал отправки метрик в секундах.
+	Collectors     []string `json:"collectors,omitempty"`      // Включённые коллекторы.
+}
+
+// AppliedConfig — отчёт агента о применении настроек.
+// Error содержит причину, по которой версия не применена; пустая строка означает успех.
+type AppliedConfig struct {
+	AgentID   string    `json:"agent_id"`
+	Version   string    `json:"version"`
+	Error     string    `json:"error,omitempty"`
+	AppliedAt time.Time `json:"applied_at"`
+}
@@ -0,0 +1,9 @@
+package models
+
+// AgentInfo — сведения, которыми агент представляется серверу в каждом запросе.
+type AgentInfo struct {
+	ID       string `json:"id"`       // Идентификатор агента, по умолчанию имя хоста.
+	Hostname string `json:"hostname"` // Имя хоста агента.
+	Version  string `json:"version"`  // Версия сборки агента.
+	OS       string `json:"os"`       // Операционная система и архитектура агента.
+}
@@ -0,0 +1,49 @@
+// Package sign формирует HMAC-подпись запросов агента.
+// Подпись покрывает метку времени, одноразовое значение (nonce) и тело запроса,
+// что позволяет серверу отклонять повторно отправленные перехваченные запросы.
+package sign
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"time"
+)
+
+// Payload возвращает подписываемые данные: метку времени, nonce и тело, разделённые переводом строки.
+func Payload(timestamp string, nonce string, body []byte) []byte {
+	payload := make([]byte, 0, len(timestamp)+len(nonce)+len(body)+2)
+	payload = append(payload, timestamp...)
+	payload = append(payload, '\n')
+	payload = append(payload, nonce...)
+	payload = append(payload, '\n')
+	return append(payload, body...)
+}
+
+// Sum вычисляет HMAC-SHA256 данных на ключе key.
+func Sum(key string, data []byte) []byte {
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// Request возвращает метку времени, nonce и шестнадцатеричную подпись для тела запроса.
+func Request(key string, body []byte) (timestamp string, nonce string, signature string, err error) {
+	timestamp = strconv.FormatInt(time.Now().Unix(), 10)
+	nonce, err = NewNonce()
+	if err != nil {
+		return "", "", "", err
+	}
+	return timestamp, nonce, hex.EncodeToString(Sum(key, Payload(timestamp, nonce, body))), nil
+}
+
+// NewNonce возвращает случайное одноразовое значение в шестнадцатеричном виде.
+func NewNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,29 @@
+package sign
+
+import (
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestPayload(t *testing.T) {
+	assert.Equal(t, []byte("1700000000\nabc\nbody"), Payload("1700000000", "abc", []byte("body")))
+}
+
+func TestRequest(t *testing.T) {
+	ts, nonce, signature, err := Request("key", []byte("body"))
+	require.NoError(t, err)
+
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), time.Unix(sec, 0), 5*time.Second)
+	assert.Len(t, nonce, 32)
+	assert.Equal(t, hex.EncodeToString(Sum("key", Payload(ts, nonce, []byte("body")))), signature)
+
+	_, other, _, err := Request("key", []byte("body"))
+	require.NoError(t, err)
+	assert.NotEqual(t, nonce, other, "nonces should be unique")
+}
@@ -23,10 +23,15 @@ const _ = grpc.SupportPackageIsVersion7
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type MetricServiceClient interface {
+	// GetAll оставлен для совместимости: значения теряют тип метрики, счётчики передаются как double.
 	GetAll(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*GetAllResponse, error)
 	SaveAll(ctx context.Context, in *SaveAllRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
 	Find(ctx context.Context, in *Metric, opts ...grpc.CallOption) (*Metric, error)
 	Save(ctx context.Context, in *Metric, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	StreamMetrics(ctx context.Context, opts ...grpc.CallOption) (MetricService_StreamMetricsClient, error)
+	List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error)
+	GetAllMetrics(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (MetricService_GetAllMetricsClient, error)
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (MetricService_WatchClient, error)
 }
 
 type metricServiceClient struct {
@@ -73,14 +78,123 @@ func (c *metricServiceClient) Save(ctx context.Context, in *Metric, opts ...grpc
 	return out, nil
 }
 
+func (c *metricServiceClient) StreamMetrics(ctx context.Context, opts ...grpc.CallOption) (MetricService_StreamMetricsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &MetricService_ServiceDesc.Streams[0], "/protos.MetricService/StreamMetrics", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &metricServiceStreamMetricsClient{stream}
+	return x, nil
+}
+
+type MetricService_StreamMetricsClient interface {
+	Send(*MetricBatch) error
+	Recv() (*StreamResponse, error)
+	grpc.ClientStream
+}
+
+type metricServiceStreamMetricsClient struct {
+	grpc.ClientStream
+}
+
+func (x *metricServiceStreamMetricsClient) Send(m *MetricBatch) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *metricServiceStreamMetricsClient) Recv() (*StreamResponse, error) {
+	m := new(StreamResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *metricServiceClient) List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error) {
+	out := new(ListResponse)
+	err := c.cc.Invoke(ctx, "/protos.MetricService/List", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *metricServiceClient) GetAllMetrics(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (MetricService_GetAllMetricsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &MetricService_ServiceDesc.Streams[1], "/protos.MetricService/GetAllMetrics", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &metricServiceGetAllMetricsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type MetricService_GetAllMetricsClient interface {
+	Recv() (*MetricChunk, error)
+	grpc.ClientStream
+}
+
+type metricServiceGetAllMetricsClient struct {
+	grpc.ClientStream
+}
+
+func (x *metricServiceGetAllMetricsClient) Recv() (*MetricChunk, error) {
+	m := new(MetricChunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *metricServiceClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (MetricService_WatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &MetricService_ServiceDesc.Streams[2], "/protos.MetricService/Watch", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &metricServiceWatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type MetricService_WatchClient interface {
+	Recv() (*MetricUpdate, error)
+	grpc.ClientStream
+}
+
+type metricServiceWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *metricServiceWatchClient) Recv() (*MetricUpdate, error) {
+	m := new(MetricUpdate)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // MetricServiceServer is the server API for MetricService service.
 // All implementations must embed UnimplementedMetricServiceServer
 // for forward compatibility
 type MetricServiceServer interface {
+	// GetAll оставлен для совместимости: значения теряют тип метрики, счётчики передаются как double.
 	GetAll(context.Context, *emptypb.Empty) (*GetAllResponse, error)
 	SaveAll(context.Context, *SaveAllRequest) (*emptypb.Empty, error)
 	Find(context.Context, *Metric) (*Metric, error)
 	Save(context.Context, *Metric) (*emptypb.Empty, error)
+	StreamMetrics(MetricService_StreamMetricsServer) error
+	List(context.Context, *ListRequest) (*ListResponse, error)
+	GetAllMetrics(*ListRequest, MetricService_GetAllMetricsServer) error
+	Watch(*WatchRequest, MetricService_WatchServer) error
 	mustEmbedUnimplementedMetricServiceServer()
 }
 
@@ -100,6 +214,18 @@ func (UnimplementedMetricServiceServer) Find(context.Context, *Metric) (*Metric,
 func (UnimplementedMetricServiceServer) Save(context.Context, *Metric) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Save not implemented")
 }
+func (UnimplementedMetricServiceServer) StreamMetrics(MetricService_StreamMetricsServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamMetrics not implemented")
+}
+func (UnimplementedMetricServiceServer) List(context.Context, *ListRequest) (*ListResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method List not implemented")
+}
+func (UnimplementedMetricServiceServer) GetAllMetrics(*ListRequest, MetricService_GetAllMetricsServer) error {
+	return status.Errorf(codes.Unimplemented, "method GetAllMetrics not implemented")
+}
+func (UnimplementedMetricServiceServer) Watch(*WatchRequest, MetricService_WatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
+}
 func (UnimplementedMetricServiceServer) mustEmbedUnimplementedMetricServiceServer() {}
 
 // UnsafeMetricServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -185,6 +311,92 @@ func _MetricService_Save_Handler(srv interface{}, ctx context.Context, dec func(
 	return interceptor(ctx, in, info, handler)
 }
 
+func _MetricService_StreamMetrics_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(MetricServiceServer).StreamMetrics(&metricServiceStreamMetricsServer{stream})
+}
+
+type MetricService_StreamMetricsServer interface {
+	Send(*StreamResponse) error
+	Recv() (*MetricBatch, error)
+	grpc.ServerStream
+}
+
+type metricServiceStreamMetricsServer struct {
+	grpc.ServerStream
+}
+
+func (x *metricServiceStreamMetricsServer) Send(m *StreamResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *metricServiceStreamMetricsServer) Recv() (*MetricBatch, error) {
+	m := new(MetricBatch)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _MetricService_List_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MetricServiceServer).List(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protos.MetricService/List",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MetricServiceServer).List(ctx, req.(*ListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MetricService_GetAllMetrics_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(MetricServiceServer).GetAllMetrics(m, &metricServiceGetAllMetricsServer{stream})
+}
+
+type MetricService_GetAllMetricsServer interface {
+	Send(*MetricChunk) error
+	grpc.ServerStream
+}
+
+type metricServiceGetAllMetricsServer struct {
+	grpc.ServerStream
+}
+
+func (x *metricServiceGetAllMetricsServer) Send(m *MetricChunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _MetricService_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(MetricServiceServer).Watch(m, &metricServiceWatchServer{stream})
+}
+
+type MetricService_WatchServer interface {
+	Send(*MetricUpdate) error
+	grpc.ServerStream
+}
+
+type metricServiceWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *metricServiceWatchServer) Send(m *MetricUpdate) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 // MetricService_ServiceDesc is the grpc.ServiceDesc for MetricService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -208,6 +420,185 @@ var MetricService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Save",
 			Handler:    _MetricService_Save_Handler,
 		},
+		{
+			MethodName: "List",
+			Handler:    _MetricService_List_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamMetrics",
+			Handler:       _MetricService_StreamMetrics_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "GetAllMetrics",
+			Handler:       _MetricService_GetAllMetrics_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Watch",
+			Handler:       _MetricService_Watch_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "metrics.proto",
+}
+
+// AgentServiceClient is the client API for AgentService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type AgentServiceClient interface {
+	GetConfig(ctx context.Context, in *AgentConfigRequest, opts ...grpc.CallOption) (*AgentConfig, error)
+	ReportApplied(ctx context.Context, in *AppliedConfig, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	ListAgents(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*AgentList, error)
+}
+
+type agentServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAgentServiceClient(cc grpc.ClientConnInterface) AgentServiceClient {
+	return &agentServiceClient{cc}
+}
+
+func (c *agentServiceClient) GetConfig(ctx context.Context, in *AgentConfigRequest, opts ...grpc.CallOption) (*AgentConfig, error) {
+	out := new(AgentConfig)
+	err := c.cc.Invoke(ctx, "/protos.AgentService/GetConfig", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *agentServiceClient) ReportApplied(ctx context.Context, in *AppliedConfig, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, "/protos.AgentService/ReportApplied", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *agentServiceClient) ListAgents(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*AgentList, error) {
+	out := new(AgentList)
+	err := c.cc.Invoke(ctx, "/protos.AgentService/ListAgents", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AgentServiceServer is the server API for AgentService service.
+// All implementations must embed UnimplementedAgentServiceServer
+// for forward compatibility
+type AgentServiceServer interface {
+	GetConfig(context.Context, *AgentConfigRequest) (*AgentConfig, error)
+	ReportApplied(context.Context, *AppliedConfig) (*emptypb.Empty, error)
+	ListAgents(context.Context, *emptypb.Empty) (*AgentList, error)
+	mustEmbedUnimplementedAgentServiceServer()
+}
+
+// UnimplementedAgentServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedAgentServiceServer struct {
+}
+
+func (UnimplementedAgentServiceServer) GetConfig(context.Context, *AgentConfigRequest) (*AgentConfig, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetConfig not implemented")
+}
+func (UnimplementedAgentServiceServer) ReportApplied(context.Context, *AppliedConfig) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReportApplied not implemented")
+}
+func (UnimplementedAgentServiceServer) ListAgents(context.Context, *emptypb.Empty) (*AgentList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListAgents not implemented")
+}
+func (UnimplementedAgentServiceServer) mustEmbedUnimplementedAgentServiceServer() {}
+
+// UnsafeAgentServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AgentServiceServer will
+// result in compilation errors.
+type UnsafeAgentServiceServer interface {
+	mustEmbedUnimplementedAgentServiceServer()
+}
+
+func RegisterAgentServiceServer(s grpc.ServiceRegistrar, srv AgentServiceServer) {
+	s.RegisterService(&AgentService_ServiceDesc, srv)
+}
+
+func _AgentService_GetConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AgentConfigRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AgentServiceServer).GetConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protos.AgentService/GetConfig",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AgentServiceServer).GetConfig(ctx, req.(*AgentConfigRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AgentService_ReportApplied_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AppliedConfig)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AgentServiceServer).ReportApplied(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protos.AgentService/ReportApplied",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AgentServiceServer).ReportApplied(ctx, req.(*AppliedConfig))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AgentService_ListAgents_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AgentServiceServer).ListAgents(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protos.AgentService/ListAgents",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AgentServiceServer).ListAgents(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// AgentService_ServiceDesc is the grpc.ServiceDesc for AgentService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var AgentService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "protos.AgentService",
+	HandlerType: (*AgentServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetConfig",
+			Handler:    _AgentService_GetConfig_Handler,
+		},
+		{
+			MethodName: "ReportApplied",
+			Handler:    _AgentService_ReportApplied_Handler,
+		},
+		{
+			MethodName: "ListAgents",
+			Handler:    _AgentService_ListAgents_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "metrics.proto",
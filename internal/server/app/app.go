@@ -1,39 +1,81 @@
 package app
 
 import (
+	"cmp"
 	"context"
 	"github.com/MxTrap/metrics/config/serverconfig"
+	"github.com/MxTrap/metrics/internal/server/clientip"
 	"github.com/MxTrap/metrics/internal/server/grpc"
+	"github.com/MxTrap/metrics/internal/server/grpc/interceptor"
 	"github.com/MxTrap/metrics/internal/server/httpserver"
 	"github.com/MxTrap/metrics/internal/server/httpserver/handlers"
+	"github.com/MxTrap/metrics/internal/server/httpserver/middlewares"
+	"github.com/MxTrap/metrics/internal/server/idempotency"
 	"github.com/MxTrap/metrics/internal/server/logger"
 	"github.com/MxTrap/metrics/internal/server/migrator"
+	"github.com/MxTrap/metrics/internal/server/models"
+	"github.com/MxTrap/metrics/internal/server/ratelimit"
+	"github.com/MxTrap/metrics/internal/server/replay"
 	"github.com/MxTrap/metrics/internal/server/repository"
 	"github.com/MxTrap/metrics/internal/server/repository/postgres"
 	"github.com/MxTrap/metrics/internal/server/service"
 	"github.com/MxTrap/metrics/internal/utils"
+	"github.com/gin-gonic/gin"
 	_ "github.com/jackc/pgx/v5"
 	_ "github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+	grpclib "google.golang.org/grpc"
 	"sync"
+	"time"
+)
+
+const (
+	defaultReplayCacheSize = 100000
+
+	// defaultIdempotencyWindow покрывает отправку буфера агента после долгой недоступности сервера.
+	defaultIdempotencyWindow    = 24 * time.Hour
+	defaultIdempotencyCacheSize = 100000
+
+	defaultAgentSilentAfter = 5 * time.Minute
+
+	// defaultWatchBuffer — число обновлений, которое может накопить подписчик, прежде чем они начнут отбрасываться.
+	defaultWatchBuffer = 256
+	// defaultHistorySize — число последних обновлений каждой метрики, хранимых для графиков панели мониторинга.
+	defaultHistorySize = 120
 )
 
 type App struct {
 	httpServer     *httpserver.HTTPServer
 	grpcServer     *grpc.Server
 	metricsService *service.MetricsService
+	watchService   *service.WatchService
+	agentRegistry  *service.AgentRegistryService
 	logger         *logger.Logger
 }
 
 func NewApp(cfg *serverconfig.ServerConfig, ctx context.Context) (*App, error) {
 	log := logger.NewLogger()
 
+	trustedSubnets, err := clientip.ParseSubnets(cfg.TrustedSubnet)
+	if err != nil {
+		log.Logger.Error("could not parse trusted subnet ", err)
+		return nil, err
+	}
+	trustedProxies, err := clientip.ParseSubnets(cfg.TrustedProxies)
+	if err != nil {
+		log.Logger.Error("could not parse trusted proxies ", err)
+		return nil, err
+	}
+	ipResolver := clientip.NewResolver(trustedProxies)
+
 	fileStorage := repository.NewMetricsFileStorage(cfg.FileStoragePath)
 	var storage service.Storage
 	var storageErr error
 	storage, storageErr = repository.NewMemStorage()
+	var pgPool *pgxpool.Pool
 	if cfg.DatabaseDSN != "" {
-		pgPool, err := pgxpool.New(ctx, cfg.DatabaseDSN)
+		var err error
+		pgPool, err = pgxpool.New(ctx, cfg.DatabaseDSN)
 		if err != nil {
 			return nil, err
 		}
@@ -55,21 +97,145 @@ func NewApp(cfg *serverconfig.ServerConfig, ctx context.Context) (*App, error) {
 		return nil, storageErr
 	}
 
+	authService, err := newAuthService(cfg, pgPool)
+	if err != nil {
+		log.Logger.Error("could not initialize api tokens ", err)
+		return nil, err
+	}
+	var replayGuard *replay.Guard
+	if cfg.ReplayWindow > 0 {
+		replayGuard = replay.NewGuard(time.Duration(cfg.ReplayWindow)*time.Second, cmp.Or(cfg.ReplayCacheSize, defaultReplayCacheSize))
+	}
+
+	// ограничение по адресу клиента действует до аутентификации и защищает её от перебора
+	var ipLimiter *ratelimit.Limiter
+	if cfg.RateLimitIP > 0 {
+		ipLimiter = ratelimit.NewLimiter(cfg.RateLimitIP, cfg.RateBurstIP)
+	}
+	var httpMiddlewares []gin.HandlerFunc
+	var grpcInterceptors []grpclib.UnaryServerInterceptor
+	var grpcStreamInterceptors []grpclib.StreamServerInterceptor
+	if cfg.Key != "" {
+		grpcInterceptors = append(grpcInterceptors, interceptors.Signature(cfg.Key, replayGuard))
+		grpcStreamInterceptors = append(grpcStreamInterceptors, interceptors.StreamSignature(cfg.Key, replayGuard))
+	}
+	if authService != nil {
+		httpMiddlewares = append(httpMiddlewares, middlewares.AuthMiddleware(authService))
+		grpcInterceptors = append(grpcInterceptors, interceptors.Auth(authService))
+		grpcStreamInterceptors = append(grpcStreamInterceptors, interceptors.StreamAuth(authService))
+	}
+	// после аутентификации ограничения ведутся по токену, поэтому клиенты за одним NAT не делят ведро
+	if cfg.RateLimitHTTP > 0 {
+		httpMiddlewares = append(httpMiddlewares, middlewares.RateLimitMiddleware(ratelimit.NewLimiter(cfg.RateLimitHTTP, cfg.RateBurstHTTP)))
+	}
+	if cfg.RateLimitGRPC > 0 {
+		grpcLimiter := ratelimit.NewLimiter(cfg.RateLimitGRPC, cfg.RateBurstGRPC)
+		grpcInterceptors = append(grpcInterceptors, interceptors.RateLimit(grpcLimiter))
+		grpcStreamInterceptors = append(grpcStreamInterceptors, interceptors.StreamRateLimit(grpcLimiter))
+	}
+	httpMiddlewares = append(httpMiddlewares, middlewares.TenantMiddleware())
+	grpcInterceptors = append(grpcInterceptors, interceptors.Tenant)
+	grpcStreamInterceptors = append(grpcStreamInterceptors, interceptors.StreamTenant)
+	agentRegistry := service.NewAgentRegistryService(cmp.Or(time.Duration(cfg.AgentSilentAfter)*time.Second, defaultAgentSilentAfter))
+	agentRegistry.RegisterStatusHandler(func(agent models.AgentRecord) {
+		if agent.Silent {
+			log.Logger.Warnw("agent went silent", "agent", agent.ID, "tenant", agent.Tenant, "last_seen", agent.LastSeen)
+		} else {
+			log.Logger.Infow("agent is back", "agent", agent.ID, "tenant", agent.Tenant)
+		}
+	})
+	httpMiddlewares = append(httpMiddlewares, middlewares.AgentRegistryMiddleware(agentRegistry))
+	grpcInterceptors = append(grpcInterceptors, interceptors.AgentRegistry(agentRegistry))
+	grpcStreamInterceptors = append(grpcStreamInterceptors, interceptors.StreamAgentRegistry(agentRegistry))
+	batches := idempotency.NewCache(
+		cmp.Or(time.Duration(cfg.IdempotencyWindow)*time.Second, defaultIdempotencyWindow),
+		cmp.Or(cfg.IdempotencyCacheSize, defaultIdempotencyCacheSize),
+	)
+	httpMiddlewares = append(httpMiddlewares, middlewares.IdempotencyMiddleware(batches))
+	grpcInterceptors = append(grpcInterceptors, interceptors.Idempotency(batches))
+	grpcStreamInterceptors = append(grpcStreamInterceptors, interceptors.StreamIdempotency(batches))
+
 	metricsService := service.NewMetricsService(fileStorage, storage, cfg.StoreInterval, cfg.Restore)
-	httpRouter := httpserver.NewRouter(cfg.HTTPAddr, log, cfg.Key, cfg.CryptoKey, cfg.TrustedSubnet)
+	metricsService.RegisterQuotas(cfg.MaxBatchSize, cfg.MaxMetricsPerClient)
+	watchService := service.NewWatchService(defaultWatchBuffer)
+	metricsService.RegisterPublisher(watchService)
+	historyService := service.NewHistoryService(defaultHistorySize)
+	metricsService.RegisterPublisher(historyService)
+	httpRouter := httpserver.NewRouter(
+		cfg.HTTPAddr,
+		log,
+		cfg.Key,
+		replayGuard,
+		cfg.CryptoKey,
+		ipResolver,
+		trustedSubnets,
+		ipLimiter,
+		httpMiddlewares...,
+	)
 	metricHandler := handlers.NewMetricHandler(metricsService, httpRouter.Router)
 	metricHandler.RegisterRoutes()
-	grpcServer := grpc.NewGRPCServer(cfg.GRPCAddr, log.LoggerInterceptor, cfg.TrustedSubnet)
-	grpcServer.Register(grpc.NewMetricsServiceServer(metricsService))
+	handlers.NewAPIHandler(metricsService, httpRouter.Router).RegisterRoutes()
+	handlers.NewWatchHandler(watchService, httpRouter.Router).RegisterRoutes()
+	handlers.NewDashboardHandler(metricsService, historyService, httpRouter.Router).RegisterRoutes()
+	if authService != nil {
+		handlers.NewAdminHandler(authService, httpRouter.Router).RegisterRoutes()
+	}
+	handlers.NewAgentRegistryHandler(agentRegistry, httpRouter.Router).RegisterRoutes()
+	var agentConfigService *service.AgentConfigService
+	if cfg.AgentConfigFile != "" {
+		agentConfigStorage, err := repository.NewAgentConfigFileStorage(cfg.AgentConfigFile)
+		if err != nil {
+			log.Logger.Error("could not load agent configs ", err)
+			return nil, err
+		}
+		agentConfigService = service.NewAgentConfigService(agentConfigStorage)
+		handlers.NewAgentConfigHandler(agentConfigService, httpRouter.Router).RegisterRoutes()
+	}
+	grpcServer := grpc.NewGRPCServer(
+		cfg.GRPCAddr,
+		log.LoggerInterceptor,
+		log.StreamLoggerInterceptor,
+		ipResolver,
+		trustedSubnets,
+		ipLimiter,
+		grpcStreamInterceptors,
+		grpcInterceptors...,
+	)
+	metricsServer := grpc.NewMetricsServiceServer(metricsService)
+	metricsServer.RegisterWatchService(watchService)
+	grpcServer.Register(metricsServer)
+	agentServer := grpc.NewAgentServiceServer(agentRegistry)
+	if agentConfigService != nil {
+		agentServer.RegisterConfigService(agentConfigService)
+	}
+	grpcServer.RegisterAgentService(agentServer)
 
 	return &App{
 		httpServer:     httpRouter,
 		logger:         log,
 		metricsService: metricsService,
+		watchService:   watchService,
+		agentRegistry:  agentRegistry,
 		grpcServer:     grpcServer,
 	}, nil
 }
 
+// newAuthService создаёт сервис аутентификации по API-токенам.
+// Возвращает nil, если источник токенов не настроен и аутентификация отключена.
+func newAuthService(cfg *serverconfig.ServerConfig, pgPool *pgxpool.Pool) (*service.AuthService, error) {
+	if cfg.AuthFile != "" {
+		tokenStorage, err := repository.NewTokenFileStorage(cfg.AuthFile)
+		if err != nil {
+			return nil, err
+		}
+		return service.NewAuthService(tokenStorage), nil
+	}
+	if cfg.AuthFromDB && pgPool != nil {
+		return service.NewAuthService(postgres.NewTokenStorage(pgPool)), nil
+	}
+	return nil, nil
+}
+
 func (a App) Run(ctx context.Context) error {
 	a.logger.Logger.Info("starting server")
 	err := a.metricsService.Start(ctx)
@@ -77,6 +243,7 @@ func (a App) Run(ctx context.Context) error {
 		a.logger.Logger.Error(err.Error())
 		return err
 	}
+	go a.agentRegistry.Run(ctx)
 
 	wg := &sync.WaitGroup{}
 	wg.Add(2)
@@ -101,6 +268,7 @@ func (a App) Run(ctx context.Context) error {
 
 func (a App) GracefulShutdown(ctx context.Context) error {
 	a.logger.Logger.Info("shutting down server")
+	a.watchService.Close()
 	err := a.metricsService.Stop(ctx)
 	if err != nil {
 		a.logger.Logger.Error(err.Error())
@@ -82,4 +82,5 @@ func TestNewApp(t *testing.T) {
 	assert.NotNil(t, app.httpServer, "httpServer should not be nil")
 	assert.NotNil(t, app.metricsService, "metricsService should not be nil")
 	assert.NotNil(t, app.logger, "logger should not be nil")
+	assert.NotNil(t, app.agentRegistry, "agentRegistry should not be nil")
 }
@@ -0,0 +1,124 @@
+// Package clientip определяет IP-адрес клиента запроса.
+// Заголовки X-Real-IP и X-Forwarded-For учитываются только в запросах от доверенных прокси,
+// в остальных случаях используется адрес соединения.
+package clientip
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Subnets — список подсетей, заданных в формате CIDR.
+type Subnets []*net.IPNet
+
+// ParseSubnets разбирает список подсетей, разделённых запятыми.
+// Адрес без маски трактуется как подсеть из одного адреса. Поддерживаются IPv4 и IPv6.
+// Возвращает пустой список для пустой строки или ошибку при неверном формате.
+func ParseSubnets(list string) (Subnets, error) {
+	var subnets Subnets
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid subnet %q", item)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			subnets = append(subnets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid subnet %q: %w", item, err)
+		}
+		subnets = append(subnets, ipNet)
+	}
+	return subnets, nil
+}
+
+// Contains сообщает, входит ли адрес хотя бы в одну из подсетей.
+func (s Subnets) Contains(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, subnet := range s {
+		if subnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolver определяет адрес клиента с учётом доверенных прокси.
+type Resolver struct {
+	proxies Subnets
+}
+
+// NewResolver создаёт Resolver, доверяющий заголовкам только от указанных прокси.
+func NewResolver(proxies Subnets) *Resolver {
+	return &Resolver{proxies: proxies}
+}
+
+// Resolve возвращает адрес клиента по адресу соединения peer (host:port или host)
+// и значениям заголовков X-Real-IP и X-Forwarded-For.
+// Если соединение установлено не с доверенным прокси, заголовки игнорируются.
+// В цепочке X-Forwarded-For выбирается ближайший к серверу адрес, не принадлежащий доверенным прокси.
+// Возвращает nil, если адрес определить не удалось.
+func (r *Resolver) Resolve(peer string, realIP string, forwardedFor []string) net.IP {
+	ip := parseHost(peer)
+	if ip == nil || !r.proxies.Contains(ip) {
+		return ip
+	}
+
+	var chain []string
+	for _, value := range forwardedFor {
+		chain = append(chain, strings.Split(value, ",")...)
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(chain[i]))
+		if hop == nil {
+			return ip
+		}
+		ip = hop
+		if !r.proxies.Contains(hop) {
+			return hop
+		}
+	}
+	if len(chain) > 0 {
+		return ip
+	}
+
+	if real := net.ParseIP(strings.TrimSpace(realIP)); real != nil {
+		return real
+	}
+	return ip
+}
+
+func parseHost(addr string) net.IP {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	return net.ParseIP(addr)
+}
+
+type ipCtxKey struct{}
+
+// ContextWithIP возвращает копию контекста с адресом клиента.
+func ContextWithIP(ctx context.Context, ip net.IP) context.Context {
+	return context.WithValue(ctx, ipCtxKey{}, ip)
+}
+
+// FromContext возвращает адрес клиента, сохранённый в контексте.
+func FromContext(ctx context.Context) (net.IP, bool) {
+	ip, ok := ctx.Value(ipCtxKey{}).(net.IP)
+	return ip, ok && ip != nil
+}
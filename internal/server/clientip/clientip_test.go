@@ -0,0 +1,71 @@
+package clientip
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net"
+	"testing"
+)
+
+func TestParseSubnets(t *testing.T) {
+	subnets, err := ParseSubnets("")
+	require.NoError(t, err)
+	assert.Empty(t, subnets)
+
+	subnets, err = ParseSubnets("192.168.1.0/24, 2001:db8::/32,10.0.0.5")
+	require.NoError(t, err)
+	require.Len(t, subnets, 3)
+	assert.True(t, subnets.Contains(net.ParseIP("192.168.1.100")))
+	assert.True(t, subnets.Contains(net.ParseIP("2001:db8::1")))
+	assert.True(t, subnets.Contains(net.ParseIP("10.0.0.5")))
+	assert.False(t, subnets.Contains(net.ParseIP("10.0.0.6")))
+	assert.False(t, subnets.Contains(nil))
+
+	_, err = ParseSubnets("invalid-cidr")
+	assert.Error(t, err)
+	_, err = ParseSubnets("192.168.1.0/24,10.0.0.0/99")
+	assert.Error(t, err)
+}
+
+func TestResolve(t *testing.T) {
+	proxies, err := ParseSubnets("10.0.0.0/8,fd00::/8")
+	require.NoError(t, err)
+	resolver := NewResolver(proxies)
+
+	tests := []struct {
+		name         string
+		peer         string
+		realIP       string
+		forwardedFor []string
+		expected     string
+	}{
+		{name: "PeerOnly", peer: "192.168.1.10:5000", expected: "192.168.1.10"},
+		{name: "UntrustedPeerHeadersIgnored", peer: "203.0.113.7:5000", realIP: "192.168.1.10", forwardedFor: []string{"192.168.1.10"}, expected: "203.0.113.7"},
+		{name: "TrustedPeerRealIP", peer: "10.1.1.1:5000", realIP: "192.168.1.10", expected: "192.168.1.10"},
+		{name: "TrustedPeerForwardedFor", peer: "10.1.1.1:5000", forwardedFor: []string{"198.51.100.1, 192.168.1.10", "10.2.2.2"}, expected: "192.168.1.10"},
+		{name: "ForwardedForWinsOverRealIP", peer: "10.1.1.1:5000", realIP: "198.51.100.1", forwardedFor: []string{"192.168.1.10"}, expected: "192.168.1.10"},
+		{name: "AllHopsTrusted", peer: "10.1.1.1:5000", forwardedFor: []string{"10.3.3.3"}, expected: "10.3.3.3"},
+		{name: "InvalidHop", peer: "10.1.1.1:5000", forwardedFor: []string{"192.168.1.10, garbage"}, expected: "10.1.1.1"},
+		{name: "IPv6Peer", peer: "[fd00::1]:5000", realIP: "2001:db8::5", expected: "2001:db8::5"},
+		{name: "PeerWithoutPort", peer: "192.168.1.10", expected: "192.168.1.10"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ip := resolver.Resolve(tt.peer, tt.realIP, tt.forwardedFor)
+			assert.Equal(t, tt.expected, ip.String())
+		})
+	}
+
+	assert.Nil(t, resolver.Resolve("", "192.168.1.10", nil))
+}
+
+func TestContextWithIP(t *testing.T) {
+	_, ok := FromContext(context.Background())
+	assert.False(t, ok)
+
+	ctx := ContextWithIP(context.Background(), net.ParseIP("192.168.1.10"))
+	ip, ok := FromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "192.168.1.10", ip.String())
+}
@@ -0,0 +1,89 @@
+package grpc
+
+import (
+	"context"
+	commonmodels "github.com/MxTrap/metrics/internal/common/models"
+	"github.com/MxTrap/metrics/internal/protos/gen"
+	"github.com/MxTrap/metrics/internal/server/models"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+type agentConfigService interface {
+	Config(ctx context.Context, agentID, group string) (commonmodels.AgentConfig, error)
+	ReportApplied(ctx context.Context, applied commonmodels.AppliedConfig) error
+}
+
+type agentRegistryService interface {
+	Agents(ctx context.Context) []models.AgentRecord
+}
+
+// AgentServiceServer отдаёт реестр агентов и раздаёт агентам настройки по gRPC.
+type AgentServiceServer struct {
+	registry agentRegistryService
+	configs  agentConfigService
+	gen.UnimplementedAgentServiceServer
+}
+
+func NewAgentServiceServer(registry agentRegistryService) *AgentServiceServer {
+	return &AgentServiceServer{registry: registry}
+}
+
+// RegisterConfigService включает раздачу настроек агентам.
+// Без сервиса настроек GetConfig и ReportApplied возвращают models.ErrNoAgentConfig.
+func (s *AgentServiceServer) RegisterConfigService(configs agentConfigService) {
+	s.configs = configs
+}
+
+func (s *AgentServiceServer) GetConfig(ctx context.Context, in *gen.AgentConfigRequest) (*gen.AgentConfig, error) {
+	if s.configs == nil {
+		return nil, models.ErrNoAgentConfig
+	}
+	cfg, err := s.configs.Config(ctx, in.GetAgentId(), in.GetGroup())
+	if err != nil {
+		return nil, err
+	}
+	return &gen.AgentConfig{
+		Version:               cfg.Version,
+		PollIntervalSeconds:   int64(cfg.PollInterval),
+		ReportIntervalSeconds: int64(cfg.ReportInterval),
+		Collectors:            cfg.Collectors,
+	}, nil
+}
+
+func (s *AgentServiceServer) ReportApplied(ctx context.Context, in *gen.AppliedConfig) (*emptypb.Empty, error) {
+	if s.configs == nil {
+		return nil, models.ErrNoAgentConfig
+	}
+	err := s.configs.ReportApplied(ctx, commonmodels.AppliedConfig{
+		AgentID: in.GetAgentId(),
+		Version: in.GetVersion(),
+		Error:   in.GetError(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *AgentServiceServer) ListAgents(ctx context.Context, _ *emptypb.Empty) (*gen.AgentList, error) {
+	agents := s.registry.Agents(ctx)
+	resp := &gen.AgentList{Agents: make([]*gen.AgentRecord, len(agents))}
+	for i, a := range agents {
+		resp.Agents[i] = &gen.AgentRecord{
+			Id:            a.ID,
+			Hostname:      a.Hostname,
+			Version:       a.Version,
+			Os:            a.OS,
+			Tenant:        a.Tenant,
+			Address:       a.Address,
+			Transport:     a.Transport,
+			FirstSeenUnix: a.FirstSeen.Unix(),
+			LastSeenUnix:  a.LastSeen.Unix(),
+			Requests:      a.Requests,
+			Errors:        a.Errors,
+			LastError:     a.LastError,
+			Silent:        a.Silent,
+		}
+	}
+	return resp, nil
+}
@@ -0,0 +1,107 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/MxTrap/metrics/internal/common/models"
+	"github.com/MxTrap/metrics/internal/protos/gen"
+	servermodels "github.com/MxTrap/metrics/internal/server/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+type mockAgentConfigService struct {
+	mock.Mock
+}
+
+func (m *mockAgentConfigService) Config(ctx context.Context, agentID, group string) (models.AgentConfig, error) {
+	args := m.Called(ctx, agentID, group)
+	return args.Get(0).(models.AgentConfig), args.Error(1)
+}
+
+func (m *mockAgentConfigService) ReportApplied(ctx context.Context, applied models.AppliedConfig) error {
+	args := m.Called(ctx, applied)
+	return args.Error(0)
+}
+
+type mockAgentRegistryService struct {
+	mock.Mock
+}
+
+func (m *mockAgentRegistryService) Agents(ctx context.Context) []servermodels.AgentRecord {
+	args := m.Called(ctx)
+	return args.Get(0).([]servermodels.AgentRecord)
+}
+
+func TestAgentServiceGetConfig(t *testing.T) {
+	svc := &mockAgentConfigService{}
+	svc.On("Config", mock.Anything, "host-1", "edge").Return(models.AgentConfig{
+		Version: "v1", PollInterval: 5, ReportInterval: 30, Collectors: []string{"runtime"},
+	}, nil)
+	svc.On("Config", mock.Anything, "host-2", "").Return(models.AgentConfig{}, servermodels.ErrNoAgentConfig)
+	server := NewAgentServiceServer(&mockAgentRegistryService{})
+	server.RegisterConfigService(svc)
+
+	cfg, err := server.GetConfig(context.Background(), &gen.AgentConfigRequest{AgentId: "host-1", Group: "edge"})
+	require.NoError(t, err)
+	assert.Equal(t, "v1", cfg.GetVersion())
+	assert.Equal(t, int64(5), cfg.GetPollIntervalSeconds())
+	assert.Equal(t, int64(30), cfg.GetReportIntervalSeconds())
+	assert.Equal(t, []string{"runtime"}, cfg.GetCollectors())
+
+	_, err = server.GetConfig(context.Background(), &gen.AgentConfigRequest{AgentId: "host-2"})
+	assert.ErrorIs(t, err, servermodels.ErrNoAgentConfig)
+}
+
+func TestAgentServiceReportApplied(t *testing.T) {
+	svc := &mockAgentConfigService{}
+	svc.On("ReportApplied", mock.Anything, models.AppliedConfig{AgentID: "host-1", Version: "v1", Error: "unknown collector"}).Return(nil)
+	server := NewAgentServiceServer(&mockAgentRegistryService{})
+	server.RegisterConfigService(svc)
+
+	_, err := server.ReportApplied(context.Background(), &gen.AppliedConfig{AgentId: "host-1", Version: "v1", Error: "unknown collector"})
+	require.NoError(t, err)
+	svc.AssertExpectations(t)
+}
+
+func TestAgentServiceWithoutConfigs(t *testing.T) {
+	server := NewAgentServiceServer(&mockAgentRegistryService{})
+
+	_, err := server.GetConfig(context.Background(), &gen.AgentConfigRequest{AgentId: "host-1"})
+	assert.ErrorIs(t, err, servermodels.ErrNoAgentConfig)
+	_, err = server.ReportApplied(context.Background(), &gen.AppliedConfig{AgentId: "host-1"})
+	assert.ErrorIs(t, err, servermodels.ErrNoAgentConfig)
+}
+
+func TestAgentServiceListAgents(t *testing.T) {
+	registry := &mockAgentRegistryService{}
+	lastSeen := time.Unix(1700000000, 0)
+	registry.On("Agents", mock.Anything).Return([]servermodels.AgentRecord{{
+		AgentInfo: models.AgentInfo{ID: "host-1", Hostname: "host-1.local", Version: "v1.2.0", OS: "linux/amd64"},
+		Tenant:    servermodels.DefaultTenant,
+		Transport: servermodels.AgentTransportGRPC,
+		FirstSeen: lastSeen.Add(-time.Hour),
+		LastSeen:  lastSeen,
+		Requests:  10,
+		Errors:    1,
+		LastError: "boom",
+		Silent:    true,
+	}})
+	server := NewAgentServiceServer(registry)
+
+	resp, err := server.ListAgents(context.Background(), &emptypb.Empty{})
+	require.NoError(t, err)
+	require.Len(t, resp.GetAgents(), 1)
+	agent := resp.GetAgents()[0]
+	assert.Equal(t, "host-1", agent.GetId())
+	assert.Equal(t, "v1.2.0", agent.GetVersion())
+	assert.Equal(t, "linux/amd64", agent.GetOs())
+	assert.Equal(t, servermodels.AgentTransportGRPC, agent.GetTransport())
+	assert.Equal(t, lastSeen.Unix(), agent.GetLastSeenUnix())
+	assert.Equal(t, int64(1), agent.GetErrors())
+	assert.True(t, agent.GetSilent())
+}
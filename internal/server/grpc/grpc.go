@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"github.com/MxTrap/metrics/config"
 	"github.com/MxTrap/metrics/internal/protos/gen"
+	"github.com/MxTrap/metrics/internal/server/clientip"
 	"github.com/MxTrap/metrics/internal/server/grpc/interceptor"
+	"github.com/MxTrap/metrics/internal/server/ratelimit"
 	"google.golang.org/grpc"
 	"net"
 )
@@ -14,13 +16,34 @@ type Server struct {
 	addr string
 }
 
-func NewGRPCServer(addr config.AddrConfig, logger grpc.UnaryServerInterceptor, cidr string) *Server {
+func NewGRPCServer(
+	addr config.AddrConfig,
+	logger grpc.UnaryServerInterceptor,
+	streamLogger grpc.StreamServerInterceptor,
+	resolver *clientip.Resolver,
+	trusted clientip.Subnets,
+	limiter *ratelimit.Limiter,
+	streams []grpc.StreamServerInterceptor,
+	extra ...grpc.UnaryServerInterceptor,
+) *Server {
+	unary := []grpc.UnaryServerInterceptor{
+		logger,
+		interceptors.StatusErrorInterceptor,
+		interceptors.IPValidator(resolver, trusted),
+	}
+	stream := []grpc.StreamServerInterceptor{
+		streamLogger,
+		interceptors.StreamStatusError,
+		interceptors.StreamIPValidator(resolver, trusted),
+	}
+	if limiter != nil {
+		// ограничение по адресу клиента проверяется до проверки подписи и аутентификации
+		unary = append(unary, interceptors.RateLimit(limiter))
+		stream = append(stream, interceptors.StreamRateLimit(limiter))
+	}
 	srv := grpc.NewServer(
-		grpc.ChainUnaryInterceptor(
-			logger,
-			interceptors.StatusErrorInterceptor,
-			interceptors.IPValidator(cidr),
-		),
+		grpc.ChainUnaryInterceptor(append(unary, extra...)...),
+		grpc.ChainStreamInterceptor(append(stream, streams...)...),
 	)
 
 	return &Server{
@@ -34,6 +57,10 @@ func (s *Server) Register(svc gen.MetricServiceServer) {
 	gen.RegisterMetricServiceServer(s.srv, svc)
 }
 
+func (s *Server) RegisterAgentService(svc gen.AgentServiceServer) {
+	gen.RegisterAgentServiceServer(s.srv, svc)
+}
+
 func (s *Server) Run() error {
 	lis, err := net.Listen("tcp", s.addr)
 	if err != nil {
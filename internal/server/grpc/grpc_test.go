@@ -4,8 +4,10 @@ import (
 	"context"
 	"github.com/MxTrap/metrics/config"
 	"github.com/MxTrap/metrics/internal/protos/gen"
+	"github.com/MxTrap/metrics/internal/server/clientip"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
 	"google.golang.org/protobuf/types/known/emptypb"
 	"net"
@@ -63,7 +65,12 @@ func TestNewGRPCServer(t *testing.T) {
 	logger := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
 		return handler(ctx, req)
 	}
-	server := NewGRPCServer(addrConfig, logger, "192.168.1.0/24")
+	streamLogger := func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, ss)
+	}
+	trusted, err := clientip.ParseSubnets("192.168.1.0/24")
+	require.NoError(t, err)
+	server := NewGRPCServer(addrConfig, logger, streamLogger, clientip.NewResolver(nil), trusted, nil, nil)
 	assert.NotNil(t, server)
 	assert.Equal(t, "localhost:50051", server.addr)
 	assert.NotNil(t, server.srv)
@@ -74,7 +81,10 @@ func TestRegister(t *testing.T) {
 	logger := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
 		return handler(ctx, req)
 	}
-	server := NewGRPCServer(addrConfig, logger, "")
+	streamLogger := func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, ss)
+	}
+	server := NewGRPCServer(addrConfig, logger, streamLogger, clientip.NewResolver(nil), nil, nil, nil)
 
 	var registeredServer gen.MetricServiceServer = &mockMetricServiceServer{}
 
@@ -0,0 +1,77 @@
+package interceptors
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	commonmodels "github.com/MxTrap/metrics/internal/common/models"
+	"github.com/MxTrap/metrics/internal/protos/gen"
+	"github.com/MxTrap/metrics/internal/server/models"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"strings"
+)
+
+type agentRegistry interface {
+	Seen(ctx context.Context, info commonmodels.AgentInfo, transport string, err error)
+}
+
+// agentInfo возвращает сведения, которыми агент представился в метаданных вызова.
+func agentInfo(ctx context.Context) commonmodels.AgentInfo {
+	md, _ := metadata.FromIncomingContext(ctx)
+	value := func(key string) string {
+		if values := md.Get(key); len(values) > 0 {
+			return strings.TrimSpace(values[0])
+		}
+		return ""
+	}
+	return commonmodels.AgentInfo{
+		ID:       value("x-agent-id"),
+		Hostname: value("x-agent-hostname"),
+		Version:  value("x-agent-version"),
+		OS:       value("x-agent-os"),
+	}
+}
+
+func AgentRegistry(registry agentRegistry) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		info := agentInfo(ctx)
+		if info.ID == "" {
+			return handler(ctx, req)
+		}
+		resp, err := handler(ctx, req)
+		registry.Seen(ctx, info, models.AgentTransportGRPC, err)
+		return resp, err
+	}
+}
+
+// StreamAgentRegistry отмечает агента при каждом подтверждении пакета:
+// отвергнутые и отложенные пакеты учитываются как ошибки.
+func StreamAgentRegistry(registry agentRegistry) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := ss.Context()
+		info := agentInfo(ctx)
+		if info.ID == "" {
+			return handler(srv, ss)
+		}
+
+		err := handler(srv, &batchStream{
+			ServerStream: ss,
+			onSend: func(resp *gen.StreamResponse) {
+				ack := resp.GetAck()
+				if ack == nil {
+					return
+				}
+				var ackErr error
+				if s := ack.GetStatus(); s == gen.BatchAck_REJECTED || s == gen.BatchAck_RETRY {
+					ackErr = errors.New(cmp.Or(ack.GetError(), s.String()))
+				}
+				registry.Seen(ctx, info, models.AgentTransportGRPCStream, ackErr)
+			},
+		})
+		if err != nil {
+			registry.Seen(ctx, info, models.AgentTransportGRPCStream, err)
+		}
+		return err
+	}
+}
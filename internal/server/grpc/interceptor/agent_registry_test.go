@@ -0,0 +1,81 @@
+package interceptors
+
+import (
+	"context"
+	"errors"
+	commonmodels "github.com/MxTrap/metrics/internal/common/models"
+	"github.com/MxTrap/metrics/internal/protos/gen"
+	"github.com/MxTrap/metrics/internal/server/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"testing"
+)
+
+type seenCall struct {
+	info      commonmodels.AgentInfo
+	transport string
+	err       error
+}
+
+type stubAgentRegistry struct {
+	calls []seenCall
+}
+
+func (r *stubAgentRegistry) Seen(_ context.Context, info commonmodels.AgentInfo, transport string, err error) {
+	r.calls = append(r.calls, seenCall{info: info, transport: transport, err: err})
+}
+
+func agentContext() context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+		"x-agent-id", "host-1",
+		"x-agent-hostname", "host-1.local",
+		"x-agent-version", "v1.2.0",
+		"x-agent-os", "linux/amd64",
+	))
+}
+
+func TestAgentRegistry(t *testing.T) {
+	registry := &stubAgentRegistry{}
+	interceptor := AgentRegistry(registry)
+	info := &grpc.UnaryServerInfo{FullMethod: "/protos.MetricService/SaveAll"}
+	failing := errors.New("boom")
+
+	_, _ = interceptor(agentContext(), nil, info, func(context.Context, any) (any, error) { return nil, nil })
+	_, err := interceptor(agentContext(), nil, info, func(context.Context, any) (any, error) { return nil, failing })
+	assert.ErrorIs(t, err, failing, "handler error should be returned")
+	_, _ = interceptor(context.Background(), nil, info, func(context.Context, any) (any, error) { return nil, nil })
+
+	require.Len(t, registry.calls, 2, "calls without agent id should not be registered")
+	assert.Equal(t, commonmodels.AgentInfo{ID: "host-1", Hostname: "host-1.local", Version: "v1.2.0", OS: "linux/amd64"}, registry.calls[0].info)
+	assert.Equal(t, models.AgentTransportGRPC, registry.calls[0].transport)
+	assert.NoError(t, registry.calls[0].err)
+	assert.ErrorIs(t, registry.calls[1].err, failing)
+}
+
+func TestStreamAgentRegistry(t *testing.T) {
+	registry := &stubAgentRegistry{}
+	ss := &fakeServerStream{ctx: agentContext(), batches: []*gen.MetricBatch{{Id: "b1"}, {Id: "b2"}}}
+	statuses := []gen.BatchAck_Status{gen.BatchAck_APPLIED, gen.BatchAck_REJECTED}
+
+	err := StreamAgentRegistry(registry)(nil, ss, streamMetrics, func(_ any, ss grpc.ServerStream) error {
+		for _, s := range statuses {
+			batch := &gen.MetricBatch{}
+			if err := ss.RecvMsg(batch); err != nil {
+				return err
+			}
+			if err := ss.SendMsg(ackResponse(batch.GetId(), s, "")); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.Len(t, registry.calls, 2, "each ack should be registered")
+	assert.Equal(t, models.AgentTransportGRPCStream, registry.calls[0].transport)
+	assert.NoError(t, registry.calls[0].err)
+	assert.EqualError(t, registry.calls[1].err, "REJECTED")
+	assert.Len(t, ss.sent, 2, "acks should reach the client")
+}
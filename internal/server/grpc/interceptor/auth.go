@@ -0,0 +1,72 @@
+package interceptors
+
+import (
+	"context"
+	"github.com/MxTrap/metrics/internal/server/models"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"strings"
+)
+
+type authenticator interface {
+	Authenticate(ctx context.Context, secret string) (models.Token, error)
+}
+
+var methodPermissions = map[string]models.Permission{
+	"/protos.MetricService/GetAll":        models.PermissionRead,
+	"/protos.MetricService/Find":          models.PermissionRead,
+	"/protos.MetricService/Save":          models.PermissionWrite,
+	"/protos.MetricService/SaveAll":       models.PermissionWrite,
+	"/protos.MetricService/StreamMetrics": models.PermissionWrite,
+	"/protos.MetricService/List":          models.PermissionRead,
+	"/protos.MetricService/GetAllMetrics": models.PermissionRead,
+	"/protos.MetricService/Watch":         models.PermissionRead,
+	"/protos.AgentService/GetConfig":      models.PermissionWrite,
+	"/protos.AgentService/ReportApplied":  models.PermissionWrite,
+}
+
+// methodPermission возвращает разрешение, необходимое для вызова метода.
+// Для неизвестных методов требуется разрешение admin.
+func methodPermission(fullMethod string) models.Permission {
+	if p, ok := methodPermissions[fullMethod]; ok {
+		return p
+	}
+	return models.PermissionAdmin
+}
+
+func authenticate(ctx context.Context, auth authenticator, fullMethod string) (context.Context, error) {
+	var secret string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			secret, _ = strings.CutPrefix(values[0], "Bearer ")
+		}
+	}
+	token, err := auth.Authenticate(ctx, strings.TrimSpace(secret))
+	if err != nil {
+		return nil, models.ErrUnauthorized
+	}
+	if !token.Has(methodPermission(fullMethod)) {
+		return nil, models.ErrForbidden
+	}
+	return models.ContextWithToken(ctx, token), nil
+}
+
+func Auth(auth authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := authenticate(ctx, auth, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func StreamAuth(auth authenticator) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(ss.Context(), auth, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, withContext(ss, ctx))
+	}
+}
@@ -0,0 +1,59 @@
+package interceptors
+
+import (
+	"context"
+	"errors"
+	"github.com/MxTrap/metrics/internal/server/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"testing"
+)
+
+type stubAuthenticator struct {
+	tokens map[string]models.Token
+}
+
+func (a stubAuthenticator) Authenticate(_ context.Context, secret string) (models.Token, error) {
+	token, ok := a.tokens[secret]
+	if !ok {
+		return models.Token{}, errors.New("unknown token")
+	}
+	return token, nil
+}
+
+func TestAuth(t *testing.T) {
+	auth := stubAuthenticator{tokens: map[string]models.Token{
+		"writer": {ID: "writer", Permissions: []models.Permission{models.PermissionWrite}},
+	}}
+	interceptor := Auth(auth)
+	handler := func(ctx context.Context, req any) (any, error) {
+		token, ok := models.TokenFromContext(ctx)
+		require.True(t, ok, "token should be stored in context")
+		return token.ID, nil
+	}
+	withToken := func(token string) context.Context {
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+	}
+
+	resp, err := interceptor(withToken("writer"), nil, &grpc.UnaryServerInfo{FullMethod: "/protos.MetricService/SaveAll"}, handler)
+	require.NoError(t, err)
+	assert.Equal(t, "writer", resp)
+
+	_, err = interceptor(withToken("writer"), nil, &grpc.UnaryServerInfo{FullMethod: "/protos.MetricService/GetAll"}, handler)
+	assert.ErrorIs(t, err, models.ErrForbidden)
+
+	_, err = interceptor(withToken("unknown"), nil, &grpc.UnaryServerInfo{FullMethod: "/protos.MetricService/SaveAll"}, handler)
+	assert.ErrorIs(t, err, models.ErrUnauthorized)
+
+	_, err = interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/protos.MetricService/SaveAll"}, handler)
+	assert.ErrorIs(t, err, models.ErrUnauthorized)
+}
+
+func TestMethodPermission(t *testing.T) {
+	assert.Equal(t, models.PermissionRead, methodPermission("/protos.MetricService/Find"))
+	assert.Equal(t, models.PermissionWrite, methodPermission("/protos.MetricService/Save"))
+	assert.Equal(t, models.PermissionWrite, methodPermission("/protos.AgentService/GetConfig"))
+	assert.Equal(t, models.PermissionAdmin, methodPermission("/protos.MetricService/Unknown"))
+}
@@ -0,0 +1,100 @@
+package interceptors
+
+import (
+	"context"
+	"errors"
+	"github.com/MxTrap/metrics/internal/protos/gen"
+	"github.com/MxTrap/metrics/internal/server/idempotency"
+	"github.com/MxTrap/metrics/internal/server/models"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"strings"
+	"sync"
+)
+
+func Idempotency(cache *idempotency.Cache) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		var batchID string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get("x-batch-id"); len(values) > 0 {
+				batchID = strings.TrimSpace(values[0])
+			}
+		}
+		if batchID == "" || methodPermission(info.FullMethod) != models.PermissionWrite {
+			return handler(ctx, req)
+		}
+
+		key := idempotency.Key(models.TenantFromContext(ctx), batchID)
+		if err := cache.Begin(key); err != nil {
+			if errors.Is(err, idempotency.ErrDuplicate) {
+				return nil, models.ErrBatchApplied
+			}
+			return nil, models.ErrBatchInProgress
+		}
+		resp, err := handler(ctx, req)
+		if err != nil {
+			cache.Release(key)
+			return nil, err
+		}
+		cache.Commit(key)
+		return resp, nil
+	}
+}
+
+func StreamIdempotency(cache *idempotency.Cache) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if methodPermission(info.FullMethod) != models.PermissionWrite {
+			return handler(srv, ss)
+		}
+
+		tenant := models.TenantFromContext(ss.Context())
+		var mx sync.Mutex
+		pending := map[string]string{}
+		defer func() {
+			mx.Lock()
+			defer mx.Unlock()
+			for _, key := range pending {
+				cache.Release(key)
+			}
+		}()
+
+		return handler(srv, &batchStream{
+			ServerStream: ss,
+			filter: func(batch *gen.MetricBatch) []*gen.StreamResponse {
+				batchID := strings.TrimSpace(batch.GetId())
+				if batchID == "" {
+					return nil
+				}
+				key := idempotency.Key(tenant, batchID)
+				if err := cache.Begin(key); err != nil {
+					if errors.Is(err, idempotency.ErrDuplicate) {
+						return []*gen.StreamResponse{ackResponse(batch.GetId(), gen.BatchAck_DUPLICATE, "")}
+					}
+					return []*gen.StreamResponse{ackResponse(batch.GetId(), gen.BatchAck_RETRY, models.ErrBatchInProgress.Error())}
+				}
+				mx.Lock()
+				pending[batch.GetId()] = key
+				mx.Unlock()
+				return nil
+			},
+			onSend: func(resp *gen.StreamResponse) {
+				ack := resp.GetAck()
+				if ack == nil {
+					return
+				}
+				mx.Lock()
+				key, ok := pending[ack.GetId()]
+				delete(pending, ack.GetId())
+				mx.Unlock()
+				if !ok {
+					return
+				}
+				if ack.GetStatus() == gen.BatchAck_APPLIED {
+					cache.Commit(key)
+				} else {
+					cache.Release(key)
+				}
+			},
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package interceptors
+
+import (
+	"context"
+	"errors"
+	"github.com/MxTrap/metrics/internal/server/idempotency"
+	"github.com/MxTrap/metrics/internal/server/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"testing"
+	"time"
+)
+
+func TestIdempotency(t *testing.T) {
+	interceptor := Idempotency(idempotency.NewCache(time.Minute, 100))
+	write := &grpc.UnaryServerInfo{FullMethod: "/protos.MetricService/SaveAll"}
+	read := &grpc.UnaryServerInfo{FullMethod: "/protos.MetricService/GetAll"}
+	calls := 0
+	handler := func(ctx context.Context, req any) (any, error) {
+		calls++
+		return "ok", nil
+	}
+	batch := func(tenant, id string) context.Context {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-batch-id", id))
+		return models.ContextWithTenant(ctx, tenant)
+	}
+
+	resp, err := interceptor(batch("a", "1"), nil, write, handler)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", resp)
+
+	_, err = interceptor(batch("a", "1"), nil, write, handler)
+	assert.ErrorIs(t, err, models.ErrBatchApplied)
+	assert.Equal(t, 1, calls, "duplicate batch must not reach the handler")
+
+	_, err = interceptor(batch("b", "1"), nil, write, handler)
+	require.NoError(t, err, "same id of another tenant is a different batch")
+
+	_, err = interceptor(batch("a", "1"), nil, read, handler)
+	require.NoError(t, err, "read methods are not deduplicated")
+
+	_, err = interceptor(context.Background(), nil, write, handler)
+	require.NoError(t, err, "requests without batch id are not deduplicated")
+	assert.Equal(t, 4, calls)
+}
+
+func TestIdempotencyFailedBatchCanBeRetried(t *testing.T) {
+	interceptor := Idempotency(idempotency.NewCache(time.Minute, 100))
+	info := &grpc.UnaryServerInfo{FullMethod: "/protos.MetricService/SaveAll"}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-batch-id", "1"))
+
+	_, err := interceptor(ctx, nil, info, func(ctx context.Context, req any) (any, error) {
+		return nil, errors.New("storage unavailable")
+	})
+	require.Error(t, err)
+
+	_, err = interceptor(ctx, nil, info, func(ctx context.Context, req any) (any, error) {
+		return "ok", nil
+	})
+	assert.NoError(t, err)
+}
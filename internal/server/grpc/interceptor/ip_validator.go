@@ -2,43 +2,54 @@ package interceptors
 
 import (
 	"context"
+	"github.com/MxTrap/metrics/internal/server/clientip"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
-	"net"
 )
 
-func IPValidator(cidr string) grpc.UnaryServerInterceptor {
-	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
-		if cidr == "" {
-			return handler(ctx, req)
-		}
-
-		errPermissionDenied := status.Error(codes.PermissionDenied, "")
-		md, ok := metadata.FromIncomingContext(ctx)
-		if !ok {
-			return nil, errPermissionDenied
+func resolveIP(ctx context.Context, resolver *clientip.Resolver, trusted clientip.Subnets) (context.Context, error) {
+	var addr, realIP string
+	var forwardedFor []string
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		addr = p.Addr.String()
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("x-real-ip"); len(values) > 0 {
+			realIP = values[0]
 		}
+		forwardedFor = md.Get("x-forwarded-for")
+	}
 
-		ips := md.Get("X-Real-Ip")
-		if len(ips) == 0 {
-			return nil, errPermissionDenied
-		}
+	ip := resolver.Resolve(addr, realIP, forwardedFor)
+	if ip != nil {
+		ctx = clientip.ContextWithIP(ctx, ip)
+	}
 
-		ip := net.ParseIP(ips[0])
-		if ip == nil {
-			return nil, errPermissionDenied
-		}
+	if len(trusted) > 0 && !trusted.Contains(ip) {
+		return nil, status.Error(codes.PermissionDenied, "")
+	}
+	return ctx, nil
+}
 
-		_, ipNet, err := net.ParseCIDR(cidr)
+func IPValidator(resolver *clientip.Resolver, trusted clientip.Subnets) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := resolveIP(ctx, resolver, trusted)
 		if err != nil {
 			return nil, err
 		}
-		if !ipNet.Contains(ip) {
-			return nil, errPermissionDenied
-		}
-
 		return handler(ctx, req)
 	}
 }
+
+func StreamIPValidator(resolver *clientip.Resolver, trusted clientip.Subnets) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := resolveIP(ss.Context(), resolver, trusted)
+		if err != nil {
+			return err
+		}
+		return handler(srv, withContext(ss, ctx))
+	}
+}
@@ -2,38 +2,42 @@ package interceptors
 
 import (
 	"context"
+	"github.com/MxTrap/metrics/internal/server/clientip"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
+	"net"
 	"testing"
 )
 
-func TestIPValidatorEmptyCIDR(t *testing.T) {
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return "response", nil
-	}
-	info := &grpc.UnaryServerInfo{FullMethod: "/test.Method"}
-	ctx := context.Background()
-
-	interceptor := IPValidator("")
-	resp, err := interceptor(ctx, "request", info, handler)
-
-	assert.NoError(t, err)
-	assert.Equal(t, "response", resp)
+func newIPValidator(t *testing.T, proxies string, trusted string) grpc.UnaryServerInterceptor {
+	t.Helper()
+	proxySubnets, err := clientip.ParseSubnets(proxies)
+	require.NoError(t, err)
+	trustedSubnets, err := clientip.ParseSubnets(trusted)
+	require.NoError(t, err)
+	return IPValidator(clientip.NewResolver(proxySubnets), trustedSubnets)
 }
 
-func TestIPValidatorMissingMetadata(t *testing.T) {
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return "response", nil
+func peerContext(addr string, md metadata.MD) context.Context {
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(addr), Port: 5000}})
+	if md != nil {
+		ctx = metadata.NewIncomingContext(ctx, md)
 	}
-	info := &grpc.UnaryServerInfo{FullMethod: "/test.Method"}
-	ctx := context.Background()
+	return ctx
+}
 
-	interceptor := IPValidator("192.168.1.0/24")
-	resp, err := interceptor(ctx, "request", info, handler)
+func ipHandler(ctx context.Context, req interface{}) (interface{}, error) {
+	ip, _ := clientip.FromContext(ctx)
+	return ip.String(), nil
+}
 
+func assertPermissionDenied(t *testing.T, resp any, err error) {
+	t.Helper()
 	assert.Error(t, err)
 	assert.Nil(t, resp)
 	s, ok := status.FromError(err)
@@ -41,87 +45,63 @@ func TestIPValidatorMissingMetadata(t *testing.T) {
 	assert.Equal(t, codes.PermissionDenied, s.Code())
 }
 
-func TestIPValidatorMissingXRealIP(t *testing.T) {
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return "response", nil
-	}
+func TestIPValidatorEmptyCIDR(t *testing.T) {
 	info := &grpc.UnaryServerInfo{FullMethod: "/test.Method"}
-	ctx := metadata.NewIncomingContext(context.Background(), metadata.New(map[string]string{}))
 
-	interceptor := IPValidator("192.168.1.0/24")
-	resp, err := interceptor(ctx, "request", info, handler)
+	interceptor := newIPValidator(t, "", "")
+	resp, err := interceptor(peerContext("203.0.113.7", nil), "request", info, ipHandler)
 
-	assert.Error(t, err)
-	assert.Nil(t, resp)
-	s, ok := status.FromError(err)
-	assert.True(t, ok)
-	assert.Equal(t, codes.PermissionDenied, s.Code())
+	assert.NoError(t, err)
+	assert.Equal(t, "203.0.113.7", resp)
 }
 
-func TestIPValidatorInvalidXRealIP(t *testing.T) {
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return "response", nil
-	}
+func TestIPValidatorMissingPeer(t *testing.T) {
 	info := &grpc.UnaryServerInfo{FullMethod: "/test.Method"}
-	md := metadata.New(map[string]string{"X-Real-Ip": "invalid-ip"})
+	md := metadata.New(map[string]string{"X-Real-Ip": "192.168.1.100"})
 	ctx := metadata.NewIncomingContext(context.Background(), md)
 
-	interceptor := IPValidator("192.168.1.0/24")
-	resp, err := interceptor(ctx, "request", info, handler)
+	interceptor := newIPValidator(t, "", "192.168.1.0/24")
+	resp, err := interceptor(ctx, "request", info, ipHandler)
 
-	assert.Error(t, err)
-	assert.Nil(t, resp)
-	s, ok := status.FromError(err)
-	assert.True(t, ok)
-	assert.Equal(t, codes.PermissionDenied, s.Code())
+	assertPermissionDenied(t, resp, err)
 }
 
-func TestIPValidatorInvalidCIDR(t *testing.T) {
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return "response", nil
-	}
+func TestIPValidatorSpoofedXRealIP(t *testing.T) {
 	info := &grpc.UnaryServerInfo{FullMethod: "/test.Method"}
 	md := metadata.New(map[string]string{"X-Real-Ip": "192.168.1.100"})
-	ctx := metadata.NewIncomingContext(context.Background(), md)
 
-	interceptor := IPValidator("invalid-cidr")
-	resp, err := interceptor(ctx, "request", info, handler)
+	interceptor := newIPValidator(t, "", "192.168.1.0/24")
+	resp, err := interceptor(peerContext("10.0.0.100", md), "request", info, ipHandler)
 
-	assert.Error(t, err)
-	assert.Nil(t, resp)
-	_, ok := status.FromError(err)
-	assert.False(t, ok) // Ошибка парсинга CIDR не является gRPC-ошибкой
+	assertPermissionDenied(t, resp, err)
 }
 
-func TestIPValidatorIPInCIDR(t *testing.T) {
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return "response", nil
-	}
+func TestIPValidatorXRealIPFromTrustedProxy(t *testing.T) {
 	info := &grpc.UnaryServerInfo{FullMethod: "/test.Method"}
 	md := metadata.New(map[string]string{"X-Real-Ip": "192.168.1.100"})
-	ctx := metadata.NewIncomingContext(context.Background(), md)
 
-	interceptor := IPValidator("192.168.1.0/24")
-	resp, err := interceptor(ctx, "request", info, handler)
+	interceptor := newIPValidator(t, "10.0.0.0/8", "192.168.1.0/24")
+	resp, err := interceptor(peerContext("10.0.0.1", md), "request", info, ipHandler)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "192.168.1.100", resp)
+}
+
+func TestIPValidatorIPInCIDR(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Method"}
+
+	interceptor := newIPValidator(t, "", "192.168.1.0/24,2001:db8::/32")
+	resp, err := interceptor(peerContext("2001:db8::1", nil), "request", info, ipHandler)
 
 	assert.NoError(t, err)
-	assert.Equal(t, "response", resp)
+	assert.Equal(t, "2001:db8::1", resp)
 }
 
 func TestIPValidatorIPNotInCIDR(t *testing.T) {
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return "response", nil
-	}
 	info := &grpc.UnaryServerInfo{FullMethod: "/test.Method"}
-	md := metadata.New(map[string]string{"X-Real-Ip": "10.0.0.100"})
-	ctx := metadata.NewIncomingContext(context.Background(), md)
 
-	interceptor := IPValidator("192.168.1.0/24")
-	resp, err := interceptor(ctx, "request", info, handler)
+	interceptor := newIPValidator(t, "", "192.168.1.0/24")
+	resp, err := interceptor(peerContext("10.0.0.100", nil), "request", info, ipHandler)
 
-	assert.Error(t, err)
-	assert.Nil(t, resp)
-	s, ok := status.FromError(err)
-	assert.True(t, ok)
-	assert.Equal(t, codes.PermissionDenied, s.Code())
+	assertPermissionDenied(t, resp, err)
 }
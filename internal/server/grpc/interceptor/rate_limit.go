@@ -0,0 +1,45 @@
+package interceptors
+
+import (
+	"context"
+	"github.com/MxTrap/metrics/internal/protos/gen"
+	"github.com/MxTrap/metrics/internal/server/models"
+	"github.com/MxTrap/metrics/internal/server/ratelimit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"strconv"
+)
+
+func RateLimit(limiter *ratelimit.Limiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ok, wait := limiter.Allow(ratelimit.ClientKey(ctx) + " " + info.FullMethod)
+		if !ok {
+			_ = grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(ratelimit.RetryAfterSeconds(wait))))
+			return nil, models.ErrRateLimited
+		}
+		return handler(ctx, req)
+	}
+}
+
+func StreamRateLimit(limiter *ratelimit.Limiter) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		key := ratelimit.ClientKey(ss.Context()) + " " + info.FullMethod
+		return handler(srv, &batchStream{
+			ServerStream: ss,
+			filter: func(batch *gen.MetricBatch) []*gen.StreamResponse {
+				ok, _ := limiter.Allow(key)
+				if ok {
+					return nil
+				}
+				hint := &gen.ConfigHint{
+					ReportIntervalSeconds: int64(ratelimit.RetryAfterSeconds(limiter.Interval())),
+					Reason:                models.ErrRateLimited.Error(),
+				}
+				return []*gen.StreamResponse{
+					ackResponse(batch.GetId(), gen.BatchAck_RETRY, models.ErrRateLimited.Error()),
+					{Payload: &gen.StreamResponse_Hint{Hint: hint}},
+				}
+			},
+		})
+	}
+}
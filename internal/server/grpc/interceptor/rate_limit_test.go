@@ -0,0 +1,55 @@
+package interceptors
+
+import (
+	"context"
+	"github.com/MxTrap/metrics/internal/server/clientip"
+	"github.com/MxTrap/metrics/internal/server/models"
+	"github.com/MxTrap/metrics/internal/server/ratelimit"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"net"
+	"testing"
+)
+
+func TestRateLimit(t *testing.T) {
+	interceptor := RateLimit(ratelimit.NewLimiter(1, 1))
+	handler := func(ctx context.Context, req any) (any, error) {
+		return "response", nil
+	}
+	saveAll := &grpc.UnaryServerInfo{FullMethod: "/protos.MetricService/SaveAll"}
+	getAll := &grpc.UnaryServerInfo{FullMethod: "/protos.MetricService/GetAll"}
+	ctx := clientip.ContextWithIP(context.Background(), net.ParseIP("192.168.1.1"))
+
+	resp, err := interceptor(ctx, nil, saveAll, handler)
+	assert.NoError(t, err)
+	assert.Equal(t, "response", resp)
+
+	_, err = interceptor(ctx, nil, saveAll, handler)
+	assert.ErrorIs(t, err, models.ErrRateLimited)
+
+	_, err = interceptor(ctx, nil, getAll, handler)
+	assert.NoError(t, err, "methods are limited separately")
+
+	other := clientip.ContextWithIP(context.Background(), net.ParseIP("192.168.1.2"))
+	_, err = interceptor(other, nil, saveAll, handler)
+	assert.NoError(t, err, "clients are limited separately")
+}
+
+func TestRateLimitPerToken(t *testing.T) {
+	interceptor := RateLimit(ratelimit.NewLimiter(1, 1))
+	handler := func(ctx context.Context, req any) (any, error) {
+		return "response", nil
+	}
+	saveAll := &grpc.UnaryServerInfo{FullMethod: "/protos.MetricService/SaveAll"}
+	ip := clientip.ContextWithIP(context.Background(), net.ParseIP("192.168.1.1"))
+	first := models.ContextWithToken(ip, models.Token{ID: "first"})
+	second := models.ContextWithToken(ip, models.Token{ID: "second"})
+
+	_, err := interceptor(first, nil, saveAll, handler)
+	assert.NoError(t, err)
+	_, err = interceptor(first, nil, saveAll, handler)
+	assert.ErrorIs(t, err, models.ErrRateLimited)
+
+	_, err = interceptor(second, nil, saveAll, handler)
+	assert.NoError(t, err, "tokens behind one address are limited separately")
+}
@@ -0,0 +1,106 @@
+package interceptors
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/hex"
+	"errors"
+	"github.com/MxTrap/metrics/internal/common/sign"
+	"github.com/MxTrap/metrics/internal/protos/gen"
+	"github.com/MxTrap/metrics/internal/server/models"
+	"github.com/MxTrap/metrics/internal/server/replay"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+	"strings"
+)
+
+// signedMethod сообщает, подписывается ли запрос метода: подпись защищает только записываемые метрики.
+func signedMethod(fullMethod string) bool {
+	return strings.HasPrefix(fullMethod, "/protos.MetricService/") && methodPermission(fullMethod) == models.PermissionWrite
+}
+
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// verifySignature проверяет HMAC-подпись сообщения, переданную в метаданных.
+// Подпись вычисляется по детерминированной сериализации сообщения.
+func verifySignature(ctx context.Context, key string, guard *replay.Guard, req any) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	msg, ok := req.(proto.Message)
+	if !ok {
+		return models.ErrInvalidSignature
+	}
+	body, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	if err != nil {
+		return models.ErrInvalidSignature
+	}
+	return verify(key, guard, firstValue(md, "x-timestamp"), firstValue(md, "x-nonce"), firstValue(md, "hashsha256"), body)
+}
+
+// verifyBatch проверяет подпись пакета потока StreamMetrics.
+// Подписывается сериализация SaveAllRequest с метриками пакета, как и в унарном SaveAll.
+func verifyBatch(key string, guard *replay.Guard, batch *gen.MetricBatch) error {
+	body, err := proto.MarshalOptions{Deterministic: true}.Marshal(&gen.SaveAllRequest{Metrics: batch.GetMetrics()})
+	if err != nil {
+		return models.ErrInvalidSignature
+	}
+	return verify(key, guard, batch.GetTimestamp(), batch.GetNonce(), batch.GetSignature(), body)
+}
+
+func verify(key string, guard *replay.Guard, timestamp, nonce, signatureHex string, body []byte) error {
+	signature, err := hex.DecodeString(signatureHex)
+	if err != nil || len(signature) == 0 {
+		return models.ErrInvalidSignature
+	}
+	if timestamp != "" || nonce != "" {
+		body = sign.Payload(timestamp, nonce, body)
+	} else if guard != nil {
+		return models.ErrInvalidSignature
+	}
+	if !hmac.Equal(signature, sign.Sum(key, body)) {
+		return models.ErrInvalidSignature
+	}
+	if guard != nil {
+		if err = guard.Check(timestamp, nonce); errors.Is(err, replay.ErrCacheFull) {
+			return models.ErrRateLimited
+		} else if err != nil {
+			return models.ErrInvalidSignature
+		}
+	}
+	return nil
+}
+
+func Signature(key string, guard *replay.Guard) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if signedMethod(info.FullMethod) {
+			if err := verifySignature(ctx, key, guard, req); err != nil {
+				return nil, err
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+func StreamSignature(key string, guard *replay.Guard) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !signedMethod(info.FullMethod) {
+			return handler(srv, ss)
+		}
+		return handler(srv, &batchStream{
+			ServerStream: ss,
+			filter: func(batch *gen.MetricBatch) []*gen.StreamResponse {
+				if err := verifyBatch(key, guard, batch); errors.Is(err, models.ErrRateLimited) {
+					return []*gen.StreamResponse{ackResponse(batch.GetId(), gen.BatchAck_RETRY, err.Error())}
+				} else if err != nil {
+					return []*gen.StreamResponse{ackResponse(batch.GetId(), gen.BatchAck_REJECTED, err.Error())}
+				}
+				return nil
+			},
+		})
+	}
+}
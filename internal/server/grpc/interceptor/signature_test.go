@@ -0,0 +1,70 @@
+package interceptors
+
+import (
+	"context"
+	"encoding/hex"
+	"github.com/MxTrap/metrics/internal/common/sign"
+	"github.com/MxTrap/metrics/internal/protos/gen"
+	"github.com/MxTrap/metrics/internal/server/models"
+	"github.com/MxTrap/metrics/internal/server/replay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSignature(t *testing.T) {
+	key := "secret"
+	req := &gen.SaveAllRequest{Metrics: []*gen.Metric{{Id: "PollCount", Type: "counter", Delta: proto.Int64(1)}}}
+	body, err := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+	require.NoError(t, err)
+
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	signed := func(ts, nonce string) context.Context {
+		md := metadata.Pairs(
+			"x-timestamp", ts,
+			"x-nonce", nonce,
+			"hashsha256", hex.EncodeToString(sign.Sum(key, sign.Payload(ts, nonce, body))),
+		)
+		return metadata.NewIncomingContext(context.Background(), md)
+	}
+	legacy := metadata.NewIncomingContext(context.Background(), metadata.Pairs("hashsha256", hex.EncodeToString(sign.Sum(key, body))))
+	handler := func(ctx context.Context, req any) (any, error) {
+		return "response", nil
+	}
+	saveAll := &grpc.UnaryServerInfo{FullMethod: "/protos.MetricService/SaveAll"}
+
+	interceptor := Signature(key, replay.NewGuard(time.Minute, 100))
+	resp, err := interceptor(signed(now, "n1"), req, saveAll, handler)
+	require.NoError(t, err)
+	assert.Equal(t, "response", resp)
+
+	_, err = interceptor(signed(now, "n1"), req, saveAll, handler)
+	assert.ErrorIs(t, err, models.ErrInvalidSignature, "replayed nonce should be rejected")
+
+	stale := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	_, err = interceptor(signed(stale, "n2"), req, saveAll, handler)
+	assert.ErrorIs(t, err, models.ErrInvalidSignature, "stale timestamp should be rejected")
+
+	_, err = interceptor(legacy, req, saveAll, handler)
+	assert.ErrorIs(t, err, models.ErrInvalidSignature, "legacy signature should be rejected with replay protection")
+
+	_, err = interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/protos.MetricService/GetAll"}, handler)
+	assert.NoError(t, err, "read methods are not signed")
+
+	_, err = interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/protos.AgentService/ReportApplied"}, handler)
+	assert.NoError(t, err, "agent service methods are not signed")
+
+	full := Signature(key, replay.NewGuard(time.Minute, 1))
+	_, err = full(signed(now, "f1"), req, saveAll, handler)
+	require.NoError(t, err)
+	_, err = full(signed(now, "f2"), req, saveAll, handler)
+	assert.ErrorIs(t, err, models.ErrRateLimited, "full nonce cache should ask to retry")
+
+	_, err = Signature(key, nil)(legacy, req, saveAll, handler)
+	assert.NoError(t, err, "legacy signature is accepted without replay protection")
+}
@@ -14,15 +14,60 @@ func StatusErrorInterceptor(ctx context.Context, req any, _ *grpc.UnaryServerInf
 	if err == nil {
 		return resp, nil
 	}
+	return nil, statusError(err)
+}
+
+func StreamStatusError(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	err := handler(srv, ss)
+	if err == nil {
+		return nil
+	}
+	return statusError(err)
+}
+
+// statusError переводит ошибку обработчика в статус gRPC.
+func statusError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
 	if errors.Is(err, models.ErrNotFoundMetric) {
-		return nil, status.Error(codes.NotFound, "")
+		return status.Error(codes.NotFound, "")
+	}
+	if errors.Is(err, models.ErrNoAgentConfig) {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	if errors.Is(err, models.ErrMissingAgentID) {
+		return status.Error(codes.InvalidArgument, err.Error())
 	}
 	if errors.Is(err, models.ErrUnknownMetricType) {
-		return nil, status.Error(codes.FailedPrecondition, "")
+		return status.Error(codes.FailedPrecondition, "")
 	}
 	if errors.Is(err, models.ErrWrongMetricValue) {
-		return nil, status.Error(codes.FailedPrecondition, "")
+		return status.Error(codes.FailedPrecondition, "")
+	}
+	if errors.Is(err, models.ErrUnauthorized) {
+		return status.Error(codes.Unauthenticated, "")
+	}
+	if errors.Is(err, models.ErrForbidden) {
+		return status.Error(codes.PermissionDenied, "")
+	}
+	if errors.Is(err, models.ErrInvalidRequest) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	if errors.Is(err, models.ErrInvalidSignature) {
+		return status.Error(codes.InvalidArgument, "")
+	}
+	if errors.Is(err, models.ErrBatchApplied) {
+		return status.Error(codes.AlreadyExists, err.Error())
+	}
+	if errors.Is(err, models.ErrBatchInProgress) {
+		return status.Error(codes.Aborted, err.Error())
+	}
+	if errors.Is(err, models.ErrRateLimited) ||
+		errors.Is(err, models.ErrBatchTooLarge) ||
+		errors.Is(err, models.ErrMetricQuota) {
+		return status.Error(codes.ResourceExhausted, err.Error())
 	}
 
-	return nil, status.Error(codes.Internal, "")
+	return status.Error(codes.Internal, "")
 }
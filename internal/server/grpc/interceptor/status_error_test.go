@@ -92,3 +92,25 @@ func TestStatusErrorInterceptorOtherError(t *testing.T) {
 	assert.True(t, ok)
 	assert.Equal(t, codes.Internal, s.Code())
 }
+
+func TestStatusErrorInterceptorAuthErrors(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Method"}
+	cases := map[error]codes.Code{
+		models.ErrUnauthorized:     codes.Unauthenticated,
+		models.ErrForbidden:        codes.PermissionDenied,
+		models.ErrInvalidSignature: codes.InvalidArgument,
+		models.ErrInvalidRequest:   codes.InvalidArgument,
+		models.ErrRateLimited:      codes.ResourceExhausted,
+		models.ErrBatchTooLarge:    codes.ResourceExhausted,
+		models.ErrMetricQuota:      codes.ResourceExhausted,
+	}
+	for appErr, code := range cases {
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, appErr
+		}
+		_, err := StatusErrorInterceptor(context.Background(), "request", info, handler)
+		s, ok := status.FromError(err)
+		assert.True(t, ok)
+		assert.Equal(t, code, s.Code())
+	}
+}
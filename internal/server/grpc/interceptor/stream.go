@@ -0,0 +1,74 @@
+package interceptors
+
+import (
+	"context"
+	"github.com/MxTrap/metrics/internal/protos/gen"
+	"google.golang.org/grpc"
+	"sync"
+)
+
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+func withContext(ss grpc.ServerStream, ctx context.Context) grpc.ServerStream {
+	return &contextStream{ServerStream: ss, ctx: ctx}
+}
+
+// batchStream пропускает каждый принятый пакет метрик через filter.
+// Если filter вернул ответы, пакет не передаётся обработчику: ответы сразу отправляются клиенту,
+// а поток читает следующий пакет. onSend вызывается для ответов, отправляемых обработчиком.
+type batchStream struct {
+	grpc.ServerStream
+	mx     sync.Mutex
+	filter func(batch *gen.MetricBatch) []*gen.StreamResponse
+	onSend func(resp *gen.StreamResponse)
+}
+
+func (s *batchStream) RecvMsg(m any) error {
+	for {
+		if err := s.ServerStream.RecvMsg(m); err != nil {
+			return err
+		}
+		batch, ok := m.(*gen.MetricBatch)
+		if !ok || s.filter == nil {
+			return nil
+		}
+		responses := s.filter(batch)
+		if len(responses) == 0 {
+			return nil
+		}
+		if err := s.send(responses...); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *batchStream) SendMsg(m any) error {
+	if resp, ok := m.(*gen.StreamResponse); ok && s.onSend != nil {
+		s.onSend(resp)
+	}
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.ServerStream.SendMsg(m)
+}
+
+func (s *batchStream) send(responses ...*gen.StreamResponse) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	for _, resp := range responses {
+		if err := s.ServerStream.SendMsg(resp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func ackResponse(id string, status gen.BatchAck_Status, msg string) *gen.StreamResponse {
+	return &gen.StreamResponse{Payload: &gen.StreamResponse_Ack{Ack: &gen.BatchAck{Id: id, Status: status, Error: msg}}}
+}
@@ -0,0 +1,126 @@
+package interceptors
+
+import (
+	"context"
+	"encoding/hex"
+	"github.com/MxTrap/metrics/internal/common/sign"
+	"github.com/MxTrap/metrics/internal/protos/gen"
+	"github.com/MxTrap/metrics/internal/server/idempotency"
+	"github.com/MxTrap/metrics/internal/server/models"
+	"github.com/MxTrap/metrics/internal/server/ratelimit"
+	"github.com/MxTrap/metrics/internal/server/replay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"io"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx     context.Context
+	batches []*gen.MetricBatch
+	sent    []*gen.StreamResponse
+}
+
+func (f *fakeServerStream) Context() context.Context {
+	return f.ctx
+}
+
+func (f *fakeServerStream) RecvMsg(m any) error {
+	if len(f.batches) == 0 {
+		return io.EOF
+	}
+	proto.Merge(m.(*gen.MetricBatch), f.batches[0])
+	f.batches = f.batches[1:]
+	return nil
+}
+
+func (f *fakeServerStream) SendMsg(m any) error {
+	f.sent = append(f.sent, m.(*gen.StreamResponse))
+	return nil
+}
+
+var streamMetrics = &grpc.StreamServerInfo{FullMethod: "/protos.MetricService/StreamMetrics", IsClientStream: true, IsServerStream: true}
+
+// ackHandler отвечает на каждый полученный пакет статусом status и возвращает идентификаторы пакетов.
+func ackHandler(status gen.BatchAck_Status, received *[]string) grpc.StreamHandler {
+	return func(_ any, ss grpc.ServerStream) error {
+		for {
+			batch := &gen.MetricBatch{}
+			if err := ss.RecvMsg(batch); err != nil {
+				return nil
+			}
+			*received = append(*received, batch.GetId())
+			if err := ss.SendMsg(ackResponse(batch.GetId(), status, "")); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func TestStreamSignature(t *testing.T) {
+	key := "secret"
+	metrics := []*gen.Metric{{Id: "PollCount", Type: "counter", Delta: proto.Int64(1)}}
+	body, err := proto.MarshalOptions{Deterministic: true}.Marshal(&gen.SaveAllRequest{Metrics: metrics})
+	require.NoError(t, err)
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	signature := hex.EncodeToString(sign.Sum(key, sign.Payload(ts, "n1", body)))
+
+	stream := &fakeServerStream{ctx: context.Background(), batches: []*gen.MetricBatch{
+		{Id: "good", Metrics: metrics, Timestamp: ts, Nonce: "n1", Signature: signature},
+		{Id: "replayed", Metrics: metrics, Timestamp: ts, Nonce: "n1", Signature: signature},
+		{Id: "forged", Metrics: metrics, Timestamp: ts, Nonce: "n2", Signature: signature},
+	}}
+	var received []string
+	err = StreamSignature(key, replay.NewGuard(time.Minute, 100))(nil, stream, streamMetrics, ackHandler(gen.BatchAck_APPLIED, &received))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"good"}, received)
+	require.Len(t, stream.sent, 3)
+	assert.Equal(t, gen.BatchAck_REJECTED, stream.sent[1].GetAck().GetStatus())
+	assert.Equal(t, "replayed", stream.sent[1].GetAck().GetId())
+	assert.Equal(t, models.ErrInvalidSignature.Error(), stream.sent[2].GetAck().GetError())
+}
+
+func TestStreamIdempotency(t *testing.T) {
+	cache := idempotency.NewCache(time.Minute, 100)
+	interceptor := StreamIdempotency(cache)
+	ctx := models.ContextWithTenant(context.Background(), "a")
+
+	stream := &fakeServerStream{ctx: ctx, batches: []*gen.MetricBatch{{Id: "1"}, {Id: "1"}, {Id: "2"}}}
+	var received []string
+	require.NoError(t, interceptor(nil, stream, streamMetrics, ackHandler(gen.BatchAck_APPLIED, &received)))
+	assert.Equal(t, []string{"1", "2"}, received, "duplicate batch must not reach the handler")
+	require.Len(t, stream.sent, 3)
+	assert.Equal(t, gen.BatchAck_DUPLICATE, stream.sent[1].GetAck().GetStatus())
+
+	stream = &fakeServerStream{ctx: ctx, batches: []*gen.MetricBatch{{Id: "3"}}}
+	received = nil
+	require.NoError(t, interceptor(nil, stream, streamMetrics, ackHandler(gen.BatchAck_RETRY, &received)))
+	assert.NoError(t, cache.Begin(idempotency.Key("a", "3")), "batch that was not applied can be retried")
+
+	stream = &fakeServerStream{ctx: ctx, batches: []*gen.MetricBatch{{Id: "4"}}}
+	require.NoError(t, interceptor(nil, stream, streamMetrics, func(_ any, ss grpc.ServerStream) error {
+		return ss.RecvMsg(&gen.MetricBatch{})
+	}))
+	assert.NoError(t, cache.Begin(idempotency.Key("a", "4")), "unacknowledged batch is released when the stream ends")
+}
+
+func TestStreamRateLimit(t *testing.T) {
+	limiter := ratelimit.NewLimiter(0.1, 1)
+	stream := &fakeServerStream{ctx: context.Background(), batches: []*gen.MetricBatch{{Id: "1"}, {Id: "2"}}}
+	var received []string
+	require.NoError(t, StreamRateLimit(limiter)(nil, stream, streamMetrics, ackHandler(gen.BatchAck_APPLIED, &received)))
+
+	assert.Equal(t, []string{"1"}, received)
+	require.Len(t, stream.sent, 3)
+	assert.Equal(t, gen.BatchAck_RETRY, stream.sent[1].GetAck().GetStatus())
+	assert.Equal(t, "2", stream.sent[1].GetAck().GetId())
+	hint := stream.sent[2].GetHint()
+	require.NotNil(t, hint)
+	assert.Equal(t, int64(10), hint.GetReportIntervalSeconds())
+}
@@ -0,0 +1,39 @@
+package interceptors
+
+import (
+	"context"
+	"github.com/MxTrap/metrics/internal/server/models"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"strings"
+)
+
+func tenantContext(ctx context.Context) (context.Context, error) {
+	var requested string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("x-tenant-id"); len(values) > 0 {
+			requested = strings.TrimSpace(values[0])
+		}
+	}
+	tenant, err := models.ResolveTenant(ctx, requested)
+	if err != nil {
+		return nil, err
+	}
+	return models.ContextWithTenant(ctx, tenant), nil
+}
+
+func Tenant(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := tenantContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func StreamTenant(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := tenantContext(ss.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, withContext(ss, ctx))
+}
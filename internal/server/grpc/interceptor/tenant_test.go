@@ -0,0 +1,41 @@
+package interceptors
+
+import (
+	"context"
+	"github.com/MxTrap/metrics/internal/server/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"testing"
+)
+
+func TestTenant(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/protos.MetricService/GetAll"}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return models.TenantFromContext(ctx), nil
+	}
+
+	resp, err := Tenant(context.Background(), nil, info, handler)
+	require.NoError(t, err)
+	assert.Equal(t, models.DefaultTenant, resp)
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-tenant-id", "team-a"))
+	resp, err = Tenant(ctx, nil, info, handler)
+	require.NoError(t, err)
+	assert.Equal(t, "team-a", resp)
+
+	resp, err = Tenant(models.ContextWithToken(context.Background(), models.Token{ID: "b", Tenant: "team-b"}), nil, info, handler)
+	require.NoError(t, err)
+	assert.Equal(t, "team-b", resp)
+
+	resp, err = Tenant(models.ContextWithToken(context.Background(), models.Token{ID: "any"}), nil, info, handler)
+	require.NoError(t, err)
+	assert.Equal(t, models.DefaultTenant, resp)
+
+	_, err = Tenant(models.ContextWithToken(ctx, models.Token{ID: "b", Tenant: "team-b"}), nil, info, handler)
+	assert.ErrorIs(t, err, models.ErrForbidden, "header must not override the token tenant")
+
+	_, err = Tenant(models.ContextWithToken(ctx, models.Token{ID: "any"}), nil, info, handler)
+	assert.ErrorIs(t, err, models.ErrForbidden, "header must not override the default tenant of a token")
+}
@@ -2,10 +2,14 @@ package grpc
 
 import (
 	"context"
+	"errors"
 	commonmodels "github.com/MxTrap/metrics/internal/common/models"
 	"github.com/MxTrap/metrics/internal/protos/gen"
+	"github.com/MxTrap/metrics/internal/server/models"
 	"google.golang.org/protobuf/types/known/emptypb"
 	"google.golang.org/protobuf/types/known/structpb"
+	"io"
+	"time"
 )
 
 type saver interface {
@@ -16,6 +20,7 @@ type saver interface {
 type getter interface {
 	Find(ctx context.Context, metric commonmodels.Metric) (commonmodels.Metric, error)
 	GetAll(ctx context.Context) (map[string]any, error)
+	List(ctx context.Context, filter models.MetricFilter) (models.MetricPage, error)
 }
 
 // MetricService определяет интерфейс для операций с метриками, включая сохранение, получение и проверку хранилища.
@@ -24,8 +29,14 @@ type service interface {
 	getter
 	Ping(ctx context.Context) error
 }
+
+type watcher interface {
+	Subscribe(ctx context.Context, filter models.MetricFilter) (<-chan models.MetricUpdate, error)
+}
+
 type MetricsServiceServer struct {
 	service service
+	watcher watcher
 	gen.UnimplementedMetricServiceServer
 }
 
@@ -33,6 +44,12 @@ func NewMetricsServiceServer(service service) *MetricsServiceServer {
 	return &MetricsServiceServer{service: service}
 }
 
+// RegisterWatchService включает подписку на обновления метрик.
+// Без сервиса подписок Watch возвращает codes.Unimplemented.
+func (s *MetricsServiceServer) RegisterWatchService(watcher watcher) {
+	s.watcher = watcher
+}
+
 func (s *MetricsServiceServer) mapProtoMetric(metric *gen.Metric) commonmodels.Metric {
 	return commonmodels.Metric{
 		ID:    metric.Id,
@@ -51,6 +68,8 @@ func (s *MetricsServiceServer) mapCommonMetric(metric commonmodels.Metric) *gen.
 	}
 }
 
+// GetAll возвращает значения метрик в виде google.protobuf.Struct, в котором теряется тип метрики.
+// Оставлен для совместимости со старыми клиентами; новым клиентам следует использовать GetAllMetrics.
 func (s *MetricsServiceServer) GetAll(ctx context.Context, _ *emptypb.Empty) (*gen.GetAllResponse, error) {
 	m, err := s.service.GetAll(ctx)
 	if err != nil {
@@ -64,12 +83,16 @@ func (s *MetricsServiceServer) GetAll(ctx context.Context, _ *emptypb.Empty) (*g
 
 }
 
-func (s *MetricsServiceServer) SaveAll(ctx context.Context, in *gen.SaveAllRequest) (*emptypb.Empty, error) {
-	metrics := make([]commonmodels.Metric, len(in.Metrics))
-	for i, m := range in.Metrics {
+func (s *MetricsServiceServer) mapProtoMetrics(in []*gen.Metric) []commonmodels.Metric {
+	metrics := make([]commonmodels.Metric, len(in))
+	for i, m := range in {
 		metrics[i] = s.mapProtoMetric(m)
 	}
-	err := s.service.SaveAll(ctx, metrics)
+	return metrics
+}
+
+func (s *MetricsServiceServer) SaveAll(ctx context.Context, in *gen.SaveAllRequest) (*emptypb.Empty, error) {
+	err := s.service.SaveAll(ctx, s.mapProtoMetrics(in.Metrics))
 	if err != nil {
 		return nil, err
 	}
@@ -89,3 +112,145 @@ func (s *MetricsServiceServer) Save(ctx context.Context, in *gen.Metric) (*empty
 	}
 	return nil, nil
 }
+
+// listFilter переводит запрос списка метрик в фильтр сервиса.
+func (s *MetricsServiceServer) listFilter(in *gen.ListRequest) (models.MetricFilter, error) {
+	after, err := models.DecodeMetricCursor(in.GetCursor())
+	if err != nil {
+		return models.MetricFilter{}, err
+	}
+	filter := models.MetricFilter{
+		Type:        in.GetType(),
+		NamePrefix:  in.GetNamePrefix(),
+		NamePattern: in.GetNamePattern(),
+		Sort:        in.GetSort(),
+		After:       after,
+		Limit:       int(in.GetLimit()),
+	}
+	if in.GetUpdatedSinceUnix() > 0 {
+		filter.UpdatedSince = time.Unix(in.GetUpdatedSinceUnix(), 0)
+	}
+	return filter, nil
+}
+
+func (s *MetricsServiceServer) mapMetricRecord(record models.MetricRecord) *gen.MetricRecord {
+	return &gen.MetricRecord{
+		Metric:        s.mapCommonMetric(record.Metric()),
+		UpdatedAtUnix: record.UpdatedAt.Unix(),
+	}
+}
+
+// List возвращает страницу метрик с типами и временем обновления, отобранных по фильтрам запроса.
+// Следующая страница запрашивается с курсором next_cursor из ответа.
+func (s *MetricsServiceServer) List(ctx context.Context, in *gen.ListRequest) (*gen.ListResponse, error) {
+	filter, err := s.listFilter(in)
+	if err != nil {
+		return nil, err
+	}
+	page, err := s.service.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	resp := &gen.ListResponse{
+		Metrics:    make([]*gen.MetricRecord, len(page.Metrics)),
+		NextCursor: page.NextCursor,
+	}
+	for i, record := range page.Metrics {
+		resp.Metrics[i] = s.mapMetricRecord(record)
+	}
+	return resp, nil
+}
+
+// GetAllMetrics передаёт клиенту все метрики, отобранные по фильтрам запроса, с сохранением их типов.
+// Метрики читаются из сервиса постранично и отправляются частями размером limit,
+// поэтому большие выборки не собираются в памяти целиком. Курсор запроса задаёт, с какой метрики начать.
+func (s *MetricsServiceServer) GetAllMetrics(in *gen.ListRequest, stream gen.MetricService_GetAllMetricsServer) error {
+	filter, err := s.listFilter(in)
+	if err != nil {
+		return err
+	}
+	ctx := stream.Context()
+	for {
+		page, err := s.service.List(ctx, filter)
+		if err != nil {
+			return err
+		}
+		chunk := &gen.MetricChunk{Metrics: make([]*gen.MetricRecord, len(page.Metrics))}
+		for i, record := range page.Metrics {
+			chunk.Metrics[i] = s.mapMetricRecord(record)
+		}
+		if len(chunk.Metrics) > 0 {
+			if err = stream.Send(chunk); err != nil {
+				return err
+			}
+		}
+		if page.NextCursor == "" {
+			return nil
+		}
+		if filter.After, err = models.DecodeMetricCursor(page.NextCursor); err != nil {
+			return err
+		}
+	}
+}
+
+// Watch передаёт клиенту принятые сервером обновления метрик, отобранные по фильтрам запроса,
+// пока клиент не закроет поток. Если клиент не успевает читать, часть обновлений пропускается,
+// а их число передаётся в поле dropped следующего обновления.
+func (s *MetricsServiceServer) Watch(in *gen.WatchRequest, stream gen.MetricService_WatchServer) error {
+	if s.watcher == nil {
+		return s.UnimplementedMetricServiceServer.Watch(in, stream)
+	}
+	updates, err := s.watcher.Subscribe(stream.Context(), models.MetricFilter{
+		Type:        in.GetType(),
+		NamePrefix:  in.GetNamePrefix(),
+		NamePattern: in.GetNamePattern(),
+	})
+	if err != nil {
+		return err
+	}
+	for update := range updates {
+		if err = stream.Send(&gen.MetricUpdate{
+			Record:  s.mapMetricRecord(update.MetricRecord),
+			Dropped: update.Dropped,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StreamMetrics принимает пакеты метрик в долгоживущем потоке и подтверждает каждый пакет отдельно.
+// Ошибки валидации и квот отклоняют пакет без повтора, остальные ошибки просят клиента повторить отправку.
+// Поток завершается, когда клиент закрывает отправку.
+func (s *MetricsServiceServer) StreamMetrics(stream gen.MetricService_StreamMetricsServer) error {
+	for {
+		batch, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		ack := &gen.BatchAck{Id: batch.GetId(), Status: gen.BatchAck_APPLIED}
+		if err := s.service.SaveAll(stream.Context(), s.mapProtoMetrics(batch.GetMetrics())); err != nil {
+			if rejected(err) {
+				ack.Status = gen.BatchAck_REJECTED
+				ack.Error = err.Error()
+			} else {
+				ack.Status = gen.BatchAck_RETRY
+			}
+		}
+		if err := stream.Send(&gen.StreamResponse{Payload: &gen.StreamResponse_Ack{Ack: ack}}); err != nil {
+			return err
+		}
+	}
+}
+
+// rejected сообщает, что пакет не будет принят и при повторной отправке.
+func rejected(err error) bool {
+	return errors.Is(err, models.ErrUnknownMetricType) ||
+		errors.Is(err, models.ErrWrongMetricValue) ||
+		errors.Is(err, models.ErrBatchTooLarge) ||
+		errors.Is(err, models.ErrMetricQuota)
+}
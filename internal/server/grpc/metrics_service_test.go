@@ -3,15 +3,22 @@ package grpc
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/MxTrap/metrics/internal/common/models"
 	"github.com/MxTrap/metrics/internal/protos/gen"
+	servermodels "github.com/MxTrap/metrics/internal/server/models"
 	"github.com/MxTrap/metrics/internal/utils"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
 	"google.golang.org/protobuf/types/known/structpb"
+	"io"
 	"testing"
+	"time"
 )
 
 type mockService struct {
@@ -38,6 +45,11 @@ func (m *mockService) GetAll(ctx context.Context) (map[string]any, error) {
 	return args.Get(0).(map[string]any), args.Error(1)
 }
 
+func (m *mockService) List(ctx context.Context, filter servermodels.MetricFilter) (servermodels.MetricPage, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).(servermodels.MetricPage), args.Error(1)
+}
+
 func (m *mockService) Ping(ctx context.Context) error {
 	args := m.Called(ctx)
 	return args.Error(0)
@@ -143,6 +155,48 @@ func TestGetAllInvalidStruct(t *testing.T) {
 	svc.AssertExpectations(t)
 }
 
+func TestList(t *testing.T) {
+	svc := &mockService{}
+	server := NewMetricsServiceServer(svc)
+
+	ctx := context.Background()
+	cursor := servermodels.MetricCursor{Name: "Alloc", UpdatedAt: time.Unix(1700000000, 0).UTC()}
+	svc.On("List", ctx, servermodels.MetricFilter{
+		Type:         models.Counter,
+		NamePrefix:   "Poll",
+		NamePattern:  "Count$",
+		UpdatedSince: time.Unix(1700000000, 0),
+		Sort:         servermodels.SortByUpdated,
+		After:        &cursor,
+		Limit:        10,
+	}).Return(servermodels.MetricPage{
+		Metrics: []servermodels.MetricRecord{
+			{ID: "PollCount", MType: models.Counter, Delta: utils.MakePointer[int64](5), UpdatedAt: time.Unix(1700000100, 0)},
+		},
+		NextCursor: "next",
+	}, nil)
+
+	resp, err := server.List(ctx, &gen.ListRequest{
+		Type:             models.Counter,
+		NamePrefix:       "Poll",
+		NamePattern:      "Count$",
+		UpdatedSinceUnix: 1700000000,
+		Sort:             servermodels.SortByUpdated,
+		Limit:            10,
+		Cursor:           cursor.Encode(),
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.GetMetrics(), 1)
+	assert.Equal(t, "PollCount", resp.GetMetrics()[0].GetMetric().GetId())
+	assert.Equal(t, int64(5), resp.GetMetrics()[0].GetMetric().GetDelta())
+	assert.Equal(t, int64(1700000100), resp.GetMetrics()[0].GetUpdatedAtUnix())
+	assert.Equal(t, "next", resp.GetNextCursor())
+	svc.AssertExpectations(t)
+
+	_, err = server.List(ctx, &gen.ListRequest{Cursor: "!!"})
+	assert.ErrorIs(t, err, servermodels.ErrInvalidRequest)
+}
+
 func TestSaveAllSuccess(t *testing.T) {
 	svc := &mockService{}
 	server := NewMetricsServiceServer(svc)
@@ -251,3 +305,156 @@ func TestSaveError(t *testing.T) {
 	assert.Nil(t, resp)
 	svc.AssertExpectations(t)
 }
+
+type fakeMetricsStream struct {
+	grpc.ServerStream
+	batches []*gen.MetricBatch
+	sent    []*gen.StreamResponse
+}
+
+func (f *fakeMetricsStream) Context() context.Context {
+	return context.Background()
+}
+
+func (f *fakeMetricsStream) Recv() (*gen.MetricBatch, error) {
+	if len(f.batches) == 0 {
+		return nil, io.EOF
+	}
+	batch := f.batches[0]
+	f.batches = f.batches[1:]
+	return batch, nil
+}
+
+func (f *fakeMetricsStream) Send(resp *gen.StreamResponse) error {
+	f.sent = append(f.sent, resp)
+	return nil
+}
+
+func TestStreamMetrics(t *testing.T) {
+	svc := &mockService{}
+	server := NewMetricsServiceServer(svc)
+
+	delta := utils.MakePointer[int64](1)
+	ok := []models.Metric{{ID: "c", MType: "counter", Delta: delta}}
+	invalid := []models.Metric{{ID: "x", MType: "unknown"}}
+	failing := []models.Metric{{ID: "f", MType: "counter", Delta: delta}}
+	svc.On("SaveAll", mock.Anything, ok).Return(nil)
+	svc.On("SaveAll", mock.Anything, invalid).Return(fmt.Errorf("save: %w", servermodels.ErrUnknownMetricType))
+	svc.On("SaveAll", mock.Anything, failing).Return(errors.New("db is down"))
+
+	stream := &fakeMetricsStream{batches: []*gen.MetricBatch{
+		{Id: "b1", Metrics: []*gen.Metric{{Id: "c", Type: "counter", Delta: delta}}},
+		{Id: "b2", Metrics: []*gen.Metric{{Id: "x", Type: "unknown"}}},
+		{Id: "b3", Metrics: []*gen.Metric{{Id: "f", Type: "counter", Delta: delta}}},
+	}}
+	require.NoError(t, server.StreamMetrics(stream))
+
+	require.Len(t, stream.sent, 3)
+	assert.Equal(t, "b1", stream.sent[0].GetAck().GetId())
+	assert.Equal(t, gen.BatchAck_APPLIED, stream.sent[0].GetAck().GetStatus())
+	assert.Equal(t, gen.BatchAck_REJECTED, stream.sent[1].GetAck().GetStatus())
+	assert.Contains(t, stream.sent[1].GetAck().GetError(), servermodels.ErrUnknownMetricType.Error())
+	assert.Equal(t, gen.BatchAck_RETRY, stream.sent[2].GetAck().GetStatus())
+	assert.Empty(t, stream.sent[2].GetAck().GetError())
+}
+
+type fakeChunkStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	sent []*gen.MetricChunk
+}
+
+func (f *fakeChunkStream) Context() context.Context {
+	return f.ctx
+}
+
+func (f *fakeChunkStream) Send(chunk *gen.MetricChunk) error {
+	f.sent = append(f.sent, chunk)
+	return nil
+}
+
+func TestGetAllMetrics(t *testing.T) {
+	svc := &mockService{}
+	server := NewMetricsServiceServer(svc)
+
+	ctx := context.Background()
+	updatedAt := time.Unix(1700000000, 0)
+	cursor := servermodels.MetricCursor{Name: "Alloc", UpdatedAt: updatedAt}
+	svc.On("List", ctx, servermodels.MetricFilter{Type: models.Gauge, Limit: 1}).Return(servermodels.MetricPage{
+		Metrics:    []servermodels.MetricRecord{{ID: "Alloc", MType: models.Gauge, Value: utils.MakePointer(1.5), UpdatedAt: updatedAt}},
+		NextCursor: cursor.Encode(),
+	}, nil).Once()
+	svc.On("List", ctx, mock.MatchedBy(func(f servermodels.MetricFilter) bool {
+		return f.After != nil && f.After.Name == "Alloc" && f.After.UpdatedAt.Equal(updatedAt)
+	})).Return(servermodels.MetricPage{
+		Metrics: []servermodels.MetricRecord{{ID: "Sys", MType: models.Gauge, Value: utils.MakePointer(2.5), UpdatedAt: updatedAt}},
+	}, nil).Once()
+
+	stream := &fakeChunkStream{ctx: ctx}
+	require.NoError(t, server.GetAllMetrics(&gen.ListRequest{Type: models.Gauge, Limit: 1}, stream))
+	require.Len(t, stream.sent, 2, "every page should be sent as a separate chunk")
+	assert.Equal(t, "Alloc", stream.sent[0].GetMetrics()[0].GetMetric().GetId())
+	assert.Equal(t, "Sys", stream.sent[1].GetMetrics()[0].GetMetric().GetId())
+	assert.Equal(t, 2.5, stream.sent[1].GetMetrics()[0].GetMetric().GetValue())
+	svc.AssertExpectations(t)
+
+	svc.On("List", ctx, servermodels.MetricFilter{Type: "histogram"}).Return(servermodels.MetricPage{}, servermodels.ErrUnknownMetricType)
+	stream = &fakeChunkStream{ctx: ctx}
+	err := server.GetAllMetrics(&gen.ListRequest{Type: "histogram"}, stream)
+	assert.ErrorIs(t, err, servermodels.ErrUnknownMetricType)
+	assert.Empty(t, stream.sent)
+}
+
+type mockWatcher struct {
+	mock.Mock
+}
+
+func (m *mockWatcher) Subscribe(ctx context.Context, filter servermodels.MetricFilter) (<-chan servermodels.MetricUpdate, error) {
+	args := m.Called(ctx, filter)
+	updates, _ := args.Get(0).(chan servermodels.MetricUpdate)
+	return updates, args.Error(1)
+}
+
+type fakeUpdateStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	sent []*gen.MetricUpdate
+}
+
+func (f *fakeUpdateStream) Context() context.Context {
+	return f.ctx
+}
+
+func (f *fakeUpdateStream) Send(update *gen.MetricUpdate) error {
+	f.sent = append(f.sent, update)
+	return nil
+}
+
+func TestWatch(t *testing.T) {
+	server := NewMetricsServiceServer(&mockService{})
+	ctx := context.Background()
+
+	err := server.Watch(&gen.WatchRequest{}, &fakeUpdateStream{ctx: ctx})
+	assert.Equal(t, codes.Unimplemented, status.Code(err), "watch should be unavailable without a watch service")
+
+	watcher := &mockWatcher{}
+	server.RegisterWatchService(watcher)
+	updates := make(chan servermodels.MetricUpdate, 2)
+	updatedAt := time.Unix(1700000000, 0)
+	updates <- servermodels.MetricUpdate{MetricRecord: servermodels.MetricRecord{ID: "PollCount", MType: models.Counter, Delta: utils.MakePointer(int64(3)), UpdatedAt: updatedAt}}
+	updates <- servermodels.MetricUpdate{MetricRecord: servermodels.MetricRecord{ID: "PollCount", MType: models.Counter, Delta: utils.MakePointer(int64(4)), UpdatedAt: updatedAt}, Dropped: 5}
+	close(updates)
+	watcher.On("Subscribe", ctx, servermodels.MetricFilter{Type: models.Counter, NamePrefix: "Poll", NamePattern: "Count$"}).Return(updates, nil)
+	watcher.On("Subscribe", ctx, servermodels.MetricFilter{NamePattern: "("}).Return(nil, servermodels.ErrInvalidRequest)
+
+	stream := &fakeUpdateStream{ctx: ctx}
+	require.NoError(t, server.Watch(&gen.WatchRequest{Type: models.Counter, NamePrefix: "Poll", NamePattern: "Count$"}, stream))
+	require.Len(t, stream.sent, 2)
+	assert.Equal(t, int64(3), stream.sent[0].GetRecord().GetMetric().GetDelta())
+	assert.Equal(t, updatedAt.Unix(), stream.sent[0].GetRecord().GetUpdatedAtUnix())
+	assert.Equal(t, uint64(5), stream.sent[1].GetDropped())
+
+	err = server.Watch(&gen.WatchRequest{NamePattern: "("}, &fakeUpdateStream{ctx: ctx})
+	assert.ErrorIs(t, err, servermodels.ErrInvalidRequest)
+	watcher.AssertExpectations(t)
+}
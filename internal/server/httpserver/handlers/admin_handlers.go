@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"context"
+	"github.com/MxTrap/metrics/internal/server/models"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"sort"
+)
+
+type tokenLister interface {
+	ListTokens(ctx context.Context) ([]models.Token, error)
+}
+
+// AdminHandler обслуживает административные маршруты сервера.
+type AdminHandler struct {
+	router *gin.Engine
+	tokens tokenLister
+}
+
+// NewAdminHandler создаёт AdminHandler с указанным источником токенов и Gin-роутером.
+func NewAdminHandler(tokens tokenLister, router *gin.Engine) *AdminHandler {
+	return &AdminHandler{
+		router: router,
+		tokens: tokens,
+	}
+}
+
+// RegisterRoutes регистрирует административные маршруты.
+func (h AdminHandler) RegisterRoutes() {
+	h.router.GET("/admin/tokens", h.listTokens)
+}
+
+// listTokens обрабатывает GET-запросы на получение списка API-токенов.
+// Возвращает идентификаторы, разрешения и префиксы токенов без их секретов.
+func (h AdminHandler) listTokens(g *gin.Context) {
+	tokens, err := h.tokens.ListTokens(g)
+	if err != nil {
+		_ = g.Error(err)
+		return
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].ID < tokens[j].ID
+	})
+	g.JSON(http.StatusOK, tokens)
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	servermodels "github.com/MxTrap/metrics/internal/server/models"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type mockTokenLister struct {
+	mock.Mock
+}
+
+func (m *mockTokenLister) ListTokens(ctx context.Context) ([]servermodels.Token, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]servermodels.Token), args.Error(1)
+}
+
+func TestAdminHandlerListTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	lister := &mockTokenLister{}
+	lister.On("ListTokens", mock.Anything).Return([]servermodels.Token{
+		{ID: "ops", Hash: "secret-hash", Permissions: []servermodels.Permission{servermodels.PermissionAdmin}},
+		{ID: "agent", Hash: "secret-hash", Permissions: []servermodels.Permission{servermodels.PermissionWrite}, Prefix: "app_"},
+	}, nil)
+	NewAdminHandler(lister, router).RegisterRoutes()
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/tokens", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "secret-hash", "token secrets must not be exposed")
+
+	var tokens []map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tokens))
+	require.Len(t, tokens, 2)
+	assert.Equal(t, "agent", tokens[0]["id"])
+	assert.Equal(t, "app_", tokens[0]["prefix"])
+	assert.Equal(t, "ops", tokens[1]["id"])
+}
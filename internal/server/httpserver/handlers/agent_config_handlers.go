@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	commonmodels "github.com/MxTrap/metrics/internal/common/models"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+type agentConfigService interface {
+	Config(ctx context.Context, agentID, group string) (commonmodels.AgentConfig, error)
+	ReportApplied(ctx context.Context, applied commonmodels.AppliedConfig) error
+	Applied(ctx context.Context) []commonmodels.AppliedConfig
+}
+
+// AgentConfigHandler раздаёт агентам настройки и принимает отчёты об их применении.
+type AgentConfigHandler struct {
+	router  *gin.Engine
+	service agentConfigService
+}
+
+// NewAgentConfigHandler создаёт AgentConfigHandler с указанным сервисом настроек и Gin-роутером.
+func NewAgentConfigHandler(service agentConfigService, router *gin.Engine) *AgentConfigHandler {
+	return &AgentConfigHandler{
+		router:  router,
+		service: service,
+	}
+}
+
+// RegisterRoutes регистрирует маршруты настроек агентов.
+func (h AgentConfigHandler) RegisterRoutes() {
+	h.router.GET("/agent/config", h.config)
+	h.router.POST("/agent/config/applied", h.reportApplied)
+	h.router.GET("/admin/agent-configs", h.listApplied)
+}
+
+// config обрабатывает GET-запросы агента на получение его настроек.
+// Агент передаёт идентификатор и группу в параметрах agent_id и group.
+// Версия настроек возвращается в заголовке ETag; если она совпадает с If-None-Match, возвращается 304.
+func (h AgentConfigHandler) config(g *gin.Context) {
+	cfg, err := h.service.Config(g, g.Query("agent_id"), g.Query("group"))
+	if err != nil {
+		_ = g.Error(err)
+		return
+	}
+	etag := strconv.Quote(cfg.Version)
+	g.Header("ETag", etag)
+	if g.GetHeader("If-None-Match") == etag {
+		g.Status(http.StatusNotModified)
+		return
+	}
+	g.JSON(http.StatusOK, cfg)
+}
+
+// reportApplied обрабатывает POST-запросы агента с версией применённых настроек.
+// Возвращает HTTP 200 при успехе или статус ошибки при неудаче.
+func (h AgentConfigHandler) reportApplied(g *gin.Context) {
+	rawData, err := g.GetRawData()
+	if err != nil {
+		g.Status(http.StatusBadRequest)
+		return
+	}
+	var applied commonmodels.AppliedConfig
+	if err = json.Unmarshal(rawData, &applied); err != nil {
+		g.Status(http.StatusBadRequest)
+		return
+	}
+	if err = h.service.ReportApplied(g, applied); err != nil {
+		_ = g.Error(err)
+		return
+	}
+	g.Status(http.StatusOK)
+}
+
+// listApplied обрабатывает GET-запросы на получение версий настроек, применённых агентами.
+func (h AgentConfigHandler) listApplied(g *gin.Context) {
+	g.JSON(http.StatusOK, h.service.Applied(g))
+}
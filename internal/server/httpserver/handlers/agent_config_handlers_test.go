@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	commonmodels "github.com/MxTrap/metrics/internal/common/models"
+	"github.com/MxTrap/metrics/internal/server/httpserver/middlewares"
+	servermodels "github.com/MxTrap/metrics/internal/server/models"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type mockAgentConfigService struct {
+	mock.Mock
+}
+
+func (m *mockAgentConfigService) Config(ctx context.Context, agentID, group string) (commonmodels.AgentConfig, error) {
+	args := m.Called(ctx, agentID, group)
+	return args.Get(0).(commonmodels.AgentConfig), args.Error(1)
+}
+
+func (m *mockAgentConfigService) ReportApplied(ctx context.Context, applied commonmodels.AppliedConfig) error {
+	args := m.Called(ctx, applied)
+	return args.Error(0)
+}
+
+func (m *mockAgentConfigService) Applied(ctx context.Context) []commonmodels.AppliedConfig {
+	args := m.Called(ctx)
+	return args.Get(0).([]commonmodels.AppliedConfig)
+}
+
+func newAgentConfigRouter(svc *mockAgentConfigService) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middlewares.StatusErrorMiddleware())
+	NewAgentConfigHandler(svc, router).RegisterRoutes()
+	return router
+}
+
+func TestAgentConfigHandlerConfig(t *testing.T) {
+	svc := &mockAgentConfigService{}
+	svc.On("Config", mock.Anything, "host-1", "edge").Return(commonmodels.AgentConfig{Version: "v1", ReportInterval: 30}, nil)
+	svc.On("Config", mock.Anything, "host-2", "").Return(commonmodels.AgentConfig{}, servermodels.ErrNoAgentConfig)
+	router := newAgentConfigRouter(svc)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/agent/config?agent_id=host-1&group=edge", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"v1"`, w.Header().Get("ETag"))
+	var cfg commonmodels.AgentConfig
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &cfg))
+	assert.Equal(t, commonmodels.AgentConfig{Version: "v1", ReportInterval: 30}, cfg)
+
+	req := httptest.NewRequest(http.MethodGet, "/agent/config?agent_id=host-1&group=edge", nil)
+	req.Header.Set("If-None-Match", `"v1"`)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/agent/config?agent_id=host-2", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestAgentConfigHandlerReportApplied(t *testing.T) {
+	svc := &mockAgentConfigService{}
+	svc.On("ReportApplied", mock.Anything, commonmodels.AppliedConfig{AgentID: "host-1", Version: "v1"}).Return(nil)
+	svc.On("Applied", mock.Anything).Return([]commonmodels.AppliedConfig{{AgentID: "host-1", Version: "v1"}})
+	router := newAgentConfigRouter(svc)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/agent/config/applied", strings.NewReader(`{"agent_id":"host-1","version":"v1"}`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/agent/config/applied", strings.NewReader(`not json`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/agent-configs", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"agent_id":"host-1"`)
+	svc.AssertExpectations(t)
+}
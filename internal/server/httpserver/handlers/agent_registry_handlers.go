@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"context"
+	"github.com/MxTrap/metrics/internal/server/models"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+type agentRegistryService interface {
+	Agents(ctx context.Context) []models.AgentRecord
+}
+
+// AgentRegistryHandler отдаёт реестр агентов, отправляющих данные на сервер.
+type AgentRegistryHandler struct {
+	router  *gin.Engine
+	service agentRegistryService
+}
+
+// NewAgentRegistryHandler создаёт AgentRegistryHandler с указанным реестром агентов и Gin-роутером.
+func NewAgentRegistryHandler(service agentRegistryService, router *gin.Engine) *AgentRegistryHandler {
+	return &AgentRegistryHandler{
+		router:  router,
+		service: service,
+	}
+}
+
+// RegisterRoutes регистрирует маршруты реестра агентов.
+func (h AgentRegistryHandler) RegisterRoutes() {
+	h.router.GET("/agents", h.list)
+}
+
+// list обрабатывает GET-запросы на получение списка агентов с временем последнего запроса,
+// транспортом, числом ошибок и признаком молчания.
+func (h AgentRegistryHandler) list(g *gin.Context) {
+	g.JSON(http.StatusOK, h.service.Agents(g))
+}
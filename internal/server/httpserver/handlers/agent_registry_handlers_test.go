@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	commonmodels "github.com/MxTrap/metrics/internal/common/models"
+	servermodels "github.com/MxTrap/metrics/internal/server/models"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type mockAgentRegistryService struct {
+	mock.Mock
+}
+
+func (m *mockAgentRegistryService) Agents(ctx context.Context) []servermodels.AgentRecord {
+	args := m.Called(ctx)
+	return args.Get(0).([]servermodels.AgentRecord)
+}
+
+func TestAgentRegistryHandlerList(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	lastSeen := time.Unix(1700000000, 0).UTC()
+	agents := []servermodels.AgentRecord{{
+		AgentInfo: commonmodels.AgentInfo{ID: "host-1", Hostname: "host-1.local", Version: "v1.2.0", OS: "linux/amd64"},
+		Tenant:    servermodels.DefaultTenant,
+		Transport: servermodels.AgentTransportHTTP,
+		FirstSeen: lastSeen.Add(-time.Hour),
+		LastSeen:  lastSeen,
+		Requests:  3,
+		Silent:    true,
+	}}
+	svc := &mockAgentRegistryService{}
+	svc.On("Agents", mock.Anything).Return(agents)
+	router := gin.New()
+	NewAgentRegistryHandler(svc, router).RegisterRoutes()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/agents", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var got []servermodels.AgentRecord
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.Equal(t, agents, got)
+	assert.Contains(t, w.Body.String(), `"id":"host-1"`, "agent info should be inlined in the record")
+}
@@ -0,0 +1,297 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	commonmodels "github.com/MxTrap/metrics/internal/common/models"
+	"github.com/MxTrap/metrics/internal/server/models"
+	"github.com/gin-gonic/gin"
+	"github.com/mailru/easyjson"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// APIv2Prefix — префикс маршрутов второй версии HTTP API.
+const APIv2Prefix = "/api/v2"
+
+type lister interface {
+	List(ctx context.Context, filter models.MetricFilter) (models.MetricPage, error)
+}
+
+// APIService определяет операции с метриками, необходимые API v2: операции MetricService
+// и постраничный список метрик с фильтрами.
+type APIService interface {
+	MetricService
+	lister
+}
+
+// BatchResult — результат пакетного сохранения метрик через API v2.
+type BatchResult struct {
+	Accepted int `json:"accepted"`
+}
+
+// apiRoute описывает маршрут API v2. По списку маршрутов регистрируются обработчики
+// и строится документ OpenAPI, поэтому описание не расходится с реализацией.
+type apiRoute struct {
+	method      string
+	path        string
+	operationID string
+	summary     string
+	query       []apiParam
+	request     string
+	status      int
+	response    string
+	handler     gin.HandlerFunc
+}
+
+// apiParam описывает параметр строки запроса маршрута API v2.
+type apiParam struct {
+	name        string
+	kind        string
+	description string
+}
+
+// APIHandler обслуживает вторую версию HTTP API: ресурсы метрик в формате JSON,
+// ошибки в виде JSON-объектов с кодами и документ OpenAPI с описанием API.
+type APIHandler struct {
+	router  *gin.Engine
+	service APIService
+}
+
+// NewAPIHandler создаёт APIHandler с указанным APIService и Gin-роутером.
+func NewAPIHandler(service APIService, router *gin.Engine) *APIHandler {
+	return &APIHandler{
+		router:  router,
+		service: service,
+	}
+}
+
+// routes возвращает маршруты API v2 относительно APIv2Prefix.
+func (h APIHandler) routes() []apiRoute {
+	return []apiRoute{
+		{
+			method:      http.MethodGet,
+			path:        "/metrics",
+			operationID: "listMetrics",
+			summary:     "List metrics matching the filters",
+			query: []apiParam{
+				{name: "type", kind: "string", description: "Metric type, gauge or counter"},
+				{name: "prefix", kind: "string", description: "Metric name prefix"},
+				{name: "match", kind: "string", description: "Regular expression the metric name must match"},
+				{name: "updated_since", kind: "string", description: "Only metrics updated at or after this RFC 3339 time"},
+				{name: "sort", kind: "string", description: "Sort order: name, -name, updated or -updated; name by default"},
+				{name: "limit", kind: "integer", description: fmt.Sprintf("Page size, %d by default, at most %d", models.DefaultMetricPageSize, models.MaxMetricPageSize)},
+				{name: "cursor", kind: "string", description: "Cursor returned as next_cursor by the previous page"},
+			},
+			status:   http.StatusOK,
+			response: "MetricPage",
+			handler:  h.list,
+		},
+		{
+			method:      http.MethodPost,
+			path:        "/metrics",
+			operationID: "saveMetric",
+			summary:     "Save a metric and return its stored value",
+			request:     "Metric",
+			status:      http.StatusOK,
+			response:    "Metric",
+			handler:     h.save,
+		},
+		{
+			method:      http.MethodPost,
+			path:        "/metrics/batch",
+			operationID: "saveMetrics",
+			summary:     "Save a batch of metrics",
+			request:     "Metrics",
+			status:      http.StatusOK,
+			response:    "BatchResult",
+			handler:     h.saveBatch,
+		},
+		{
+			method:      http.MethodGet,
+			path:        "/metrics/:type/:name",
+			operationID: "getMetric",
+			summary:     "Get a metric by type and name",
+			status:      http.StatusOK,
+			response:    "Metric",
+			handler:     h.find,
+		},
+		{
+			method:      http.MethodPut,
+			path:        "/metrics/:type/:name",
+			operationID: "putMetric",
+			summary:     "Save a metric addressed by type and name and return its stored value",
+			request:     "Metric",
+			status:      http.StatusOK,
+			response:    "Metric",
+			handler:     h.put,
+		},
+	}
+}
+
+// RegisterRoutes регистрирует маршруты API v2 и документ OpenAPI по адресу /api/v2/openapi.json.
+func (h APIHandler) RegisterRoutes() {
+	group := h.router.Group(APIv2Prefix)
+	for _, r := range h.routes() {
+		group.Handle(r.method, r.path, r.handler)
+	}
+	group.GET("/openapi.json", h.openAPI)
+}
+
+// validateMetric проверяет, что у метрики есть имя, известный тип и значение, соответствующее типу.
+func validateMetric(metric commonmodels.Metric) error {
+	if metric.ID == "" {
+		return fmt.Errorf("%w: metric id is required", models.ErrInvalidRequest)
+	}
+	switch metric.MType {
+	case commonmodels.Counter:
+		if metric.Delta == nil {
+			return fmt.Errorf("%w: counter requires delta", models.ErrWrongMetricValue)
+		}
+	case commonmodels.Gauge:
+		if metric.Value == nil {
+			return fmt.Errorf("%w: gauge requires value", models.ErrWrongMetricValue)
+		}
+	default:
+		return fmt.Errorf("%w: %q", models.ErrUnknownMetricType, metric.MType)
+	}
+	return nil
+}
+
+// bindMetric читает метрику из тела запроса.
+func bindMetric(g *gin.Context) (commonmodels.Metric, error) {
+	metric := commonmodels.Metric{}
+	if err := easyjson.UnmarshalFromReader(g.Request.Body, &metric); err != nil {
+		return commonmodels.Metric{}, fmt.Errorf("%w: %v", models.ErrInvalidRequest, err)
+	}
+	return metric, nil
+}
+
+// listFilter разбирает фильтр, сортировку и страницу списка метрик из параметров запроса.
+func listFilter(g *gin.Context) (models.MetricFilter, error) {
+	filter := models.MetricFilter{
+		Type:        g.Query("type"),
+		NamePrefix:  g.Query("prefix"),
+		NamePattern: g.Query("match"),
+		Sort:        g.Query("sort"),
+	}
+	if raw := g.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			return models.MetricFilter{}, fmt.Errorf("%w: limit must be a positive number", models.ErrInvalidRequest)
+		}
+		filter.Limit = limit
+	}
+	if raw := g.Query("updated_since"); raw != "" {
+		since, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return models.MetricFilter{}, fmt.Errorf("%w: updated_since must be an RFC 3339 time", models.ErrInvalidRequest)
+		}
+		filter.UpdatedSince = since
+	}
+	after, err := models.DecodeMetricCursor(g.Query("cursor"))
+	if err != nil {
+		return models.MetricFilter{}, err
+	}
+	filter.After = after
+	return filter, nil
+}
+
+// list обрабатывает GET-запросы на получение страницы метрик с типами и временем обновления,
+// отобранных по фильтрам из параметров запроса.
+func (h APIHandler) list(g *gin.Context) {
+	filter, err := listFilter(g)
+	if err != nil {
+		_ = g.Error(err)
+		return
+	}
+	page, err := h.service.List(g, filter)
+	if err != nil {
+		_ = g.Error(err)
+		return
+	}
+	g.JSON(http.StatusOK, page)
+}
+
+// store сохраняет метрику и отвечает её значением в хранилище,
+// для метрик типа counter — накопленным.
+func (h APIHandler) store(g *gin.Context, metric commonmodels.Metric) {
+	if err := validateMetric(metric); err != nil {
+		_ = g.Error(err)
+		return
+	}
+	if err := h.service.Save(g, metric); err != nil {
+		_ = g.Error(err)
+		return
+	}
+	stored, err := h.service.Find(g, commonmodels.Metric{ID: metric.ID, MType: metric.MType})
+	if err != nil {
+		_ = g.Error(err)
+		return
+	}
+	g.JSON(http.StatusOK, stored)
+}
+
+// save обрабатывает POST-запросы на сохранение метрики, переданной в теле запроса.
+func (h APIHandler) save(g *gin.Context) {
+	metric, err := bindMetric(g)
+	if err != nil {
+		_ = g.Error(err)
+		return
+	}
+	h.store(g, metric)
+}
+
+// put обрабатывает PUT-запросы на сохранение метрики, тип и имя которой заданы в пути.
+// Тип и имя из тела запроса, если они указаны, должны совпадать с путём.
+func (h APIHandler) put(g *gin.Context) {
+	metric, err := bindMetric(g)
+	if err != nil {
+		_ = g.Error(err)
+		return
+	}
+	mType, name := g.Param("type"), g.Param("name")
+	if (metric.ID != "" && metric.ID != name) || (metric.MType != "" && metric.MType != mType) {
+		_ = g.Error(fmt.Errorf("%w: metric in body does not match the path", models.ErrInvalidRequest))
+		return
+	}
+	metric.ID, metric.MType = name, mType
+	h.store(g, metric)
+}
+
+// saveBatch обрабатывает POST-запросы на сохранение пакета метрик.
+// Пакет отклоняется целиком, если хотя бы одна метрика некорректна.
+func (h APIHandler) saveBatch(g *gin.Context) {
+	metrics := commonmodels.Metrics{}
+	if err := easyjson.UnmarshalFromReader(g.Request.Body, &metrics); err != nil {
+		_ = g.Error(fmt.Errorf("%w: %v", models.ErrInvalidRequest, err))
+		return
+	}
+	for i, metric := range metrics {
+		if err := validateMetric(metric); err != nil {
+			_ = g.Error(fmt.Errorf("metrics[%d]: %w", i, err))
+			return
+		}
+	}
+	if err := h.service.SaveAll(g, metrics); err != nil {
+		_ = g.Error(err)
+		return
+	}
+	g.JSON(http.StatusOK, BatchResult{Accepted: len(metrics)})
+}
+
+// find обрабатывает GET-запросы на получение метрики по типу и имени.
+func (h APIHandler) find(g *gin.Context) {
+	metric, err := h.service.Find(g, commonmodels.Metric{ID: g.Param("name"), MType: g.Param("type")})
+	if err != nil {
+		_ = g.Error(err)
+		return
+	}
+	g.JSON(http.StatusOK, metric)
+}
+
+// openAPI обрабатывает GET-запросы на получение документа OpenAPI с описанием API v2.
+func (h APIHandler) openAPI(g *gin.Context) {
+	g.JSON(http.StatusOK, openAPIDocument(h.routes()))
+}
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	commonmodels "github.com/MxTrap/metrics/internal/common/models"
+	"github.com/MxTrap/metrics/internal/server/httpserver/middlewares"
+	servermodels "github.com/MxTrap/metrics/internal/server/models"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func setupAPIRouter(svc *mockMetricSvc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middlewares.StatusErrorMiddleware())
+	NewAPIHandler(svc, router).RegisterRoutes()
+	return router
+}
+
+func doAPI(router *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return w
+}
+
+func apiError(t *testing.T, w *httptest.ResponseRecorder) servermodels.APIError {
+	t.Helper()
+	var body servermodels.APIErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body), w.Body.String())
+	return body.Error
+}
+
+func TestAPIHandlerList(t *testing.T) {
+	updatedAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	cursor := servermodels.MetricCursor{Name: "Alloc", UpdatedAt: updatedAt}
+	page := servermodels.MetricPage{
+		Metrics:    []servermodels.MetricRecord{{ID: "PollCount", MType: commonmodels.Counter, Delta: ptr(int64(7)), UpdatedAt: updatedAt}},
+		NextCursor: cursor.Encode(),
+	}
+	svc := &mockMetricSvc{}
+	svc.On("List", mock.Anything, servermodels.MetricFilter{
+		Type:         commonmodels.Counter,
+		NamePrefix:   "Poll",
+		NamePattern:  "Count$",
+		UpdatedSince: updatedAt,
+		Sort:         servermodels.SortByUpdatedDesc,
+		After:        &cursor,
+		Limit:        2,
+	}).Return(page, nil)
+	svc.On("List", mock.Anything, servermodels.MetricFilter{Type: "histogram"}).Return(servermodels.MetricPage{}, servermodels.ErrUnknownMetricType)
+	router := setupAPIRouter(svc)
+
+	w := doAPI(router, http.MethodGet, "/api/v2/metrics?type=counter&prefix=Poll&match=Count%24&updated_since=2024-01-01T12:00:00Z&sort=-updated&limit=2&cursor="+cursor.Encode(), "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.JSONEq(t, `{
+		"metrics": [{"id":"PollCount","type":"counter","delta":7,"updated_at":"2024-01-01T12:00:00Z"}],
+		"next_cursor": "`+cursor.Encode()+`"
+	}`, w.Body.String())
+
+	w = doAPI(router, http.MethodGet, "/api/v2/metrics?type=histogram", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "unknown_metric_type", apiError(t, w).Code)
+
+	for _, query := range []string{"limit=0", "limit=ten", "cursor=!!", "updated_since=yesterday"} {
+		w = doAPI(router, http.MethodGet, "/api/v2/metrics?"+query, "")
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+		assert.Equal(t, "invalid_request", apiError(t, w).Code, query)
+	}
+	svc.AssertNumberOfCalls(t, "List", 2)
+}
+
+func TestAPIHandlerFind(t *testing.T) {
+	value := 1.5
+	svc := &mockMetricSvc{}
+	svc.On("Find", mock.Anything, commonmodels.Metric{ID: "Alloc", MType: commonmodels.Gauge}).
+		Return(commonmodels.Metric{ID: "Alloc", MType: commonmodels.Gauge, Value: &value}, nil)
+	svc.On("Find", mock.Anything, commonmodels.Metric{ID: "Missing", MType: commonmodels.Gauge}).
+		Return(commonmodels.Metric{}, servermodels.ErrNotFoundMetric)
+	router := setupAPIRouter(svc)
+
+	w := doAPI(router, http.MethodGet, "/api/v2/metrics/gauge/Alloc", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"id":"Alloc","type":"gauge","value":1.5}`, w.Body.String())
+
+	w = doAPI(router, http.MethodGet, "/api/v2/metrics/gauge/Missing", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, servermodels.APIError{Code: "metric_not_found", Message: "metric not found"}, apiError(t, w))
+}
+
+func TestAPIHandlerSave(t *testing.T) {
+	delta, total := int64(2), int64(10)
+	svc := &mockMetricSvc{}
+	svc.On("Save", mock.Anything, commonmodels.Metric{ID: "PollCount", MType: commonmodels.Counter, Delta: &delta}).Return(nil)
+	svc.On("Find", mock.Anything, commonmodels.Metric{ID: "PollCount", MType: commonmodels.Counter}).
+		Return(commonmodels.Metric{ID: "PollCount", MType: commonmodels.Counter, Delta: &total}, nil)
+	router := setupAPIRouter(svc)
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		expectedStatus int
+		expectedCode   string
+	}{
+		{
+			name:           "Post",
+			method:         http.MethodPost,
+			path:           "/api/v2/metrics",
+			body:           `{"id":"PollCount","type":"counter","delta":2}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Put",
+			method:         http.MethodPut,
+			path:           "/api/v2/metrics/counter/PollCount",
+			body:           `{"delta":2}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Put with mismatched name",
+			method:         http.MethodPut,
+			path:           "/api/v2/metrics/counter/PollCount",
+			body:           `{"id":"Other","delta":2}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "invalid_request",
+		},
+		{
+			name:           "Malformed body",
+			method:         http.MethodPost,
+			path:           "/api/v2/metrics",
+			body:           `{`,
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "invalid_request",
+		},
+		{
+			name:           "Missing value",
+			method:         http.MethodPost,
+			path:           "/api/v2/metrics",
+			body:           `{"id":"Alloc","type":"gauge"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "wrong_metric_value",
+		},
+		{
+			name:           "Unknown type",
+			method:         http.MethodPost,
+			path:           "/api/v2/metrics",
+			body:           `{"id":"Alloc","type":"histogram","value":1}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   "unknown_metric_type",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := doAPI(router, tt.method, tt.path, tt.body)
+			require.Equal(t, tt.expectedStatus, w.Code, w.Body.String())
+			if tt.expectedCode != "" {
+				assert.Equal(t, tt.expectedCode, apiError(t, w).Code)
+				return
+			}
+			assert.JSONEq(t, `{"id":"PollCount","type":"counter","delta":10}`, w.Body.String(), "response should hold the stored value")
+		})
+	}
+	svc.AssertNumberOfCalls(t, "Save", 2)
+}
+
+func TestAPIHandlerSaveBatch(t *testing.T) {
+	value := 1.5
+	batch := []commonmodels.Metric{{ID: "Alloc", MType: commonmodels.Gauge, Value: &value}}
+	svc := &mockMetricSvc{}
+	svc.On("SaveAll", mock.Anything, mock.Anything).Return(nil).Once()
+	svc.On("SaveAll", mock.Anything, mock.Anything).Return(servermodels.ErrBatchTooLarge).Once()
+	router := setupAPIRouter(svc)
+
+	w := doAPI(router, http.MethodPost, "/api/v2/metrics/batch", `[{"id":"Alloc","type":"gauge","value":1.5}]`)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"accepted":1}`, w.Body.String())
+	svc.AssertCalled(t, "SaveAll", mock.Anything, batch)
+
+	w = doAPI(router, http.MethodPost, "/api/v2/metrics/batch", `[{"id":"Alloc","type":"gauge","value":1.5}]`)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "batch_too_large", apiError(t, w).Code)
+
+	w = doAPI(router, http.MethodPost, "/api/v2/metrics/batch", `[{"id":"Alloc","type":"gauge","value":1.5},{"id":"PollCount","type":"counter"}]`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, servermodels.APIError{Code: "wrong_metric_value", Message: "metrics[1]: wrong metric value: counter requires delta"}, apiError(t, w))
+	svc.AssertNumberOfCalls(t, "SaveAll", 2)
+}
+
+func TestAPIHandlerOpenAPI(t *testing.T) {
+	router := setupAPIRouter(&mockMetricSvc{})
+
+	w := doAPI(router, http.MethodGet, "/api/v2/openapi.json", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var doc struct {
+		OpenAPI string                               `json:"openapi"`
+		Paths   map[string]map[string]map[string]any `json:"paths"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	assert.Equal(t, openAPIVersion, doc.OpenAPI)
+
+	var operations []string
+	for path, methods := range doc.Paths {
+		for method := range methods {
+			operations = append(operations, strings.ToUpper(method)+" "+path)
+		}
+	}
+	assert.ElementsMatch(t, []string{
+		"GET /api/v2/metrics",
+		"POST /api/v2/metrics",
+		"POST /api/v2/metrics/batch",
+		"GET /api/v2/metrics/{type}/{name}",
+		"PUT /api/v2/metrics/{type}/{name}",
+	}, operations, "document should describe every registered route")
+	assert.Len(t, doc.Paths["/api/v2/metrics/{type}/{name}"]["get"]["parameters"], 2)
+}
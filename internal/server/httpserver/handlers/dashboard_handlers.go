@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	commonmodels "github.com/MxTrap/metrics/internal/common/models"
+	"github.com/MxTrap/metrics/internal/server/models"
+	"github.com/MxTrap/metrics/internal/server/templates"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DashboardPrefix — префикс страниц метрик и статических файлов панели мониторинга.
+// Таблица метрик отображается на корневой странице.
+const DashboardPrefix = "/dashboard"
+
+// Размеры области графика истории метрики в единицах viewBox.
+const (
+	sparklineWidth  = 600
+	sparklineHeight = 120
+)
+
+type historian interface {
+	History(ctx context.Context, mType, name string) []models.HistoryPoint
+}
+
+// DashboardHandler обслуживает панель мониторинга: таблицу метрик с сортировкой, фильтрами
+// и автообновлением и страницы метрик с графиком истории.
+// Шаблоны и статические файлы встроены в исполняемый файл сервера.
+type DashboardHandler struct {
+	router  *gin.Engine
+	service lister
+	history historian
+}
+
+// NewDashboardHandler создаёт DashboardHandler со списком метрик, историей их обновлений и Gin-роутером.
+func NewDashboardHandler(service lister, history historian, router *gin.Engine) *DashboardHandler {
+	return &DashboardHandler{
+		router:  router,
+		service: service,
+		history: history,
+	}
+}
+
+// RegisterRoutes регистрирует таблицу метрик по адресу /, страницы метрик по адресу
+// /dashboard/metrics/:type/:name и статические файлы по адресу /dashboard/static.
+func (h DashboardHandler) RegisterRoutes() {
+	h.router.GET("/", h.index)
+	group := h.router.Group(DashboardPrefix)
+	group.GET("/metrics/:type/:name", h.metric)
+	group.StaticFS("/static", http.FS(templates.Static()))
+}
+
+// dashboardRow — строка таблицы метрик панели мониторинга.
+type dashboardRow struct {
+	ID        string
+	Type      string
+	Value     string
+	UpdatedAt time.Time
+	Link      string
+}
+
+func newDashboardRow(record models.MetricRecord) dashboardRow {
+	row := dashboardRow{
+		ID:        record.ID,
+		Type:      record.MType,
+		UpdatedAt: record.UpdatedAt.UTC(),
+		Link:      DashboardPrefix + "/metrics/" + url.PathEscape(record.MType) + "/" + url.PathEscape(record.ID),
+	}
+	switch {
+	case record.Delta != nil:
+		row.Value = strconv.FormatInt(*record.Delta, 10)
+	case record.Value != nil:
+		row.Value = formatFloat(*record.Value)
+	}
+	return row
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// dashboardPage — общие данные страниц панели мониторинга.
+type dashboardPage struct {
+	Title    string
+	Subtitle string
+	Static   string
+	Watch    string
+}
+
+func newDashboardPage(title, subtitle, watch string) dashboardPage {
+	return dashboardPage{
+		Title:    title,
+		Subtitle: subtitle,
+		Static:   DashboardPrefix + "/static",
+		Watch:    watch,
+	}
+}
+
+// records возвращает все метрики, доступные запросу, отсортированные по имени.
+func (h DashboardHandler) records(ctx context.Context, filter models.MetricFilter) ([]models.MetricRecord, error) {
+	filter.Limit = models.MaxMetricPageSize
+	var records []models.MetricRecord
+	for {
+		page, err := h.service.List(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, page.Metrics...)
+		if page.NextCursor == "" {
+			return records, nil
+		}
+		if filter.After, err = models.DecodeMetricCursor(page.NextCursor); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// index обрабатывает GET-запросы на получение таблицы всех метрик с типами и временем обновления.
+func (h DashboardHandler) index(g *gin.Context) {
+	records, err := h.records(g, models.MetricFilter{})
+	if err != nil {
+		_ = g.Error(err)
+		return
+	}
+	rows := make([]dashboardRow, len(records))
+	for i, record := range records {
+		rows[i] = newDashboardRow(record)
+	}
+	g.HTML(http.StatusOK, "dashboard.tmpl", struct {
+		dashboardPage
+		Rows   []dashboardRow
+		List   string
+		Detail string
+	}{
+		dashboardPage: newDashboardPage("Dashboard", "", APIv2Prefix+"/watch"),
+		Rows:          rows,
+		List:          APIv2Prefix + "/metrics",
+		Detail:        DashboardPrefix + "/metrics",
+	})
+}
+
+// metric обрабатывает GET-запросы на получение страницы метрики с графиком её истории.
+// Для метрик типа counter график показывает принятые приращения, для метрик типа gauge — значения.
+func (h DashboardHandler) metric(g *gin.Context) {
+	mType, name := g.Param("type"), g.Param("name")
+	records, err := h.records(g, models.MetricFilter{
+		Type:        mType,
+		NamePrefix:  name,
+		NamePattern: "^" + regexp.QuoteMeta(name) + "$",
+	})
+	if err != nil {
+		_ = g.Error(err)
+		return
+	}
+	if len(records) == 0 {
+		_ = g.Error(models.ErrNotFoundMetric)
+		return
+	}
+
+	points := h.history.History(g, mType, name)
+	title := "Values"
+	if mType == commonmodels.Counter {
+		title = "Increments"
+	}
+	watch := url.Values{"type": {mType}, "match": {"^" + regexp.QuoteMeta(name) + "$"}}
+	page := struct {
+		dashboardPage
+		Row          dashboardRow
+		HistoryTitle string
+		Points       []models.HistoryPoint
+		Sparkline    string
+		Width        int
+		Height       int
+		Min          string
+		Max          string
+		Since        time.Time
+	}{
+		dashboardPage: newDashboardPage(name, name, APIv2Prefix+"/watch?"+watch.Encode()),
+		Row:           newDashboardRow(records[0]),
+		HistoryTitle:  title,
+		Points:        points,
+		Sparkline:     sparkline(points, sparklineWidth, sparklineHeight),
+		Width:         sparklineWidth,
+		Height:        sparklineHeight,
+	}
+	if len(points) > 0 {
+		lo, hi := valueRange(points)
+		page.Min, page.Max = formatFloat(lo), formatFloat(hi)
+		page.Since = points[0].Time.UTC()
+	}
+	g.HTML(http.StatusOK, "metric.tmpl", page)
+}
+
+// valueRange возвращает наименьшее и наибольшее значения точек истории.
+func valueRange(points []models.HistoryPoint) (float64, float64) {
+	lo, hi := points[0].Value, points[0].Value
+	for _, p := range points[1:] {
+		lo, hi = min(lo, p.Value), max(hi, p.Value)
+	}
+	return lo, hi
+}
+
+// sparkline возвращает координаты вершин ломаной графика истории для атрибута points элемента polyline.
+// По горизонтали точки располагаются по времени обновления, по вертикали — по значению;
+// постоянное значение рисуется горизонтальной линией посередине.
+func sparkline(points []models.HistoryPoint, width, height float64) string {
+	if len(points) == 0 {
+		return ""
+	}
+	lo, hi := valueRange(points)
+	start, span := points[0].Time, points[len(points)-1].Time.Sub(points[0].Time)
+
+	coords := make([]string, 0, len(points)+1)
+	for i, p := range points {
+		x := width
+		switch {
+		case span > 0:
+			x = width * float64(p.Time.Sub(start)) / float64(span)
+		case len(points) > 1:
+			x = width * float64(i) / float64(len(points)-1)
+		}
+		y := height / 2
+		if hi > lo {
+			y = height * (hi - p.Value) / (hi - lo)
+		}
+		coords = append(coords, fmt.Sprintf("%.1f,%.1f", x, y))
+	}
+	if len(points) == 1 {
+		// одна точка рисуется отрезком на всю ширину графика
+		coords = append([]string{fmt.Sprintf("0,%.1f", height/2)}, coords...)
+	}
+	return strings.Join(coords, " ")
+}
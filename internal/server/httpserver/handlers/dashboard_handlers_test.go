@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	commonmodels "github.com/MxTrap/metrics/internal/common/models"
+	"github.com/MxTrap/metrics/internal/server/httpserver/middlewares"
+	servermodels "github.com/MxTrap/metrics/internal/server/models"
+	"github.com/MxTrap/metrics/internal/server/templates"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeHistorian map[string][]servermodels.HistoryPoint
+
+func (f fakeHistorian) History(_ context.Context, mType, name string) []servermodels.HistoryPoint {
+	return f[mType+"/"+name]
+}
+
+func setupDashboardRouter(svc *mockMetricSvc, history fakeHistorian) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middlewares.StatusErrorMiddleware())
+	router.SetHTMLTemplate(templates.HTML())
+	NewDashboardHandler(svc, history, router).RegisterRoutes()
+	return router
+}
+
+func TestDashboardHandlerIndex(t *testing.T) {
+	updatedAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	cursor := servermodels.MetricCursor{Name: "Alloc", UpdatedAt: updatedAt}
+	svc := &mockMetricSvc{}
+	svc.On("List", mock.Anything, servermodels.MetricFilter{Limit: servermodels.MaxMetricPageSize}).Return(servermodels.MetricPage{
+		Metrics:    []servermodels.MetricRecord{{ID: "Alloc", MType: commonmodels.Gauge, Value: ptr(1.5), UpdatedAt: updatedAt}},
+		NextCursor: cursor.Encode(),
+	}, nil)
+	svc.On("List", mock.Anything, servermodels.MetricFilter{Limit: servermodels.MaxMetricPageSize, After: &cursor}).Return(servermodels.MetricPage{
+		Metrics: []servermodels.MetricRecord{{ID: "Poll<Count>", MType: commonmodels.Counter, Delta: ptr(int64(7)), UpdatedAt: updatedAt}},
+	}, nil)
+	router := setupDashboardRouter(svc, nil)
+
+	w := doAPI(router, http.MethodGet, "/", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	body := w.Body.String()
+	assert.Contains(t, body, `<tr data-key="gauge/Alloc" data-name="Alloc" data-type="gauge" data-value="1.5" data-updated="1704110400000">`)
+	assert.Contains(t, body, `<a href="/dashboard/metrics/gauge/Alloc">Alloc</a>`)
+	assert.Contains(t, body, `<time datetime="2024-01-01T12:00:00.000Z">2024-01-01 12:00:00 UTC</time>`)
+	assert.Contains(t, body, `<a href="/dashboard/metrics/counter/Poll%3CCount%3E">Poll&lt;Count&gt;</a>`, "names should be escaped")
+	assert.Contains(t, body, `<td class="num">7</td>`)
+	assert.Contains(t, body, `<span id="count" class="count">2 metrics</span>`)
+	assert.Contains(t, body, `src="/dashboard/static/dashboard.js"`)
+	assert.NotContains(t, body, "No metrics yet")
+	svc.AssertExpectations(t)
+}
+
+func TestDashboardHandlerIndexError(t *testing.T) {
+	svc := &mockMetricSvc{}
+	svc.On("List", mock.Anything, mock.Anything).Return(servermodels.MetricPage{}, servermodels.ErrForbidden)
+	router := setupDashboardRouter(svc, nil)
+
+	w := doAPI(router, http.MethodGet, "/", "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestDashboardHandlerMetric(t *testing.T) {
+	updatedAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	svc := &mockMetricSvc{}
+	svc.On("List", mock.Anything, servermodels.MetricFilter{
+		Type:        commonmodels.Counter,
+		NamePrefix:  "Poll.Count",
+		NamePattern: `^Poll\.Count$`,
+		Limit:       servermodels.MaxMetricPageSize,
+	}).Return(servermodels.MetricPage{
+		Metrics: []servermodels.MetricRecord{{ID: "Poll.Count", MType: commonmodels.Counter, Delta: ptr(int64(30)), UpdatedAt: updatedAt}},
+	}, nil)
+	svc.On("List", mock.Anything, mock.Anything).Return(servermodels.MetricPage{Metrics: []servermodels.MetricRecord{}}, nil)
+	history := fakeHistorian{"counter/Poll.Count": {
+		{Time: updatedAt.Add(-2 * time.Second), Value: 10},
+		{Time: updatedAt.Add(-time.Second), Value: 20},
+		{Time: updatedAt, Value: 0},
+	}}
+	router := setupDashboardRouter(svc, history)
+
+	w := doAPI(router, http.MethodGet, "/dashboard/metrics/counter/Poll.Count", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.Contains(t, body, `<h2>Increments</h2>`)
+	assert.Contains(t, body, `<polyline points="0.0,60.0 300.0,0.0 600.0,120.0"/>`)
+	assert.Contains(t, body, `3 points · min 0 · max 20`)
+	assert.Contains(t, body, `<dd class="num">30</dd>`)
+	assert.Contains(t, body, `data-watch="/api/v2/watch?match=%5EPoll%5C.Count%24&amp;type=counter"`)
+
+	w = doAPI(router, http.MethodGet, "/dashboard/metrics/gauge/Missing", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestDashboardHandlerMetricWithoutHistory(t *testing.T) {
+	svc := &mockMetricSvc{}
+	svc.On("List", mock.Anything, mock.Anything).Return(servermodels.MetricPage{
+		Metrics: []servermodels.MetricRecord{{ID: "Alloc", MType: commonmodels.Gauge, Value: ptr(1.5), UpdatedAt: time.Now()}},
+	}, nil)
+	router := setupDashboardRouter(svc, fakeHistorian{})
+
+	w := doAPI(router, http.MethodGet, "/dashboard/metrics/gauge/Alloc", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<h2>Values</h2>`)
+	assert.Contains(t, w.Body.String(), "No updates since the server started.")
+	assert.NotContains(t, w.Body.String(), "<polyline")
+}
+
+func TestDashboardHandlerStatic(t *testing.T) {
+	router := setupDashboardRouter(&mockMetricSvc{}, nil)
+
+	for path, contentType := range map[string]string{
+		"/dashboard/static/dashboard.js":  "text/javascript; charset=utf-8",
+		"/dashboard/static/dashboard.css": "text/css; charset=utf-8",
+	} {
+		w := doAPI(router, http.MethodGet, path, "")
+		require.Equal(t, http.StatusOK, w.Code, path)
+		assert.Equal(t, contentType, w.Header().Get("Content-Type"), path)
+		assert.NotEmpty(t, w.Body.String(), path)
+	}
+	assert.Equal(t, http.StatusNotFound, doAPI(router, http.MethodGet, "/dashboard/static/missing.js", "").Code)
+}
+
+func TestSparkline(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	tests := []struct {
+		name     string
+		points   []servermodels.HistoryPoint
+		expected string
+	}{
+		{
+			name:     "Empty",
+			expected: "",
+		},
+		{
+			name:     "Single point",
+			points:   []servermodels.HistoryPoint{{Time: start, Value: 5}},
+			expected: "0,5.0 100.0,5.0",
+		},
+		{
+			name: "Constant value",
+			points: []servermodels.HistoryPoint{
+				{Time: start, Value: 5},
+				{Time: start.Add(3 * time.Second), Value: 5},
+			},
+			expected: "0.0,5.0 100.0,5.0",
+		},
+		{
+			name: "Spaced by time",
+			points: []servermodels.HistoryPoint{
+				{Time: start, Value: 0},
+				{Time: start.Add(time.Second), Value: 10},
+				{Time: start.Add(4 * time.Second), Value: 5},
+			},
+			expected: "0.0,10.0 25.0,0.0 100.0,5.0",
+		},
+		{
+			name: "Same time",
+			points: []servermodels.HistoryPoint{
+				{Time: start, Value: 0},
+				{Time: start, Value: 10},
+			},
+			expected: "0.0,10.0 100.0,0.0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, sparkline(tt.points, 100, 10))
+		})
+	}
+}
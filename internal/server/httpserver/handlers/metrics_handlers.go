@@ -58,7 +58,6 @@ func (h MetricsHandler) RegisterRoutes() {
 	h.router.POST(fmt.Sprintf("/update/%s/:metricValue", uri), h.save)
 	h.router.POST("/updates/", h.saveAll)
 	h.router.POST("/value/", h.findJSON)
-	h.router.GET("/", h.getAll)
 	h.router.GET("/ping", h.ping)
 }
 
@@ -228,19 +227,6 @@ func (h MetricsHandler) findJSON(g *gin.Context) {
 	g.JSON(http.StatusOK, m)
 }
 
-// getAll обрабатывает GET-запросы для получения всех метрик.
-// Возвращает HTML-страницу со всеми метриками или статус ошибки при неудаче.
-func (h MetricsHandler) getAll(g *gin.Context) {
-	all, err := h.service.GetAll(g)
-	if err != nil {
-		_ = g.Error(err)
-		return
-	}
-	g.HTML(http.StatusOK, "index.tmpl", gin.H{
-		"metrics": all,
-	})
-}
-
 // ping обрабатывает GET-запросы для проверки доступности хранилища метрик.
 // Возвращает HTTPAddr 200 при успехе или статус ошибки при неудаче.
 func (h MetricsHandler) ping(g *gin.Context) {
@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"github.com/MxTrap/metrics/internal/common/models"
+	servermodels "github.com/MxTrap/metrics/internal/server/models"
 	"github.com/gin-gonic/gin"
 	"github.com/mailru/easyjson"
 	"github.com/stretchr/testify/assert"
@@ -39,6 +40,11 @@ func (m *mockMetricSvc) GetAll(ctx context.Context) (map[string]interface{}, err
 	return args.Get(0).(map[string]interface{}), args.Error(1)
 }
 
+func (m *mockMetricSvc) List(ctx context.Context, filter servermodels.MetricFilter) (servermodels.MetricPage, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).(servermodels.MetricPage), args.Error(1)
+}
+
 func (m *mockMetricSvc) Ping(ctx context.Context) error {
 	args := m.Called(ctx)
 	return args.Error(0)
@@ -71,7 +77,6 @@ func TestRegisterRoutes(t *testing.T) {
 		"/update/:metricType/:metricName/:metricValue",
 		"/updates/",
 		"/value/",
-		"/",
 		"/ping",
 	}
 	assert.ElementsMatch(t, expectedPaths, routePaths)
@@ -287,23 +292,6 @@ func TestFindJSON(t *testing.T) {
 	service.AssertExpectations(t)
 }
 
-func TestGetAll(t *testing.T) {
-	service := &mockMetricSvc{}
-	gin.SetMode(gin.TestMode)
-
-	metrics := map[string]any{
-		"gauge1":   models.Metric{ID: "gauge1", MType: models.Gauge, Value: ptr(42.5)},
-		"counter1": models.Metric{ID: "counter1", MType: models.Counter, Delta: ptr(int64(100))},
-	}
-	service.On("GetAll", mock.Anything).Return(metrics, nil)
-
-	req, _ := http.NewRequest("GET", "/", nil)
-	w := httptest.NewRecorder()
-	c, _ := gin.CreateTestContext(w)
-	c.Request = req
-
-}
-
 func TestPing(t *testing.T) {
 	service := &mockMetricSvc{}
 	router := gin.New()
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// openAPIVersion — версия спецификации OpenAPI, которой соответствует документ API v2.
+const openAPIVersion = "3.0.3"
+
+// pathParam находит параметры пути в нотации Gin (:name).
+var pathParam = regexp.MustCompile(`:(\w+)`)
+
+// errorResponses — ответы с ошибками, общие для всех маршрутов API v2.
+var errorResponses = map[int]string{
+	http.StatusBadRequest:          "Malformed request, unknown metric type or wrong value",
+	http.StatusUnauthorized:        "Missing or invalid API token",
+	http.StatusForbidden:           "API token lacks the required permission",
+	http.StatusNotFound:            "Metric not found",
+	http.StatusTooManyRequests:     "Rate limit or metric quota exceeded",
+	http.StatusInternalServerError: "Internal server error",
+}
+
+// openAPISchemas возвращает схемы ресурсов API v2.
+func openAPISchemas() map[string]any {
+	return map[string]any{
+		"Metric": map[string]any{
+			"type":     "object",
+			"required": []string{"id", "type"},
+			"properties": map[string]any{
+				"id":    map[string]any{"type": "string", "description": "Metric name"},
+				"type":  map[string]any{"type": "string", "enum": []string{"gauge", "counter"}},
+				"delta": map[string]any{"type": "integer", "format": "int64", "description": "Counter increment or accumulated value"},
+				"value": map[string]any{"type": "number", "format": "double", "description": "Gauge value"},
+			},
+		},
+		"MetricRecord": map[string]any{
+			"allOf": []any{
+				schemaRef("Metric"),
+				map[string]any{
+					"type":     "object",
+					"required": []string{"updated_at"},
+					"properties": map[string]any{
+						"updated_at": map[string]any{"type": "string", "format": "date-time", "description": "Time of the last update"},
+					},
+				},
+			},
+		},
+		"Metrics": map[string]any{
+			"type":  "array",
+			"items": schemaRef("Metric"),
+		},
+		"MetricPage": map[string]any{
+			"type":     "object",
+			"required": []string{"metrics"},
+			"properties": map[string]any{
+				"metrics": map[string]any{
+					"type":  "array",
+					"items": schemaRef("MetricRecord"),
+				},
+				"next_cursor": map[string]any{"type": "string", "description": "Cursor of the next page, absent on the last page"},
+			},
+		},
+		"BatchResult": map[string]any{
+			"type":     "object",
+			"required": []string{"accepted"},
+			"properties": map[string]any{
+				"accepted": map[string]any{"type": "integer", "description": "Number of accepted metrics"},
+			},
+		},
+		"Error": map[string]any{
+			"type":     "object",
+			"required": []string{"error"},
+			"properties": map[string]any{
+				"error": map[string]any{
+					"type":     "object",
+					"required": []string{"code", "message"},
+					"properties": map[string]any{
+						"code":    map[string]any{"type": "string", "description": "Machine-readable error code, e.g. metric_not_found"},
+						"message": map[string]any{"type": "string"},
+					},
+				},
+			},
+		},
+	}
+}
+
+func schemaRef(name string) map[string]any {
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+func jsonContent(schema string) map[string]any {
+	return map[string]any{
+		"application/json": map[string]any{"schema": schemaRef(schema)},
+	}
+}
+
+// openAPIOperation строит описание операции OpenAPI для маршрута API v2.
+func openAPIOperation(r apiRoute) map[string]any {
+	var params []map[string]any
+	for _, m := range pathParam.FindAllStringSubmatch(r.path, -1) {
+		params = append(params, map[string]any{
+			"name":     m[1],
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]any{"type": "string"},
+		})
+	}
+	for _, p := range r.query {
+		params = append(params, map[string]any{
+			"name":        p.name,
+			"in":          "query",
+			"description": p.description,
+			"schema":      map[string]any{"type": p.kind},
+		})
+	}
+
+	responses := map[string]any{
+		strconv.Itoa(r.status): map[string]any{
+			"description": http.StatusText(r.status),
+			"content":     jsonContent(r.response),
+		},
+	}
+	for status, description := range errorResponses {
+		responses[strconv.Itoa(status)] = map[string]any{
+			"description": description,
+			"content":     jsonContent("Error"),
+		}
+	}
+
+	op := map[string]any{
+		"operationId": r.operationID,
+		"summary":     r.summary,
+		"responses":   responses,
+	}
+	if params != nil {
+		op["parameters"] = params
+	}
+	if r.request != "" {
+		op["requestBody"] = map[string]any{
+			"required": true,
+			"content":  jsonContent(r.request),
+		}
+	}
+	return op
+}
+
+// openAPIDocument строит документ OpenAPI по списку маршрутов API v2.
+func openAPIDocument(routes []apiRoute) map[string]any {
+	paths := map[string]map[string]any{}
+	for _, r := range routes {
+		path := APIv2Prefix + pathParam.ReplaceAllString(r.path, "{$1}")
+		if paths[path] == nil {
+			paths[path] = map[string]any{}
+		}
+		paths[path][strings.ToLower(r.method)] = openAPIOperation(r)
+	}
+	return map[string]any{
+		"openapi": openAPIVersion,
+		"info": map[string]any{
+			"title":   "Metrics API",
+			"version": "2.0.0",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": openAPISchemas(),
+			"securitySchemes": map[string]any{
+				"bearerAuth": map[string]any{"type": "http", "scheme": "bearer"},
+			},
+		},
+		"security": []map[string]any{{"bearerAuth": []string{}}},
+	}
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/MxTrap/metrics/internal/server/models"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+	"io"
+	"net/http"
+	"time"
+)
+
+// defaultHeartbeat — интервал комментариев, которые поток событий отправляет при отсутствии обновлений,
+// чтобы прокси-серверы не закрывали простаивающее соединение.
+const defaultHeartbeat = 15 * time.Second
+
+type watcher interface {
+	Subscribe(ctx context.Context, filter models.MetricFilter) (<-chan models.MetricUpdate, error)
+}
+
+// WatchHandler обслуживает подписки на обновления метрик: поток событий (SSE) и соединения WebSocket.
+type WatchHandler struct {
+	router    *gin.Engine
+	watcher   watcher
+	heartbeat time.Duration
+}
+
+// NewWatchHandler создаёт WatchHandler с указанным сервисом подписок и Gin-роутером.
+func NewWatchHandler(watcher watcher, router *gin.Engine) *WatchHandler {
+	return &WatchHandler{
+		router:    router,
+		watcher:   watcher,
+		heartbeat: defaultHeartbeat,
+	}
+}
+
+// RegisterRoutes регистрирует поток событий по адресу /api/v2/watch и WebSocket по адресу /api/v2/watch/ws.
+func (h WatchHandler) RegisterRoutes() {
+	group := h.router.Group(APIv2Prefix)
+	group.GET("/watch", h.events)
+	group.GET("/watch/ws", h.socket)
+}
+
+// watchFilter разбирает условия подписки из параметров запроса.
+func watchFilter(g *gin.Context) models.MetricFilter {
+	return models.MetricFilter{
+		Type:        g.Query("type"),
+		NamePrefix:  g.Query("prefix"),
+		NamePattern: g.Query("match"),
+	}
+}
+
+// events обрабатывает GET-запросы на подписку в виде потока событий (SSE).
+// Каждое обновление отправляется событием metric с JSON-объектом обновления в данных.
+// Поток завершается, когда клиент закрывает соединение.
+func (h WatchHandler) events(g *gin.Context) {
+	updates, err := h.watcher.Subscribe(g.Request.Context(), watchFilter(g))
+	if err != nil {
+		_ = g.Error(err)
+		return
+	}
+
+	header := g.Writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("X-Accel-Buffering", "no")
+	g.Status(http.StatusOK)
+	g.Writer.Flush()
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case update, ok := <-updates:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(update)
+			if err != nil {
+				return
+			}
+			if _, err = fmt.Fprintf(g.Writer, "event: metric\ndata: %s\n\n", data); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := io.WriteString(g.Writer, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+		g.Writer.Flush()
+	}
+}
+
+// socket обрабатывает GET-запросы на подписку через WebSocket.
+// Каждое обновление отправляется текстовым сообщением с JSON-объектом обновления.
+// Сообщения клиента игнорируются; подписка завершается, когда клиент закрывает соединение.
+func (h WatchHandler) socket(g *gin.Context) {
+	ctx, cancel := context.WithCancel(g.Request.Context())
+	defer cancel()
+	updates, err := h.watcher.Subscribe(ctx, watchFilter(g))
+	if err != nil {
+		_ = g.Error(err)
+		return
+	}
+
+	// проверка Origin не нужна: доступ определяется токеном из заголовка Authorization, а не cookie
+	server := websocket.Server{Handler: func(conn *websocket.Conn) {
+		defer conn.Close()
+		go func() {
+			// чтение завершается, когда клиент закрывает соединение
+			_, _ = io.Copy(io.Discard, conn)
+			cancel()
+		}()
+		for update := range updates {
+			if err := websocket.JSON.Send(conn, update); err != nil {
+				return
+			}
+		}
+	}}
+	server.ServeHTTP(g.Writer, g.Request)
+}
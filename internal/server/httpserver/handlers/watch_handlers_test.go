@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	commonmodels "github.com/MxTrap/metrics/internal/common/models"
+	"github.com/MxTrap/metrics/internal/server/httpserver/middlewares"
+	servermodels "github.com/MxTrap/metrics/internal/server/models"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+)
+
+type fakeWatcher struct {
+	filters chan servermodels.MetricFilter
+	updates chan servermodels.MetricUpdate
+	closed  chan struct{}
+}
+
+func newFakeWatcher() *fakeWatcher {
+	return &fakeWatcher{
+		filters: make(chan servermodels.MetricFilter, 1),
+		updates: make(chan servermodels.MetricUpdate),
+		closed:  make(chan struct{}),
+	}
+}
+
+func (w *fakeWatcher) Subscribe(ctx context.Context, filter servermodels.MetricFilter) (<-chan servermodels.MetricUpdate, error) {
+	if filter.Type == "histogram" {
+		return nil, servermodels.ErrUnknownMetricType
+	}
+	w.filters <- filter
+	out := make(chan servermodels.MetricUpdate)
+	go func() {
+		defer close(w.closed)
+		defer close(out)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case update := <-w.updates:
+				out <- update
+			}
+		}
+	}()
+	return out, nil
+}
+
+func setupWatchServer(t *testing.T, watcher *fakeWatcher) *httptest.Server {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middlewares.StatusErrorMiddleware())
+	h := NewWatchHandler(watcher, router)
+	h.heartbeat = 10 * time.Millisecond
+	h.RegisterRoutes()
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func waitClosed(t *testing.T, watcher *fakeWatcher) {
+	t.Helper()
+	select {
+	case <-watcher.closed:
+	case <-time.After(time.Second):
+		require.FailNow(t, "subscription was not cancelled")
+	}
+}
+
+var watchUpdate = servermodels.MetricUpdate{
+	MetricRecord: servermodels.MetricRecord{
+		ID:        "PollCount",
+		MType:     commonmodels.Counter,
+		Delta:     ptr(int64(1)),
+		UpdatedAt: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+	},
+	Dropped: 2,
+}
+
+func TestWatchHandlerEvents(t *testing.T) {
+	watcher := newFakeWatcher()
+	server := setupWatchServer(t, watcher)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/v2/watch?type=counter&prefix=Poll&match=Count%24", nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "no-cache", resp.Header.Get("Cache-Control"))
+	assert.Equal(t, servermodels.MetricFilter{Type: commonmodels.Counter, NamePrefix: "Poll", NamePattern: "Count$"}, <-watcher.filters)
+
+	watcher.updates <- watchUpdate
+	reader := bufio.NewReader(resp.Body)
+	var event []string
+	for {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+		if line == "" && event != nil {
+			break
+		}
+		event = append(event, line)
+	}
+	require.Len(t, event, 2)
+	assert.Equal(t, "event: metric", event[0])
+	assert.JSONEq(t, `{"id":"PollCount","type":"counter","delta":1,"updated_at":"2024-01-01T12:00:00Z","dropped":2}`,
+		strings.TrimPrefix(event[1], "data: "))
+
+	line, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, ": heartbeat\n", line, "idle stream should send heartbeats")
+
+	cancel()
+	waitClosed(t, watcher)
+}
+
+func TestWatchHandlerInvalidFilter(t *testing.T) {
+	server := setupWatchServer(t, newFakeWatcher())
+
+	resp, err := http.Get(server.URL + "/api/v2/watch?type=histogram")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "application/json; charset=utf-8", resp.Header.Get("Content-Type"))
+}
+
+func TestWatchHandlerSocket(t *testing.T) {
+	watcher := newFakeWatcher()
+	server := setupWatchServer(t, watcher)
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/v2/watch/ws?type=counter"
+	conn, err := websocket.Dial(url, "", server.URL)
+	require.NoError(t, err)
+	assert.Equal(t, servermodels.MetricFilter{Type: commonmodels.Counter}, <-watcher.filters)
+
+	watcher.updates <- watchUpdate
+	var update servermodels.MetricUpdate
+	require.NoError(t, websocket.JSON.Receive(conn, &update))
+	assert.Equal(t, watchUpdate, update)
+
+	require.NoError(t, conn.Close())
+	waitClosed(t, watcher)
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"fmt"
 	"github.com/MxTrap/metrics/config"
+	"github.com/MxTrap/metrics/internal/server/clientip"
 	"github.com/MxTrap/metrics/internal/server/httpserver/middlewares"
-	"github.com/MxTrap/metrics/internal/utils"
+	"github.com/MxTrap/metrics/internal/server/ratelimit"
+	"github.com/MxTrap/metrics/internal/server/replay"
+	"github.com/MxTrap/metrics/internal/server/templates"
 	"github.com/gin-contrib/pprof"
 	"github.com/gin-gonic/gin"
 	"net/http"
@@ -20,13 +23,29 @@ type logger interface {
 	LoggerMiddleware() gin.HandlerFunc
 }
 
-func NewRouter(cfg config.AddrConfig, log logger, key string, cryptoKey string, cidr string) *HTTPServer {
+func NewRouter(
+	cfg config.AddrConfig,
+	log logger,
+	key string,
+	guard *replay.Guard,
+	cryptoKey string,
+	resolver *clientip.Resolver,
+	trusted clientip.Subnets,
+	limiter *ratelimit.Limiter,
+	extra ...gin.HandlerFunc,
+) *HTTPServer {
 	router := gin.New()
 	router.Use(
 		log.LoggerMiddleware(),
 		gin.Recovery(),
-		middlewares.IPValidator(cidr),
-		middlewares.HashDecodeMiddleware(key),
+		middlewares.IPValidator(resolver, trusted),
+	)
+	if limiter != nil {
+		// ограничение по адресу клиента проверяется до проверки подписи, расшифровки и аутентификации
+		router.Use(middlewares.RateLimitMiddleware(limiter))
+	}
+	router.Use(
+		middlewares.HashDecodeMiddleware(key, guard),
 		middlewares.ContentEncodingMiddleware(),
 		middlewares.AcceptEncodingMiddleware(),
 		middlewares.HashEncodeMiddleware(key),
@@ -39,8 +58,10 @@ func NewRouter(cfg config.AddrConfig, log logger, key string, cryptoKey string,
 		}
 		router.Use(decrypter.DecrypterMiddleware())
 	}
+	router.Use(extra...)
+	router.ContextWithFallback = true
 	router.HandleMethodNotAllowed = true
-	router.LoadHTMLGlob(utils.GetProjectPath() + "/internal/server/templates/*")
+	router.SetHTMLTemplate(templates.HTML())
 
 	return &HTTPServer{
 		server: &http.Server{
@@ -10,6 +10,8 @@ import (
 	"time"
 
 	"github.com/MxTrap/metrics/config"
+	"github.com/MxTrap/metrics/internal/server/clientip"
+	"github.com/MxTrap/metrics/internal/server/ratelimit"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -31,7 +33,7 @@ func TestNewRouter(t *testing.T) {
 	log := &mockLogger{}
 	key := "testkey"
 
-	server := NewRouter(cfg, log, key, "", "")
+	server := NewRouter(cfg, log, key, nil, "", clientip.NewResolver(nil), nil, nil)
 	require.NotNil(t, server, "server should not be nil")
 	assert.NotNil(t, server.Router, "router should not be nil")
 	assert.NotNil(t, server.server, "http server should not be nil")
@@ -55,7 +57,7 @@ func TestStop(t *testing.T) {
 	log := &mockLogger{}
 	key := "testkey"
 
-	server := NewRouter(cfg, log, key, "", "")
+	server := NewRouter(cfg, log, key, nil, "", clientip.NewResolver(nil), nil, nil)
 
 	go func() {
 		_ = server.Run()
@@ -83,7 +85,7 @@ func TestNewRouterInvalidTemplatesPath(t *testing.T) {
 	log := &mockLogger{}
 	key := "testkey"
 
-	server := NewRouter(cfg, log, key, "", "")
+	server := NewRouter(cfg, log, key, nil, "", clientip.NewResolver(nil), nil, nil)
 	assert.NotNil(t, server, "server should be created even with invalid templates path")
 
 	server.Router.GET("/test", func(c *gin.Context) {
@@ -95,3 +97,24 @@ func TestNewRouterInvalidTemplatesPath(t *testing.T) {
 	server.Router.ServeHTTP(w, req)
 	assert.Equal(t, http.StatusOK, w.Code, "request should succeed despite invalid templates")
 }
+
+func TestNewRouterRateLimitBeforeSignature(t *testing.T) {
+	cfg := config.AddrConfig{
+		Host: "localhost",
+		Port: 8080,
+	}
+	server := NewRouter(cfg, &mockLogger{}, "testkey", nil, "", clientip.NewResolver(nil), nil, ratelimit.NewLimiter(0.001, 1))
+	server.Router.POST("/updates/", func(c *gin.Context) {
+		c.String(http.StatusOK, "OK")
+	})
+
+	codes := make([]int, 2)
+	for i := range codes {
+		req := httptest.NewRequest(http.MethodPost, "/updates/", strings.NewReader("[]"))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		server.Router.ServeHTTP(w, req)
+		codes[i] = w.Code
+	}
+	assert.Equal(t, []int{http.StatusBadRequest, http.StatusTooManyRequests}, codes, "unsigned requests should count against the limit")
+}
@@ -0,0 +1,45 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	commonmodels "github.com/MxTrap/metrics/internal/common/models"
+	"github.com/MxTrap/metrics/internal/server/models"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+)
+
+type agentRegistry interface {
+	Seen(ctx context.Context, info commonmodels.AgentInfo, transport string, err error)
+}
+
+// agentInfo возвращает сведения, которыми агент представился в заголовках запроса.
+func agentInfo(header http.Header) commonmodels.AgentInfo {
+	return commonmodels.AgentInfo{
+		ID:       strings.TrimSpace(header.Get("X-Agent-ID")),
+		Hostname: strings.TrimSpace(header.Get("X-Agent-Hostname")),
+		Version:  strings.TrimSpace(header.Get("X-Agent-Version")),
+		OS:       strings.TrimSpace(header.Get("X-Agent-OS")),
+	}
+}
+
+func AgentRegistryMiddleware(registry agentRegistry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		info := agentInfo(c.Request.Header)
+		if info.ID == "" {
+			c.Next()
+			return
+		}
+
+		c.Next()
+
+		var err error
+		if len(c.Errors) > 0 {
+			err = c.Errors.Last()
+		} else if status := c.Writer.Status(); status >= http.StatusBadRequest {
+			err = errors.New(http.StatusText(status))
+		}
+		registry.Seen(c.Request.Context(), info, models.AgentTransportHTTP, err)
+	}
+}
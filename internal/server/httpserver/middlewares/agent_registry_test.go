@@ -0,0 +1,60 @@
+package middlewares
+
+import (
+	"context"
+	commonmodels "github.com/MxTrap/metrics/internal/common/models"
+	"github.com/MxTrap/metrics/internal/server/models"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type seenCall struct {
+	info      commonmodels.AgentInfo
+	transport string
+	err       error
+}
+
+type stubAgentRegistry struct {
+	calls []seenCall
+}
+
+func (r *stubAgentRegistry) Seen(_ context.Context, info commonmodels.AgentInfo, transport string, err error) {
+	r.calls = append(r.calls, seenCall{info: info, transport: transport, err: err})
+}
+
+func TestAgentRegistryMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	registry := &stubAgentRegistry{}
+	router := gin.New()
+	router.Use(StatusErrorMiddleware(), AgentRegistryMiddleware(registry))
+	router.POST("/updates/", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	router.POST("/update/", func(c *gin.Context) {
+		_ = c.Error(models.ErrWrongMetricValue)
+	})
+
+	send := func(path string, agent bool) {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		if agent {
+			req.Header.Set("X-Agent-ID", "host-1")
+			req.Header.Set("X-Agent-Hostname", "host-1.local")
+			req.Header.Set("X-Agent-Version", "v1.2.0")
+			req.Header.Set("X-Agent-OS", "linux/amd64")
+		}
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	send("/updates/", true)
+	send("/update/", true)
+	send("/updates/", false)
+
+	require.Len(t, registry.calls, 2, "requests without agent id should not be registered")
+	assert.Equal(t, commonmodels.AgentInfo{ID: "host-1", Hostname: "host-1.local", Version: "v1.2.0", OS: "linux/amd64"}, registry.calls[0].info)
+	assert.Equal(t, models.AgentTransportHTTP, registry.calls[0].transport)
+	assert.NoError(t, registry.calls[0].err)
+	assert.ErrorIs(t, registry.calls[1].err, models.ErrWrongMetricValue)
+}
@@ -0,0 +1,60 @@
+package middlewares
+
+import (
+	"context"
+	"github.com/MxTrap/metrics/internal/server/models"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+)
+
+type authenticator interface {
+	Authenticate(ctx context.Context, secret string) (models.Token, error)
+}
+
+// requiredPermission определяет разрешение, необходимое для выполнения запроса.
+func requiredPermission(c *gin.Context) models.Permission {
+	path := c.Request.URL.Path
+	switch {
+	case strings.HasPrefix(path, "/admin"), strings.HasPrefix(path, "/debug"), path == "/agents":
+		return models.PermissionAdmin
+	// настройки агентов запрашивают сами агенты, которым выдаются токены с разрешением write
+	case strings.HasPrefix(path, "/agent/"):
+		return models.PermissionWrite
+	case c.Request.Method == http.MethodGet, strings.HasPrefix(path, "/value"):
+		return models.PermissionRead
+	default:
+		return models.PermissionWrite
+	}
+}
+
+func bearerToken(header string) string {
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+func AuthMiddleware(auth authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.URL.Path == "/ping" {
+			c.Next()
+			return
+		}
+
+		token, err := auth.Authenticate(c.Request.Context(), bearerToken(c.Request.Header.Get("Authorization")))
+		if err != nil {
+			c.Header("WWW-Authenticate", "Bearer")
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		if !token.Has(requiredPermission(c)) {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+
+		c.Request = c.Request.WithContext(models.ContextWithToken(c.Request.Context(), token))
+		c.Next()
+	}
+}
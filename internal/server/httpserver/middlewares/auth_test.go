@@ -0,0 +1,88 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"github.com/MxTrap/metrics/internal/server/models"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type stubAuthenticator struct {
+	tokens map[string]models.Token
+}
+
+func (a stubAuthenticator) Authenticate(_ context.Context, secret string) (models.Token, error) {
+	token, ok := a.tokens[secret]
+	if !ok {
+		return models.Token{}, errors.New("unknown token")
+	}
+	return token, nil
+}
+
+func newAuthRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	auth := stubAuthenticator{tokens: map[string]models.Token{
+		"reader": {ID: "reader", Permissions: []models.Permission{models.PermissionRead}},
+		"writer": {ID: "writer", Permissions: []models.Permission{models.PermissionWrite}, Prefix: "app_"},
+	}}
+	router := gin.New()
+	router.Use(AuthMiddleware(auth))
+	handler := func(c *gin.Context) {
+		token, _ := models.TokenFromContext(c.Request.Context())
+		c.String(http.StatusOK, token.ID)
+	}
+	router.GET("/value/:type/:name", handler)
+	router.POST("/updates/", handler)
+	router.GET("/admin/tokens", handler)
+	router.GET("/agent/config", handler)
+	router.GET("/agents", handler)
+	router.GET("/ping", handler)
+	return router
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		authorization  string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{"Missing token", http.MethodPost, "/updates/", "", http.StatusUnauthorized, ""},
+		{"Unknown token", http.MethodPost, "/updates/", "Bearer nope", http.StatusUnauthorized, ""},
+		{"Not a bearer token", http.MethodPost, "/updates/", "Basic writer", http.StatusUnauthorized, ""},
+		{"Writer may write", http.MethodPost, "/updates/", "Bearer writer", http.StatusOK, "writer"},
+		{"Reader may not write", http.MethodPost, "/updates/", "Bearer reader", http.StatusForbidden, ""},
+		{"Reader may read", http.MethodGet, "/value/gauge/Alloc", "Bearer reader", http.StatusOK, "reader"},
+		{"Writer may not read", http.MethodGet, "/value/gauge/Alloc", "Bearer writer", http.StatusForbidden, ""},
+		{"Agent fetches config with write token", http.MethodGet, "/agent/config", "Bearer writer", http.StatusOK, "writer"},
+		{"Reader may not fetch agent config", http.MethodGet, "/agent/config", "Bearer reader", http.StatusForbidden, ""},
+		{"Admin route requires admin", http.MethodGet, "/admin/tokens", "Bearer reader", http.StatusForbidden, ""},
+		{"Agent registry requires admin", http.MethodGet, "/agents", "Bearer reader", http.StatusForbidden, ""},
+		{"Ping is public", http.MethodGet, "/ping", "", http.StatusOK, ""},
+	}
+
+	router := newAuthRouter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, tt.path, nil)
+			require.NoError(t, err)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus == http.StatusOK {
+				assert.Equal(t, tt.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
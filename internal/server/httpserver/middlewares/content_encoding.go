@@ -25,6 +25,14 @@ func ContentEncodingMiddleware() gin.HandlerFunc {
 	}
 }
 
+// streaming сообщает, что клиент запрашивает поток событий (SSE) или соединение WebSocket.
+// Ответы таких запросов не сжимаются и не подписываются: они не ограничены по длине
+// и должны доходить до клиента сразу после записи.
+func streaming(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream") ||
+		strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
 type writer struct {
 	gin.ResponseWriter
 	Writer *gzip.Writer
@@ -45,7 +53,7 @@ func (w writer) Write(b []byte) (int, error) {
 
 func AcceptEncodingMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if !strings.Contains(c.Request.Header.Get("Accept-Encoding"), "gzip") {
+		if !strings.Contains(c.Request.Header.Get("Accept-Encoding"), "gzip") || streaming(c.Request) {
 			return
 		}
 
@@ -105,6 +105,29 @@ func TestAcceptEncodingMiddlewareGzipJSON(t *testing.T) {
 	assert.Equal(t, "test data", string(body))
 }
 
+func TestAcceptEncodingMiddlewareStreaming(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(AcceptEncodingMiddleware())
+	router.GET("/test", func(c *gin.Context) {
+		c.Header("Content-Type", "application/json; charset=utf-8")
+		c.String(http.StatusOK, "test data")
+	})
+
+	for _, header := range [][2]string{{"Accept", "text/event-stream"}, {"Upgrade", "websocket"}} {
+		req, err := http.NewRequest("GET", "/test", nil)
+		require.NoError(t, err)
+		req.Header.Set("Accept-Encoding", "gzip")
+		req.Header.Set(header[0], header[1])
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("Content-Encoding"), header[0])
+		assert.Equal(t, "test data", w.Body.String(), header[0])
+	}
+}
+
 func TestAcceptEncodingMiddlewareGzipHTML(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
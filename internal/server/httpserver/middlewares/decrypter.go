@@ -47,6 +47,11 @@ func (d *Decrypter) DecrypterMiddleware() gin.HandlerFunc {
 			c.AbortWithStatus(http.StatusInternalServerError)
 			return
 		}
+		// запросы без тела, например GET настроек агента, не шифруются
+		if bodyBuffer.Len() == 0 {
+			c.Request.Body = http.NoBody
+			return
+		}
 		decryptedBytes, err := d.key.Decrypt(nil, bodyBuffer.Bytes(), &rsa.OAEPOptions{Hash: crypto.SHA256})
 		if err != nil {
 			fmt.Println(err)
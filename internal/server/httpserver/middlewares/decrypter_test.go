@@ -128,3 +128,23 @@ func TestDecrypterMiddlewareInvalidCiphertext(t *testing.T) {
 
 	assert.Equal(t, http.StatusInternalServerError, w.Code, "status should be InternalServerError")
 }
+
+func TestDecrypterMiddlewareEmptyBody(t *testing.T) {
+	tempDir := t.TempDir()
+	privateKey, _ := generateKeyPair(t)
+	privateKeyPath := filepath.Join(tempDir, "private.pem")
+	savePrivateKey(t, privateKey, privateKeyPath)
+
+	decrypter, err := NewDecrypter(privateKeyPath)
+	require.NoError(t, err, "NewDecrypter should succeed")
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/agent/config", decrypter.DecrypterMiddleware(), func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/agent/config", nil))
+	assert.Equal(t, http.StatusOK, w.Code, "request without body should not be decrypted")
+}
@@ -5,15 +5,25 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
+	"github.com/MxTrap/metrics/internal/common/sign"
+	"github.com/MxTrap/metrics/internal/server/ratelimit"
+	"github.com/MxTrap/metrics/internal/server/replay"
 	"github.com/gin-gonic/gin"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 )
 
-func HashDecodeMiddleware(key string) gin.HandlerFunc {
+// batchRequest сообщает, является ли запрос пакетным обновлением метрик, которое должно быть подписано.
+func batchRequest(r *http.Request) bool {
+	return strings.Contains(r.URL.String(), "updates") || strings.HasSuffix(r.URL.Path, "/metrics/batch")
+}
+
+func HashDecodeMiddleware(key string, guard *replay.Guard) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if key != "" && strings.Contains(c.Request.URL.String(), "updates") {
+		if key != "" && batchRequest(c.Request) {
 			hashHeaderStr := c.Request.Header.Get("HashSHA256")
 			if hashHeaderStr == "" {
 				c.AbortWithStatus(http.StatusBadRequest)
@@ -38,12 +48,30 @@ func HashDecodeMiddleware(key string) gin.HandlerFunc {
 			}
 			c.Request.Body = io.NopCloser(&bodyBuffer)
 
-			h := hmac.New(sha256.New, []byte(key))
-			h.Write(bodyBuffer.Bytes())
-			if !hmac.Equal(hashHeader, h.Sum(nil)) {
+			timestamp := c.Request.Header.Get("X-Timestamp")
+			nonce := c.Request.Header.Get("X-Nonce")
+			signed := bodyBuffer.Bytes()
+			if timestamp != "" || nonce != "" {
+				signed = sign.Payload(timestamp, nonce, signed)
+			} else if guard != nil {
 				c.AbortWithStatus(http.StatusBadRequest)
 				return
 			}
+
+			if !hmac.Equal(hashHeader, sign.Sum(key, signed)) {
+				c.AbortWithStatus(http.StatusBadRequest)
+				return
+			}
+			if guard != nil {
+				if err = guard.Check(timestamp, nonce); errors.Is(err, replay.ErrCacheFull) {
+					c.Header("Retry-After", strconv.Itoa(ratelimit.RetryAfterSeconds(guard.RetryAfter())))
+					c.AbortWithStatus(http.StatusTooManyRequests)
+					return
+				} else if err != nil {
+					c.AbortWithStatus(http.StatusBadRequest)
+					return
+				}
+			}
 		}
 	}
 }
@@ -66,7 +94,7 @@ func (w responseWriter) Write(b []byte) (int, error) {
 
 func HashEncodeMiddleware(key string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if key != "" {
+		if key != "" && !streaming(c.Request) {
 			w := &responseWriter{body: &bytes.Buffer{}, ResponseWriter: c.Writer, key: key}
 			c.Writer = w
 		}
@@ -5,14 +5,18 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
+	"github.com/MxTrap/metrics/internal/common/sign"
+	"github.com/MxTrap/metrics/internal/server/replay"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 )
 
 func setupGinContext(method, path, body string, headers map[string]string) (*gin.Context, *httptest.ResponseRecorder) {
@@ -69,6 +73,24 @@ func TestHashDecodeMiddleware(t *testing.T) {
 			headers:        map[string]string{},
 			expectedStatus: http.StatusOK,
 		},
+		{
+			name: "Valid HMAC with API v2 batch",
+			key:  key,
+			url:  "/api/v2/metrics/batch",
+			body: body,
+			headers: map[string]string{
+				"HashSHA256": validHash,
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Unsigned API v2 batch",
+			key:            key,
+			url:            "/api/v2/metrics/batch",
+			body:           body,
+			headers:        map[string]string{},
+			expectedStatus: http.StatusBadRequest,
+		},
 		{
 			name:           "Missing HashSHA256 header",
 			key:            key,
@@ -102,7 +124,7 @@ func TestHashDecodeMiddleware(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			c, w := setupGinContext(http.MethodPost, tt.url, tt.body, tt.headers)
-			middleware := HashDecodeMiddleware(tt.key)
+			middleware := HashDecodeMiddleware(tt.key, nil)
 
 			middleware(c)
 
@@ -118,6 +140,61 @@ func TestHashDecodeMiddleware(t *testing.T) {
 	}
 }
 
+func TestHashDecodeMiddlewareReplay(t *testing.T) {
+	key := "secret"
+	body := `{"data":"test"}`
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	stale := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	signed := func(ts, nonce string) map[string]string {
+		return map[string]string{
+			"X-Timestamp": ts,
+			"X-Nonce":     nonce,
+			"HashSHA256":  hex.EncodeToString(sign.Sum(key, sign.Payload(ts, nonce, []byte(body)))),
+		}
+	}
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write([]byte(body))
+	legacy := map[string]string{"HashSHA256": hex.EncodeToString(h.Sum(nil))}
+
+	guard := replay.NewGuard(time.Minute, 100)
+	full := replay.NewGuard(time.Minute, 1)
+	tests := []struct {
+		name           string
+		guard          *replay.Guard
+		headers        map[string]string
+		expectedStatus int
+	}{
+		{name: "Signed request", guard: guard, headers: signed(now, "n1"), expectedStatus: http.StatusOK},
+		{name: "Replayed nonce", guard: guard, headers: signed(now, "n1"), expectedStatus: http.StatusBadRequest},
+		{name: "Stale timestamp", guard: guard, headers: signed(stale, "n2"), expectedStatus: http.StatusBadRequest},
+		{name: "Legacy signature rejected", guard: guard, headers: legacy, expectedStatus: http.StatusBadRequest},
+		{
+			name:  "Tampered timestamp",
+			guard: guard,
+			headers: map[string]string{
+				"X-Timestamp": now,
+				"X-Nonce":     "n3",
+				"HashSHA256":  signed(stale, "n3")["HashSHA256"],
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{name: "First nonce in a full cache", guard: full, headers: signed(now, "f1"), expectedStatus: http.StatusOK},
+		{name: "Cache full of live nonces", guard: full, headers: signed(now, "f2"), expectedStatus: http.StatusTooManyRequests},
+		{name: "Legacy signature without guard", headers: legacy, expectedStatus: http.StatusOK},
+		{name: "New signature without guard", headers: signed(stale, "n4"), expectedStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := setupGinContext(http.MethodPost, "/updates/", body, tt.headers)
+
+			HashDecodeMiddleware(key, tt.guard)(c)
+
+			assert.Equal(t, tt.expectedStatus, w.Code, "Status code should match")
+		})
+	}
+}
+
 func TestHashDecodeMiddleware_BodyReadError(t *testing.T) {
 	key := "secret"
 	body := `{"data":"test"}`
@@ -131,7 +208,7 @@ func TestHashDecodeMiddleware_BodyReadError(t *testing.T) {
 	})
 	c.Request.Body = &errorReader{err: assert.AnError}
 
-	middleware := HashDecodeMiddleware(key)
+	middleware := HashDecodeMiddleware(key, nil)
 	middleware(c)
 
 	assert.Equal(t, http.StatusBadRequest, w.Code, "Should return BadRequest on body read error")
@@ -173,6 +250,25 @@ func TestHashEncodeMiddlewareWithKey(t *testing.T) {
 	assert.Equal(t, expectedHash, w.Header().Get("HashSHA256"))
 }
 
+func TestHashEncodeMiddlewareStreaming(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(HashEncodeMiddleware("secret"))
+	router.GET("/test", func(c *gin.Context) {
+		c.String(http.StatusOK, "event")
+	})
+
+	req, err := http.NewRequest("GET", "/test", nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept", "text/event-stream")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "event", w.Body.String())
+	assert.Empty(t, w.Header().Get("HashSHA256"), "streamed responses should not be signed")
+}
+
 func TestHashEncodeMiddlewareEmptyKey(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
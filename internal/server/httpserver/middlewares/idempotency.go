@@ -0,0 +1,41 @@
+package middlewares
+
+import (
+	"errors"
+	"github.com/MxTrap/metrics/internal/server/idempotency"
+	"github.com/MxTrap/metrics/internal/server/models"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+)
+
+func IdempotencyMiddleware(cache *idempotency.Cache) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		batchID := strings.TrimSpace(c.Request.Header.Get("X-Batch-ID"))
+		if batchID == "" || c.Request.Method == http.MethodGet {
+			c.Next()
+			return
+		}
+
+		key := idempotency.Key(models.TenantFromContext(c.Request.Context()), batchID)
+		err := cache.Begin(key)
+		if errors.Is(err, idempotency.ErrDuplicate) {
+			c.Header("X-Batch-Replayed", "true")
+			c.AbortWithStatus(http.StatusOK)
+			return
+		}
+		if err != nil {
+			_ = c.Error(models.ErrBatchInProgress)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+
+		if len(c.Errors) == 0 && c.Writer.Status() < http.StatusMultipleChoices {
+			cache.Commit(key)
+		} else {
+			cache.Release(key)
+		}
+	}
+}
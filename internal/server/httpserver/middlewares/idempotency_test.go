@@ -0,0 +1,71 @@
+package middlewares
+
+import (
+	"github.com/MxTrap/metrics/internal/server/idempotency"
+	"github.com/MxTrap/metrics/internal/server/models"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(StatusErrorMiddleware(), IdempotencyMiddleware(idempotency.NewCache(time.Minute, 100)))
+	calls := 0
+	fail := false
+	router.POST("/updates/", func(c *gin.Context) {
+		calls++
+		if fail {
+			c.Error(models.ErrWrongMetricValue)
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+
+	send := func(batchID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/updates/", nil)
+		if batchID != "" {
+			req.Header.Set("X-Batch-ID", batchID)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	fail = true
+	assert.Equal(t, http.StatusBadRequest, send("1").Code)
+	fail = false
+	assert.Equal(t, http.StatusOK, send("1").Code, "failed batch should be applied on retry")
+
+	w := send("1")
+	assert.Equal(t, http.StatusOK, w.Code, "duplicate should be acknowledged")
+	assert.Equal(t, "true", w.Header().Get("X-Batch-Replayed"))
+	assert.Equal(t, 2, calls, "duplicate must not reach the handler")
+
+	assert.Equal(t, http.StatusOK, send("").Code)
+	assert.Equal(t, http.StatusOK, send("").Code)
+	assert.Equal(t, 4, calls, "requests without batch id are not deduplicated")
+}
+
+func TestIdempotencyMiddlewareInProgress(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cache := idempotency.NewCache(time.Minute, 100)
+	assert.NoError(t, cache.Begin(idempotency.Key(models.DefaultTenant, "1")))
+
+	router := gin.New()
+	router.Use(StatusErrorMiddleware(), IdempotencyMiddleware(cache))
+	router.POST("/updates/", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/updates/", nil)
+	req.Header.Set("X-Batch-ID", "1")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
@@ -1,37 +1,27 @@
 package middlewares
 
 import (
+	"github.com/MxTrap/metrics/internal/server/clientip"
 	"github.com/gin-gonic/gin"
-	"net"
 	"net/http"
 )
 
-func IPValidator(cidr string) gin.HandlerFunc {
+func IPValidator(resolver *clientip.Resolver, trusted clientip.Subnets) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if cidr == "" {
-			c.Next()
-			return
-		}
-
-		xRealIP := c.Request.Header.Get("X-Real-IP")
-		if xRealIP == "" {
-			c.AbortWithStatus(http.StatusForbidden)
-			return
+		ip := resolver.Resolve(
+			c.Request.RemoteAddr,
+			c.Request.Header.Get("X-Real-IP"),
+			c.Request.Header.Values("X-Forwarded-For"),
+		)
+		if ip != nil {
+			c.Request = c.Request.WithContext(clientip.ContextWithIP(c.Request.Context(), ip))
 		}
 
-		ip := net.ParseIP(xRealIP)
-		if ip == nil {
-			c.AbortWithStatus(http.StatusForbidden)
-			return
-		}
-
-		_, ipNet, err := net.ParseCIDR(cidr)
-		if err != nil {
-			_ = c.Error(err)
-			c.AbortWithStatus(http.StatusInternalServerError)
+		if len(trusted) == 0 {
+			c.Next()
 			return
 		}
-		if !ipNet.Contains(ip) {
+		if !trusted.Contains(ip) {
 			c.AbortWithStatus(http.StatusForbidden)
 			return
 		}
@@ -1,6 +1,7 @@
 package middlewares
 
 import (
+	"github.com/MxTrap/metrics/internal/server/clientip"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -9,104 +10,100 @@ import (
 	"testing"
 )
 
-func TestIPValidatorEmptyCIDR(t *testing.T) {
+func newIPValidatorRouter(t *testing.T, proxies string, trusted string) *gin.Engine {
+	t.Helper()
 	gin.SetMode(gin.TestMode)
+	proxySubnets, err := clientip.ParseSubnets(proxies)
+	require.NoError(t, err)
+	trustedSubnets, err := clientip.ParseSubnets(trusted)
+	require.NoError(t, err)
+
 	router := gin.New()
-	router.GET("/test", IPValidator(""), func(c *gin.Context) {
-		c.String(http.StatusOK, "OK")
+	router.GET("/test", IPValidator(clientip.NewResolver(proxySubnets), trustedSubnets), func(c *gin.Context) {
+		ip, _ := clientip.FromContext(c.Request.Context())
+		c.String(http.StatusOK, ip.String())
 	})
+	return router
+}
 
-	req, err := http.NewRequest(http.MethodGet, "/test", nil)
-	require.NoError(t, err)
+func doIPRequest(router *gin.Engine, remoteAddr string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.RemoteAddr = remoteAddr
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
 	w := httptest.NewRecorder()
-
 	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestIPValidatorEmptyCIDR(t *testing.T) {
+	router := newIPValidatorRouter(t, "", "")
+
+	w := doIPRequest(router, "203.0.113.7:5000", nil)
 
 	assert.Equal(t, http.StatusOK, w.Code)
-	assert.Equal(t, "OK", w.Body.String())
+	assert.Equal(t, "203.0.113.7", w.Body.String())
 }
 
-func TestIPValidatorMissingXRealIP(t *testing.T) {
-	gin.SetMode(gin.TestMode)
-	router := gin.New()
-	router.GET("/test", IPValidator("192.168.1.0/24"), func(c *gin.Context) {
-		c.String(http.StatusOK, "OK")
-	})
+func TestIPValidatorPeerInCIDR(t *testing.T) {
+	router := newIPValidatorRouter(t, "", "192.168.1.0/24")
 
-	req, err := http.NewRequest(http.MethodGet, "/test", nil)
-	require.NoError(t, err)
-	w := httptest.NewRecorder()
+	w := doIPRequest(router, "192.168.1.100:5000", nil)
 
-	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "192.168.1.100", w.Body.String())
+}
+
+func TestIPValidatorPeerNotInCIDR(t *testing.T) {
+	router := newIPValidatorRouter(t, "", "192.168.1.0/24")
+
+	w := doIPRequest(router, "10.0.0.100:5000", nil)
 
 	assert.Equal(t, http.StatusForbidden, w.Code)
 }
 
-func TestIPValidatorInvalidXRealIP(t *testing.T) {
-	gin.SetMode(gin.TestMode)
-	router := gin.New()
-	router.GET("/test", IPValidator("192.168.1.0/24"), func(c *gin.Context) {
-		c.String(http.StatusOK, "OK")
-	})
+func TestIPValidatorSpoofedXRealIP(t *testing.T) {
+	router := newIPValidatorRouter(t, "", "192.168.1.0/24")
 
-	req, err := http.NewRequest(http.MethodGet, "/test", nil)
-	require.NoError(t, err)
-	req.Header.Set("X-Real-IP", "invalid-ip")
-	w := httptest.NewRecorder()
-
-	router.ServeHTTP(w, req)
+	w := doIPRequest(router, "10.0.0.100:5000", map[string]string{"X-Real-IP": "192.168.1.100"})
 
 	assert.Equal(t, http.StatusForbidden, w.Code)
 }
 
-func TestIPValidatorInvalidCIDR(t *testing.T) {
-	gin.SetMode(gin.TestMode)
-	router := gin.New()
-	router.GET("/test", IPValidator("invalid-cidr"), func(c *gin.Context) {
-		c.String(http.StatusOK, "OK")
-	})
+func TestIPValidatorXRealIPFromTrustedProxy(t *testing.T) {
+	router := newIPValidatorRouter(t, "10.0.0.0/8", "192.168.1.0/24")
 
-	req, err := http.NewRequest(http.MethodGet, "/test", nil)
-	require.NoError(t, err)
-	req.Header.Set("X-Real-IP", "192.168.1.100")
-	w := httptest.NewRecorder()
-
-	router.ServeHTTP(w, req)
+	w := doIPRequest(router, "10.0.0.1:5000", map[string]string{"X-Real-IP": "192.168.1.100"})
 
-	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "192.168.1.100", w.Body.String())
 }
 
-func TestIPValidatorIPInCIDR(t *testing.T) {
-	gin.SetMode(gin.TestMode)
-	router := gin.New()
-	router.GET("/test", IPValidator("192.168.1.0/24"), func(c *gin.Context) {
-		c.String(http.StatusOK, "OK")
-	})
+func TestIPValidatorXForwardedForFromTrustedProxy(t *testing.T) {
+	router := newIPValidatorRouter(t, "10.0.0.0/8", "192.168.1.0/24")
 
-	req, err := http.NewRequest(http.MethodGet, "/test", nil)
-	require.NoError(t, err)
-	req.Header.Set("X-Real-IP", "192.168.1.100")
-	w := httptest.NewRecorder()
+	w := doIPRequest(router, "10.0.0.1:5000", map[string]string{"X-Forwarded-For": "203.0.113.7, 192.168.1.100"})
+	assert.Equal(t, http.StatusOK, w.Code)
 
-	router.ServeHTTP(w, req)
+	w = doIPRequest(router, "10.0.0.1:5000", map[string]string{"X-Forwarded-For": "192.168.1.100, 203.0.113.7"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestIPValidatorMultipleSubnetsIPv6(t *testing.T) {
+	router := newIPValidatorRouter(t, "", "192.168.1.0/24,2001:db8::/32")
 
+	w := doIPRequest(router, "[2001:db8::1]:5000", nil)
 	assert.Equal(t, http.StatusOK, w.Code)
-	assert.Equal(t, "OK", w.Body.String())
-}
 
-func TestIPValidatorIPNotInCIDR(t *testing.T) {
-	gin.SetMode(gin.TestMode)
-	router := gin.New()
-	router.GET("/test", IPValidator("192.168.1.0/24"), func(c *gin.Context) {
-		c.String(http.StatusOK, "OK")
-	})
+	w = doIPRequest(router, "[2001:db9::1]:5000", nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
 
-	req, err := http.NewRequest(http.MethodGet, "/test", nil)
-	require.NoError(t, err)
-	req.Header.Set("X-Real-IP", "10.0.0.100")
-	w := httptest.NewRecorder()
+func TestIPValidatorInvalidPeer(t *testing.T) {
+	router := newIPValidatorRouter(t, "", "192.168.1.0/24")
 
-	router.ServeHTTP(w, req)
+	w := doIPRequest(router, "invalid-ip", map[string]string{"X-Real-IP": "192.168.1.100"})
 
 	assert.Equal(t, http.StatusForbidden, w.Code)
 }
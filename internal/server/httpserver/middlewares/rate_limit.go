@@ -0,0 +1,24 @@
+package middlewares
+
+import (
+	"github.com/MxTrap/metrics/internal/server/ratelimit"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+func RateLimitMiddleware(limiter *ratelimit.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			route = c.Request.URL.Path
+		}
+		ok, wait := limiter.Allow(ratelimit.ClientKey(c.Request.Context()) + " " + c.Request.Method + " " + route)
+		if !ok {
+			c.Header("Retry-After", strconv.Itoa(ratelimit.RetryAfterSeconds(wait)))
+			c.AbortWithStatus(http.StatusTooManyRequests)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,70 @@
+package middlewares
+
+import (
+	"github.com/MxTrap/metrics/internal/server/clientip"
+	"github.com/MxTrap/metrics/internal/server/models"
+	"github.com/MxTrap/metrics/internal/server/ratelimit"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRateLimitMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(IPValidator(clientip.NewResolver(nil), nil), RateLimitMiddleware(ratelimit.NewLimiter(0.5, 2)))
+	router.POST("/updates/", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	router.GET("/", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	do := func(method, path, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, do(http.MethodPost, "/updates/", "192.168.1.1:5000").Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodPost, "/updates/", "192.168.1.1:5000").Code)
+
+	w := do(http.MethodPost, "/updates/", "192.168.1.1:5000")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/", "192.168.1.1:5000").Code, "routes are limited separately")
+	assert.Equal(t, http.StatusOK, do(http.MethodPost, "/updates/", "192.168.1.2:5000").Code, "clients are limited separately")
+}
+
+func TestRateLimitMiddlewarePerToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	ipLimiter := RateLimitMiddleware(ratelimit.NewLimiter(0.5, 10))
+	tokenLimiter := RateLimitMiddleware(ratelimit.NewLimiter(0.5, 1))
+	authenticate := func(c *gin.Context) {
+		token := models.Token{ID: c.GetHeader("Authorization")}
+		c.Request = c.Request.WithContext(models.ContextWithToken(c.Request.Context(), token))
+	}
+	router.Use(IPValidator(clientip.NewResolver(nil), nil), ipLimiter, authenticate, tokenLimiter)
+	router.POST("/updates/", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	do := func(token string) int {
+		req := httptest.NewRequest(http.MethodPost, "/updates/", nil)
+		req.RemoteAddr = "192.168.1.1:5000"
+		req.Header.Set("Authorization", token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, do("first"))
+	assert.Equal(t, http.StatusTooManyRequests, do("first"))
+	assert.Equal(t, http.StatusOK, do("second"), "tokens behind one address are limited separately")
+}
@@ -1,33 +1,91 @@
 package middlewares
 
 import (
+	"encoding/json"
 	"errors"
 	"github.com/MxTrap/metrics/internal/server/models"
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"strings"
 )
 
+// apiPrefix — префикс маршрутов, ошибки которых возвращаются JSON-объектом в теле ответа.
+// Устаревшие маршруты по-прежнему отвечают только статусом.
+const apiPrefix = "/api/"
+
+type errorStatus struct {
+	err    error
+	status int
+	code   string
+}
+
+var errorStatuses = []errorStatus{
+	{models.ErrNotFoundMetric, http.StatusNotFound, "metric_not_found"},
+	{models.ErrNoAgentConfig, http.StatusNotFound, "agent_config_not_found"},
+	{models.ErrMissingAgentID, http.StatusBadRequest, "missing_agent_id"},
+	{models.ErrUnknownMetricType, http.StatusBadRequest, "unknown_metric_type"},
+	{models.ErrWrongMetricValue, http.StatusBadRequest, "wrong_metric_value"},
+	{models.ErrInvalidSignature, http.StatusBadRequest, "invalid_signature"},
+	{models.ErrInvalidRequest, http.StatusBadRequest, "invalid_request"},
+	{models.ErrUnauthorized, http.StatusUnauthorized, "unauthorized"},
+	{models.ErrForbidden, http.StatusForbidden, "forbidden"},
+	{models.ErrBatchInProgress, http.StatusConflict, "batch_in_progress"},
+	{models.ErrRateLimited, http.StatusTooManyRequests, "rate_limited"},
+	{models.ErrBatchTooLarge, http.StatusTooManyRequests, "batch_too_large"},
+	{models.ErrMetricQuota, http.StatusTooManyRequests, "metric_quota_exceeded"},
+}
+
+// statusCode возвращает код ошибки API для ответа, у которого известен только HTTP-статус.
+func statusCode(status int) string {
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+}
+
+// lookupError сопоставляет ошибку обработчика с HTTP-статусом, кодом и сообщением ошибки API.
+// Сообщения неизвестных ошибок не раскрываются клиенту.
+func lookupError(err error) (int, models.APIError) {
+	for _, e := range errorStatuses {
+		if errors.Is(err, e.err) {
+			return e.status, models.APIError{Code: e.code, Message: err.Error()}
+		}
+	}
+	status := http.StatusInternalServerError
+	return status, models.APIError{Code: statusCode(status), Message: http.StatusText(status)}
+}
+
+// writeAPIError прерывает обработку запроса и записывает ошибку API в тело ответа.
+// Если статус уже отправлен предыдущим обработчиком, дописывается только тело.
+func writeAPIError(c *gin.Context, status int, apiErr models.APIError) {
+	body := models.APIErrorResponse{Error: apiErr}
+	if !c.Writer.Written() {
+		c.AbortWithStatusJSON(status, body)
+		return
+	}
+	c.Abort()
+	data, err := json.Marshal(body)
+	if err != nil {
+		return
+	}
+	_, _ = c.Writer.Write(data)
+}
+
 func StatusErrorMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Next()
 
+		api := strings.HasPrefix(c.Request.URL.Path, apiPrefix)
 		err := c.Errors.Last()
 		if err == nil {
+			if status := c.Writer.Status(); api && status >= http.StatusBadRequest && c.Writer.Size() <= 0 {
+				writeAPIError(c, status, models.APIError{Code: statusCode(status), Message: http.StatusText(status)})
+			}
 			return
 		}
 
-		if errors.Is(err, models.ErrNotFoundMetric) {
-			c.AbortWithStatus(http.StatusNotFound)
-			return
-		}
-		if errors.Is(err, models.ErrUnknownMetricType) {
-			c.AbortWithStatus(http.StatusBadRequest)
-			return
-		}
-		if errors.Is(err, models.ErrWrongMetricValue) {
-			c.AbortWithStatus(http.StatusBadRequest)
+		status, apiErr := lookupError(err)
+		if api {
+			writeAPIError(c, status, apiErr)
 			return
 		}
-		c.AbortWithStatus(http.StatusInternalServerError)
+		c.AbortWithStatus(status)
 	}
 }
@@ -1,10 +1,13 @@
 package middlewares
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/MxTrap/metrics/internal/server/models"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -44,6 +47,36 @@ func TestStatusErrorMiddleware(t *testing.T) {
 			err:            models.ErrWrongMetricValue,
 			expectedStatus: http.StatusBadRequest,
 		},
+		{
+			name:           "ErrUnauthorized",
+			err:            models.ErrUnauthorized,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "ErrForbidden",
+			err:            models.ErrForbidden,
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "ErrInvalidSignature",
+			err:            models.ErrInvalidSignature,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "ErrRateLimited",
+			err:            models.ErrRateLimited,
+			expectedStatus: http.StatusTooManyRequests,
+		},
+		{
+			name:           "ErrBatchTooLarge",
+			err:            models.ErrBatchTooLarge,
+			expectedStatus: http.StatusTooManyRequests,
+		},
+		{
+			name:           "ErrMetricQuota",
+			err:            models.ErrMetricQuota,
+			expectedStatus: http.StatusTooManyRequests,
+		},
 		{
 			name:           "Generic error",
 			err:            errors.New("unexpected error"),
@@ -82,3 +115,73 @@ func TestStatusErrorMiddleware_MultipleErrors(t *testing.T) {
 
 	assert.Equal(t, http.StatusNotFound, w.Code, "Status code should match last error (ErrNotFoundMetric)")
 }
+
+func TestStatusErrorMiddleware_API(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(StatusErrorMiddleware())
+	router.GET("/api/v2/missing", func(c *gin.Context) {
+		_ = c.Error(fmt.Errorf("cpu: %w", models.ErrNotFoundMetric))
+	})
+	router.GET("/api/v2/broken", func(c *gin.Context) {
+		_ = c.Error(errors.New("connection refused"))
+	})
+	router.GET("/api/v2/limited", func(c *gin.Context) {
+		c.AbortWithStatus(http.StatusTooManyRequests)
+	})
+	router.GET("/value/missing", func(c *gin.Context) {
+		_ = c.Error(models.ErrNotFoundMetric)
+	})
+
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+		expectedError  *models.APIError
+	}{
+		{
+			name:           "Known error",
+			path:           "/api/v2/missing",
+			expectedStatus: http.StatusNotFound,
+			expectedError:  &models.APIError{Code: "metric_not_found", Message: "cpu: metric not found"},
+		},
+		{
+			name:           "Unknown error hides details",
+			path:           "/api/v2/broken",
+			expectedStatus: http.StatusInternalServerError,
+			expectedError:  &models.APIError{Code: "internal_server_error", Message: "Internal Server Error"},
+		},
+		{
+			name:           "Status without error",
+			path:           "/api/v2/limited",
+			expectedStatus: http.StatusTooManyRequests,
+			expectedError:  &models.APIError{Code: "too_many_requests", Message: "Too Many Requests"},
+		},
+		{
+			name:           "Unknown route",
+			path:           "/api/v2/unknown",
+			expectedStatus: http.StatusNotFound,
+			expectedError:  &models.APIError{Code: "not_found", Message: "Not Found"},
+		},
+		{
+			name:           "Legacy route keeps empty body",
+			path:           "/value/missing",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			assert.Equal(t, tt.expectedStatus, w.Code, "Status code should match")
+			if tt.expectedError == nil {
+				assert.Empty(t, w.Body.String(), "legacy routes should not have an error body")
+				return
+			}
+			var body models.APIErrorResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(t, *tt.expectedError, body.Error)
+		})
+	}
+}
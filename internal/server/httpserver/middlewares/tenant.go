@@ -0,0 +1,21 @@
+package middlewares
+
+import (
+	"github.com/MxTrap/metrics/internal/server/models"
+	"github.com/gin-gonic/gin"
+	"strings"
+)
+
+func TenantMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requested := strings.TrimSpace(c.Request.Header.Get("X-Tenant-ID"))
+		tenant, err := models.ResolveTenant(c.Request.Context(), requested)
+		if err != nil {
+			_ = c.Error(err)
+			c.Abort()
+			return
+		}
+		c.Request = c.Request.WithContext(models.ContextWithTenant(c.Request.Context(), tenant))
+		c.Next()
+	}
+}
@@ -0,0 +1,58 @@
+package middlewares
+
+import (
+	"github.com/MxTrap/metrics/internal/server/models"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTenantMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name     string
+		header   string
+		token    *models.Token
+		status   int
+		expected string
+	}{
+		{name: "NoHeader", status: http.StatusOK, expected: models.DefaultTenant},
+		{name: "Header", header: "team-a", status: http.StatusOK, expected: "team-a"},
+		{name: "TokenTenant", token: &models.Token{ID: "b", Tenant: "team-b"}, status: http.StatusOK, expected: "team-b"},
+		{name: "TokenTenantHeader", header: "team-b", token: &models.Token{ID: "b", Tenant: "team-b"}, status: http.StatusOK, expected: "team-b"},
+		{name: "TokenTenantConflict", header: "team-a", token: &models.Token{ID: "b", Tenant: "team-b"}, status: http.StatusForbidden},
+		{name: "TokenWithoutTenant", token: &models.Token{ID: "any"}, status: http.StatusOK, expected: models.DefaultTenant},
+		{name: "TokenWithoutTenantConflict", header: "team-a", token: &models.Token{ID: "any"}, status: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(StatusErrorMiddleware())
+			token := tt.token
+			router.Use(func(c *gin.Context) {
+				if token != nil {
+					c.Request = c.Request.WithContext(models.ContextWithToken(c.Request.Context(), *token))
+				}
+			})
+			router.Use(TenantMiddleware())
+			router.GET("/", func(c *gin.Context) {
+				c.String(http.StatusOK, models.TenantFromContext(c.Request.Context()))
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set("X-Tenant-ID", tt.header)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			if tt.status == http.StatusOK {
+				assert.Equal(t, tt.expected, w.Body.String())
+			}
+		})
+	}
+}
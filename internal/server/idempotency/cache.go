@@ -0,0 +1,107 @@
+// Package idempotency не даёт применить повторно отправленный агентом пакет метрик.
+// Агент помечает каждый пакет идентификатором и при повторной отправке из буфера передаёт тот же идентификатор,
+// поэтому счётчики из пакета, уже применённого до обрыва связи, не удваиваются.
+// Идентификаторы хранятся в памяти в течение окна и теряются при перезапуске сервера.
+package idempotency
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrDuplicate  = errors.New("batch already applied")
+	ErrInProgress = errors.New("batch is being applied")
+)
+
+type entry struct {
+	key     string
+	expires time.Time
+}
+
+// Cache хранит идентификаторы применённых и применяемых пакетов.
+// Число идентификаторов ограничено: при переполнении вытесняются самые старые.
+type Cache struct {
+	mx       sync.Mutex
+	ttl      time.Duration
+	capacity int
+	// applied — true для применённого пакета, false для пакета, который применяется сейчас
+	applied map[string]bool
+	queue   []entry
+	now     func() time.Time
+}
+
+// NewCache создаёт Cache, помнящий пакеты в течение ttl, но не более capacity штук.
+func NewCache(ttl time.Duration, capacity int) *Cache {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &Cache{
+		ttl:      ttl,
+		capacity: capacity,
+		applied:  make(map[string]bool, capacity),
+		now:      time.Now,
+	}
+}
+
+// Begin резервирует пакет key перед применением.
+// Возвращает ErrDuplicate, если пакет уже применён, и ErrInProgress, если он применяется параллельным запросом.
+// После успешного Begin нужно вызвать Commit или Release.
+func (c *Cache) Begin(key string) error {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	c.evict(c.now())
+	if applied, ok := c.applied[key]; ok {
+		if applied {
+			return ErrDuplicate
+		}
+		return ErrInProgress
+	}
+	c.applied[key] = false
+	return nil
+}
+
+// Commit отмечает пакет key применённым, повторы будут отклоняться в течение ttl.
+func (c *Cache) Commit(key string) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if applied, ok := c.applied[key]; !ok || applied {
+		return
+	}
+	if len(c.queue) >= c.capacity {
+		c.pop()
+	}
+	c.applied[key] = true
+	c.queue = append(c.queue, entry{key: key, expires: c.now().Add(c.ttl)})
+}
+
+// Release снимает резервирование с пакета, который не удалось применить, чтобы его можно было отправить повторно.
+func (c *Cache) Release(key string) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if applied, ok := c.applied[key]; ok && !applied {
+		delete(c.applied, key)
+	}
+}
+
+// evict удаляет пакеты, окно повтора которых истекло.
+func (c *Cache) evict(now time.Time) {
+	for len(c.queue) > 0 && now.After(c.queue[0].expires) {
+		c.pop()
+	}
+}
+
+func (c *Cache) pop() {
+	delete(c.applied, c.queue[0].key)
+	c.queue[0] = entry{}
+	c.queue = c.queue[1:]
+}
+
+// Key возвращает ключ пакета batchID, отправленного от имени tenant.
+func Key(tenant, batchID string) string {
+	return tenant + "\x00" + batchID
+}
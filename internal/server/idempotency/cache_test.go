@@ -0,0 +1,47 @@
+package idempotency
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestBeginCommit(t *testing.T) {
+	c := NewCache(time.Minute, 100)
+	now := time.Unix(1700000000, 0)
+	c.now = func() time.Time { return now }
+
+	assert.NoError(t, c.Begin("a"))
+	assert.ErrorIs(t, c.Begin("a"), ErrInProgress)
+	c.Commit("a")
+	assert.ErrorIs(t, c.Begin("a"), ErrDuplicate)
+
+	now = now.Add(2 * time.Minute)
+	assert.NoError(t, c.Begin("a"), "batch should be accepted again after ttl")
+}
+
+func TestRelease(t *testing.T) {
+	c := NewCache(time.Minute, 100)
+
+	assert.NoError(t, c.Begin("a"))
+	c.Release("a")
+	assert.NoError(t, c.Begin("a"), "released batch can be retried")
+	c.Commit("a")
+	c.Release("a")
+	assert.ErrorIs(t, c.Begin("a"), ErrDuplicate, "release must not forget applied batch")
+}
+
+func TestCapacity(t *testing.T) {
+	c := NewCache(time.Hour, 2)
+
+	for _, key := range []string{"a", "b", "c"} {
+		assert.NoError(t, c.Begin(key))
+		c.Commit(key)
+	}
+	assert.NoError(t, c.Begin("a"), "oldest batch should be evicted")
+	assert.ErrorIs(t, c.Begin("c"), ErrDuplicate)
+}
+
+func TestKey(t *testing.T) {
+	assert.NotEqual(t, Key("a", "1"), Key("b", "1"), "batches of different tenants must not collide")
+}
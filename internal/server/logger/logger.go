@@ -52,3 +52,21 @@ func (l *Logger) LoggerInterceptor(
 
 	return resp, err
 }
+
+func (l *Logger) StreamLoggerInterceptor(
+	srv any,
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	duration := time.Since(start)
+	l.Logger.Infoln(
+		"full method", info.FullMethod,
+		"duration", duration,
+		"err", err,
+	)
+
+	return err
+}
@@ -141,3 +141,19 @@ func TestLoggerInterceptorSuccess(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "response", resp)
 }
+
+func TestStreamLoggerInterceptor(t *testing.T) {
+	logger := &Logger{Logger: *zap.NewExample().Sugar()}
+
+	called := false
+	mockHandler := func(srv any, stream grpc.ServerStream) error {
+		called = true
+		return nil
+	}
+
+	info := &grpc.StreamServerInfo{FullMethod: "/service/TestStream", IsClientStream: true, IsServerStream: true}
+	err := logger.StreamLoggerInterceptor(nil, nil, info, mockHandler)
+
+	assert.NoError(t, err)
+	assert.True(t, called)
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	commonmodels "github.com/MxTrap/metrics/internal/common/models"
+	"time"
+)
+
+// Транспорты, через которые агенты отправляют данные.
+const (
+	AgentTransportHTTP       = "http"
+	AgentTransportGRPC       = "grpc"
+	AgentTransportGRPCStream = "grpc-stream"
+)
+
+// AgentRecord — запись реестра агентов: сведения агента и статистика его запросов.
+// Silent означает, что агент не присылал данных дольше допустимого интервала.
+type AgentRecord struct {
+	commonmodels.AgentInfo
+	Tenant    string    `json:"tenant"`
+	Address   string    `json:"address,omitempty"`
+	Transport string    `json:"transport"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Requests  int64     `json:"requests"`
+	Errors    int64     `json:"errors"`
+	LastError string    `json:"last_error,omitempty"`
+	Silent    bool      `json:"silent"`
+}
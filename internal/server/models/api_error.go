@@ -0,0 +1,12 @@
+package models
+
+// APIError описывает ошибку API v2: машиночитаемый код и сообщение для человека.
+type APIError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// APIErrorResponse — тело ответа API v2 с ошибкой.
+type APIErrorResponse struct {
+	Error APIError `json:"error"`
+}
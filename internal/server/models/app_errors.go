@@ -6,4 +6,15 @@ var (
 	ErrUnknownMetricType = errors.New("unknown metric type")
 	ErrNotFoundMetric    = errors.New("metric not found")
 	ErrWrongMetricValue  = errors.New("wrong metric value")
+	ErrUnauthorized      = errors.New("unauthorized")
+	ErrForbidden         = errors.New("forbidden")
+	ErrRateLimited       = errors.New("rate limit exceeded")
+	ErrBatchTooLarge     = errors.New("too many metrics in batch")
+	ErrMetricQuota       = errors.New("distinct metric names quota exceeded")
+	ErrInvalidSignature  = errors.New("invalid request signature")
+	ErrBatchInProgress   = errors.New("batch with the same id is being applied")
+	ErrBatchApplied      = errors.New("batch with the same id already applied")
+	ErrNoAgentConfig     = errors.New("agent config not found")
+	ErrMissingAgentID    = errors.New("agent id is required")
+	ErrInvalidRequest    = errors.New("invalid request")
 )
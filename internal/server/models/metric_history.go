@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// HistoryPoint — точка истории метрики: время обновления и значение.
+// Для метрик типа gauge Value содержит значение, для метрик типа counter — принятое приращение.
+type HistoryPoint struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+}
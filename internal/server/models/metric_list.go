@@ -0,0 +1,174 @@
+package models
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	commonmodels "github.com/MxTrap/metrics/internal/common/models"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Порядок сортировки списка метрик. Префикс "-" означает сортировку по убыванию.
+const (
+	SortByName        = "name"
+	SortByNameDesc    = "-name"
+	SortByUpdated     = "updated"
+	SortByUpdatedDesc = "-updated"
+)
+
+const (
+	// DefaultMetricPageSize — размер страницы списка метрик, если он не задан в запросе.
+	DefaultMetricPageSize = 100
+	// MaxMetricPageSize — наибольший допустимый размер страницы списка метрик.
+	MaxMetricPageSize = 1000
+)
+
+// MetricRecord — метрика вместе со временем её последнего обновления.
+type MetricRecord struct {
+	ID        string    `json:"id"`
+	MType     string    `json:"type"`
+	Delta     *int64    `json:"delta,omitempty"`
+	Value     *float64  `json:"value,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// NewMetricRecord создаёт MetricRecord из метрики и времени её обновления.
+func NewMetricRecord(metric commonmodels.Metric, updatedAt time.Time) MetricRecord {
+	return MetricRecord{
+		ID:        metric.ID,
+		MType:     metric.MType,
+		Delta:     metric.Delta,
+		Value:     metric.Value,
+		UpdatedAt: updatedAt,
+	}
+}
+
+// Metric возвращает метрику записи без времени обновления.
+func (r MetricRecord) Metric() commonmodels.Metric {
+	return commonmodels.Metric{
+		ID:    r.ID,
+		MType: r.MType,
+		Delta: r.Delta,
+		Value: r.Value,
+	}
+}
+
+// MetricCursor указывает на последнюю метрику страницы; следующая страница начинается после неё.
+type MetricCursor struct {
+	Name      string    `json:"n"`
+	UpdatedAt time.Time `json:"u"`
+}
+
+// Encode возвращает непрозрачное строковое представление курсора для передачи клиенту.
+func (c MetricCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeMetricCursor разбирает курсор, полученный от клиента.
+// Возвращает nil для пустой строки и ErrInvalidRequest для повреждённого курсора.
+func DecodeMetricCursor(s string) (*MetricCursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidRequest)
+	}
+	c := &MetricCursor{}
+	if err = json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidRequest)
+	}
+	return c, nil
+}
+
+// MetricFilter задаёт условия выборки метрик, порядок сортировки и страницу списка.
+// Пустые поля условий не ограничивают выборку.
+type MetricFilter struct {
+	Type         string
+	NamePrefix   string
+	NamePattern  string
+	UpdatedSince time.Time
+	Sort         string
+	After        *MetricCursor
+	Limit        int
+}
+
+// Matcher проверяет условия выборки фильтра и возвращает функцию, сообщающую, удовлетворяет ли им метрика.
+// Возвращает ErrUnknownMetricType при неизвестном типе и ErrInvalidRequest при некорректном регулярном выражении имени.
+func (f MetricFilter) Matcher() (func(r MetricRecord) bool, error) {
+	if f.Type != "" && !MetricTypes[f.Type] {
+		return nil, ErrUnknownMetricType
+	}
+	var pattern *regexp.Regexp
+	if f.NamePattern != "" {
+		var err error
+		if pattern, err = regexp.Compile(f.NamePattern); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+		}
+	}
+	return func(r MetricRecord) bool {
+		return (f.Type == "" || r.MType == f.Type) &&
+			strings.HasPrefix(r.ID, f.NamePrefix) &&
+			(pattern == nil || pattern.MatchString(r.ID)) &&
+			!r.UpdatedAt.Before(f.UpdatedSince)
+	}, nil
+}
+
+// Descending сообщает, что метрики сортируются по убыванию.
+func (f MetricFilter) Descending() bool {
+	return strings.HasPrefix(f.Sort, "-")
+}
+
+// ByUpdated сообщает, что метрики сортируются по времени обновления, а при равенстве — по имени.
+func (f MetricFilter) ByUpdated() bool {
+	return strings.TrimPrefix(f.Sort, "-") == SortByUpdated
+}
+
+// Compare сравнивает метрики в порядке сортировки фильтра.
+func (f MetricFilter) Compare(a, b MetricRecord) int {
+	c := 0
+	if f.ByUpdated() {
+		c = a.UpdatedAt.Compare(b.UpdatedAt)
+	}
+	if c == 0 {
+		c = cmp.Compare(a.ID, b.ID)
+	}
+	if f.Descending() {
+		return -c
+	}
+	return c
+}
+
+// MetricPage — страница списка метрик.
+// NextCursor передаётся в следующем запросе для получения следующей страницы и пуст на последней странице.
+type MetricPage struct {
+	Metrics    []MetricRecord `json:"metrics"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+// NewMetricPage собирает страницу из отсортированных метрик, следующих за курсором фильтра.
+// Хранилища выбирают на одну метрику больше размера страницы, чтобы узнать, есть ли следующая.
+func NewMetricPage(records []MetricRecord, limit int) MetricPage {
+	page := MetricPage{Metrics: records}
+	if limit > 0 && len(records) > limit {
+		page.Metrics = records[:limit]
+		last := page.Metrics[limit-1]
+		page.NextCursor = MetricCursor{Name: last.ID, UpdatedAt: last.UpdatedAt}.Encode()
+	}
+	if page.Metrics == nil {
+		page.Metrics = []MetricRecord{}
+	}
+	return page
+}
+
+// MetricUpdate — принятое сервером обновление метрики, доставляемое подписчикам.
+// Для метрик типа counter Delta содержит принятое приращение, а не накопленное значение.
+// Dropped — число обновлений, пропущенных подписчиком перед этим из-за переполнения его буфера.
+type MetricUpdate struct {
+	MetricRecord
+	Dropped uint64 `json:"dropped,omitempty"`
+}
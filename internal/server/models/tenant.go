@@ -0,0 +1,49 @@
+package models
+
+import (
+	"context"
+	commonmodels "github.com/MxTrap/metrics/internal/common/models"
+)
+
+// DefaultTenant — арендатор, к которому относятся метрики запросов без явного арендатора.
+const DefaultTenant = "default"
+
+// TenantMetrics содержит метрики всех арендаторов: арендатор -> имя метрики -> метрика.
+type TenantMetrics map[string]map[string]commonmodels.Metric
+
+type tenantCtxKey struct{}
+
+// ContextWithTenant возвращает копию контекста с идентификатором арендатора.
+func ContextWithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantCtxKey{}, tenant)
+}
+
+// TenantFromContext возвращает арендатора запроса или DefaultTenant, если он не задан.
+func TenantFromContext(ctx context.Context) string {
+	tenant, ok := ctx.Value(tenantCtxKey{}).(string)
+	if !ok || tenant == "" {
+		return DefaultTenant
+	}
+	return tenant
+}
+
+// ResolveTenant определяет арендатора запроса.
+// Для аутентифицированного запроса арендатор задаётся только токеном: токен без арендатора
+// относится к DefaultTenant, а переданный в заголовке другой арендатор отклоняется с ErrForbidden.
+// Выбор арендатора заголовком допускается только при отключённой аутентификации.
+func ResolveTenant(ctx context.Context, requested string) (string, error) {
+	if token, ok := TokenFromContext(ctx); ok {
+		tenant := token.Tenant
+		if tenant == "" {
+			tenant = DefaultTenant
+		}
+		if requested != "" && requested != tenant {
+			return "", ErrForbidden
+		}
+		return tenant, nil
+	}
+	if requested != "" {
+		return requested, nil
+	}
+	return DefaultTenant, nil
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"strings"
+)
+
+// Permission определяет действие, разрешённое API-токену.
+type Permission string
+
+const (
+	PermissionRead  Permission = "read"
+	PermissionWrite Permission = "write"
+	PermissionAdmin Permission = "admin"
+)
+
+// Token описывает API-токен: его идентификатор, хэш секрета, набор разрешений,
+// префикс имён метрик, которыми токен может оперировать, и арендатора.
+type Token struct {
+	ID          string       `json:"id"`
+	Hash        string       `json:"-"`
+	Permissions []Permission `json:"permissions"`
+	Prefix      string       `json:"prefix"`
+	Tenant      string       `json:"tenant,omitempty"`
+}
+
+// Has сообщает, выдано ли токену разрешение. Разрешение admin включает все остальные.
+func (t Token) Has(p Permission) bool {
+	return slices.Contains(t.Permissions, p) || slices.Contains(t.Permissions, PermissionAdmin)
+}
+
+// Allows сообщает, может ли токен выполнить действие над метрикой с указанным именем.
+func (t Token) Allows(p Permission, metricName string) bool {
+	return t.Has(p) && strings.HasPrefix(metricName, t.Prefix)
+}
+
+// HashSecret возвращает хэш секрета токена в том виде, в котором он хранится.
+func HashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+type tokenCtxKey struct{}
+
+// ContextWithToken возвращает копию контекста с аутентифицированным токеном.
+func ContextWithToken(ctx context.Context, token Token) context.Context {
+	return context.WithValue(ctx, tokenCtxKey{}, token)
+}
+
+// TokenFromContext извлекает токен из контекста. Второе значение равно false,
+// если запрос не проходил аутентификацию.
+func TokenFromContext(ctx context.Context) (Token, bool) {
+	token, ok := ctx.Value(tokenCtxKey{}).(Token)
+	return token, ok
+}
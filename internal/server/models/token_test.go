@@ -0,0 +1,47 @@
+package models
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenHas(t *testing.T) {
+	reader := Token{Permissions: []Permission{PermissionRead}}
+	admin := Token{Permissions: []Permission{PermissionAdmin}}
+
+	assert.True(t, reader.Has(PermissionRead))
+	assert.False(t, reader.Has(PermissionWrite))
+	assert.False(t, reader.Has(PermissionAdmin))
+	assert.True(t, admin.Has(PermissionRead))
+	assert.True(t, admin.Has(PermissionWrite))
+}
+
+func TestTokenAllows(t *testing.T) {
+	token := Token{Permissions: []Permission{PermissionWrite}, Prefix: "app_"}
+
+	assert.True(t, token.Allows(PermissionWrite, "app_requests"))
+	assert.False(t, token.Allows(PermissionWrite, "Alloc"))
+	assert.False(t, token.Allows(PermissionRead, "app_requests"))
+
+	unscoped := Token{Permissions: []Permission{PermissionRead}}
+	assert.True(t, unscoped.Allows(PermissionRead, "Alloc"))
+}
+
+func TestHashSecret(t *testing.T) {
+	sum := sha256.Sum256([]byte("secret"))
+	assert.Equal(t, hex.EncodeToString(sum[:]), HashSecret("secret"))
+}
+
+func TestTokenContext(t *testing.T) {
+	_, ok := TokenFromContext(context.Background())
+	assert.False(t, ok, "empty context should not contain token")
+
+	token := Token{ID: "agent", Permissions: []Permission{PermissionWrite}}
+	got, ok := TokenFromContext(ContextWithToken(context.Background(), token))
+	assert.True(t, ok)
+	assert.Equal(t, token, got)
+}
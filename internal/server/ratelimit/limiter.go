@@ -0,0 +1,103 @@
+// Package ratelimit ограничивает частоту запросов клиентов алгоритмом token bucket.
+// Для каждого ключа (клиента и маршрута) ведётся отдельное ведро.
+package ratelimit
+
+import (
+	"context"
+	"github.com/MxTrap/metrics/internal/server/clientip"
+	"github.com/MxTrap/metrics/internal/server/models"
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepEvery — число вызовов Allow между очистками простаивающих вёдер.
+const sweepEvery = 1024
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter выдаёт разрешения на запросы: не более rate в секунду с запасом burst на каждый ключ.
+type Limiter struct {
+	mx      sync.Mutex
+	rate    float64
+	burst   float64
+	buckets map[string]*bucket
+	calls   int
+	now     func() time.Time
+}
+
+// NewLimiter создаёт Limiter с указанной скоростью пополнения (запросов в секунду) и размером ведра.
+// Если burst меньше единицы, используется значение 1.
+func NewLimiter(rate float64, burst int) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &Limiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: map[string]*bucket{},
+		now:     time.Now,
+	}
+}
+
+// Allow расходует один токен из ведра ключа.
+// Возвращает true, если запрос разрешён, иначе false и время до появления следующего токена.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	now := l.now()
+	l.calls++
+	if l.calls%sweepEvery == 0 {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	return false, wait
+}
+
+// Interval возвращает время пополнения одного токена — минимальный устойчивый интервал между запросами клиента.
+func (l *Limiter) Interval() time.Duration {
+	return time.Duration(float64(time.Second) / l.rate)
+}
+
+// sweep удаляет вёдра, которые успели заполниться полностью: они эквивалентны новым.
+func (l *Limiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// ClientKey возвращает идентификатор клиента запроса: идентификатор токена, если запрос аутентифицирован,
+// иначе IP-адрес клиента. Возвращает пустую строку, если клиента определить не удалось.
+func ClientKey(ctx context.Context) string {
+	if token, ok := models.TokenFromContext(ctx); ok {
+		return "token:" + token.ID
+	}
+	if ip, ok := clientip.FromContext(ctx); ok {
+		return "ip:" + ip.String()
+	}
+	return ""
+}
+
+// RetryAfterSeconds округляет время ожидания вверх до целых секунд, как того требует заголовок Retry-After.
+func RetryAfterSeconds(wait time.Duration) int {
+	return max(1, int(math.Ceil(wait.Seconds())))
+}
@@ -0,0 +1,76 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/MxTrap/metrics/internal/server/clientip"
+	"github.com/MxTrap/metrics/internal/server/models"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestAllow(t *testing.T) {
+	l := NewLimiter(2, 3)
+	now := time.Unix(1700000000, 0)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		ok, _ := l.Allow("client")
+		assert.True(t, ok, "burst requests should be allowed")
+	}
+	ok, wait := l.Allow("client")
+	assert.False(t, ok, "request above burst should be rejected")
+	assert.Equal(t, 500*time.Millisecond, wait)
+
+	ok, _ = l.Allow("other")
+	assert.True(t, ok, "buckets are independent per key")
+
+	now = now.Add(500 * time.Millisecond)
+	ok, _ = l.Allow("client")
+	assert.True(t, ok, "token should be refilled")
+	ok, _ = l.Allow("client")
+	assert.False(t, ok)
+
+	now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		ok, _ = l.Allow("client")
+		assert.True(t, ok, "bucket should not exceed burst")
+	}
+	ok, _ = l.Allow("client")
+	assert.False(t, ok)
+}
+
+func TestSweep(t *testing.T) {
+	l := NewLimiter(1, 1)
+	now := time.Unix(1700000000, 0)
+	l.now = func() time.Time { return now }
+	for i := 0; i < sweepEvery-1; i++ {
+		l.Allow(strconv.Itoa(i))
+	}
+	now = now.Add(time.Minute)
+	l.Allow("last")
+	assert.Len(t, l.buckets, 1, "idle buckets should be evicted")
+}
+
+func TestClientKey(t *testing.T) {
+	assert.Equal(t, "", ClientKey(context.Background()))
+
+	ctx := clientip.ContextWithIP(context.Background(), net.ParseIP("192.168.1.10"))
+	assert.Equal(t, "ip:192.168.1.10", ClientKey(ctx))
+
+	ctx = models.ContextWithToken(ctx, models.Token{ID: "agent"})
+	assert.Equal(t, "token:agent", ClientKey(ctx))
+}
+
+func TestRetryAfterSeconds(t *testing.T) {
+	assert.Equal(t, 1, RetryAfterSeconds(0))
+	assert.Equal(t, 1, RetryAfterSeconds(200*time.Millisecond))
+	assert.Equal(t, 3, RetryAfterSeconds(2100*time.Millisecond))
+}
+
+func TestInterval(t *testing.T) {
+	assert.Equal(t, 500*time.Millisecond, NewLimiter(2, 1).Interval())
+	assert.Equal(t, 10*time.Second, NewLimiter(0.1, 1).Interval())
+}
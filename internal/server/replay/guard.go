@@ -0,0 +1,119 @@
+// Package replay защищает сервер от повторной отправки перехваченных подписанных запросов.
+// Запрос принимается, только если его метка времени попадает в допустимое окно,
+// а nonce ещё не встречался в течение этого окна.
+package replay
+
+import (
+	"container/heap"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	ErrMissingNonce = errors.New("missing timestamp or nonce")
+	ErrStale        = errors.New("request timestamp outside of allowed window")
+	ErrReplayed     = errors.New("nonce already used")
+	ErrCacheFull    = errors.New("too many recent nonces")
+)
+
+type entry struct {
+	nonce   string
+	expires time.Time
+}
+
+// expiryHeap — куча nonce, упорядоченная по времени истечения.
+type expiryHeap []entry
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].expires.Before(h[j].expires) }
+func (h expiryHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *expiryHeap) Push(x any)        { *h = append(*h, x.(entry)) }
+func (h *expiryHeap) Pop() any {
+	old := *h
+	e := old[len(old)-1]
+	old[len(old)-1] = entry{}
+	*h = old[:len(old)-1]
+	return e
+}
+
+// Guard проверяет метки времени и хранит недавно использованные nonce.
+// Число хранимых nonce ограничено. Nonce удаляются только после истечения, поэтому
+// при кэше, заполненном действующими nonce, новые запросы отклоняются с ErrCacheFull,
+// а не вытесняют nonce, повтор которых ещё может быть принят.
+type Guard struct {
+	mx       sync.Mutex
+	window   time.Duration
+	capacity int
+	seen     map[string]time.Time
+	expiries expiryHeap
+	now      func() time.Time
+}
+
+// NewGuard создаёт Guard с окном допустимого расхождения часов window и ёмкостью кэша nonce capacity.
+func NewGuard(window time.Duration, capacity int) *Guard {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &Guard{
+		window:   window,
+		capacity: capacity,
+		seen:     make(map[string]time.Time, capacity),
+		now:      time.Now,
+	}
+}
+
+// Check проверяет метку времени (Unix-секунды) и nonce запроса и запоминает nonce.
+// Возвращает ErrMissingNonce, ErrStale или ErrReplayed, если запрос нужно отклонить,
+// и ErrCacheFull, если запрос можно повторить, когда истечёт самый старый nonce (см. RetryAfter).
+func (g *Guard) Check(timestamp string, nonce string) error {
+	if timestamp == "" || nonce == "" {
+		return ErrMissingNonce
+	}
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrStale
+	}
+
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	now := g.now()
+	skew := now.Sub(time.Unix(sec, 0))
+	if skew > g.window || skew < -g.window {
+		return ErrStale
+	}
+
+	g.evict(now)
+	if _, ok := g.seen[nonce]; ok {
+		return ErrReplayed
+	}
+	if len(g.expiries) >= g.capacity {
+		return ErrCacheFull
+	}
+	// Запрос с этим nonce может прийти, пока его метка времени остаётся в окне.
+	expires := time.Unix(sec, 0).Add(g.window)
+	g.seen[nonce] = expires
+	heap.Push(&g.expiries, entry{nonce: nonce, expires: expires})
+	return nil
+}
+
+// RetryAfter возвращает время до истечения ближайшего nonce, после которого в кэше освободится место.
+func (g *Guard) RetryAfter() time.Duration {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	if len(g.expiries) == 0 {
+		return 0
+	}
+	return max(0, g.expiries[0].expires.Sub(g.now()))
+}
+
+// evict удаляет nonce, повтор которых будет отклонён проверкой метки времени.
+func (g *Guard) evict(now time.Time) {
+	for len(g.expiries) > 0 && now.After(g.expiries[0].expires) {
+		e := heap.Pop(&g.expiries).(entry)
+		delete(g.seen, e.nonce)
+	}
+}
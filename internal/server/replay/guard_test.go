@@ -0,0 +1,61 @@
+package replay
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func ts(t time.Time) string {
+	return strconv.FormatInt(t.Unix(), 10)
+}
+
+func TestCheck(t *testing.T) {
+	g := NewGuard(time.Minute, 100)
+	now := time.Unix(1700000000, 0)
+	g.now = func() time.Time { return now }
+
+	assert.NoError(t, g.Check(ts(now), "a"))
+	assert.ErrorIs(t, g.Check(ts(now), "a"), ErrReplayed)
+	assert.NoError(t, g.Check(ts(now.Add(-30*time.Second)), "b"), "skew inside window is allowed")
+	assert.NoError(t, g.Check(ts(now.Add(30*time.Second)), "c"), "clock ahead inside window is allowed")
+
+	assert.ErrorIs(t, g.Check(ts(now.Add(-2*time.Minute)), "d"), ErrStale)
+	assert.ErrorIs(t, g.Check(ts(now.Add(2*time.Minute)), "e"), ErrStale)
+	assert.ErrorIs(t, g.Check("not-a-number", "f"), ErrStale)
+	assert.ErrorIs(t, g.Check("", "g"), ErrMissingNonce)
+	assert.ErrorIs(t, g.Check(ts(now), ""), ErrMissingNonce)
+}
+
+func TestCheckEviction(t *testing.T) {
+	g := NewGuard(time.Minute, 2)
+	now := time.Unix(1700000000, 0)
+	g.now = func() time.Time { return now }
+
+	assert.NoError(t, g.Check(ts(now), "a"))
+	assert.NoError(t, g.Check(ts(now), "b"))
+	assert.ErrorIs(t, g.Check(ts(now), "c"), ErrCacheFull, "live nonces must not be evicted")
+	assert.Len(t, g.seen, 2, "cache should stay bounded")
+	assert.ErrorIs(t, g.Check(ts(now), "a"), ErrReplayed)
+	assert.Equal(t, time.Minute, g.RetryAfter())
+
+	sent := now
+	now = now.Add(2 * time.Minute)
+	assert.NoError(t, g.Check(ts(now), "c"))
+	assert.Len(t, g.seen, 1, "expired nonces should be evicted")
+	assert.ErrorIs(t, g.Check(ts(sent), "a"), ErrStale, "expired nonce is still rejected by timestamp")
+}
+
+func TestCheckEvictionByExpiry(t *testing.T) {
+	g := NewGuard(time.Minute, 2)
+	now := time.Unix(1700000000, 0)
+	g.now = func() time.Time { return now }
+
+	assert.NoError(t, g.Check(ts(now), "late"))
+	assert.NoError(t, g.Check(ts(now.Add(-50*time.Second)), "early"), "older timestamp arrives second")
+
+	now = now.Add(20 * time.Second)
+	assert.NoError(t, g.Check(ts(now), "next"), "nonce with the earliest expiry should be evicted")
+	assert.ErrorIs(t, g.Check(ts(now), "late"), ErrReplayed, "nonce inserted first is still live")
+}
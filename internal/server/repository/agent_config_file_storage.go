@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/MxTrap/metrics/internal/common/models"
+	servermodels "github.com/MxTrap/metrics/internal/server/models"
+	"os"
+	"sync"
+	"time"
+)
+
+// AgentConfigFileStorage раздаёт настройки агентов из JSON-файла.
+// Файл перечитывается при изменении, поэтому новые настройки доходят до агентов без перезапуска сервера.
+// Для агента выбираются настройки по его идентификатору, затем по группе, затем настройки по умолчанию.
+type AgentConfigFileStorage struct {
+	path    string
+	mx      sync.Mutex
+	modTime time.Time
+	content agentConfigFile
+}
+
+type agentConfigFile struct {
+	Default *models.AgentConfig           `json:"default"`
+	Groups  map[string]models.AgentConfig `json:"groups"`
+	Agents  map[string]models.AgentConfig `json:"agents"`
+}
+
+// NewAgentConfigFileStorage читает файл настроек агентов по указанному пути.
+// Возвращает ошибку, если файл не удалось прочитать или разобрать.
+func NewAgentConfigFileStorage(filePath string) (*AgentConfigFileStorage, error) {
+	s := &AgentConfigFileStorage{path: filePath}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Find возвращает настройки агента с указанным идентификатором и группой.
+// Возвращает models.ErrNoAgentConfig, если для агента настройки не заданы.
+func (s *AgentConfigFileStorage) Find(_ context.Context, agentID, group string) (models.AgentConfig, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if err := s.reload(); err != nil {
+		return models.AgentConfig{}, err
+	}
+
+	if cfg, ok := s.content.Agents[agentID]; ok {
+		return cfg, nil
+	}
+	if cfg, ok := s.content.Groups[group]; ok && group != "" {
+		return cfg, nil
+	}
+	if s.content.Default != nil {
+		return *s.content.Default, nil
+	}
+	return models.AgentConfig{}, servermodels.ErrNoAgentConfig
+}
+
+// reload перечитывает файл, если он изменился с последнего чтения.
+func (s *AgentConfigFileStorage) reload() error {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return err
+	}
+	if info.ModTime().Equal(s.modTime) {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	var content agentConfigFile
+	if err = json.Unmarshal(data, &content); err != nil {
+		return err
+	}
+	if content.Default != nil {
+		cfg := withVersion(*content.Default)
+		content.Default = &cfg
+	}
+	for name, cfg := range content.Groups {
+		content.Groups[name] = withVersion(cfg)
+	}
+	for id, cfg := range content.Agents {
+		content.Agents[id] = withVersion(cfg)
+	}
+
+	s.content = content
+	s.modTime = info.ModTime()
+	return nil
+}
+
+// withVersion вычисляет версию настроек по их содержимому, если версия не указана в файле.
+func withVersion(cfg models.AgentConfig) models.AgentConfig {
+	if cfg.Version != "" {
+		return cfg
+	}
+	data, _ := json.Marshal(cfg)
+	sum := sha256.Sum256(data)
+	cfg.Version = hex.EncodeToString(sum[:6])
+	return cfg
+}
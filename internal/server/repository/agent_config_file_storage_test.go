@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/MxTrap/metrics/internal/server/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgentConfigFileStorage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agents.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"default": {"report_interval": 10},
+		"groups": {"edge": {"version": "edge-1", "poll_interval": 5, "collectors": ["runtime"]}},
+		"agents": {"host-1": {"report_interval": 30}}
+	}`), 0644))
+
+	storage, err := NewAgentConfigFileStorage(path)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	cfg, err := storage.Find(ctx, "host-1", "edge")
+	require.NoError(t, err)
+	assert.Equal(t, 30, cfg.ReportInterval, "agent settings take precedence over group")
+	assert.NotEmpty(t, cfg.Version, "version is derived from content when omitted")
+
+	cfg, err = storage.Find(ctx, "host-2", "edge")
+	require.NoError(t, err)
+	assert.Equal(t, "edge-1", cfg.Version)
+	assert.Equal(t, []string{"runtime"}, cfg.Collectors)
+
+	cfg, err = storage.Find(ctx, "host-3", "")
+	require.NoError(t, err)
+	assert.Equal(t, 10, cfg.ReportInterval)
+	defaultVersion := cfg.Version
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"default": {"report_interval": 20}}`), 0644))
+	later := time.Now().Add(time.Second)
+	require.NoError(t, os.Chtimes(path, later, later))
+
+	cfg, err = storage.Find(ctx, "host-3", "")
+	require.NoError(t, err)
+	assert.Equal(t, 20, cfg.ReportInterval, "changed file is reloaded")
+	assert.NotEqual(t, defaultVersion, cfg.Version)
+
+	_, err = storage.Find(ctx, "host-1", "edge")
+	require.NoError(t, err, "removed agent falls back to default")
+
+	require.NoError(t, os.WriteFile(path, []byte(`{}`), 0644))
+	require.NoError(t, os.Chtimes(path, later.Add(time.Second), later.Add(time.Second)))
+	_, err = storage.Find(ctx, "host-3", "")
+	assert.ErrorIs(t, err, models.ErrNoAgentConfig)
+}
+
+func TestNewAgentConfigFileStorageErrors(t *testing.T) {
+	_, err := NewAgentConfigFileStorage(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err, "missing file should fail")
+
+	path := filepath.Join(t.TempDir(), "agents.json")
+	require.NoError(t, os.WriteFile(path, []byte(`not json`), 0644))
+	_, err = NewAgentConfigFileStorage(path)
+	assert.Error(t, err, "invalid json should fail")
+}
@@ -6,9 +6,15 @@ import (
 	"bufio"
 	"encoding/json"
 	commonmodels "github.com/MxTrap/metrics/internal/common/models"
+	"github.com/MxTrap/metrics/internal/server/models"
 	"os"
 )
 
+// snapshot — формат файла с метриками, разделёнными по арендаторам.
+type snapshot struct {
+	Tenants models.TenantMetrics `json:"tenants"`
+}
+
 type MetricsFileStorage struct {
 	filePath string
 	file     *os.File
@@ -28,11 +34,11 @@ func NewMetricsFileStorage(filePath string) *MetricsFileStorage {
 	}
 }
 
-// Save сохраняет метрики в файл в формате JSON.
+// Save сохраняет метрики всех арендаторов в файл в формате JSON.
 // Очищает файл перед записью и записывает новые данные.
 // Возвращает ошибку при неудаче.
-func (s *MetricsFileStorage) Save(metrics map[string]commonmodels.Metric) error {
-	data, err := json.Marshal(metrics)
+func (s *MetricsFileStorage) Save(metrics models.TenantMetrics) error {
+	data, err := json.Marshal(snapshot{Tenants: metrics})
 
 	if err != nil {
 		return err
@@ -55,9 +61,10 @@ func (s *MetricsFileStorage) Save(metrics map[string]commonmodels.Metric) error
 }
 
 // Read считывает метрики из файла в формате JSON.
-// Возвращает карту метрик или пустую карту, если файл пуст.
+// Файлы старого формата без арендаторов загружаются в DefaultTenant.
+// Возвращает метрики по арендаторам или пустую карту, если файл пуст.
 // Возвращает ошибку при неудаче десериализации.
-func (s *MetricsFileStorage) Read() (map[string]commonmodels.Metric, error) {
+func (s *MetricsFileStorage) Read() (models.TenantMetrics, error) {
 	var data []byte
 	scanner := bufio.NewScanner(s.file)
 
@@ -66,15 +73,20 @@ func (s *MetricsFileStorage) Read() (map[string]commonmodels.Metric, error) {
 	}
 
 	if len(data) == 0 {
-		return map[string]commonmodels.Metric{}, nil
+		return models.TenantMetrics{}, nil
 	}
-	var res map[string]commonmodels.Metric
-	err := json.Unmarshal(data, &res)
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err == nil && snap.Tenants != nil {
+		return snap.Tenants, nil
+	}
+
+	var legacy map[string]commonmodels.Metric
+	err := json.Unmarshal(data, &legacy)
 	if err != nil {
 		return nil, err
 	}
 
-	return res, nil
+	return models.TenantMetrics{models.DefaultTenant: legacy}, nil
 }
 
 // Close закрывает файл хранилища.
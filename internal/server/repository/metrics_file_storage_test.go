@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/MxTrap/metrics/internal/common/models"
+	servermodels "github.com/MxTrap/metrics/internal/server/models"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -51,21 +52,21 @@ func TestMetricsFileStorage_Save(t *testing.T) {
 		"counter1": {ID: "counter1", MType: models.Counter, Delta: utils.MakePointer(int64(100))},
 	}
 
-	err := storage.Save(metrics)
+	err := storage.Save(servermodels.TenantMetrics{"team-a": metrics})
 	assert.NoError(t, err, "Save should not return error")
 
 	data, err := os.ReadFile(tmpFilePath)
 	assert.NoError(t, err, "Failed to read temp file")
-	var savedMetrics map[string]models.Metric
-	err = json.Unmarshal(data, &savedMetrics)
+	var saved snapshot
+	err = json.Unmarshal(data, &saved)
 	assert.NoError(t, err, "Failed to unmarshal saved data")
-	assert.Equal(t, metrics, savedMetrics, "Saved metrics should match input")
+	assert.Equal(t, servermodels.TenantMetrics{"team-a": metrics}, saved.Tenants, "Saved metrics should match input")
 
 	invalidMetrics := map[string]models.Metric{
 		"invalid": {ID: "invalid", MType: "unknown", Value: nil},
 	}
 
-	err = storage.Save(invalidMetrics)
+	err = storage.Save(servermodels.TenantMetrics{servermodels.DefaultTenant: invalidMetrics})
 	assert.NoError(t, err, "Save should handle invalid metrics gracefully")
 }
 
@@ -75,7 +76,7 @@ func TestMetricsFileStorage_Read(t *testing.T) {
 
 	result, err := storage.Read()
 	assert.NoError(t, err, "Read should not return error for empty file")
-	assert.Equal(t, map[string]models.Metric{}, result, "Read should return empty map for empty file")
+	assert.Equal(t, servermodels.TenantMetrics{}, result, "Read should return empty map for empty file")
 
 	metrics := map[string]models.Metric{
 		"gauge1":   {ID: "gauge1", MType: models.Gauge, Value: utils.MakePointer(42.5)},
@@ -92,7 +93,21 @@ func TestMetricsFileStorage_Read(t *testing.T) {
 
 	result, err = storage.Read()
 	assert.NoError(t, err, "Read should not return error")
-	assert.Equal(t, metrics, result, "Read should return correct metrics")
+	assert.Equal(t, servermodels.TenantMetrics{servermodels.DefaultTenant: metrics}, result, "Legacy file should be read into default tenant")
+
+	tenants := servermodels.TenantMetrics{"team-a": metrics, "team-b": {}}
+	data, err = json.Marshal(snapshot{Tenants: tenants})
+	assert.NoError(t, err, "Failed to marshal test snapshot")
+	err = os.WriteFile(tmpFilePath, data, os.ModePerm)
+	assert.NoError(t, err, "Failed to write test data to file")
+
+	storage.file.Close()
+	storage.file, err = os.OpenFile(tmpFilePath, os.O_RDWR, os.ModePerm)
+	assert.NoError(t, err, "Failed to reopen file")
+
+	result, err = storage.Read()
+	assert.NoError(t, err, "Read should not return error")
+	assert.Equal(t, tenants, result, "Read should return metrics per tenant")
 
 	storage.file.Close()
 	err = os.WriteFile(tmpFilePath, []byte("invalid json"), os.ModePerm)
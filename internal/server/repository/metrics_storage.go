@@ -6,18 +6,31 @@ import (
 	"context"
 	"errors"
 	"github.com/MxTrap/metrics/internal/common/models"
+	servermodels "github.com/MxTrap/metrics/internal/server/models"
+	"github.com/MxTrap/metrics/internal/utils"
 	"maps"
+	"slices"
+	"sort"
+	"sync"
+	"time"
 )
 
+// MemStorage хранит метрики в памяти, разделяя их по арендаторам.
+// Для каждой метрики запоминается время последнего обновления.
 type MemStorage struct {
-	metrics map[string]models.Metric
+	mx      sync.RWMutex
+	metrics servermodels.TenantMetrics
+	updated map[string]map[string]time.Time
+	now     func() time.Time
 }
 
 // NewMemStorage создаёт новое хранилище метрик в памяти.
 // Возвращает указатель на инициализированный MemStorage или ошибку.
 func NewMemStorage() (*MemStorage, error) {
 	return &MemStorage{
-		metrics: map[string]models.Metric{},
+		metrics: servermodels.TenantMetrics{},
+		updated: map[string]map[string]time.Time{},
+		now:     time.Now,
 	}, nil
 }
 
@@ -25,37 +38,118 @@ func (s *MemStorage) Ping(_ context.Context) error {
 	return errors.New("not implemented")
 }
 
-// Save сохраняет метрику в хранилище.
+// partition возвращает метрики арендатора и время их обновления, создавая раздел при необходимости.
+// Вызывающий должен удерживать блокировку на запись.
+func (s *MemStorage) partition(tenant string) (map[string]models.Metric, map[string]time.Time) {
+	p, ok := s.metrics[tenant]
+	if !ok {
+		p = map[string]models.Metric{}
+		s.metrics[tenant] = p
+	}
+	u, ok := s.updated[tenant]
+	if !ok {
+		u = map[string]time.Time{}
+		s.updated[tenant] = u
+	}
+	return p, u
+}
+
+// timestamp возвращает текущее время без показаний монотонных часов,
+// чтобы оно совпадало со временем, восстановленным из курсора.
+func (s *MemStorage) timestamp() time.Time {
+	return s.now().Round(0)
+}
+
+// Save сохраняет метрику арендатора в хранилище.
 // Для метрик типа Counter агрегирует значение Delta с существующей метрикой.
 // Возвращает ошибку при неудаче.
-func (s *MemStorage) Save(_ context.Context, metric models.Metric) error {
-	if val, ok := s.metrics[metric.ID]; ok && metric.MType == models.Counter {
+func (s *MemStorage) Save(_ context.Context, tenant string, metric models.Metric) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	p, u := s.partition(tenant)
+	if val, ok := p[metric.ID]; ok && metric.MType == models.Counter {
 		*(metric.Delta) = *(metric.Delta) + *(val.Delta)
 	}
-	s.metrics[metric.ID] = metric
+	p[metric.ID] = metric
+	u[metric.ID] = s.timestamp()
 	return nil
 }
 
-// Find получает метрику по её идентификатору.
+// Find получает метрику арендатора по её идентификатору.
 // Возвращает метрику или ошибку, если метрика не найдена.
-func (s *MemStorage) Find(_ context.Context, metric string) (models.Metric, error) {
-	value, ok := s.metrics[metric]
+func (s *MemStorage) Find(_ context.Context, tenant string, metric string) (models.Metric, error) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	value, ok := s.metrics[tenant][metric]
 	if !ok {
 		return models.Metric{}, errors.New("not found")
 	}
 	return value, nil
 }
 
-// GetAll возвращает все метрики из хранилища.
+// GetAll возвращает копию всех метрик арендатора.
 // Возвращает карту метрик или ошибку при неудаче.
-func (s *MemStorage) GetAll(_ context.Context) (map[string]models.Metric, error) {
-	return s.metrics, nil
+func (s *MemStorage) GetAll(_ context.Context, tenant string) (map[string]models.Metric, error) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	metrics := make(map[string]models.Metric, len(s.metrics[tenant]))
+	maps.Copy(metrics, s.metrics[tenant])
+	return metrics, nil
 }
 
-// SaveAll сохраняет набор метрик в хранилище.
-// Копирует переданные метрики в хранилище, перезаписывая существующие.
+// SaveAll сохраняет набор метрик арендатора в хранилище.
+// Gauge перезаписывают существующие значения, а Delta метрик типа Counter прибавляются к ним, как в Save.
+// Переданные метрики не изменяются: сервис публикует их подписчикам как приращения.
 // Возвращает ошибку при неудаче.
-func (s *MemStorage) SaveAll(_ context.Context, metrics map[string]models.Metric) error {
-	maps.Copy(s.metrics, metrics)
+func (s *MemStorage) SaveAll(_ context.Context, tenant string, metrics map[string]models.Metric) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	p, u := s.partition(tenant)
+	now := s.timestamp()
+	for name, metric := range metrics {
+		if val, ok := p[name]; ok && metric.MType == models.Counter && metric.Delta != nil && val.Delta != nil {
+			metric.Delta = utils.MakePointer(*metric.Delta + *val.Delta)
+		}
+		p[name] = metric
+		u[name] = now
+	}
 	return nil
 }
+
+// List возвращает страницу метрик арендатора, удовлетворяющих фильтру, в порядке его сортировки.
+// Возвращает ошибку при некорректном фильтре.
+func (s *MemStorage) List(_ context.Context, tenant string, filter servermodels.MetricFilter) (servermodels.MetricPage, error) {
+	match, err := filter.Matcher()
+	if err != nil {
+		return servermodels.MetricPage{}, err
+	}
+
+	s.mx.RLock()
+	records := make([]servermodels.MetricRecord, 0, len(s.metrics[tenant]))
+	for name, metric := range s.metrics[tenant] {
+		if record := servermodels.NewMetricRecord(metric, s.updated[tenant][name]); match(record) {
+			records = append(records, record)
+		}
+	}
+	s.mx.RUnlock()
+
+	slices.SortFunc(records, filter.Compare)
+	if filter.After != nil {
+		after := servermodels.MetricRecord{ID: filter.After.Name, UpdatedAt: filter.After.UpdatedAt}
+		records = records[sort.Search(len(records), func(i int) bool {
+			return filter.Compare(records[i], after) > 0
+		}):]
+	}
+	return servermodels.NewMetricPage(records, filter.Limit), nil
+}
+
+// Snapshot возвращает копию метрик всех арендаторов.
+func (s *MemStorage) Snapshot(_ context.Context) (servermodels.TenantMetrics, error) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	snapshot := make(servermodels.TenantMetrics, len(s.metrics))
+	for tenant, metrics := range s.metrics {
+		snapshot[tenant] = maps.Clone(metrics)
+	}
+	return snapshot, nil
+}
@@ -3,10 +3,12 @@ package repository
 import (
 	"context"
 	"github.com/MxTrap/metrics/internal/common/models"
+	servermodels "github.com/MxTrap/metrics/internal/server/models"
 	"github.com/MxTrap/metrics/internal/utils"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"testing"
+	"time"
 )
 
 func TestNewMemStorage(t *testing.T) {
@@ -35,10 +37,10 @@ func TestSaveGauge(t *testing.T) {
 		MType: models.Gauge,
 		Value: utils.MakePointer(42.5),
 	}
-	err = storage.Save(context.Background(), metric)
+	err = storage.Save(context.Background(), testTenant, metric)
 	require.NoError(t, err)
 
-	saved, err := storage.Find(context.Background(), "testGauge")
+	saved, err := storage.Find(context.Background(), testTenant, "testGauge")
 	require.NoError(t, err)
 	assert.Equal(t, metric, saved)
 }
@@ -52,7 +54,7 @@ func TestSaveCounter(t *testing.T) {
 		MType: models.Counter,
 		Delta: utils.MakePointer[int64](10),
 	}
-	err = storage.Save(context.Background(), metric1)
+	err = storage.Save(context.Background(), testTenant, metric1)
 	require.NoError(t, err)
 
 	metric2 := models.Metric{
@@ -60,10 +62,10 @@ func TestSaveCounter(t *testing.T) {
 		MType: models.Counter,
 		Delta: utils.MakePointer[int64](20),
 	}
-	err = storage.Save(context.Background(), metric2)
+	err = storage.Save(context.Background(), testTenant, metric2)
 	require.NoError(t, err)
 
-	saved, err := storage.Find(context.Background(), "testCounter")
+	saved, err := storage.Find(context.Background(), testTenant, "testCounter")
 	require.NoError(t, err)
 	assert.Equal(t, models.Counter, saved.MType)
 	assert.Equal(t, int64(30), *saved.Delta)
@@ -73,7 +75,7 @@ func TestFindNotFound(t *testing.T) {
 	storage, err := NewMemStorage()
 	require.NoError(t, err)
 
-	_, err = storage.Find(context.Background(), "nonexistent")
+	_, err = storage.Find(context.Background(), testTenant, "nonexistent")
 	assert.Error(t, err)
 	assert.Equal(t, "not found", err.Error())
 }
@@ -87,11 +89,11 @@ func TestGetAll(t *testing.T) {
 		"counter1": {ID: "counter1", MType: models.Counter, Delta: utils.MakePointer(int64(100))},
 	}
 	for _, m := range metrics {
-		err = storage.Save(context.Background(), m)
+		err = storage.Save(context.Background(), testTenant, m)
 		require.NoError(t, err)
 	}
 
-	result, err := storage.GetAll(context.Background())
+	result, err := storage.GetAll(context.Background(), testTenant)
 	require.NoError(t, err)
 	assert.Equal(t, metrics, result)
 }
@@ -100,7 +102,7 @@ func TestGetAllEmpty(t *testing.T) {
 	storage, err := NewMemStorage()
 	require.NoError(t, err)
 
-	result, err := storage.GetAll(context.Background())
+	result, err := storage.GetAll(context.Background(), testTenant)
 	require.NoError(t, err)
 	assert.Empty(t, result)
 }
@@ -113,7 +115,7 @@ func TestSaveAll(t *testing.T) {
 		"gauge1": {ID: "gauge1", MType: models.Gauge, Value: utils.MakePointer(42.5)},
 	}
 	for _, m := range existing {
-		err = storage.Save(context.Background(), m)
+		err = storage.Save(context.Background(), testTenant, m)
 		require.NoError(t, err)
 	}
 
@@ -121,10 +123,150 @@ func TestSaveAll(t *testing.T) {
 		"gauge1":   {ID: "gauge1", MType: models.Gauge, Value: utils.MakePointer(99.9)},
 		"counter1": {ID: "counter1", MType: models.Counter, Delta: utils.MakePointer[int64](100)},
 	}
-	err = storage.SaveAll(context.Background(), newMetrics)
+	err = storage.SaveAll(context.Background(), testTenant, newMetrics)
 	require.NoError(t, err)
 
-	result, err := storage.GetAll(context.Background())
+	result, err := storage.GetAll(context.Background(), testTenant)
 	require.NoError(t, err)
 	assert.Equal(t, newMetrics, result)
 }
+
+func TestSaveAllCounters(t *testing.T) {
+	storage, err := NewMemStorage()
+	require.NoError(t, err)
+
+	first := map[string]models.Metric{
+		"counter1": {ID: "counter1", MType: models.Counter, Delta: utils.MakePointer[int64](10)},
+	}
+	second := map[string]models.Metric{
+		"counter1": {ID: "counter1", MType: models.Counter, Delta: utils.MakePointer[int64](20)},
+	}
+	require.NoError(t, storage.SaveAll(context.Background(), testTenant, first))
+	require.NoError(t, storage.SaveAll(context.Background(), testTenant, second))
+
+	saved, err := storage.Find(context.Background(), testTenant, "counter1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(30), *saved.Delta)
+	assert.Equal(t, int64(10), *first["counter1"].Delta, "first batch should stay unchanged")
+	assert.Equal(t, int64(20), *second["counter1"].Delta, "second batch should stay unchanged")
+}
+
+const testTenant = "team-a"
+
+func TestTenantIsolation(t *testing.T) {
+	storage, err := NewMemStorage()
+	require.NoError(t, err)
+
+	alloc := models.Metric{ID: "Alloc", MType: models.Gauge, Value: utils.MakePointer(1.0)}
+	otherAlloc := models.Metric{ID: "Alloc", MType: models.Gauge, Value: utils.MakePointer(2.0)}
+	require.NoError(t, storage.Save(context.Background(), "team-a", alloc))
+	require.NoError(t, storage.Save(context.Background(), "team-b", otherAlloc))
+
+	saved, err := storage.Find(context.Background(), "team-a", "Alloc")
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, *saved.Value)
+
+	_, err = storage.Find(context.Background(), "team-c", "Alloc")
+	assert.Error(t, err)
+
+	all, err := storage.GetAll(context.Background(), "team-b")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]models.Metric{"Alloc": otherAlloc}, all)
+
+	snapshot, err := storage.Snapshot(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, snapshot, 2)
+}
+
+func TestList(t *testing.T) {
+	storage, err := NewMemStorage()
+	require.NoError(t, err)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tick := start
+	storage.now = func() time.Time {
+		tick = tick.Add(time.Second)
+		return tick
+	}
+	ctx := context.Background()
+	for _, m := range []models.Metric{
+		{ID: "Sys", MType: models.Gauge, Value: utils.MakePointer(1.0)},
+		{ID: "Alloc", MType: models.Gauge, Value: utils.MakePointer(2.0)},
+		{ID: "PollCount", MType: models.Counter, Delta: utils.MakePointer(int64(3))},
+		{ID: "HeapAlloc", MType: models.Gauge, Value: utils.MakePointer(4.0)},
+	} {
+		require.NoError(t, storage.Save(ctx, testTenant, m))
+	}
+	require.NoError(t, storage.Save(ctx, "team-b", models.Metric{ID: "Other", MType: models.Gauge, Value: utils.MakePointer(5.0)}))
+
+	names := func(page servermodels.MetricPage) []string {
+		var ids []string
+		for _, r := range page.Metrics {
+			ids = append(ids, r.ID)
+		}
+		return ids
+	}
+
+	tests := []struct {
+		name     string
+		filter   servermodels.MetricFilter
+		expected []string
+	}{
+		{
+			name:     "By name",
+			filter:   servermodels.MetricFilter{Sort: servermodels.SortByName, Limit: 10},
+			expected: []string{"Alloc", "HeapAlloc", "PollCount", "Sys"},
+		},
+		{
+			name:     "By update time descending",
+			filter:   servermodels.MetricFilter{Sort: servermodels.SortByUpdatedDesc, Limit: 10},
+			expected: []string{"HeapAlloc", "PollCount", "Alloc", "Sys"},
+		},
+		{
+			name:     "By type",
+			filter:   servermodels.MetricFilter{Type: models.Counter, Sort: servermodels.SortByName, Limit: 10},
+			expected: []string{"PollCount"},
+		},
+		{
+			name:     "By prefix",
+			filter:   servermodels.MetricFilter{NamePrefix: "Heap", Sort: servermodels.SortByName, Limit: 10},
+			expected: []string{"HeapAlloc"},
+		},
+		{
+			name:     "By pattern",
+			filter:   servermodels.MetricFilter{NamePattern: "Alloc$", Sort: servermodels.SortByNameDesc, Limit: 10},
+			expected: []string{"HeapAlloc", "Alloc"},
+		},
+		{
+			name:     "Updated since",
+			filter:   servermodels.MetricFilter{UpdatedSince: start.Add(3 * time.Second), Sort: servermodels.SortByName, Limit: 10},
+			expected: []string{"HeapAlloc", "PollCount"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := storage.List(ctx, testTenant, tt.filter)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, names(page))
+			assert.Empty(t, page.NextCursor)
+		})
+	}
+
+	t.Run("Pagination", func(t *testing.T) {
+		filter := servermodels.MetricFilter{Sort: servermodels.SortByUpdated, Limit: 3}
+		page, err := storage.List(ctx, testTenant, filter)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Sys", "Alloc", "PollCount"}, names(page))
+		assert.Equal(t, start.Add(3*time.Second), page.Metrics[2].UpdatedAt)
+		require.NotEmpty(t, page.NextCursor)
+
+		filter.After, err = servermodels.DecodeMetricCursor(page.NextCursor)
+		require.NoError(t, err)
+		page, err = storage.List(ctx, testTenant, filter)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"HeapAlloc"}, names(page))
+		assert.Empty(t, page.NextCursor)
+	})
+
+	_, err = storage.List(ctx, testTenant, servermodels.MetricFilter{NamePattern: "(", Limit: 10})
+	assert.Error(t, err)
+}
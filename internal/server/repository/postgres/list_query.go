@@ -0,0 +1,60 @@
+package postgres
+
+import (
+	servermodels "github.com/MxTrap/metrics/internal/server/models"
+	"strconv"
+	"strings"
+)
+
+// likeEscaper экранирует служебные символы шаблона LIKE.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// nameC — имя метрики в сопоставлении "C": сортировка и курсор по нему совпадают
+// с побайтовым порядком MemStorage и используют индексы миграции 4 при любом сопоставлении базы.
+const nameC = `m.metric_name COLLATE "C"`
+
+// listQuery строит запрос страницы метрик арендатора по фильтру.
+// Условия, сортировка и постраничная выборка по курсору выполняются в базе данных;
+// запрос выбирает на одну строку больше размера страницы, чтобы определить наличие следующей.
+// Шаблон имени проверяется оператором ~, то есть регулярным выражением PostgreSQL, а не RE2.
+func listQuery(tenant string, filter servermodels.MetricFilter) (string, []any) {
+	args := []any{tenant}
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	var sb strings.Builder
+	sb.WriteString(selectListStmt)
+	if filter.Type != "" {
+		sb.WriteString(" AND t.metric_type = " + arg(filter.Type))
+	}
+	if filter.NamePrefix != "" {
+		sb.WriteString(" AND " + nameC + " LIKE " + arg(likeEscaper.Replace(filter.NamePrefix)+"%"))
+	}
+	if filter.NamePattern != "" {
+		sb.WriteString(" AND m.metric_name ~ " + arg(filter.NamePattern))
+	}
+	if !filter.UpdatedSince.IsZero() {
+		sb.WriteString(" AND m.updated_at >= " + arg(filter.UpdatedSince))
+	}
+
+	op, dir := ">", "ASC"
+	if filter.Descending() {
+		op, dir = "<", "DESC"
+	}
+	if filter.After != nil {
+		if filter.ByUpdated() {
+			sb.WriteString(" AND (m.updated_at, " + nameC + ") " + op + " (" + arg(filter.After.UpdatedAt) + ", " + arg(filter.After.Name) + ")")
+		} else {
+			sb.WriteString(" AND " + nameC + " " + op + " " + arg(filter.After.Name))
+		}
+	}
+	if filter.ByUpdated() {
+		sb.WriteString(" ORDER BY m.updated_at " + dir + ", " + nameC + " " + dir)
+	} else {
+		sb.WriteString(" ORDER BY " + nameC + " " + dir)
+	}
+	sb.WriteString(" LIMIT " + arg(filter.Limit+1) + ";")
+	return sb.String(), args
+}
@@ -0,0 +1,73 @@
+package postgres
+
+import (
+	"testing"
+	"time"
+
+	"github.com/MxTrap/metrics/internal/common/models"
+	servermodels "github.com/MxTrap/metrics/internal/server/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListQuery(t *testing.T) {
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		filter        servermodels.MetricFilter
+		expectedWhere string
+		expectedOrder string
+		expectedArgs  []any
+	}{
+		{
+			name:          "Without conditions",
+			filter:        servermodels.MetricFilter{Sort: servermodels.SortByName, Limit: 10},
+			expectedOrder: ` ORDER BY m.metric_name COLLATE "C" ASC LIMIT $2;`,
+			expectedArgs:  []any{testTenant, 11},
+		},
+		{
+			name: "All conditions",
+			filter: servermodels.MetricFilter{
+				Type:         models.Gauge,
+				NamePrefix:   "heap_%",
+				NamePattern:  "Alloc$",
+				UpdatedSince: since,
+				Sort:         servermodels.SortByName,
+				Limit:        5,
+			},
+			expectedWhere: ` AND t.metric_type = $2 AND m.metric_name COLLATE "C" LIKE $3 AND m.metric_name ~ $4 AND m.updated_at >= $5`,
+			expectedOrder: ` ORDER BY m.metric_name COLLATE "C" ASC LIMIT $6;`,
+			expectedArgs:  []any{testTenant, models.Gauge, `heap\_\%%`, "Alloc$", since, 6},
+		},
+		{
+			name: "Descending name after cursor",
+			filter: servermodels.MetricFilter{
+				Sort:  servermodels.SortByNameDesc,
+				After: &servermodels.MetricCursor{Name: "Sys"},
+				Limit: 10,
+			},
+			expectedWhere: ` AND m.metric_name COLLATE "C" < $2`,
+			expectedOrder: ` ORDER BY m.metric_name COLLATE "C" DESC LIMIT $3;`,
+			expectedArgs:  []any{testTenant, "Sys", 11},
+		},
+		{
+			name: "Update time after cursor",
+			filter: servermodels.MetricFilter{
+				Sort:  servermodels.SortByUpdated,
+				After: &servermodels.MetricCursor{Name: "Sys", UpdatedAt: since},
+				Limit: 10,
+			},
+			expectedWhere: ` AND (m.updated_at, m.metric_name COLLATE "C") > ($2, $3)`,
+			expectedOrder: ` ORDER BY m.updated_at ASC, m.metric_name COLLATE "C" ASC LIMIT $4;`,
+			expectedArgs:  []any{testTenant, since, "Sys", 11},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args := listQuery(testTenant, tt.filter)
+			assert.Equal(t, selectListStmt+tt.expectedWhere+tt.expectedOrder, query)
+			assert.Equal(t, tt.expectedArgs, args)
+		})
+	}
+}
@@ -5,13 +5,16 @@ package postgres
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/MxTrap/metrics/internal/common/models"
 	"github.com/MxTrap/metrics/internal/server/logger"
+	servermodels "github.com/MxTrap/metrics/internal/server/models"
 	"github.com/MxTrap/metrics/internal/utils"
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"time"
 )
 
 type Storage struct {
@@ -20,11 +23,13 @@ type Storage struct {
 }
 
 type dbMetric struct {
-	ID    int64    `db:"id"`
-	MType string   `db:"metric_type"`
-	Name  string   `db:"metric_name"`
-	Value *float64 `db:"value"`
-	Delta *int64   `db:"delta"`
+	ID        int64     `db:"id"`
+	Tenant    string    `db:"tenant"`
+	MType     string    `db:"metric_type"`
+	Name      string    `db:"metric_name"`
+	Value     *float64  `db:"value"`
+	Delta     *int64    `db:"delta"`
+	UpdatedAt time.Time `db:"updated_at"`
 }
 
 // NewPostgresStorage создаёт новое хранилище метрик для PostgreSQL с указанным пулом соединений и логгером.
@@ -80,10 +85,10 @@ func (s *Storage) Ping(ctx context.Context) error {
 	return s.db.Ping(ctx)
 }
 
-// Save сохраняет метрику в базе данных.
+// Save сохраняет метрику арендатора в базе данных.
 // Выполняет обновление или вставку с повторными попытками при необходимости.
 // Возвращает ошибку при неудаче.
-func (s *Storage) Save(ctx context.Context, metric models.Metric) error {
+func (s *Storage) Save(ctx context.Context, tenant string, metric models.Metric) error {
 	s.log.Logger.Info("Save")
 
 	return s.withRetry(func() error {
@@ -93,12 +98,12 @@ func (s *Storage) Save(ctx context.Context, metric models.Metric) error {
 			return err
 		}
 
-		exec, err := tx.Exec(ctx, updateStmt, metric.MType, metric.ID, metric.Value, metric.Delta)
+		exec, err := tx.Exec(ctx, updateStmt, metric.MType, metric.ID, metric.Value, metric.Delta, tenant)
 		if err != nil {
 			return err
 		}
 		if exec.RowsAffected() == 0 {
-			_, err = tx.Exec(ctx, insertStmt, metric.MType, metric.ID, metric.Value, metric.Delta)
+			_, err = tx.Exec(ctx, insertStmt, metric.MType, metric.ID, metric.Value, metric.Delta, tenant)
 			if err != nil {
 				err = tx.Rollback(ctx)
 				if err != nil {
@@ -115,9 +120,9 @@ func (s *Storage) Save(ctx context.Context, metric models.Metric) error {
 	})
 }
 
-// Find получает метрику по её идентификатору из базы данных.
+// Find получает метрику арендатора по её идентификатору из базы данных.
 // Возвращает метрику или ошибку, если метрика не найдена.
-func (s *Storage) Find(ctx context.Context, metricName string) (models.Metric, error) {
+func (s *Storage) Find(ctx context.Context, tenant string, metricName string) (models.Metric, error) {
 	s.log.Logger.Info("Find")
 
 	var metric models.Metric
@@ -125,7 +130,7 @@ func (s *Storage) Find(ctx context.Context, metricName string) (models.Metric, e
 	err := s.withRetry(func() error {
 		rows, err := s.db.Query(
 			ctx,
-			findStmt, metricName,
+			findStmt, tenant, metricName,
 		)
 
 		if err != nil {
@@ -150,15 +155,15 @@ func (s *Storage) Find(ctx context.Context, metricName string) (models.Metric, e
 	return metric, nil
 }
 
-// GetAll возвращает все метрики из базы данных.
+// GetAll возвращает все метрики арендатора из базы данных.
 // Возвращает карту метрик или ошибку при неудаче.
-func (s *Storage) GetAll(ctx context.Context) (map[string]models.Metric, error) {
+func (s *Storage) GetAll(ctx context.Context, tenant string) (map[string]models.Metric, error) {
 	s.log.Logger.Info("Get all")
 
 	var metrics map[string]models.Metric
 
 	err := s.withRetry(func() error {
-		rows, err := s.db.Query(ctx, selectAllStmt)
+		rows, err := s.db.Query(ctx, selectAllStmt, tenant)
 		if err != nil {
 			return err
 		}
@@ -181,10 +186,82 @@ func (s *Storage) GetAll(ctx context.Context) (map[string]models.Metric, error)
 	return metrics, nil
 }
 
-// SaveAll сохраняет набор метрик в базе данных.
+// List возвращает страницу метрик арендатора, удовлетворяющих фильтру, в порядке его сортировки.
+// Фильтрация, сортировка и постраничная выборка выполняются запросом к базе данных.
+// Возвращает страницу, ErrInvalidRequest для шаблона имени, который не принимает PostgreSQL, или ошибку при неудаче.
+func (s *Storage) List(ctx context.Context, tenant string, filter servermodels.MetricFilter) (servermodels.MetricPage, error) {
+	s.log.Logger.Info("List")
+
+	var page servermodels.MetricPage
+
+	query, args := listQuery(tenant, filter)
+	err := s.withRetry(func() error {
+		rows, err := s.db.Query(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		dbMetrics, err := pgx.CollectRows(rows, pgx.RowToStructByName[dbMetric])
+		if err != nil {
+			return err
+		}
+		records := make([]servermodels.MetricRecord, len(dbMetrics))
+		for i, m := range dbMetrics {
+			records[i] = servermodels.NewMetricRecord(s.mapDBToCommonMetric(m), m.UpdatedAt)
+		}
+		page = servermodels.NewMetricPage(records, filter.Limit)
+		return nil
+	})
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.InvalidRegularExpression {
+		// шаблон прошёл проверку RE2, но не принят регулярными выражениями PostgreSQL
+		return servermodels.MetricPage{}, fmt.Errorf("%w: %s", servermodels.ErrInvalidRequest, pgErr.Message)
+	}
+	if err != nil {
+		return servermodels.MetricPage{}, err
+	}
+
+	return page, nil
+}
+
+// Snapshot возвращает метрики всех арендаторов из базы данных.
+// Возвращает метрики по арендаторам или ошибку при неудаче.
+func (s *Storage) Snapshot(ctx context.Context) (servermodels.TenantMetrics, error) {
+	s.log.Logger.Info("Snapshot")
+
+	var snapshot servermodels.TenantMetrics
+
+	err := s.withRetry(func() error {
+		rows, err := s.db.Query(ctx, selectSnapshotStmt)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		dbMetrics, err := pgx.CollectRows(rows, pgx.RowToStructByName[dbMetric])
+		if err != nil {
+			return err
+		}
+		tenants := servermodels.TenantMetrics{}
+		for _, m := range dbMetrics {
+			if _, ok := tenants[m.Tenant]; !ok {
+				tenants[m.Tenant] = map[string]models.Metric{}
+			}
+			tenants[m.Tenant][m.Name] = s.mapDBToCommonMetric(m)
+		}
+		snapshot = tenants
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+// SaveAll сохраняет набор метрик арендатора в базе данных.
 // Выполняет пакетное обновление или вставку с повторными попытками при необходимости.
 // Возвращает ошибку при неудаче.
-func (s *Storage) SaveAll(ctx context.Context, metrics map[string]models.Metric) error {
+func (s *Storage) SaveAll(ctx context.Context, tenant string, metrics map[string]models.Metric) error {
 	s.log.Logger.Info("Save all")
 
 	return s.withRetry(func() error {
@@ -195,7 +272,7 @@ func (s *Storage) SaveAll(ctx context.Context, metrics map[string]models.Metric)
 
 		batchUpdate := pgx.Batch{}
 		for _, metric := range metrics {
-			batchUpdate.Queue(updateStmt, metric.MType, metric.ID, metric.Value, metric.Delta)
+			batchUpdate.Queue(updateStmt, metric.MType, metric.ID, metric.Value, metric.Delta, tenant)
 		}
 
 		batchResult := tx.SendBatch(ctx, &batchUpdate)
@@ -222,7 +299,7 @@ func (s *Storage) SaveAll(ctx context.Context, metrics map[string]models.Metric)
 			insertBatch := pgx.Batch{}
 
 			for _, metric := range insertRows {
-				insertBatch.Queue(insertStmt, metric.MType, metric.ID, metric.Value, metric.Delta)
+				insertBatch.Queue(insertStmt, metric.MType, metric.ID, metric.Value, metric.Delta, tenant)
 			}
 
 			batchResult = tx.SendBatch(ctx, &insertBatch)
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/MxTrap/metrics/internal/common/models"
 	"github.com/MxTrap/metrics/internal/server/logger"
+	servermodels "github.com/MxTrap/metrics/internal/server/models"
 	"github.com/MxTrap/metrics/internal/utils"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/stretchr/testify/assert"
@@ -19,6 +20,7 @@ import (
 const dbName = "postgres"
 const username = "postgres"
 const password = "postgres"
+const testTenant = "team-a"
 
 func createTestContainer() (*postgres.PostgresContainer, error) {
 	ctx := context.Background()
@@ -105,6 +107,8 @@ func setupStorage() (*Storage, cleanupFn, error) {
 			metric_name     VARCHAR,
 			value           DOUBLE PRECISION,
 			delta           BIGINT,
+			tenant          VARCHAR NOT NULL DEFAULT 'default',
+			updated_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
 			CONSTRAINT fk_metric_metric_type
 		FOREIGN KEY (metric_type_id)
 		REFERENCES metric_type (id)
@@ -175,7 +179,7 @@ func TestSave(t *testing.T) {
 		Value: utils.MakePointer(42.0),
 	}
 
-	err = storage.Save(ctx, metric)
+	err = storage.Save(ctx, testTenant, metric)
 	assert.NoError(t, err, "save should succeed")
 }
 
@@ -193,17 +197,20 @@ func TestFind(t *testing.T) {
 	}
 	*metric.Delta = 100
 
-	err = storage.Save(ctx, metric)
+	err = storage.Save(ctx, testTenant, metric)
 	require.NoError(t, err, "failed to save metric")
 
-	found, err := storage.Find(ctx, "testCounter")
+	found, err := storage.Find(ctx, testTenant, "testCounter")
 	assert.NoError(t, err, "find should succeed")
 	assert.Equal(t, metric.ID, found.ID)
 	assert.Equal(t, metric.MType, found.MType)
 	assert.Equal(t, *metric.Delta, *found.Delta)
 
-	_, err = storage.Find(ctx, "nonexistent")
+	_, err = storage.Find(ctx, testTenant, "nonexistent")
 	assert.Error(t, err, "find should fail for nonexistent metric")
+
+	_, err = storage.Find(ctx, "team-b", "testCounter")
+	assert.Error(t, err, "find should not see metrics of another tenant")
 }
 
 func TestGetAll(t *testing.T) {
@@ -229,14 +236,18 @@ func TestGetAll(t *testing.T) {
 	*metrics["gauge1"].Value = 42.0
 	*metrics["counter1"].Delta = 100
 
-	err = storage.SaveAll(ctx, metrics)
+	err = storage.SaveAll(ctx, testTenant, metrics)
 	require.NoError(t, err, "failed to save metrics")
 
-	result, err := storage.GetAll(ctx)
+	result, err := storage.GetAll(ctx, testTenant)
 	assert.NoError(t, err, "get all should succeed")
 	assert.Len(t, result, 2, "should return 2 metrics")
 	assert.Equal(t, metrics["gauge1"], result["gauge1"])
 	assert.Equal(t, metrics["counter1"], result["counter1"])
+
+	snapshot, err := storage.Snapshot(ctx)
+	assert.NoError(t, err, "snapshot should succeed")
+	assert.Equal(t, result, snapshot[testTenant])
 }
 
 func TestSaveAll(t *testing.T) {
@@ -261,7 +272,7 @@ func TestSaveAll(t *testing.T) {
 	*metrics["gauge1"].Value = 42.0
 	*metrics["counter1"].Delta = 100
 
-	err = storage.SaveAll(ctx, metrics)
+	err = storage.SaveAll(ctx, testTenant, metrics)
 	assert.NoError(t, err, "save all should succeed")
 
 }
@@ -276,3 +287,43 @@ func TestClose(t *testing.T) {
 	err = storage.Ping(context.Background())
 	assert.Error(t, err, "ping should fail after close")
 }
+
+func TestList(t *testing.T) {
+	storage, cleanup, err := setupStorage()
+	defer cleanup(context.Background())
+
+	require.NoError(t, err, "failed to create storage")
+
+	ctx := context.Background()
+	for _, m := range []models.Metric{
+		{ID: "Alloc", MType: models.Gauge, Value: utils.MakePointer(1.0)},
+		{ID: "HeapAlloc", MType: models.Gauge, Value: utils.MakePointer(2.0)},
+		{ID: "PollCount", MType: models.Counter, Delta: utils.MakePointer[int64](3)},
+	} {
+		require.NoError(t, storage.Save(ctx, testTenant, m), "failed to save metric")
+	}
+
+	page, err := storage.List(ctx, testTenant, servermodels.MetricFilter{Type: models.Gauge, Sort: servermodels.SortByName, Limit: 1})
+	require.NoError(t, err, "list should succeed")
+	require.Len(t, page.Metrics, 1)
+	assert.Equal(t, "Alloc", page.Metrics[0].ID)
+	assert.False(t, page.Metrics[0].UpdatedAt.IsZero(), "update time should be set")
+	require.NotEmpty(t, page.NextCursor, "first page should point to the next one")
+
+	after, err := servermodels.DecodeMetricCursor(page.NextCursor)
+	require.NoError(t, err)
+	page, err = storage.List(ctx, testTenant, servermodels.MetricFilter{Type: models.Gauge, Sort: servermodels.SortByName, After: after, Limit: 1})
+	require.NoError(t, err, "list should succeed")
+	require.Len(t, page.Metrics, 1)
+	assert.Equal(t, "HeapAlloc", page.Metrics[0].ID)
+	assert.Empty(t, page.NextCursor)
+
+	page, err = storage.List(ctx, testTenant, servermodels.MetricFilter{NamePattern: "^Poll", Sort: servermodels.SortByUpdatedDesc, Limit: 10})
+	require.NoError(t, err, "list should succeed")
+	require.Len(t, page.Metrics, 1)
+	assert.Equal(t, int64(3), *page.Metrics[0].Delta)
+
+	page, err = storage.List(ctx, "team-b", servermodels.MetricFilter{Sort: servermodels.SortByName, Limit: 10})
+	require.NoError(t, err, "list should succeed")
+	assert.Empty(t, page.Metrics, "list should not see metrics of another tenant")
+}
@@ -4,14 +4,27 @@ const updateStmt = `UPDATE metric SET
 	metric_type_id = (SELECT id FROM metric_type WHERE metric_type = $1), 
     metric_name = $2, 
     value=$3,
-    delta = delta + $4
-    WHERE metric_name = $2;`
+    delta = delta + $4,
+    updated_at = now()
+    WHERE metric_name = $2 AND tenant = $5;`
 
-const insertStmt = `INSERT INTO metric (metric_type_id, metric_name, value, delta)
-						VALUES ((SELECT id FROM metric_type WHERE metric_type = $1), $2, $3, $4);`
+const insertStmt = `INSERT INTO metric (metric_type_id, metric_name, value, delta, tenant)
+						VALUES ((SELECT id FROM metric_type WHERE metric_type = $1), $2, $3, $4, $5);`
 
-const findStmt = `SELECT m.id, t.metric_type, m.metric_name, m.value, m.delta FROM metric AS m
-JOIN metric_type AS t ON m.metric_type_id = t.id WHERE m.metric_name = $1;`
+const findStmt = `SELECT m.id, m.tenant, t.metric_type, m.metric_name, m.value, m.delta, m.updated_at FROM metric AS m
+JOIN metric_type AS t ON m.metric_type_id = t.id WHERE m.tenant = $1 AND m.metric_name = $2;`
 
-const selectAllStmt = `SELECT m.id, t.metric_type, m.metric_name, m.value, m.delta FROM metric AS m 
+const selectAllStmt = `SELECT m.id, m.tenant, t.metric_type, m.metric_name, m.value, m.delta, m.updated_at FROM metric AS m 
+    		JOIN metric_type AS t ON m.metric_type_id = t.id WHERE m.tenant = $1;`
+
+const selectSnapshotStmt = `SELECT m.id, m.tenant, t.metric_type, m.metric_name, m.value, m.delta, m.updated_at FROM metric AS m 
     		JOIN metric_type AS t ON m.metric_type_id = t.id;`
+
+// selectListStmt — начало запроса списка метрик арендатора; условия фильтра,
+// сортировка и ограничение добавляются в listQuery.
+const selectListStmt = `SELECT m.id, m.tenant, t.metric_type, m.metric_name, m.value, m.delta, m.updated_at FROM metric AS m
+    		JOIN metric_type AS t ON m.metric_type_id = t.id WHERE m.tenant = $1`
+
+const findTokenStmt = `SELECT id, token_hash, permissions, prefix, tenant FROM api_token WHERE token_hash = $1;`
+
+const selectAllTokensStmt = `SELECT id, token_hash, permissions, prefix, tenant FROM api_token ORDER BY id;`
@@ -0,0 +1,72 @@
+package postgres
+
+import (
+	"context"
+	"github.com/MxTrap/metrics/internal/server/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// TokenStorage хранит API-токены в таблице api_token.
+type TokenStorage struct {
+	db *pgxpool.Pool
+}
+
+type dbToken struct {
+	ID          string   `db:"id"`
+	Hash        string   `db:"token_hash"`
+	Permissions []string `db:"permissions"`
+	Prefix      string   `db:"prefix"`
+	Tenant      string   `db:"tenant"`
+}
+
+// NewTokenStorage создаёт хранилище API-токенов поверх пула соединений.
+func NewTokenStorage(db *pgxpool.Pool) *TokenStorage {
+	return &TokenStorage{db: db}
+}
+
+func (*TokenStorage) mapDBToToken(t dbToken) models.Token {
+	permissions := make([]models.Permission, len(t.Permissions))
+	for i, p := range t.Permissions {
+		permissions[i] = models.Permission(p)
+	}
+	return models.Token{
+		ID:          t.ID,
+		Hash:        t.Hash,
+		Permissions: permissions,
+		Prefix:      t.Prefix,
+		Tenant:      t.Tenant,
+	}
+}
+
+// FindByHash возвращает токен по хэшу его секрета.
+func (s *TokenStorage) FindByHash(ctx context.Context, hash string) (models.Token, error) {
+	rows, err := s.db.Query(ctx, findTokenStmt, hash)
+	if err != nil {
+		return models.Token{}, err
+	}
+	defer rows.Close()
+	t, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[dbToken])
+	if err != nil {
+		return models.Token{}, err
+	}
+	return s.mapDBToToken(t), nil
+}
+
+// GetAll возвращает все токены, упорядоченные по идентификатору.
+func (s *TokenStorage) GetAll(ctx context.Context) ([]models.Token, error) {
+	rows, err := s.db.Query(ctx, selectAllTokensStmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	dbTokens, err := pgx.CollectRows(rows, pgx.RowToStructByName[dbToken])
+	if err != nil {
+		return nil, err
+	}
+	tokens := make([]models.Token, len(dbTokens))
+	for i, t := range dbTokens {
+		tokens[i] = s.mapDBToToken(t)
+	}
+	return tokens, nil
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/MxTrap/metrics/internal/server/models"
+	"os"
+)
+
+// TokenFileStorage хранит API-токены, загруженные из JSON-файла.
+// Секрет токена может быть указан в открытом виде (token) или в виде SHA-256 хэша (token_hash).
+type TokenFileStorage struct {
+	tokens map[string]models.Token
+}
+
+type fileToken struct {
+	ID          string              `json:"id"`
+	Token       string              `json:"token"`
+	TokenHash   string              `json:"token_hash"`
+	Permissions []models.Permission `json:"permissions"`
+	Prefix      string              `json:"prefix"`
+	Tenant      string              `json:"tenant"`
+}
+
+// NewTokenFileStorage читает файл токенов по указанному пути.
+// Возвращает ошибку, если файл не удалось прочитать или токен не содержит секрета.
+func NewTokenFileStorage(filePath string) (*TokenFileStorage, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	var content struct {
+		Tokens []fileToken `json:"tokens"`
+	}
+	err = json.Unmarshal(data, &content)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := make(map[string]models.Token, len(content.Tokens))
+	for _, t := range content.Tokens {
+		hash := t.TokenHash
+		if t.Token != "" {
+			hash = models.HashSecret(t.Token)
+		}
+		if hash == "" {
+			return nil, errors.New("token " + t.ID + " has no secret")
+		}
+		tokens[hash] = models.Token{
+			ID:          t.ID,
+			Hash:        hash,
+			Permissions: t.Permissions,
+			Prefix:      t.Prefix,
+			Tenant:      t.Tenant,
+		}
+	}
+
+	return &TokenFileStorage{tokens: tokens}, nil
+}
+
+// FindByHash возвращает токен по хэшу его секрета.
+func (s *TokenFileStorage) FindByHash(_ context.Context, hash string) (models.Token, error) {
+	token, ok := s.tokens[hash]
+	if !ok {
+		return models.Token{}, errors.New("not found")
+	}
+	return token, nil
+}
+
+// GetAll возвращает все загруженные токены.
+func (s *TokenFileStorage) GetAll(_ context.Context) ([]models.Token, error) {
+	tokens := make([]models.Token, 0, len(s.tokens))
+	for _, t := range s.tokens {
+		tokens = append(tokens, t)
+	}
+	return tokens, nil
+}
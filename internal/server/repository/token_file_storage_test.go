@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/MxTrap/metrics/internal/server/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTokensFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestNewTokenFileStorage(t *testing.T) {
+	path := writeTokensFile(t, `{"tokens": [
+		{"id": "agent", "token": "agent-secret", "permissions": ["write"], "prefix": "app_", "tenant": "team-a"},
+		{"id": "ops", "token_hash": "`+models.HashSecret("ops-secret")+`", "permissions": ["admin"]}
+	]}`)
+
+	storage, err := NewTokenFileStorage(path)
+	require.NoError(t, err)
+
+	token, err := storage.FindByHash(context.Background(), models.HashSecret("agent-secret"))
+	require.NoError(t, err)
+	assert.Equal(t, "agent", token.ID)
+	assert.Equal(t, []models.Permission{models.PermissionWrite}, token.Permissions)
+	assert.Equal(t, "app_", token.Prefix)
+	assert.Equal(t, "team-a", token.Tenant)
+
+	token, err = storage.FindByHash(context.Background(), models.HashSecret("ops-secret"))
+	require.NoError(t, err)
+	assert.Equal(t, "ops", token.ID)
+
+	_, err = storage.FindByHash(context.Background(), models.HashSecret("unknown"))
+	assert.Error(t, err)
+
+	all, err := storage.GetAll(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, all, 2)
+}
+
+func TestNewTokenFileStorageErrors(t *testing.T) {
+	_, err := NewTokenFileStorage(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err, "missing file should fail")
+
+	_, err = NewTokenFileStorage(writeTokensFile(t, `not json`))
+	assert.Error(t, err, "invalid json should fail")
+
+	_, err = NewTokenFileStorage(writeTokensFile(t, `{"tokens": [{"id": "empty"}]}`))
+	assert.Error(t, err, "token without secret should fail")
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"github.com/MxTrap/metrics/internal/common/models"
+	servermodels "github.com/MxTrap/metrics/internal/server/models"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+type agentConfigStorage interface {
+	Find(ctx context.Context, agentID, group string) (models.AgentConfig, error)
+}
+
+// AgentConfigService раздаёт агентам настройки и запоминает, какую версию применил каждый агент.
+type AgentConfigService struct {
+	storage agentConfigStorage
+	mx      sync.Mutex
+	applied map[string]models.AppliedConfig
+	now     func() time.Time
+}
+
+// NewAgentConfigService создаёт AgentConfigService поверх хранилища настроек.
+func NewAgentConfigService(storage agentConfigStorage) *AgentConfigService {
+	return &AgentConfigService{
+		storage: storage,
+		applied: make(map[string]models.AppliedConfig),
+		now:     time.Now,
+	}
+}
+
+// Config возвращает настройки агента.
+// Возвращает models.ErrMissingAgentID без идентификатора агента и models.ErrNoAgentConfig, если настройки не заданы.
+func (s *AgentConfigService) Config(ctx context.Context, agentID, group string) (models.AgentConfig, error) {
+	agentID = strings.TrimSpace(agentID)
+	if agentID == "" {
+		return models.AgentConfig{}, servermodels.ErrMissingAgentID
+	}
+	return s.storage.Find(ctx, agentID, strings.TrimSpace(group))
+}
+
+// ReportApplied запоминает версию настроек, которую применил агент, и время отчёта.
+func (s *AgentConfigService) ReportApplied(_ context.Context, applied models.AppliedConfig) error {
+	applied.AgentID = strings.TrimSpace(applied.AgentID)
+	if applied.AgentID == "" {
+		return servermodels.ErrMissingAgentID
+	}
+	applied.AppliedAt = s.now()
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.applied[applied.AgentID] = applied
+	return nil
+}
+
+// Applied возвращает последние отчёты агентов о применении настроек, упорядоченные по идентификатору агента.
+func (s *AgentConfigService) Applied(_ context.Context) []models.AppliedConfig {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	applied := make([]models.AppliedConfig, 0, len(s.applied))
+	for _, a := range s.applied {
+		applied = append(applied, a)
+	}
+	slices.SortFunc(applied, func(a, b models.AppliedConfig) int {
+		return cmp.Compare(a.AgentID, b.AgentID)
+	})
+	return applied
+}
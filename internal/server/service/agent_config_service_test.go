@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/MxTrap/metrics/internal/common/models"
+	servermodels "github.com/MxTrap/metrics/internal/server/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type mockAgentConfigStorage struct {
+	mock.Mock
+}
+
+func (m *mockAgentConfigStorage) Find(ctx context.Context, agentID, group string) (models.AgentConfig, error) {
+	args := m.Called(ctx, agentID, group)
+	return args.Get(0).(models.AgentConfig), args.Error(1)
+}
+
+func TestAgentConfigServiceConfig(t *testing.T) {
+	storage := &mockAgentConfigStorage{}
+	cfg := models.AgentConfig{Version: "v1", ReportInterval: 30}
+	storage.On("Find", mock.Anything, "host-1", "edge").Return(cfg, nil)
+	svc := NewAgentConfigService(storage)
+
+	got, err := svc.Config(context.Background(), " host-1 ", "edge")
+	require.NoError(t, err)
+	assert.Equal(t, cfg, got)
+
+	_, err = svc.Config(context.Background(), "", "edge")
+	assert.ErrorIs(t, err, servermodels.ErrMissingAgentID)
+}
+
+func TestAgentConfigServiceReportApplied(t *testing.T) {
+	svc := NewAgentConfigService(&mockAgentConfigStorage{})
+	now := time.Unix(1700000000, 0)
+	svc.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	require.NoError(t, svc.ReportApplied(ctx, models.AppliedConfig{AgentID: "host-2", Version: "v1"}))
+	require.NoError(t, svc.ReportApplied(ctx, models.AppliedConfig{AgentID: "host-1", Version: "v1"}))
+	require.NoError(t, svc.ReportApplied(ctx, models.AppliedConfig{AgentID: "host-1", Version: "v2", Error: "unknown collector"}))
+	assert.ErrorIs(t, svc.ReportApplied(ctx, models.AppliedConfig{Version: "v1"}), servermodels.ErrMissingAgentID)
+
+	assert.Equal(t, []models.AppliedConfig{
+		{AgentID: "host-1", Version: "v2", Error: "unknown collector", AppliedAt: now},
+		{AgentID: "host-2", Version: "v1", AppliedAt: now},
+	}, svc.Applied(ctx))
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	commonmodels "github.com/MxTrap/metrics/internal/common/models"
+	"github.com/MxTrap/metrics/internal/server/clientip"
+	"github.com/MxTrap/metrics/internal/server/models"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AgentStatusHandler получает запись агента, когда агент замолкает или снова выходит на связь.
+type AgentStatusHandler func(record models.AgentRecord)
+
+// forgetSilentFactor — во сколько раз дольше интервала молчания агент должен молчать,
+// чтобы его запись удалилась из реестра и не занимала память после смены идентификатора.
+const forgetSilentFactor = 10
+
+type agentKey struct {
+	tenant string
+	id     string
+}
+
+// AgentRegistryService ведёт реестр агентов, которые отправляют данные на сервер:
+// сведения агента, время последнего запроса, транспорт и число ошибок.
+// Агент считается замолчавшим, если от него нет запросов дольше silentAfter,
+// и забывается, если молчит дольше forgetSilentFactor×silentAfter.
+type AgentRegistryService struct {
+	mx          sync.Mutex
+	agents      map[agentKey]*models.AgentRecord
+	silentAfter time.Duration
+	onStatus    AgentStatusHandler
+	now         func() time.Time
+}
+
+// NewAgentRegistryService создаёт пустой реестр агентов с указанным интервалом молчания.
+func NewAgentRegistryService(silentAfter time.Duration) *AgentRegistryService {
+	return &AgentRegistryService{
+		agents:      make(map[agentKey]*models.AgentRecord),
+		silentAfter: silentAfter,
+		onStatus:    func(models.AgentRecord) {},
+		now:         time.Now,
+	}
+}
+
+// RegisterStatusHandler задаёт обработчик смены состояния агента.
+func (s *AgentRegistryService) RegisterStatusHandler(handler AgentStatusHandler) {
+	s.onStatus = handler
+}
+
+// Seen отмечает запрос агента, пришедший через transport; err — ошибка обработки запроса.
+// Запросы без идентификатора агента не учитываются.
+func (s *AgentRegistryService) Seen(ctx context.Context, info commonmodels.AgentInfo, transport string, err error) {
+	info.ID = strings.TrimSpace(info.ID)
+	if info.ID == "" {
+		return
+	}
+	key := agentKey{tenant: models.TenantFromContext(ctx), id: info.ID}
+	now := s.now()
+
+	s.mx.Lock()
+	record, ok := s.agents[key]
+	if !ok {
+		record = &models.AgentRecord{Tenant: key.tenant, FirstSeen: now}
+		s.agents[key] = record
+	}
+	record.AgentInfo = info
+	record.Transport = transport
+	record.LastSeen = now
+	record.Requests++
+	if ip, ok := clientip.FromContext(ctx); ok {
+		record.Address = ip.String()
+	}
+	if err != nil {
+		record.Errors++
+		record.LastError = err.Error()
+	}
+	returned := record.Silent
+	record.Silent = false
+	snapshot := *record
+	s.mx.Unlock()
+
+	if returned {
+		s.onStatus(snapshot)
+	}
+}
+
+// Agents возвращает записи всех известных агентов, упорядоченные по арендатору и идентификатору.
+func (s *AgentRegistryService) Agents(_ context.Context) []models.AgentRecord {
+	now := s.now()
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	records := make([]models.AgentRecord, 0, len(s.agents))
+	for _, r := range s.agents {
+		record := *r
+		record.Silent = s.silent(r, now)
+		records = append(records, record)
+	}
+	slices.SortFunc(records, func(a, b models.AgentRecord) int {
+		return cmp.Or(cmp.Compare(a.Tenant, b.Tenant), cmp.Compare(a.ID, b.ID))
+	})
+	return records
+}
+
+// Run проверяет молчание агентов до отмены контекста.
+func (s *AgentRegistryService) Run(ctx context.Context) {
+	ticker := time.NewTicker(max(s.silentAfter/2, time.Second))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.checkSilent()
+		}
+	}
+}
+
+// checkSilent отмечает замолчавших агентов и сообщает о каждом обработчику один раз,
+// а агентов, молчащих дольше forgetSilentFactor×silentAfter, удаляет из реестра.
+func (s *AgentRegistryService) checkSilent() {
+	now := s.now()
+	var silent []models.AgentRecord
+	s.mx.Lock()
+	for key, r := range s.agents {
+		if !r.Silent && s.silent(r, now) {
+			r.Silent = true
+			silent = append(silent, *r)
+		}
+		if s.silentAfter > 0 && now.Sub(r.LastSeen) > forgetSilentFactor*s.silentAfter {
+			delete(s.agents, key)
+		}
+	}
+	s.mx.Unlock()
+
+	for _, r := range silent {
+		s.onStatus(r)
+	}
+}
+
+func (s *AgentRegistryService) silent(r *models.AgentRecord, now time.Time) bool {
+	return s.silentAfter > 0 && now.Sub(r.LastSeen) > s.silentAfter
+}
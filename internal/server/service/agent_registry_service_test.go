@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	commonmodels "github.com/MxTrap/metrics/internal/common/models"
+	"github.com/MxTrap/metrics/internal/server/clientip"
+	"github.com/MxTrap/metrics/internal/server/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgentRegistryServiceSeen(t *testing.T) {
+	svc := NewAgentRegistryService(time.Minute)
+	start := time.Unix(1700000000, 0)
+	svc.now = func() time.Time { return start }
+
+	ctx := clientip.ContextWithIP(models.ContextWithTenant(context.Background(), "acme"), net.ParseIP("10.0.0.5"))
+	info := commonmodels.AgentInfo{ID: "host-1", Hostname: "host-1", Version: "v1.2.0", OS: "linux/amd64"}
+	svc.Seen(ctx, info, models.AgentTransportHTTP, nil)
+	svc.now = func() time.Time { return start.Add(10 * time.Second) }
+	svc.Seen(ctx, info, models.AgentTransportGRPC, errors.New("quota exceeded"))
+	svc.Seen(context.Background(), commonmodels.AgentInfo{Hostname: "anonymous"}, models.AgentTransportHTTP, nil)
+
+	agents := svc.Agents(context.Background())
+	require.Len(t, agents, 1, "requests without agent id should not be registered")
+	assert.Equal(t, models.AgentRecord{
+		AgentInfo: info,
+		Tenant:    "acme",
+		Address:   "10.0.0.5",
+		Transport: models.AgentTransportGRPC,
+		FirstSeen: start,
+		LastSeen:  start.Add(10 * time.Second),
+		Requests:  2,
+		Errors:    1,
+		LastError: "quota exceeded",
+	}, agents[0])
+}
+
+func TestAgentRegistryServiceAgentsOrder(t *testing.T) {
+	svc := NewAgentRegistryService(time.Minute)
+	svc.Seen(models.ContextWithTenant(context.Background(), "b"), commonmodels.AgentInfo{ID: "a"}, models.AgentTransportHTTP, nil)
+	svc.Seen(context.Background(), commonmodels.AgentInfo{ID: "z"}, models.AgentTransportHTTP, nil)
+	svc.Seen(models.ContextWithTenant(context.Background(), "b"), commonmodels.AgentInfo{ID: "a"}, models.AgentTransportHTTP, nil)
+
+	agents := svc.Agents(context.Background())
+	require.Len(t, agents, 2, "the same agent of a tenant should be registered once")
+	assert.Equal(t, "b", agents[0].Tenant)
+	assert.Equal(t, models.DefaultTenant, agents[1].Tenant)
+}
+
+func TestAgentRegistryServiceSilence(t *testing.T) {
+	svc := NewAgentRegistryService(time.Minute)
+	start := time.Unix(1700000000, 0)
+	svc.now = func() time.Time { return start }
+	var changes []models.AgentRecord
+	svc.RegisterStatusHandler(func(record models.AgentRecord) {
+		changes = append(changes, record)
+	})
+	svc.Seen(context.Background(), commonmodels.AgentInfo{ID: "host-1"}, models.AgentTransportHTTP, nil)
+
+	svc.now = func() time.Time { return start.Add(30 * time.Second) }
+	svc.checkSilent()
+	assert.Empty(t, changes, "agent within the interval should not be silent")
+	assert.False(t, svc.Agents(context.Background())[0].Silent)
+
+	svc.now = func() time.Time { return start.Add(2 * time.Minute) }
+	assert.True(t, svc.Agents(context.Background())[0].Silent, "listing should report silence before the check")
+	svc.checkSilent()
+	svc.checkSilent()
+	require.Len(t, changes, 1, "silence should be reported once")
+	assert.True(t, changes[0].Silent)
+
+	svc.Seen(context.Background(), commonmodels.AgentInfo{ID: "host-1"}, models.AgentTransportHTTP, nil)
+	require.Len(t, changes, 2, "return of the agent should be reported")
+	assert.False(t, changes[1].Silent)
+}
+
+func TestAgentRegistryServiceForget(t *testing.T) {
+	svc := NewAgentRegistryService(time.Minute)
+	start := time.Unix(1700000000, 0)
+	svc.now = func() time.Time { return start }
+	var changes []models.AgentRecord
+	svc.RegisterStatusHandler(func(record models.AgentRecord) {
+		changes = append(changes, record)
+	})
+	svc.Seen(context.Background(), commonmodels.AgentInfo{ID: "host-1"}, models.AgentTransportHTTP, nil)
+	svc.Seen(context.Background(), commonmodels.AgentInfo{ID: "host-2"}, models.AgentTransportHTTP, nil)
+
+	svc.now = func() time.Time { return start.Add(5 * time.Minute) }
+	svc.Seen(context.Background(), commonmodels.AgentInfo{ID: "host-2"}, models.AgentTransportHTTP, nil)
+	svc.now = func() time.Time { return start.Add(11 * time.Minute) }
+	svc.checkSilent()
+
+	agents := svc.Agents(context.Background())
+	require.Len(t, agents, 1, "agent silent for too long should be forgotten")
+	assert.Equal(t, "host-2", agents[0].ID)
+	require.Len(t, changes, 2, "forgotten agent should be reported silent first")
+	assert.ElementsMatch(t, []string{"host-1", "host-2"}, []string{changes[0].ID, changes[1].ID})
+}
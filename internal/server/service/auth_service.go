@@ -0,0 +1,44 @@
+package service
+
+import (
+	"context"
+	"github.com/MxTrap/metrics/internal/server/models"
+)
+
+type tokenStorage interface {
+	FindByHash(ctx context.Context, hash string) (models.Token, error)
+	GetAll(ctx context.Context) ([]models.Token, error)
+}
+
+// AuthService проверяет API-токены и предоставляет список зарегистрированных токенов.
+type AuthService struct {
+	storage tokenStorage
+}
+
+// NewAuthService создаёт AuthService поверх хранилища токенов.
+func NewAuthService(storage tokenStorage) *AuthService {
+	return &AuthService{storage: storage}
+}
+
+// Authenticate находит токен по предъявленному секрету.
+// Возвращает models.ErrUnauthorized, если секрет пуст или не зарегистрирован.
+func (s *AuthService) Authenticate(ctx context.Context, secret string) (models.Token, error) {
+	if secret == "" {
+		return models.Token{}, models.ErrUnauthorized
+	}
+	token, err := s.storage.FindByHash(ctx, models.HashSecret(secret))
+	if err != nil {
+		return models.Token{}, models.ErrUnauthorized
+	}
+	return token, nil
+}
+
+// ListTokens возвращает все токены без секретов.
+// Доступен только токену с разрешением admin.
+func (s *AuthService) ListTokens(ctx context.Context) ([]models.Token, error) {
+	token, ok := models.TokenFromContext(ctx)
+	if !ok || !token.Has(models.PermissionAdmin) {
+		return nil, models.ErrForbidden
+	}
+	return s.storage.GetAll(ctx)
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	servermodels "github.com/MxTrap/metrics/internal/server/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type mockTokenStorage struct {
+	mock.Mock
+}
+
+func (m *mockTokenStorage) FindByHash(ctx context.Context, hash string) (servermodels.Token, error) {
+	args := m.Called(ctx, hash)
+	return args.Get(0).(servermodels.Token), args.Error(1)
+}
+
+func (m *mockTokenStorage) GetAll(ctx context.Context) ([]servermodels.Token, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]servermodels.Token), args.Error(1)
+}
+
+func TestAuthenticate(t *testing.T) {
+	storage := &mockTokenStorage{}
+	token := servermodels.Token{ID: "agent", Permissions: []servermodels.Permission{servermodels.PermissionWrite}}
+	storage.On("FindByHash", mock.Anything, servermodels.HashSecret("secret")).Return(token, nil)
+	storage.On("FindByHash", mock.Anything, mock.Anything).Return(servermodels.Token{}, errors.New("not found"))
+	svc := NewAuthService(storage)
+
+	got, err := svc.Authenticate(context.Background(), "secret")
+	require.NoError(t, err)
+	assert.Equal(t, token, got)
+
+	_, err = svc.Authenticate(context.Background(), "wrong")
+	assert.ErrorIs(t, err, servermodels.ErrUnauthorized)
+
+	_, err = svc.Authenticate(context.Background(), "")
+	assert.ErrorIs(t, err, servermodels.ErrUnauthorized)
+}
+
+func TestListTokens(t *testing.T) {
+	storage := &mockTokenStorage{}
+	tokens := []servermodels.Token{{ID: "agent"}, {ID: "ops"}}
+	storage.On("GetAll", mock.Anything).Return(tokens, nil)
+	svc := NewAuthService(storage)
+
+	admin := servermodels.Token{ID: "ops", Permissions: []servermodels.Permission{servermodels.PermissionAdmin}}
+	got, err := svc.ListTokens(servermodels.ContextWithToken(context.Background(), admin))
+	require.NoError(t, err)
+	assert.Equal(t, tokens, got)
+
+	writer := servermodels.Token{ID: "agent", Permissions: []servermodels.Permission{servermodels.PermissionWrite}}
+	_, err = svc.ListTokens(servermodels.ContextWithToken(context.Background(), writer))
+	assert.ErrorIs(t, err, servermodels.ErrForbidden)
+
+	_, err = svc.ListTokens(context.Background())
+	assert.ErrorIs(t, err, servermodels.ErrForbidden)
+}
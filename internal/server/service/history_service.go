@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	commonmodels "github.com/MxTrap/metrics/internal/common/models"
+	"github.com/MxTrap/metrics/internal/server/models"
+	"slices"
+	"sync"
+	"time"
+)
+
+// HistoryService хранит в памяти последние обновления каждой метрики для графиков панели мониторинга.
+// Для каждой метрики хранится не больше size точек; более старые точки вытесняются.
+// История не сохраняется между перезапусками сервера.
+type HistoryService struct {
+	mx     sync.RWMutex
+	points map[string]map[string][]models.HistoryPoint
+	size   int
+	now    func() time.Time
+}
+
+// NewHistoryService создаёт HistoryService, хранящий до size последних точек каждой метрики.
+func NewHistoryService(size int) *HistoryService {
+	return &HistoryService{
+		points: map[string]map[string][]models.HistoryPoint{},
+		size:   max(size, 1),
+		now:    time.Now,
+	}
+}
+
+func historyKey(mType, name string) string {
+	return mType + "/" + name
+}
+
+// Publish добавляет сохранённые метрики арендатора запроса в их историю.
+func (s *HistoryService) Publish(ctx context.Context, metrics []commonmodels.Metric) {
+	tenant := models.TenantFromContext(ctx)
+	now := s.now().Round(0)
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	history, ok := s.points[tenant]
+	if !ok {
+		history = map[string][]models.HistoryPoint{}
+		s.points[tenant] = history
+	}
+	for _, metric := range metrics {
+		point := models.HistoryPoint{Time: now}
+		switch {
+		case metric.Value != nil:
+			point.Value = *metric.Value
+		case metric.Delta != nil:
+			point.Value = float64(*metric.Delta)
+		default:
+			continue
+		}
+		key := historyKey(metric.MType, metric.ID)
+		points := append(history[key], point)
+		if len(points) > s.size {
+			points = slices.Clone(points[len(points)-s.size:])
+		}
+		history[key] = points
+	}
+}
+
+// History возвращает историю метрики арендатора запроса от старых точек к новым.
+// Для метрики без обновлений с момента запуска сервера возвращает пустой срез.
+func (s *HistoryService) History(ctx context.Context, mType, name string) []models.HistoryPoint {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return slices.Clone(s.points[models.TenantFromContext(ctx)][historyKey(mType, name)])
+}
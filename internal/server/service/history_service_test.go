@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/MxTrap/metrics/internal/common/models"
+	servermodels "github.com/MxTrap/metrics/internal/server/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistoryService(t *testing.T) {
+	history := NewHistoryService(3)
+	now := time.Unix(1700000000, 0)
+	history.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := range 4 {
+		now = now.Add(time.Second)
+		history.Publish(ctx, []models.Metric{
+			{ID: "Alloc", MType: models.Gauge, Value: ptr(float64(i))},
+			{ID: "PollCount", MType: models.Counter, Delta: ptr(int64(10 * i))},
+		})
+	}
+
+	assert.Equal(t, []servermodels.HistoryPoint{
+		{Time: time.Unix(1700000002, 0), Value: 1},
+		{Time: time.Unix(1700000003, 0), Value: 2},
+		{Time: time.Unix(1700000004, 0), Value: 3},
+	}, history.History(ctx, models.Gauge, "Alloc"), "only the latest points should be kept")
+	assert.Equal(t, []float64{10, 20, 30}, values(history.History(ctx, models.Counter, "PollCount")))
+	assert.Empty(t, history.History(ctx, models.Counter, "Alloc"))
+	assert.Empty(t, history.History(servermodels.ContextWithTenant(ctx, "team-b"), models.Gauge, "Alloc"))
+
+	points := history.History(ctx, models.Gauge, "Alloc")
+	points[0].Value = 100
+	assert.Equal(t, 1.0, history.History(ctx, models.Gauge, "Alloc")[0].Value, "history should be returned as a copy")
+}
+
+func values(points []servermodels.HistoryPoint) []float64 {
+	out := make([]float64, len(points))
+	for i, p := range points {
+		out[i] = p.Value
+	}
+	return out
+}
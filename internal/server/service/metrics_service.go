@@ -4,19 +4,27 @@ package service
 
 import (
 	"context"
+	"fmt"
 	commonmodels "github.com/MxTrap/metrics/internal/common/models"
 	"github.com/MxTrap/metrics/internal/server/models"
+	"github.com/MxTrap/metrics/internal/server/ratelimit"
+	"github.com/MxTrap/metrics/internal/utils"
+	"maps"
+	"slices"
+	"strings"
 	"time"
 )
 
 type storageGetter interface {
-	GetAll(ctx context.Context) (map[string]commonmodels.Metric, error)
-	Find(ctx context.Context, metric string) (commonmodels.Metric, error)
+	GetAll(ctx context.Context, tenant string) (map[string]commonmodels.Metric, error)
+	Find(ctx context.Context, tenant string, metric string) (commonmodels.Metric, error)
+	Snapshot(ctx context.Context) (models.TenantMetrics, error)
+	List(ctx context.Context, tenant string, filter models.MetricFilter) (models.MetricPage, error)
 }
 
 type storageSaver interface {
-	Save(ctx context.Context, metrics commonmodels.Metric) error
-	SaveAll(ctx context.Context, metrics map[string]commonmodels.Metric) error
+	Save(ctx context.Context, tenant string, metrics commonmodels.Metric) error
+	SaveAll(ctx context.Context, tenant string, metrics map[string]commonmodels.Metric) error
 }
 
 type Storage interface {
@@ -26,17 +34,24 @@ type Storage interface {
 }
 
 type fileStorage interface {
-	Save(metrics map[string]commonmodels.Metric) error
-	Read() (map[string]commonmodels.Metric, error)
+	Save(metrics models.TenantMetrics) error
+	Read() (models.TenantMetrics, error)
 	Close() error
 }
 
+type publisher interface {
+	Publish(ctx context.Context, metrics []commonmodels.Metric)
+}
+
 type MetricsService struct {
 	fileStorage  fileStorage
 	storage      Storage
 	saveInterval int
 	restore      bool
 	ticker       *time.Ticker
+	maxBatchSize int
+	nameQuota    *nameQuota
+	publishers   []publisher
 }
 
 // NewMetricsService создаёт новый MetricsService с указанным файловым хранилищем, хранилищем, интервалом сохранения и флагом восстановления.
@@ -50,20 +65,59 @@ func NewMetricsService(fileStorage fileStorage, storage Storage, saveInterval in
 	}
 }
 
+// RegisterQuotas задаёт ограничения на число метрик в одном пакете и на число различных
+// имён метрик, создаваемых одним клиентом. Нулевое значение снимает ограничение.
+func (s *MetricsService) RegisterQuotas(maxBatchSize int, maxMetricsPerClient int) {
+	s.maxBatchSize = maxBatchSize
+	s.nameQuota = newNameQuota(maxMetricsPerClient)
+}
+
+// RegisterPublisher добавляет получателя принятых обновлений метрик, например WatchService или HistoryService.
+// Обновления передаются после успешного сохранения в хранилище.
+func (s *MetricsService) RegisterPublisher(p publisher) {
+	s.publishers = append(s.publishers, p)
+}
+
+// publish передаёт сохранённые метрики получателям обновлений.
+func (s *MetricsService) publish(ctx context.Context, metrics ...commonmodels.Metric) {
+	for _, p := range s.publishers {
+		p.Publish(ctx, metrics)
+	}
+}
+
 func (*MetricsService) validateMetric(metricType string) bool {
 	_, ok := models.MetricTypes[metricType]
 	return ok
 }
 
-// SaveAll сохраняет массив метрик в хранилище.
+// authorize проверяет, что токен запроса разрешает действие над метрикой.
+// Запросы без токена (аутентификация отключена) не ограничиваются.
+func (*MetricsService) authorize(ctx context.Context, permission models.Permission, metricName string) error {
+	token, ok := models.TokenFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	if !token.Allows(permission, metricName) {
+		return models.ErrForbidden
+	}
+	return nil
+}
+
+// SaveAll сохраняет массив метрик арендатора запроса в хранилище.
 // Агрегирует метрики типа Counter и выполняет синхронное сохранение в файл, если saveInterval равен 0.
-// Возвращает ошибку при неудаче.
+// Возвращает ErrBatchTooLarge или ErrMetricQuota при превышении квот и ошибку при неудаче.
 func (s *MetricsService) SaveAll(ctx context.Context, metrics []commonmodels.Metric) error {
+	if s.maxBatchSize > 0 && len(metrics) > s.maxBatchSize {
+		return models.ErrBatchTooLarge
+	}
 	m := make(map[string]commonmodels.Metric, len(metrics))
 	for _, metric := range metrics {
 		if !s.validateMetric(metric.MType) {
 			continue
 		}
+		if err := s.authorize(ctx, models.PermissionWrite, metric.ID); err != nil {
+			return err
+		}
 		if val, ok := m[metric.ID]; metric.MType == commonmodels.Counter && ok {
 			*metric.Delta = *metric.Delta + *val.Delta
 		}
@@ -71,10 +125,19 @@ func (s *MetricsService) SaveAll(ctx context.Context, metrics []commonmodels.Met
 		m[metric.ID] = metric
 	}
 
-	err := s.storage.SaveAll(ctx, m)
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	if err := s.nameQuota.admit(ratelimit.ClientKey(ctx), names); err != nil {
+		return err
+	}
+
+	err := s.storage.SaveAll(ctx, models.TenantFromContext(ctx), m)
 	if err != nil {
 		return err
 	}
+	s.publish(ctx, slices.Collect(maps.Values(m))...)
 	if s.saveInterval == 0 {
 		err := s.saveToFile(ctx)
 		if err != nil {
@@ -84,7 +147,7 @@ func (s *MetricsService) SaveAll(ctx context.Context, metrics []commonmodels.Met
 	return nil
 }
 
-// Save сохраняет одну метрику в хранилище.
+// Save сохраняет одну метрику арендатора запроса в хранилище.
 // Выполняет синхронное сохранение в файл, если saveInterval равен 0.
 // Возвращает ошибку при неверном типе метрики, отсутствии значения или неудаче сохранения.
 func (s *MetricsService) Save(ctx context.Context, metric commonmodels.Metric) error {
@@ -95,11 +158,23 @@ func (s *MetricsService) Save(ctx context.Context, metric commonmodels.Metric) e
 	if metric.Delta == nil && metric.Value == nil {
 		return models.ErrWrongMetricValue
 	}
+	if err := s.authorize(ctx, models.PermissionWrite, metric.ID); err != nil {
+		return err
+	}
+	if err := s.nameQuota.admit(ratelimit.ClientKey(ctx), []string{metric.ID}); err != nil {
+		return err
+	}
 
-	err := s.storage.Save(ctx, metric)
+	// хранилище может накопить значение счётчика в переданной метрике, а подписчики получают приращение
+	accepted := metric
+	if metric.Delta != nil {
+		accepted.Delta = utils.MakePointer(*metric.Delta)
+	}
+	err := s.storage.Save(ctx, models.TenantFromContext(ctx), metric)
 	if err != nil {
 		return err
 	}
+	s.publish(ctx, accepted)
 	if s.saveInterval == 0 {
 		err := s.saveToFile(ctx)
 		if err != nil {
@@ -109,14 +184,17 @@ func (s *MetricsService) Save(ctx context.Context, metric commonmodels.Metric) e
 	return nil
 }
 
-// Find получает метрику по её идентификатору и типу.
+// Find получает метрику арендатора запроса по её идентификатору и типу.
 // Возвращает метрику или ошибку при неверном типе или отсутствии метрики.
 func (s *MetricsService) Find(ctx context.Context, metric commonmodels.Metric) (commonmodels.Metric, error) {
 	if !s.validateMetric(metric.MType) {
 		return commonmodels.Metric{}, models.ErrUnknownMetricType
 	}
+	if err := s.authorize(ctx, models.PermissionRead, metric.ID); err != nil {
+		return commonmodels.Metric{}, err
+	}
 
-	val, err := s.storage.Find(ctx, metric.ID)
+	val, err := s.storage.Find(ctx, models.TenantFromContext(ctx), metric.ID)
 	if err != nil {
 		return commonmodels.Metric{}, models.ErrNotFoundMetric
 	}
@@ -124,15 +202,19 @@ func (s *MetricsService) Find(ctx context.Context, metric commonmodels.Metric) (
 	return val, nil
 }
 
-// GetAll возвращает все метрики из хранилища в виде карты с их значениями.
+// GetAll возвращает все метрики арендатора запроса в виде карты с их значениями.
+// Метрики, недоступные токену запроса, в результат не попадают.
 // Возвращает карту или ошибку при неудаче.
 func (s *MetricsService) GetAll(ctx context.Context) (map[string]any, error) {
 	dst := map[string]any{}
-	metrics, err := s.storage.GetAll(ctx)
+	metrics, err := s.storage.GetAll(ctx, models.TenantFromContext(ctx))
 	if err != nil {
 		return dst, err
 	}
 	for k, v := range metrics {
+		if s.authorize(ctx, models.PermissionRead, k) != nil {
+			continue
+		}
 		var val any
 		if v.Delta != nil {
 			val = *v.Delta
@@ -145,8 +227,71 @@ func (s *MetricsService) GetAll(ctx context.Context) (map[string]any, error) {
 	return dst, nil
 }
 
+// List возвращает страницу метрик арендатора запроса с типами и временем обновления,
+// отобранных и упорядоченных по фильтру. Префикс имени сужается до префикса, разрешённого токену запроса.
+// Возвращает ErrUnknownMetricType или ErrInvalidRequest при некорректном фильтре.
+func (s *MetricsService) List(ctx context.Context, filter models.MetricFilter) (models.MetricPage, error) {
+	if _, err := filter.Matcher(); err != nil {
+		return models.MetricPage{}, err
+	}
+	switch filter.Sort {
+	case "":
+		filter.Sort = models.SortByName
+	case models.SortByName, models.SortByNameDesc, models.SortByUpdated, models.SortByUpdatedDesc:
+	default:
+		return models.MetricPage{}, fmt.Errorf("%w: unknown sort order %q", models.ErrInvalidRequest, filter.Sort)
+	}
+	switch {
+	case filter.Limit == 0:
+		filter.Limit = models.DefaultMetricPageSize
+	case filter.Limit < 0 || filter.Limit > models.MaxMetricPageSize:
+		return models.MetricPage{}, fmt.Errorf("%w: limit must be between 1 and %d", models.ErrInvalidRequest, models.MaxMetricPageSize)
+	}
+
+	filter, ok, err := scopeFilter(ctx, filter)
+	if err != nil {
+		return models.MetricPage{}, err
+	}
+	if !ok {
+		return models.NewMetricPage(nil, filter.Limit), nil
+	}
+	return s.storage.List(ctx, models.TenantFromContext(ctx), filter)
+}
+
+// scopeFilter ограничивает фильтр метриками, которые разрешено читать токену запроса.
+// Возвращает false, если токену не доступна ни одна метрика, подходящая под фильтр,
+// и ErrForbidden, если токен не разрешает чтение.
+func scopeFilter(ctx context.Context, filter models.MetricFilter) (models.MetricFilter, bool, error) {
+	token, ok := models.TokenFromContext(ctx)
+	if !ok {
+		return filter, true, nil
+	}
+	if !token.Has(models.PermissionRead) {
+		return filter, false, models.ErrForbidden
+	}
+	prefix, ok := narrowPrefix(filter.NamePrefix, token.Prefix)
+	if !ok {
+		return filter, false, nil
+	}
+	filter.NamePrefix = prefix
+	return filter, true, nil
+}
+
+// narrowPrefix возвращает префикс, которому удовлетворяют имена, подходящие под оба префикса.
+// Возвращает false, если таких имён нет.
+func narrowPrefix(a, b string) (string, bool) {
+	switch {
+	case strings.HasPrefix(a, b):
+		return a, true
+	case strings.HasPrefix(b, a):
+		return b, true
+	default:
+		return "", false
+	}
+}
+
 func (s *MetricsService) saveToFile(ctx context.Context) error {
-	all, err := s.storage.GetAll(ctx)
+	all, err := s.storage.Snapshot(ctx)
 	if err != nil {
 		return err
 	}
@@ -171,9 +316,11 @@ func (s *MetricsService) Start(ctx context.Context) error {
 		if err != nil {
 			return err
 		}
-		err = s.storage.SaveAll(ctx, read)
-		if err != nil {
-			return err
+		for tenant, metrics := range read {
+			err = s.storage.SaveAll(ctx, tenant, metrics)
+			if err != nil {
+				return err
+			}
 		}
 	}
 
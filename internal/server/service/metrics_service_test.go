@@ -18,26 +18,36 @@ type mockStorage struct {
 	mock.Mock
 }
 
-func (m *mockStorage) Save(ctx context.Context, metric models.Metric) error {
-	args := m.Called(ctx, metric)
+func (m *mockStorage) Save(ctx context.Context, tenant string, metric models.Metric) error {
+	args := m.Called(ctx, tenant, metric)
 	return args.Error(0)
 }
 
-func (m *mockStorage) SaveAll(ctx context.Context, metrics map[string]models.Metric) error {
-	args := m.Called(ctx, metrics)
+func (m *mockStorage) SaveAll(ctx context.Context, tenant string, metrics map[string]models.Metric) error {
+	args := m.Called(ctx, tenant, metrics)
 	return args.Error(0)
 }
 
-func (m *mockStorage) Find(ctx context.Context, metric string) (models.Metric, error) {
-	args := m.Called(ctx, metric)
+func (m *mockStorage) Find(ctx context.Context, tenant string, metric string) (models.Metric, error) {
+	args := m.Called(ctx, tenant, metric)
 	return args.Get(0).(models.Metric), args.Error(1)
 }
 
-func (m *mockStorage) GetAll(ctx context.Context) (map[string]models.Metric, error) {
-	args := m.Called(ctx)
+func (m *mockStorage) GetAll(ctx context.Context, tenant string) (map[string]models.Metric, error) {
+	args := m.Called(ctx, tenant)
 	return args.Get(0).(map[string]models.Metric), args.Error(1)
 }
 
+func (m *mockStorage) Snapshot(ctx context.Context) (servermodels.TenantMetrics, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(servermodels.TenantMetrics), args.Error(1)
+}
+
+func (m *mockStorage) List(ctx context.Context, tenant string, filter servermodels.MetricFilter) (servermodels.MetricPage, error) {
+	args := m.Called(ctx, tenant, filter)
+	return args.Get(0).(servermodels.MetricPage), args.Error(1)
+}
+
 func (m *mockStorage) Ping(ctx context.Context) error {
 	args := m.Called(ctx)
 	return args.Error(0)
@@ -47,14 +57,14 @@ type mockFileStorage struct {
 	mock.Mock
 }
 
-func (m *mockFileStorage) Save(metrics map[string]models.Metric) error {
+func (m *mockFileStorage) Save(metrics servermodels.TenantMetrics) error {
 	args := m.Called(metrics)
 	return args.Error(0)
 }
 
-func (m *mockFileStorage) Read() (map[string]models.Metric, error) {
+func (m *mockFileStorage) Read() (servermodels.TenantMetrics, error) {
 	args := m.Called()
-	return args.Get(0).(map[string]models.Metric), args.Error(1)
+	return args.Get(0).(servermodels.TenantMetrics), args.Error(1)
 }
 
 func (m *mockFileStorage) Close() error {
@@ -99,12 +109,10 @@ func TestSaveAll(t *testing.T) {
 		"counter1": {ID: "counter1", MType: "counter", Delta: ptr(int64(150))},
 	}
 
-	storage.On("GetAll", mock.Anything).Return(map[string]models.Metric{
-		"gauge1":   {ID: "gauge1", MType: "gauge", Value: ptr(42.5)},
-		"counter1": {ID: "counter1", MType: "counter", Delta: ptr(int64(150))},
-	}, nil)
-	storage.On("SaveAll", mock.Anything, expectedMap).Return(nil)
-	fileStorage.On("Save", expectedMap).Return(nil)
+	snapshot := servermodels.TenantMetrics{servermodels.DefaultTenant: expectedMap}
+	storage.On("Snapshot", mock.Anything).Return(snapshot, nil)
+	storage.On("SaveAll", mock.Anything, servermodels.DefaultTenant, expectedMap).Return(nil)
+	fileStorage.On("Save", snapshot).Return(nil)
 
 	err := service.SaveAll(context.Background(), metrics)
 	assert.NoError(t, err)
@@ -123,7 +131,7 @@ func TestSaveAllAsync(t *testing.T) {
 		"gauge1": {ID: "gauge1", MType: "gauge", Value: ptr(42.5)},
 	}
 
-	storage.On("SaveAll", mock.Anything, expectedMap).Return(nil)
+	storage.On("SaveAll", mock.Anything, servermodels.DefaultTenant, expectedMap).Return(nil)
 
 	err := service.SaveAll(context.Background(), metrics)
 	assert.NoError(t, err)
@@ -141,7 +149,7 @@ func TestSaveAllError(t *testing.T) {
 		"gauge1": {ID: "gauge1", MType: "gauge", Value: ptr(42.5)},
 	}
 
-	storage.On("SaveAll", mock.Anything, expectedMap).Return(errors.New("storage error"))
+	storage.On("SaveAll", mock.Anything, servermodels.DefaultTenant, expectedMap).Return(errors.New("storage error"))
 
 	err := service.SaveAll(context.Background(), metrics)
 	assert.Error(t, err)
@@ -156,9 +164,11 @@ func TestSave(t *testing.T) {
 	metric := models.Metric{ID: "gauge1", MType: "gauge", Value: ptr(42.5)}
 	metricsMap := map[string]models.Metric{"gauge1": metric}
 
-	storage.On("Save", mock.Anything, metric).Return(nil)
-	fileStorage.On("Save", metricsMap).Return(nil)
-	storage.On("GetAll", mock.Anything).Return(metricsMap, nil)
+	snapshot := servermodels.TenantMetrics{servermodels.DefaultTenant: metricsMap}
+
+	storage.On("Save", mock.Anything, servermodels.DefaultTenant, metric).Return(nil)
+	fileStorage.On("Save", snapshot).Return(nil)
+	storage.On("Snapshot", mock.Anything).Return(snapshot, nil)
 
 	err := service.Save(context.Background(), metric)
 	assert.NoError(t, err)
@@ -166,6 +176,43 @@ func TestSave(t *testing.T) {
 	fileStorage.AssertExpectations(t)
 }
 
+func TestPublish(t *testing.T) {
+	storage := &mockStorage{}
+	service := &MetricsService{storage: storage, saveInterval: 5}
+	watch := NewWatchService(10)
+	history := NewHistoryService(10)
+	service.RegisterPublisher(watch)
+	service.RegisterPublisher(history)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates, err := watch.Subscribe(ctx, servermodels.MetricFilter{})
+	assert.NoError(t, err)
+
+	// хранилище накапливает значение счётчика в переданной метрике
+	storage.On("Save", mock.Anything, servermodels.DefaultTenant, mock.Anything).Run(func(args mock.Arguments) {
+		*args.Get(2).(models.Metric).Delta += 100
+	}).Return(nil).Once()
+	storage.On("Save", mock.Anything, servermodels.DefaultTenant, mock.Anything).Return(errors.New("storage error")).Once()
+	storage.On("SaveAll", mock.Anything, servermodels.DefaultTenant, mock.Anything).Return(nil)
+
+	assert.NoError(t, service.Save(ctx, models.Metric{ID: "counter1", MType: "counter", Delta: ptr(int64(5))}))
+	update := receive(t, updates)
+	assert.Equal(t, "counter1", update.ID)
+	assert.Equal(t, int64(5), *update.Delta, "subscribers should receive the accepted increment")
+
+	assert.Error(t, service.Save(ctx, models.Metric{ID: "gauge1", MType: "gauge", Value: ptr(1.0)}))
+	assert.Empty(t, updates, "failed saves should not be published")
+
+	assert.NoError(t, service.SaveAll(ctx, []models.Metric{
+		{ID: "counter1", MType: "counter", Delta: ptr(int64(1))},
+		{ID: "counter1", MType: "counter", Delta: ptr(int64(2))},
+	}))
+	update = receive(t, updates)
+	assert.Equal(t, int64(3), *update.Delta)
+	assert.Empty(t, updates)
+	assert.Equal(t, []float64{5, 3}, values(history.History(ctx, "counter", "counter1")), "every publisher should receive updates")
+}
+
 func TestSaveInvalidType(t *testing.T) {
 	service := &MetricsService{}
 	metric := models.Metric{ID: "invalid", MType: "unknown"}
@@ -188,7 +235,7 @@ func TestFind(t *testing.T) {
 	metric := models.Metric{ID: "gauge1", MType: "gauge"}
 	expected := models.Metric{ID: "gauge1", MType: "gauge", Value: ptr(42.5)}
 
-	storage.On("Find", mock.Anything, "gauge1").Return(expected, nil)
+	storage.On("Find", mock.Anything, servermodels.DefaultTenant, "gauge1").Return(expected, nil)
 
 	result, err := service.Find(context.Background(), metric)
 	assert.NoError(t, err)
@@ -209,7 +256,7 @@ func TestFindNotFound(t *testing.T) {
 	service := &MetricsService{storage: storage}
 	metric := models.Metric{ID: "gauge1", MType: "gauge"}
 
-	storage.On("Find", mock.Anything, "gauge1").Return(models.Metric{}, errors.New("not found"))
+	storage.On("Find", mock.Anything, servermodels.DefaultTenant, "gauge1").Return(models.Metric{}, errors.New("not found"))
 
 	_, err := service.Find(context.Background(), metric)
 	assert.Error(t, err)
@@ -229,7 +276,7 @@ func TestGetAll(t *testing.T) {
 		"counter1": int64(100),
 	}
 
-	storage.On("GetAll", mock.Anything).Return(metrics, nil)
+	storage.On("GetAll", mock.Anything, servermodels.DefaultTenant).Return(metrics, nil)
 
 	result, err := service.GetAll(context.Background())
 	assert.NoError(t, err)
@@ -237,6 +284,141 @@ func TestGetAll(t *testing.T) {
 	storage.AssertExpectations(t)
 }
 
+func TestTokenPrefixScope(t *testing.T) {
+	storage := &mockStorage{}
+	service := &MetricsService{storage: storage, saveInterval: 5}
+	token := servermodels.Token{
+		ID:          "team",
+		Permissions: []servermodels.Permission{servermodels.PermissionRead, servermodels.PermissionWrite},
+		Prefix:      "app_",
+	}
+	ctx := servermodels.ContextWithToken(context.Background(), token)
+
+	err := service.Save(ctx, models.Metric{ID: "Alloc", MType: "gauge", Value: ptr(1.0)})
+	assert.ErrorIs(t, err, servermodels.ErrForbidden)
+
+	err = service.SaveAll(ctx, []models.Metric{
+		{ID: "app_requests", MType: "counter", Delta: ptr(int64(1))},
+		{ID: "Alloc", MType: "gauge", Value: ptr(1.0)},
+	})
+	assert.ErrorIs(t, err, servermodels.ErrForbidden)
+
+	_, err = service.Find(ctx, models.Metric{ID: "Alloc", MType: "gauge"})
+	assert.ErrorIs(t, err, servermodels.ErrForbidden)
+
+	storage.On("GetAll", mock.Anything, servermodels.DefaultTenant).Return(map[string]models.Metric{
+		"app_requests": {ID: "app_requests", MType: "counter", Delta: ptr(int64(3))},
+		"Alloc":        {ID: "Alloc", MType: "gauge", Value: ptr(1.0)},
+	}, nil)
+	all, err := service.GetAll(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"app_requests": int64(3)}, all)
+	storage.AssertNotCalled(t, "Save", mock.Anything, mock.Anything, mock.Anything)
+	storage.AssertNotCalled(t, "SaveAll", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestList(t *testing.T) {
+	page := servermodels.MetricPage{Metrics: []servermodels.MetricRecord{{ID: "app_requests", MType: "counter", Delta: ptr(int64(3))}}}
+
+	tests := []struct {
+		name          string
+		ctx           context.Context
+		filter        servermodels.MetricFilter
+		expectedQuery *servermodels.MetricFilter
+		expectedErr   error
+	}{
+		{
+			name:          "Defaults",
+			ctx:           context.Background(),
+			expectedQuery: &servermodels.MetricFilter{Sort: servermodels.SortByName, Limit: servermodels.DefaultMetricPageSize},
+		},
+		{
+			name:          "Token prefix narrows the filter",
+			ctx:           servermodels.ContextWithToken(context.Background(), servermodels.Token{Permissions: []servermodels.Permission{servermodels.PermissionRead}, Prefix: "app_"}),
+			filter:        servermodels.MetricFilter{NamePrefix: "app", Sort: servermodels.SortByUpdatedDesc, Limit: 10},
+			expectedQuery: &servermodels.MetricFilter{NamePrefix: "app_", Sort: servermodels.SortByUpdatedDesc, Limit: 10},
+		},
+		{
+			name:   "Prefix outside of the token scope",
+			ctx:    servermodels.ContextWithToken(context.Background(), servermodels.Token{Permissions: []servermodels.Permission{servermodels.PermissionRead}, Prefix: "app_"}),
+			filter: servermodels.MetricFilter{NamePrefix: "sys_"},
+		},
+		{
+			name:        "Token without read permission",
+			ctx:         servermodels.ContextWithToken(context.Background(), servermodels.Token{Permissions: []servermodels.Permission{servermodels.PermissionWrite}}),
+			expectedErr: servermodels.ErrForbidden,
+		},
+		{
+			name:        "Unknown type",
+			ctx:         context.Background(),
+			filter:      servermodels.MetricFilter{Type: "histogram"},
+			expectedErr: servermodels.ErrUnknownMetricType,
+		},
+		{
+			name:        "Invalid pattern",
+			ctx:         context.Background(),
+			filter:      servermodels.MetricFilter{NamePattern: "("},
+			expectedErr: servermodels.ErrInvalidRequest,
+		},
+		{
+			name:        "Unknown sort order",
+			ctx:         context.Background(),
+			filter:      servermodels.MetricFilter{Sort: "value"},
+			expectedErr: servermodels.ErrInvalidRequest,
+		},
+		{
+			name:        "Limit too large",
+			ctx:         context.Background(),
+			filter:      servermodels.MetricFilter{Limit: servermodels.MaxMetricPageSize + 1},
+			expectedErr: servermodels.ErrInvalidRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := &mockStorage{}
+			service := &MetricsService{storage: storage}
+			if tt.expectedQuery != nil {
+				storage.On("List", mock.Anything, servermodels.DefaultTenant, *tt.expectedQuery).Return(page, nil)
+			}
+
+			result, err := service.List(tt.ctx, tt.filter)
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			if tt.expectedQuery == nil {
+				assert.Empty(t, result.Metrics)
+				storage.AssertNotCalled(t, "List", mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+			assert.Equal(t, page, result)
+			storage.AssertExpectations(t)
+		})
+	}
+}
+
+func TestTenantIsolation(t *testing.T) {
+	storage := &mockStorage{}
+	service := &MetricsService{storage: storage, saveInterval: 5}
+	ctx := servermodels.ContextWithTenant(context.Background(), "team-a")
+	metric := models.Metric{ID: "Alloc", MType: "gauge", Value: ptr(1.0)}
+
+	storage.On("Save", mock.Anything, "team-a", metric).Return(nil)
+	storage.On("Find", mock.Anything, "team-a", "Alloc").Return(metric, nil)
+	storage.On("GetAll", mock.Anything, "team-a").Return(map[string]models.Metric{"Alloc": metric}, nil)
+
+	assert.NoError(t, service.Save(ctx, metric))
+	found, err := service.Find(ctx, models.Metric{ID: "Alloc", MType: "gauge"})
+	assert.NoError(t, err)
+	assert.Equal(t, metric, found)
+	all, err := service.GetAll(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"Alloc": 1.0}, all)
+	storage.AssertExpectations(t)
+}
+
 func TestPing(t *testing.T) {
 	storage := &mockStorage{}
 	service := &MetricsService{storage: storage}
@@ -252,12 +434,14 @@ func TestStartRestore(t *testing.T) {
 	storage := &mockStorage{}
 	fileStorage := &mockFileStorage{}
 	service := &MetricsService{storage: storage, fileStorage: fileStorage, restore: true}
-	metrics := map[string]models.Metric{
-		"gauge1": {ID: "gauge1", MType: "gauge", Value: ptr(42.5)},
+	metrics := servermodels.TenantMetrics{
+		"team-a": {"gauge1": {ID: "gauge1", MType: "gauge", Value: ptr(42.5)}},
+		"team-b": {"gauge1": {ID: "gauge1", MType: "gauge", Value: ptr(1.5)}},
 	}
 
 	fileStorage.On("Read").Return(metrics, nil)
-	storage.On("SaveAll", mock.Anything, metrics).Return(nil)
+	storage.On("SaveAll", mock.Anything, "team-a", metrics["team-a"]).Return(nil)
+	storage.On("SaveAll", mock.Anything, "team-b", metrics["team-b"]).Return(nil)
 
 	err := service.Start(context.Background())
 	assert.NoError(t, err)
@@ -270,11 +454,11 @@ func TestStartWithTicker(t *testing.T) {
 	storage := &mockStorage{}
 	fileStorage := &mockFileStorage{}
 	service := &MetricsService{storage: storage, fileStorage: fileStorage, saveInterval: 1}
-	metrics := map[string]models.Metric{
-		"gauge1": {ID: "gauge1", MType: "gauge", Value: ptr(42.5)},
+	metrics := servermodels.TenantMetrics{
+		servermodels.DefaultTenant: {"gauge1": {ID: "gauge1", MType: "gauge", Value: ptr(42.5)}},
 	}
 
-	storage.On("GetAll", mock.Anything).Return(metrics, nil)
+	storage.On("Snapshot", mock.Anything).Return(metrics, nil)
 	fileStorage.On("Save", metrics).Return(nil)
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -292,18 +476,18 @@ func TestStartWithTicker(t *testing.T) {
 
 	assert.NotNil(t, service.ticker)
 	fileStorage.AssertCalled(t, "Save", metrics)
-	storage.AssertCalled(t, "GetAll", mock.Anything)
+	storage.AssertCalled(t, "Snapshot", mock.Anything)
 }
 
 func TestStop(t *testing.T) {
 	storage := &mockStorage{}
 	fileStorage := &mockFileStorage{}
 	service := &MetricsService{storage: storage, fileStorage: fileStorage, ticker: time.NewTicker(time.Second)}
-	metrics := map[string]models.Metric{
-		"gauge1": {ID: "gauge1", MType: "gauge", Value: ptr(42.5)},
+	metrics := servermodels.TenantMetrics{
+		servermodels.DefaultTenant: {"gauge1": {ID: "gauge1", MType: "gauge", Value: ptr(42.5)}},
 	}
 
-	storage.On("GetAll", mock.Anything).Return(metrics, nil)
+	storage.On("Snapshot", mock.Anything).Return(metrics, nil)
 	fileStorage.On("Save", metrics).Return(nil)
 	fileStorage.On("Close").Return(nil)
 
@@ -341,8 +525,8 @@ func BenchmarkServiceSaveAll(b *testing.B) {
 			}
 		}
 
-		storage.On("SaveAll", mock.Anything, mock.Anything).Return(nil)
-		storage.On("GetAll", mock.Anything).Return(map[string]models.Metric{}, nil)
+		storage.On("SaveAll", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+		storage.On("Snapshot", mock.Anything).Return(servermodels.TenantMetrics{}, nil)
 		fileStorage.On("Save", mock.Anything).Return(nil)
 
 		b.Run("Metrics"+strconv.Itoa(count), func(b *testing.B) {
@@ -355,3 +539,38 @@ func BenchmarkServiceSaveAll(b *testing.B) {
 		})
 	}
 }
+
+func TestQuotas(t *testing.T) {
+	storage := &mockStorage{}
+	service := &MetricsService{storage: storage, saveInterval: 5}
+	service.RegisterQuotas(2, 3)
+	ctx := servermodels.ContextWithToken(context.Background(), servermodels.Token{ID: "agent", Permissions: []servermodels.Permission{servermodels.PermissionWrite}})
+	storage.On("SaveAll", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	storage.On("Save", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	err := service.SaveAll(ctx, []models.Metric{
+		{ID: "a", MType: "gauge", Value: ptr(1.0)},
+		{ID: "b", MType: "gauge", Value: ptr(1.0)},
+		{ID: "c", MType: "gauge", Value: ptr(1.0)},
+	})
+	assert.ErrorIs(t, err, servermodels.ErrBatchTooLarge)
+
+	err = service.SaveAll(ctx, []models.Metric{
+		{ID: "a", MType: "gauge", Value: ptr(1.0)},
+		{ID: "b", MType: "gauge", Value: ptr(1.0)},
+	})
+	assert.NoError(t, err)
+
+	err = service.SaveAll(ctx, []models.Metric{
+		{ID: "c", MType: "gauge", Value: ptr(1.0)},
+		{ID: "d", MType: "gauge", Value: ptr(1.0)},
+	})
+	assert.ErrorIs(t, err, servermodels.ErrMetricQuota, "batch exceeding the quota is rejected as a whole")
+
+	assert.NoError(t, service.Save(ctx, models.Metric{ID: "c", MType: "gauge", Value: ptr(1.0)}))
+	assert.NoError(t, service.Save(ctx, models.Metric{ID: "a", MType: "gauge", Value: ptr(2.0)}), "known names are not counted again")
+	assert.ErrorIs(t, service.Save(ctx, models.Metric{ID: "d", MType: "gauge", Value: ptr(1.0)}), servermodels.ErrMetricQuota)
+
+	other := servermodels.ContextWithToken(context.Background(), servermodels.Token{ID: "other", Permissions: []servermodels.Permission{servermodels.PermissionWrite}})
+	assert.NoError(t, service.Save(other, models.Metric{ID: "d", MType: "gauge", Value: ptr(1.0)}), "quota is per client")
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"github.com/MxTrap/metrics/internal/server/models"
+	"sync"
+)
+
+// nameQuota ограничивает число различных имён метрик, которые может создать один клиент.
+type nameQuota struct {
+	limit int
+	mx    sync.Mutex
+	names map[string]map[string]struct{}
+}
+
+func newNameQuota(limit int) *nameQuota {
+	return &nameQuota{
+		limit: limit,
+		names: map[string]map[string]struct{}{},
+	}
+}
+
+// admit учитывает имена метрик клиента. Если новые имена превышают квоту,
+// ни одно из них не учитывается и возвращается ErrMetricQuota.
+func (q *nameQuota) admit(client string, names []string) error {
+	if q == nil || q.limit <= 0 {
+		return nil
+	}
+	q.mx.Lock()
+	defer q.mx.Unlock()
+
+	known := q.names[client]
+	fresh := map[string]struct{}{}
+	for _, name := range names {
+		if _, ok := known[name]; !ok {
+			fresh[name] = struct{}{}
+		}
+	}
+	if len(fresh) == 0 {
+		return nil
+	}
+	if len(known)+len(fresh) > q.limit {
+		return models.ErrMetricQuota
+	}
+	if known == nil {
+		known = make(map[string]struct{}, len(fresh))
+		q.names[client] = known
+	}
+	for name := range fresh {
+		known[name] = struct{}{}
+	}
+	return nil
+}
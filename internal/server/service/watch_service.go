@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	commonmodels "github.com/MxTrap/metrics/internal/common/models"
+	"github.com/MxTrap/metrics/internal/server/models"
+	"sync"
+	"time"
+)
+
+// subscription — подписка на обновления метрик одного арендатора, отобранные по фильтру.
+type subscription struct {
+	tenant  string
+	match   func(models.MetricRecord) bool
+	updates chan models.MetricUpdate
+
+	mx      sync.Mutex
+	dropped uint64
+}
+
+// deliver передаёт обновление подписчику, не блокируясь. Если буфер подписчика заполнен,
+// обновление отбрасывается и учитывается в поле Dropped следующего доставленного обновления.
+func (s *subscription) deliver(record models.MetricRecord) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	select {
+	case s.updates <- models.MetricUpdate{MetricRecord: record, Dropped: s.dropped}:
+		s.dropped = 0
+	default:
+		s.dropped++
+	}
+}
+
+// WatchService рассылает принятые сервером обновления метрик подписчикам.
+// Медленный подписчик не задерживает сохранение метрик и других подписчиков:
+// у каждого подписчика свой буфер, при переполнении которого обновления для него отбрасываются.
+type WatchService struct {
+	mx          sync.RWMutex
+	subscribers map[*subscription]struct{}
+	closed      bool
+	buffer      int
+	now         func() time.Time
+}
+
+// NewWatchService создаёт WatchService с буфером на buffer обновлений для каждого подписчика.
+func NewWatchService(buffer int) *WatchService {
+	return &WatchService{
+		subscribers: map[*subscription]struct{}{},
+		buffer:      max(buffer, 1),
+		now:         time.Now,
+	}
+}
+
+// Subscribe подписывает на обновления метрик арендатора запроса, удовлетворяющие условиям фильтра.
+// Учитываются тип, префикс и регулярное выражение имени; префикс сужается до разрешённого токену запроса.
+// Подписка действует до завершения ctx, после чего возвращённый канал обновлений закрывается.
+// Возвращает ErrUnknownMetricType или ErrInvalidRequest при некорректном фильтре и ErrForbidden,
+// если токен не разрешает чтение.
+func (s *WatchService) Subscribe(ctx context.Context, filter models.MetricFilter) (<-chan models.MetricUpdate, error) {
+	filter, ok, err := scopeFilter(ctx, models.MetricFilter{
+		Type:        filter.Type,
+		NamePrefix:  filter.NamePrefix,
+		NamePattern: filter.NamePattern,
+	})
+	if err != nil {
+		return nil, err
+	}
+	match, err := filter.Matcher()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		// токену не доступна ни одна метрика, подходящая под фильтр
+		match = func(models.MetricRecord) bool { return false }
+	}
+
+	sub := &subscription{
+		tenant:  models.TenantFromContext(ctx),
+		match:   match,
+		updates: make(chan models.MetricUpdate, s.buffer),
+	}
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.closed {
+		close(sub.updates)
+		return sub.updates, nil
+	}
+	s.subscribers[sub] = struct{}{}
+
+	go func() {
+		<-ctx.Done()
+		s.mx.Lock()
+		defer s.mx.Unlock()
+		s.unsubscribe(sub)
+	}()
+	return sub.updates, nil
+}
+
+// unsubscribe удаляет подписку и закрывает её канал обновлений. Вызывается под блокировкой mx.
+func (s *WatchService) unsubscribe(sub *subscription) {
+	if _, ok := s.subscribers[sub]; !ok {
+		return
+	}
+	delete(s.subscribers, sub)
+	close(sub.updates)
+}
+
+// Close завершает все подписки, чтобы остановка сервера не ждала закрытия потоков клиентами.
+// Подписки, оформленные после Close, завершаются сразу.
+func (s *WatchService) Close() {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.closed = true
+	for sub := range s.subscribers {
+		s.unsubscribe(sub)
+	}
+}
+
+// Publish рассылает сохранённые метрики арендатора запроса подходящим подписчикам.
+func (s *WatchService) Publish(ctx context.Context, metrics []commonmodels.Metric) {
+	tenant := models.TenantFromContext(ctx)
+	now := s.now().Round(0)
+
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	for sub := range s.subscribers {
+		if sub.tenant != tenant {
+			continue
+		}
+		for _, metric := range metrics {
+			if record := models.NewMetricRecord(metric, now); sub.match(record) {
+				sub.deliver(record)
+			}
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/MxTrap/metrics/internal/common/models"
+	servermodels "github.com/MxTrap/metrics/internal/server/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func receive(t *testing.T, updates <-chan servermodels.MetricUpdate) servermodels.MetricUpdate {
+	t.Helper()
+	select {
+	case update := <-updates:
+		return update
+	case <-time.After(time.Second):
+		require.FailNow(t, "no update received")
+		return servermodels.MetricUpdate{}
+	}
+}
+
+func TestWatchServiceFilters(t *testing.T) {
+	watch := NewWatchService(10)
+	now := time.Unix(1700000000, 0)
+	watch.now = func() time.Time { return now }
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	counters, err := watch.Subscribe(ctx, servermodels.MetricFilter{Type: models.Counter})
+	require.NoError(t, err)
+	heap, err := watch.Subscribe(ctx, servermodels.MetricFilter{NamePrefix: "Heap", NamePattern: "Alloc$"})
+	require.NoError(t, err)
+	scoped, err := watch.Subscribe(servermodels.ContextWithToken(ctx, servermodels.Token{
+		Permissions: []servermodels.Permission{servermodels.PermissionRead},
+		Prefix:      "app_",
+	}), servermodels.MetricFilter{})
+	require.NoError(t, err)
+	otherTenant, err := watch.Subscribe(servermodels.ContextWithTenant(ctx, "team-b"), servermodels.MetricFilter{})
+	require.NoError(t, err)
+
+	watch.Publish(ctx, []models.Metric{
+		{ID: "PollCount", MType: models.Counter, Delta: ptr(int64(1))},
+		{ID: "HeapAlloc", MType: models.Gauge, Value: ptr(2.0)},
+		{ID: "HeapInuse", MType: models.Gauge, Value: ptr(3.0)},
+		{ID: "app_requests", MType: models.Counter, Delta: ptr(int64(4))},
+	})
+
+	assert.Equal(t, "PollCount", receive(t, counters).ID)
+	assert.Equal(t, "app_requests", receive(t, counters).ID)
+	update := receive(t, heap)
+	assert.Equal(t, servermodels.MetricRecord{ID: "HeapAlloc", MType: models.Gauge, Value: ptr(2.0), UpdatedAt: now}, update.MetricRecord)
+	assert.Equal(t, "app_requests", receive(t, scoped).ID, "token prefix should limit the subscription")
+	assert.Empty(t, heap)
+	assert.Empty(t, scoped)
+	assert.Empty(t, otherTenant, "updates of other tenants should not be delivered")
+}
+
+func TestWatchServiceSlowSubscriber(t *testing.T) {
+	watch := NewWatchService(2)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sub, err := watch.Subscribe(ctx, servermodels.MetricFilter{})
+	require.NoError(t, err)
+
+	for i := range 5 {
+		watch.Publish(ctx, []models.Metric{{ID: "PollCount", MType: models.Counter, Delta: ptr(int64(i))}})
+	}
+	assert.Equal(t, int64(0), *receive(t, sub).Delta)
+	assert.Equal(t, int64(1), *receive(t, sub).Delta)
+
+	watch.Publish(ctx, []models.Metric{{ID: "PollCount", MType: models.Counter, Delta: ptr(int64(5))}})
+	update := receive(t, sub)
+	assert.Equal(t, int64(5), *update.Delta)
+	assert.Equal(t, uint64(3), update.Dropped, "dropped updates should be reported with the next one")
+}
+
+func TestWatchServiceUnsubscribe(t *testing.T) {
+	watch := NewWatchService(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	sub, err := watch.Subscribe(ctx, servermodels.MetricFilter{})
+	require.NoError(t, err)
+
+	cancel()
+	select {
+	case _, ok := <-sub:
+		assert.False(t, ok, "updates should be closed")
+	case <-time.After(time.Second):
+		require.FailNow(t, "subscription was not closed")
+	}
+	watch.Publish(context.Background(), []models.Metric{{ID: "Alloc", MType: models.Gauge, Value: ptr(1.0)}})
+	watch.mx.RLock()
+	assert.Empty(t, watch.subscribers)
+	watch.mx.RUnlock()
+}
+
+func TestWatchServiceClose(t *testing.T) {
+	watch := NewWatchService(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sub, err := watch.Subscribe(ctx, servermodels.MetricFilter{})
+	require.NoError(t, err)
+
+	watch.Close()
+	_, ok := <-sub
+	assert.False(t, ok, "updates should be closed")
+
+	late, err := watch.Subscribe(ctx, servermodels.MetricFilter{})
+	require.NoError(t, err)
+	_, ok = <-late
+	assert.False(t, ok, "subscriptions after Close should end immediately")
+
+	cancel()
+	watch.Publish(context.Background(), []models.Metric{{ID: "Alloc", MType: models.Gauge, Value: ptr(1.0)}})
+}
+
+func TestWatchServiceInvalidFilter(t *testing.T) {
+	watch := NewWatchService(1)
+	ctx := context.Background()
+
+	_, err := watch.Subscribe(ctx, servermodels.MetricFilter{Type: "histogram"})
+	assert.ErrorIs(t, err, servermodels.ErrUnknownMetricType)
+	_, err = watch.Subscribe(ctx, servermodels.MetricFilter{NamePattern: "("})
+	assert.ErrorIs(t, err, servermodels.ErrInvalidRequest)
+	_, err = watch.Subscribe(servermodels.ContextWithToken(ctx, servermodels.Token{}), servermodels.MetricFilter{})
+	assert.ErrorIs(t, err, servermodels.ErrForbidden)
+}
@@ -0,0 +1,235 @@
+// Панель мониторинга: сортировка и фильтрация таблицы метрик и автообновление страниц
+// по потоку обновлений /api/v2/watch.
+(() => {
+	'use strict';
+
+	const liveKey = 'metrics.dashboard.live';
+	// полная перезагрузка списка страхует от пропущенных обновлений
+	const resyncInterval = 60000;
+
+	const live = document.getElementById('live');
+	const status = document.getElementById('status');
+
+	const formatTimes = (root) => {
+		for (const el of root.querySelectorAll('time[datetime]')) {
+			const date = new Date(el.dateTime);
+			if (!Number.isNaN(date.getTime())) {
+				el.textContent = date.toLocaleString();
+			}
+		}
+	};
+
+	const setStatus = (text) => {
+		status.textContent = text;
+	};
+
+	// watch открывает поток обновлений, пока включено автообновление.
+	const watch = (url, onUpdate, onReconnect) => {
+		let source = null;
+		let failed = false;
+		const start = () => {
+			source = new EventSource(url);
+			source.addEventListener('open', () => {
+				setStatus('live');
+				if (failed) {
+					failed = false;
+					onReconnect();
+				}
+			});
+			source.addEventListener('error', () => {
+				failed = true;
+				setStatus('reconnecting…');
+			});
+			source.addEventListener('metric', (e) => onUpdate(JSON.parse(e.data)));
+		};
+		const stop = () => {
+			if (source) {
+				source.close();
+				source = null;
+			}
+			setStatus('paused');
+		};
+		const toggle = () => {
+			localStorage.setItem(liveKey, live.checked ? 'on' : 'off');
+			live.checked ? start() : stop();
+		};
+		live.checked = localStorage.getItem(liveKey) !== 'off';
+		live.addEventListener('change', toggle);
+		live.checked ? start() : stop();
+	};
+
+	const initTable = (table) => {
+		const tbody = table.tBodies[0];
+		const filter = document.getElementById('filter');
+		const type = document.getElementById('type');
+		const count = document.getElementById('count');
+		const rows = new Map();
+		let sortKey = 'name';
+		let descending = false;
+
+		for (const row of tbody.querySelectorAll('tr[data-key]')) {
+			rows.set(row.dataset.key, row);
+		}
+
+		const compare = (a, b) => {
+			let c;
+			switch (sortKey) {
+				case 'value':
+				case 'updated':
+					c = Number(a.dataset[sortKey]) - Number(b.dataset[sortKey]);
+					break;
+				default:
+					c = a.dataset[sortKey].localeCompare(b.dataset[sortKey]);
+			}
+			if (c === 0 && sortKey !== 'name') {
+				c = a.dataset.name.localeCompare(b.dataset.name);
+			}
+			return descending ? -c : c;
+		};
+
+		const render = () => {
+			const name = filter.value.trim().toLowerCase();
+			let shown = 0;
+			const sorted = [...rows.values()].sort(compare);
+			for (const row of sorted) {
+				row.hidden = (name !== '' && !row.dataset.name.toLowerCase().includes(name)) ||
+					(type.value !== '' && row.dataset.type !== type.value);
+				if (!row.hidden) {
+					shown++;
+				}
+			}
+			tbody.replaceChildren(...sorted);
+			count.textContent = shown === rows.size ? `${rows.size} metrics` : `${shown} of ${rows.size} metrics`;
+		};
+
+		const cell = (child) => {
+			const td = document.createElement('td');
+			td.append(child);
+			return td;
+		};
+
+		const newRow = (record) => {
+			const row = document.createElement('tr');
+			row.dataset.key = `${record.type}/${record.id}`;
+			row.dataset.name = record.id;
+			row.dataset.type = record.type;
+			const link = document.createElement('a');
+			link.href = `${table.dataset.detail}/${encodeURIComponent(record.type)}/${encodeURIComponent(record.id)}`;
+			link.textContent = record.id;
+			const badge = document.createElement('span');
+			badge.className = `type ${record.type}`;
+			badge.textContent = record.type;
+			const value = cell('');
+			value.className = 'num';
+			row.append(cell(link), cell(badge), value, cell(document.createElement('time')));
+			rows.set(row.dataset.key, row);
+			return row;
+		};
+
+		const setRecord = (row, value, updatedAt) => {
+			row.dataset.value = String(value);
+			row.cells[2].textContent = String(value);
+			const time = row.cells[3].firstChild;
+			const date = new Date(updatedAt);
+			row.dataset.updated = String(date.getTime());
+			time.dateTime = updatedAt;
+			time.textContent = date.toLocaleString();
+		};
+
+		const flash = (row) => {
+			row.classList.remove('updated');
+			// перезапуск анимации подсветки
+			void row.offsetWidth;
+			row.classList.add('updated');
+		};
+
+		const resync = async () => {
+			const records = [];
+			let cursor = '';
+			do {
+				const url = new URL(table.dataset.list, location.href);
+				url.searchParams.set('limit', '1000');
+				if (cursor) {
+					url.searchParams.set('cursor', cursor);
+				}
+				const resp = await fetch(url, {headers: {Accept: 'application/json'}});
+				if (!resp.ok) {
+					setStatus(`refresh failed: ${resp.status}`);
+					return;
+				}
+				const page = await resp.json();
+				records.push(...page.metrics);
+				cursor = page.next_cursor || '';
+			} while (cursor);
+
+			rows.clear();
+			for (const record of records) {
+				setRecord(newRow(record), record.type === 'counter' ? record.delta : record.value, record.updated_at);
+			}
+			render();
+		};
+
+		const update = (u) => {
+			const key = `${u.type}/${u.id}`;
+			let row = rows.get(key);
+			let value = u.type === 'counter' ? u.delta : u.value;
+			if (row) {
+				// обновления счётчиков содержат приращение
+				if (u.type === 'counter') {
+					value += Number(row.dataset.value);
+				}
+			} else {
+				row = newRow(u);
+			}
+			setRecord(row, value, u.updated_at);
+			render();
+			flash(row);
+			if (u.dropped) {
+				resync();
+			}
+		};
+
+		for (const button of table.tHead.querySelectorAll('button[data-sort]')) {
+			button.addEventListener('click', () => {
+				descending = sortKey === button.dataset.sort ? !descending : false;
+				sortKey = button.dataset.sort;
+				for (const th of table.tHead.querySelectorAll('th')) {
+					th.removeAttribute('aria-sort');
+				}
+				button.parentElement.setAttribute('aria-sort', descending ? 'descending' : 'ascending');
+				render();
+			});
+		}
+		filter.addEventListener('input', render);
+		type.addEventListener('change', render);
+
+		watch(table.dataset.watch, update, resync);
+		setInterval(() => {
+			if (live.checked) {
+				resync();
+			}
+		}, resyncInterval);
+		render();
+	};
+
+	// initMetric перезагружает страницу метрики после её обновлений, чтобы перерисовать историю.
+	const initMetric = (main) => {
+		let pending = null;
+		const reload = () => {
+			if (pending === null) {
+				pending = setTimeout(() => location.reload(), 1000);
+			}
+		};
+		watch(main.dataset.watch, reload, reload);
+	};
+
+	formatTimes(document);
+	const table = document.getElementById('metrics');
+	if (table) {
+		initTable(table);
+	}
+	const metric = document.getElementById('metric');
+	if (metric) {
+		initMetric(metric);
+	}
+})();
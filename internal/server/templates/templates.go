@@ -0,0 +1,27 @@
+// Package templates содержит шаблоны и статические файлы панели мониторинга,
+// встроенные в исполняемый файл сервера.
+package templates
+
+import (
+	"embed"
+	"html/template"
+	"io/fs"
+)
+
+//go:embed *.tmpl static
+var files embed.FS
+
+// HTML возвращает разобранные шаблоны страниц панели мониторинга.
+// Страницы отображаются по имени файла шаблона, например dashboard.tmpl.
+func HTML() *template.Template {
+	return template.Must(template.ParseFS(files, "*.tmpl"))
+}
+
+// Static возвращает статические файлы панели мониторинга: стили и скрипт.
+func Static() fs.FS {
+	static, err := fs.Sub(files, "static")
+	if err != nil {
+		panic(err)
+	}
+	return static
+}
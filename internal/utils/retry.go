@@ -1,6 +1,11 @@
 package utils
 
-import "time"
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+)
 
 func Retry(fn func() error, retryCount int) error {
 	var err error
@@ -16,3 +21,55 @@ func Retry(fn func() error, retryCount int) error {
 	}
 	return err
 }
+
+// Backoff задаёт повторы с экспоненциально растущей задержкой и случайным разбросом.
+// Разброс не даёт многим клиентам, потерявшим сервер одновременно, повторять запросы в один и тот же момент.
+type Backoff struct {
+	Attempts int           // Число попыток, включая первую.
+	Initial  time.Duration // Задержка перед первым повтором.
+	Max      time.Duration // Наибольшая задержка.
+	Jitter   float64       // Доля задержки от 0 до 1, на которую она случайно уменьшается.
+}
+
+// DefaultBackoff — повторы запросов агента к серверу.
+var DefaultBackoff = Backoff{Attempts: 3, Initial: time.Second, Max: 10 * time.Second, Jitter: 0.5}
+
+// Delay возвращает задержку перед повтором с номером attempt, начиная с нуля.
+func (b Backoff) Delay(attempt int) time.Duration {
+	d := b.Initial
+	for i := 0; i < attempt && d < b.Max; i++ {
+		d *= 2
+	}
+	if b.Max > 0 {
+		d = min(d, b.Max)
+	}
+	if b.Jitter > 0 && d > 0 {
+		d -= time.Duration(rand.Float64() * b.Jitter * float64(d))
+	}
+	return d
+}
+
+// RetryContext вызывает fn, пока она не завершится успешно или не будут исчерпаны попытки,
+// выжидая между попытками задержку b. Ожидание прерывается отменой контекста,
+// тогда возвращается последняя ошибка fn вместе с ошибкой контекста.
+func RetryContext(ctx context.Context, b Backoff, fn func() error) error {
+	attempts := max(b.Attempts, 1)
+	var err error
+	for i := 0; i < attempts; i++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+		if i == attempts-1 {
+			break
+		}
+
+		timer := time.NewTimer(b.Delay(i))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Join(err, ctx.Err())
+		case <-timer.C:
+		}
+	}
+	return err
+}
@@ -1,10 +1,12 @@
 package utils
 
 import (
+	"context"
 	"errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"testing"
+	"time"
 )
 
 func TestRetrySuccessFirstAttempt(t *testing.T) {
@@ -48,3 +50,56 @@ func TestRetryFailure(t *testing.T) {
 	assert.Equal(t, expectedError, err, "Retry should return the last error")
 	assert.Equal(t, 3, callCount, "fn should be called exactly retryCount times")
 }
+
+func TestBackoffDelay(t *testing.T) {
+	b := Backoff{Initial: 100 * time.Millisecond, Max: time.Second}
+	assert.Equal(t, 100*time.Millisecond, b.Delay(0))
+	assert.Equal(t, 400*time.Millisecond, b.Delay(2))
+	assert.Equal(t, time.Second, b.Delay(10), "delay should be capped")
+
+	b.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		d := b.Delay(1)
+		assert.GreaterOrEqual(t, d, 100*time.Millisecond, "jitter should take at most half of the delay")
+		assert.LessOrEqual(t, d, 200*time.Millisecond)
+	}
+}
+
+func TestRetryContext(t *testing.T) {
+	b := Backoff{Attempts: 3, Initial: time.Millisecond}
+	callCount := 0
+	err := RetryContext(context.Background(), b, func() error {
+		callCount++
+		if callCount < 2 {
+			return errors.New("temporary error")
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, callCount)
+
+	expectedError := errors.New("persistent error")
+	callCount = 0
+	err = RetryContext(context.Background(), b, func() error {
+		callCount++
+		return expectedError
+	})
+	assert.Equal(t, expectedError, err, "RetryContext should return the last error")
+	assert.Equal(t, 3, callCount, "fn should be called exactly Attempts times")
+}
+
+func TestRetryContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	expectedError := errors.New("server unavailable")
+	callCount := 0
+	start := time.Now()
+	err := RetryContext(ctx, Backoff{Attempts: 3, Initial: time.Hour}, func() error {
+		callCount++
+		cancel()
+		return expectedError
+	})
+	assert.Less(t, time.Since(start), time.Second, "cancellation should interrupt the delay")
+	assert.Equal(t, 1, callCount)
+	assert.ErrorIs(t, err, expectedError)
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS api_token CASCADE;
\ No newline at end of file
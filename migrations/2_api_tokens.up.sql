@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS api_token
+(
+    id            VARCHAR  PRIMARY KEY,
+    token_hash    VARCHAR  NOT NULL UNIQUE,
+    permissions   TEXT[]   NOT NULL DEFAULT '{}',
+    prefix        VARCHAR  NOT NULL DEFAULT ''
+);
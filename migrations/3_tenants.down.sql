@@ -0,0 +1,5 @@
+ALTER TABLE api_token DROP COLUMN IF EXISTS tenant;
+
+DROP INDEX IF EXISTS idx_metric_tenant_name;
+
+ALTER TABLE metric DROP COLUMN IF EXISTS tenant;
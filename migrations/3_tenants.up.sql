@@ -0,0 +1,5 @@
+ALTER TABLE metric ADD COLUMN tenant VARCHAR NOT NULL DEFAULT 'default';
+
+CREATE INDEX idx_metric_tenant_name ON metric (tenant, metric_name);
+
+ALTER TABLE api_token ADD COLUMN tenant VARCHAR NOT NULL DEFAULT '';
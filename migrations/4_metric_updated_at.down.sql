@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_metric_tenant_name;
+DROP INDEX IF EXISTS idx_metric_tenant_updated;
+
+ALTER TABLE metric DROP COLUMN IF EXISTS updated_at;
@@ -0,0 +1,6 @@
+ALTER TABLE metric ADD COLUMN updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+-- сопоставление "C" задаёт побайтовый порядок имён, как у хранилища в памяти,
+-- и позволяет индексу обслуживать и сортировку, и поиск по префиксу через LIKE
+CREATE INDEX idx_metric_tenant_updated ON metric (tenant, updated_at, metric_name COLLATE "C");
+CREATE INDEX idx_metric_tenant_name ON metric (tenant, metric_name COLLATE "C");